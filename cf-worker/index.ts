@@ -13,10 +13,17 @@ const MessageRequestSchema = z.object({
 
 const SandboxExecRequestSchema = z.object({
   cmd: z.string().min(1, "Command cannot be empty"),
+  env: z.record(z.string(), z.string()).optional(),
+  jobId: z.string().optional(),
+});
+
+const SandboxCancelRequestSchema = z.object({
+  jobId: z.string().min(1, "Job ID cannot be empty"),
 });
 
 interface DuetMessage {
-  role: "user" | "agent";
+  // a "summary" message stands in for everything before it, see compact()
+  role: "user" | "agent" | "summary";
   userId?: string;
   text: string;
   ts: number;
@@ -25,15 +32,36 @@ interface DuetMessage {
 interface DuetAgentState {
   messages: DuetMessage[];
 }
+// once a room's history passes SUMMARIZE_AFTER messages, all but the last
+// CONTEXT_TURNS are folded into a single summary message
+const SUMMARIZE_AFTER = 30;
+const CONTEXT_TURNS = 10;
+
 const REGEX_ROOM_ID_PATH = /^\/api\/rooms\/([^/]+)(\/.*)?$/;
 
+// DUET_WORKER_TOKEN is an optional secret (`wrangler secret put`); when set,
+// room requests must carry it as a bearer token
+type WorkerEnv = Env & { DUET_WORKER_TOKEN?: string };
+
+function authorized(request: Request, env: WorkerEnv): boolean {
+  if (!env.DUET_WORKER_TOKEN) {
+    return true;
+  }
+  return (
+    request.headers.get("Authorization") === `Bearer ${env.DUET_WORKER_TOKEN}`
+  );
+}
+
 // worker which routes requests to DuetAgent instances based on room ID
 export default {
-  async fetch(request: Request, env: Env): Promise<Response> {
+  async fetch(request: Request, env: WorkerEnv): Promise<Response> {
     const url = new URL(request.url);
     if (url.pathname === "/health") {
       return new Response("ok");
     }
+    if (!authorized(request, env)) {
+      return Response.json({ error: "unauthorized" }, { status: 401 });
+    }
     const match = url.pathname.match(REGEX_ROOM_ID_PATH);
     if (!match) {
       return new Response("not found - room ID is required", { status: 404 });
@@ -57,9 +85,14 @@ export default {
       );
     }
 
-    if (restPath !== "/message" && restPath !== "/sandbox/exec") {
+    if (
+      restPath !== "/message" &&
+      restPath !== "/sandbox/exec" &&
+      restPath !== "/sandbox/cancel" &&
+      restPath !== "/sandbox/reset"
+    ) {
       return new Response(
-        "not found - supported: POST /message, POST /sandbox/exec, DELETE /",
+        "not found - supported: POST /message, POST /sandbox/exec, POST /sandbox/cancel, POST /sandbox/reset, DELETE /",
         { status: 404 }
       );
     }
@@ -89,6 +122,9 @@ interface AIMessage {
 export class DuetAgent extends Agent<Env, DuetAgentState> {
   override initialState: DuetAgentState = { messages: [] };
 
+  // sandbox commands still running, by the job ID the server gave them
+  private jobs = new Map<string, AbortController>();
+
   override async onRequest(request: Request): Promise<Response> {
     const url = new URL(request.url);
     const roomId = request.headers.get("x-room-id") || "default";
@@ -102,6 +138,11 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
       return Response.json({ error: "method not allowed" }, { status: 405 });
     }
 
+    // reset has no body
+    if (url.pathname === "/sandbox/reset") {
+      return this.handleSandboxReset(roomId);
+    }
+
     const rawBody = await request.json();
 
     switch (url.pathname) {
@@ -111,9 +152,15 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
       case "/sandbox/exec":
         return this.handleSandboxExec(roomId, rawBody);
 
+      case "/sandbox/cancel":
+        return this.handleSandboxCancel(rawBody);
+
       default:
         return Response.json(
-          { error: "the available endpoints are /message and /sandbox/exec" },
+          {
+            error:
+              "the available endpoints are /message, /sandbox/exec, /sandbox/cancel and /sandbox/reset",
+          },
           { status: 404 }
         );
     }
@@ -126,6 +173,52 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
     return result.response?.trim() || "";
   }
 
+  private toAIMessages(messages: DuetMessage[]): AIMessage[] {
+    return messages.map<AIMessage>((m) => {
+      if (m.role === "summary") {
+        return {
+          role: "system",
+          content: `Summary of the conversation so far: ${m.text}`,
+        };
+      }
+      return {
+        role: m.role === "agent" ? "assistant" : "user",
+        content: m.text,
+      };
+    });
+  }
+
+  // compact keeps token usage bounded in long rooms by summarizing older
+  // exchanges; on failure the history is left alone and retried next time
+  private async compact(messages: DuetMessage[]): Promise<DuetMessage[]> {
+    if (messages.length <= SUMMARIZE_AFTER) {
+      return messages;
+    }
+    const older = messages.slice(0, -CONTEXT_TURNS);
+    const recent = messages.slice(-CONTEXT_TURNS);
+    try {
+      const summary = await this.runAI([
+        ...this.toAIMessages(older),
+        {
+          role: "user",
+          content:
+            "Summarize the conversation above for your own later reference in at most 150 words. " +
+            "Keep the decisions made, the commands and file names that mattered and any open questions. Don't add anything new.",
+        },
+      ]);
+      if (!summary) {
+        return messages;
+      }
+      const last = older.at(-1);
+      return [
+        { role: "summary", text: summary, ts: last ? last.ts : Date.now() },
+        ...recent,
+      ];
+    } catch {
+      return messages;
+    }
+  }
+
   private async handleMessage(
     roomId: string,
     rawBody: unknown
@@ -143,6 +236,9 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
     }
 
     const data = parseResult.data;
+    const history = await this.compact(this.state.messages);
+    const summary = history[0]?.role === "summary" ? history.slice(0, 1) : [];
+    const rest = history.slice(summary.length);
 
     const userMsg: DuetMessage = {
       role: "user",
@@ -160,10 +256,8 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
           "When asked to perform an action, briefly explain what you will do and wrap the exact shell command(s) in <run> tags. " +
           "Do NOT include predicted output in your response - just provide the explanation and command.",
       },
-      ...this.state.messages.slice(-10).map<AIMessage>((m) => ({
-        role: m.role === "agent" ? "assistant" : "user",
-        content: m.text,
-      })),
+      ...this.toAIMessages(summary),
+      ...this.toAIMessages(rest.slice(-CONTEXT_TURNS)),
       { role: "user", content: userMsg.text },
     ];
 
@@ -176,7 +270,7 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
       ts: Date.now(),
     };
 
-    const nextMessages = [...this.state.messages, userMsg, agentMsg].slice(-50);
+    const nextMessages = [...history, userMsg, agentMsg].slice(-50);
     this.setState({ messages: nextMessages });
 
     return Response.json({ reply: agentMsg.text, messages: nextMessages });
@@ -225,19 +319,79 @@ export class DuetAgent extends Agent<Env, DuetAgentState> {
 
     const data = parseResult.data;
     const sandboxName = `sandbox-${roomId}`;
+    const controller = new AbortController();
+    if (data.jobId) {
+      this.jobs.set(data.jobId, controller);
+    }
 
     try {
       const sandbox = getSandbox(this.env.Sandbox, sandboxName);
-      const result = await sandbox.exec(data.cmd);
+      const result = await sandbox.exec(data.cmd, {
+        env: data.env,
+        signal: controller.signal,
+      });
 
       return Response.json({ result, sandboxName });
     } catch (error) {
+      if (controller.signal.aborted) {
+        return Response.json({ error: "cancelled" }, { status: 409 });
+      }
       return Response.json(
         {
           error: `sandbox execution failed: ${error instanceof Error ? error.message : "unknown error"}`,
         },
         { status: 500 }
       );
+    } finally {
+      if (data.jobId) {
+        this.jobs.delete(data.jobId);
+      }
+    }
+  }
+
+  private handleSandboxCancel(rawBody: unknown): Response {
+    const parseResult = SandboxCancelRequestSchema.safeParse(rawBody);
+
+    if (!parseResult.success) {
+      return Response.json(
+        {
+          error: "invalid request",
+          details: z.flattenError(parseResult.error).fieldErrors,
+        },
+        { status: 400 }
+      );
+    }
+
+    const { jobId } = parseResult.data;
+    const controller = this.jobs.get(jobId);
+    if (!controller) {
+      return Response.json({ error: "no such job" }, { status: 404 });
+    }
+    controller.abort();
+    this.jobs.delete(jobId);
+    return Response.json({ cancelled: true, jobId });
+  }
+
+  // handleSandboxReset destroys a sandbox whose environment got into a bad
+  // state; the next exec starts a fresh one under the same name
+  private async handleSandboxReset(roomId: string): Promise<Response> {
+    const sandboxName = `sandbox-${roomId}`;
+    for (const controller of this.jobs.values()) {
+      controller.abort();
+    }
+    this.jobs.clear();
+
+    try {
+      const sandbox = getSandbox(this.env.Sandbox, sandboxName);
+      await sandbox.destroy();
+      return Response.json({ reset: true, sandboxName });
+    } catch (error) {
+      return Response.json(
+        {
+          error: `sandbox reset failed: ${error instanceof Error ? error.message : "unknown error"}`,
+        },
+        { status: 500 }
+      );
     }
   }
 
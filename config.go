@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyConfigFile sets flags from a YAML file whose keys are flag names,
+// e.g.
+//
+//	addr: ":2222"
+//	worker: https://duet.example.workers.dev
+//	room-idle-timeout: 1h
+//	allow-shell: [zsh, "docker compose exec app bash"]
+//	env:
+//	  EDITOR: vim
+//
+// Flags given on the command line win; a repeatable flag given there
+// replaces the file's list rather than adding to it.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	onCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { onCommandLine[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if onCommandLine[name] {
+			continue
+		}
+		for _, v := range configValues(values[key]) {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValues flattens a YAML value into flag values: a list sets a
+// repeatable flag once per item, and a map once per "key=value" pair.
+func configValues(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		var out []string
+		for _, item := range v {
+			out = append(out, configValues(item)...)
+		}
+		return out
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]string, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, fmt.Sprintf("%s=%v", k, v[k]))
+		}
+		return out
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
@@ -4,27 +4,77 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
+
+	"github.com/jaypopat/duet/internal/tracing"
 )
 
+// ErrUnauthorized means the worker turned down our auth token, or wanted
+// one and got none.
+var ErrUnauthorized = errors.New("AI worker rejected the auth token (check -worker-token)")
+
+// ErrJobNotFound means the sandbox command had already finished, or was
+// never started, when we tried to cancel it.
+var ErrJobNotFound = errors.New("sandbox command not running")
+
 // Client communicates with the Duet CF Worker AI endpoints
 type Client struct {
 	baseURL string
+	token   string // sent as a bearer token when set
 	http    *http.Client
 }
 
-// NewClient creates a new AI client
-func NewClient(baseURL string) *Client {
+// NewClient creates a new AI client. token is the worker's shared secret
+// (DUET_WORKER_TOKEN on the worker); leave it empty for an open worker.
+func NewClient(baseURL, token string) *Client {
 	return &Client{
 		baseURL: baseURL,
+		token:   token,
 		http: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+// newRequest builds a worker request with our auth attached.
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	// lets the worker's own spans join the caller's trace
+	tracing.Inject(ctx, req.Header)
+	return req, nil
+}
+
+// do sends req, turning an auth failure into ErrUnauthorized and a server
+// error into a *StatusError rather than a confusing decode error.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, ErrUnauthorized
+	}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
 // MessageRequest is the request body for /message endpoint
 type MessageRequest struct {
 	Text   string `json:"text"`
@@ -48,7 +98,14 @@ type MessageResponse struct {
 
 // ExecRequest is the request body for /sandbox/exec endpoint
 type ExecRequest struct {
-	Cmd string `json:"cmd"`
+	Cmd   string            `json:"cmd"`
+	Env   map[string]string `json:"env,omitempty"`
+	JobID string            `json:"jobId,omitempty"` // lets /sandbox/cancel find the command
+}
+
+// CancelRequest is the request body for /sandbox/cancel endpoint
+type CancelRequest struct {
+	JobID string `json:"jobId"`
 }
 
 // ExecResult contains stdout/stderr from sandbox execution
@@ -78,13 +135,15 @@ func (c *Client) SendMessage(ctx context.Context, roomID, text, userID string) (
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	req, err := c.newRequest(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
+	if errors.Is(err, ErrUnauthorized) || errors.As(err, new(*StatusError)) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -106,30 +165,36 @@ func (c *Client) SendMessage(ctx context.Context, roomID, text, userID string) (
 func (c *Client) CleanupRoom(ctx context.Context, roomID string) error {
 	url := fmt.Sprintf("%s/api/rooms/%s", c.baseURL, roomID)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	req, err := c.newRequest(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return fmt.Errorf("create cleanup request: %w", err)
 	}
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
+	if errors.Is(err, ErrUnauthorized) || errors.As(err, new(*StatusError)) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("cleanup request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return fmt.Errorf("cleanup failed with status %d", resp.StatusCode)
 	}
 
 	return nil
 }
 
-// ExecCommand executes a command in the room's sandbox
-func (c *Client) ExecCommand(ctx context.Context, roomID, cmd string) (*ExecResponse, error) {
+// ExecCommand executes a command in the room's sandbox with the given
+// environment variables set, as job jobID
+func (c *Client) ExecCommand(ctx context.Context, roomID, jobID, cmd string, env map[string]string) (*ExecResponse, error) {
 	url := fmt.Sprintf("%s/api/rooms/%s/sandbox/exec", c.baseURL, roomID)
 
 	body := ExecRequest{
-		Cmd: cmd,
+		Cmd:   cmd,
+		Env:   env,
+		JobID: jobID,
 	}
 
 	jsonBody, err := json.Marshal(body)
@@ -137,13 +202,15 @@ func (c *Client) ExecCommand(ctx context.Context, roomID, cmd string) (*ExecResp
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	req, err := c.newRequest(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
+	if errors.Is(err, ErrUnauthorized) || errors.As(err, new(*StatusError)) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("send request: %w", err)
 	}
@@ -160,3 +227,61 @@ func (c *Client) ExecCommand(ctx context.Context, roomID, cmd string) (*ExecResp
 
 	return &result, nil
 }
+
+// CancelCommand kills a sandbox command started with ExecCommand. Dropping
+// the exec request alone would leave it running in the sandbox.
+func (c *Client) CancelCommand(ctx context.Context, roomID, jobID string) error {
+	url := fmt.Sprintf("%s/api/rooms/%s/sandbox/cancel", c.baseURL, roomID)
+
+	jsonBody, err := json.Marshal(CancelRequest{JobID: jobID})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if errors.Is(err, ErrUnauthorized) || errors.As(err, new(*StatusError)) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrJobNotFound
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("cancel failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ResetSandbox destroys the room's sandbox, e.g. after a command broke its
+// environment. The worker creates a fresh one on the next ExecCommand.
+func (c *Client) ResetSandbox(ctx context.Context, roomID string) error {
+	url := fmt.Sprintf("%s/api/rooms/%s/sandbox/reset", c.baseURL, roomID)
+
+	req, err := c.newRequest(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if errors.Is(err, ErrUnauthorized) || errors.As(err, new(*StatusError)) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("reset failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOllamaHost is where a local Ollama listens out of the box.
+const DefaultOllamaHost = "http://localhost:11434"
+
+// Ollama answers with a model served by a local Ollama, for servers that
+// can't reach Cloudflare. It has no sandbox.
+type Ollama struct {
+	host    string
+	model   string
+	history HistoryFunc
+	http    *http.Client
+}
+
+// NewOllama creates a provider for model on host (DefaultOllamaHost when
+// empty), reading each room's conversation from history.
+func NewOllama(host, model string, history HistoryFunc) *Ollama {
+	if host == "" {
+		host = DefaultOllamaHost
+	}
+	return &Ollama{
+		host:    strings.TrimRight(host, "/"),
+		model:   model,
+		history: history,
+		http: &http.Client{
+			// local models on CPU are slow; the caller's context still applies
+			Timeout: 2 * time.Minute,
+		},
+	}
+}
+
+type ollamaChatRequest struct {
+	Model    string     `json:"model"`
+	Messages []chatTurn `json:"messages"`
+	Stream   bool       `json:"stream"`
+}
+
+type ollamaChatResponse struct {
+	Message chatTurn `json:"message"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// SendMessage asks the model about text, with the room's recent
+// conversation as context.
+func (o *Ollama) SendMessage(ctx context.Context, roomID, text, userID string) (*MessageResponse, error) {
+	var history []ChatMessage
+	if o.history != nil {
+		history = compact(ctx, o.history(roomID), o.chat)
+	}
+
+	reply, err := o.chat(ctx, promptFor(history, text))
+	if err != nil {
+		return nil, err
+	}
+	return exchange(history, text, userID, reply), nil
+}
+
+// chat sends one chat request to the model.
+func (o *Ollama) chat(ctx context.Context, turns []chatTurn) (string, error) {
+	jsonBody, err := json.Marshal(ollamaChatRequest{
+		Model:    o.model,
+		Messages: turns,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.host+"/api/chat", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+
+	var result ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("ollama error: %s", result.Error)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+	return strings.TrimSpace(result.Message.Content), nil
+}
+
+func (o *Ollama) ExecCommand(ctx context.Context, roomID, jobID, cmd string, env map[string]string) (*ExecResponse, error) {
+	return nil, ErrNoSandbox
+}
+
+func (o *Ollama) CancelCommand(ctx context.Context, roomID, jobID string) error {
+	return ErrNoSandbox
+}
+
+func (o *Ollama) ResetSandbox(ctx context.Context, roomID string) error {
+	return ErrNoSandbox
+}
+
+// CleanupRoom has nothing to do; the conversation lives with the room.
+func (o *Ollama) CleanupRoom(ctx context.Context, roomID string) error {
+	return nil
+}
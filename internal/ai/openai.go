@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOpenAIURL is the OpenAI API; any compatible server (vLLM, LM
+// Studio, OpenRouter, ...) works by pointing the base URL at it.
+const DefaultOpenAIURL = "https://api.openai.com/v1"
+
+// OpenAI answers with a model behind an OpenAI-compatible chat completions
+// API, for deployments without the worker. It has no sandbox.
+type OpenAI struct {
+	baseURL string
+	apiKey  string
+	model   string
+	history HistoryFunc
+	http    *http.Client
+}
+
+// NewOpenAI creates a provider for model at baseURL (DefaultOpenAIURL when
+// empty), reading each room's conversation from history. apiKey may be
+// empty for local servers that don't check it.
+func NewOpenAI(baseURL, apiKey, model string, history HistoryFunc) *OpenAI {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIURL
+	}
+	return &OpenAI{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		history: history,
+		http: &http.Client{
+			Timeout: 2 * time.Minute,
+		},
+	}
+}
+
+type openAIChatRequest struct {
+	Model    string     `json:"model"`
+	Messages []chatTurn `json:"messages"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message chatTurn `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// SendMessage asks the model about text, with the room's recent
+// conversation as context.
+func (o *OpenAI) SendMessage(ctx context.Context, roomID, text, userID string) (*MessageResponse, error) {
+	var history []ChatMessage
+	if o.history != nil {
+		history = compact(ctx, o.history(roomID), o.chat)
+	}
+
+	reply, err := o.chat(ctx, promptFor(history, text))
+	if err != nil {
+		return nil, err
+	}
+	return exchange(history, text, userID, reply), nil
+}
+
+// chat sends one chat completions request to the model.
+func (o *OpenAI) chat(ctx context.Context, turns []chatTurn) (string, error) {
+	jsonBody, err := json.Marshal(openAIChatRequest{
+		Model:    o.model,
+		Messages: turns,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+
+	var result openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("api error: %s", result.Error.Message)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("api returned status %d", resp.StatusCode)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("api returned no choices")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+func (o *OpenAI) ExecCommand(ctx context.Context, roomID, jobID, cmd string, env map[string]string) (*ExecResponse, error) {
+	return nil, ErrNoSandbox
+}
+
+func (o *OpenAI) CancelCommand(ctx context.Context, roomID, jobID string) error {
+	return ErrNoSandbox
+}
+
+func (o *OpenAI) ResetSandbox(ctx context.Context, roomID string) error {
+	return ErrNoSandbox
+}
+
+// CleanupRoom has nothing to do; the conversation lives with the room.
+func (o *OpenAI) CleanupRoom(ctx context.Context, roomID string) error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Provider answers the AI sidebar and runs sandbox commands for a room.
+// Client talks to the Cloudflare worker; the others call a model directly
+// and have no sandbox.
+type Provider interface {
+	SendMessage(ctx context.Context, roomID, text, userID string) (*MessageResponse, error)
+	// ExecCommand runs cmd as job jobID, which CancelCommand can abort.
+	ExecCommand(ctx context.Context, roomID, jobID, cmd string, env map[string]string) (*ExecResponse, error)
+	CancelCommand(ctx context.Context, roomID, jobID string) error
+	// ResetSandbox destroys the room's sandbox; the next command gets a
+	// fresh one.
+	ResetSandbox(ctx context.Context, roomID string) error
+	CleanupRoom(ctx context.Context, roomID string) error
+}
+
+var ErrNoSandbox = errors.New("the sandbox needs the Duet worker")
+
+// HasSandbox reports whether p can run sandbox commands.
+func HasSandbox(p Provider) bool {
+	if r, ok := p.(*Resilient); ok {
+		p = r.p
+	}
+	_, ok := p.(*Client)
+	return ok
+}
+
+// HistoryFunc returns a room's conversation so far, oldest first. Direct
+// providers keep no state of their own; the room holds it (and persists it).
+type HistoryFunc func(roomID string) []ChatMessage
+
+// Like the worker, direct providers send the model the last contextTurns
+// messages and hand back at most keepMessages.
+const (
+	contextTurns = 10
+	keepMessages = 50
+)
+
+const systemPrompt = "You are Duet, a concise pair-programming assistant helping the people in a shared terminal session."
+
+// chatTurn is one message in an OpenAI-style chat request.
+type chatTurn struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// promptFor builds the chat request for text following history. A summary
+// at the start of history is always sent, whatever falls out of the window.
+func promptFor(history []ChatMessage, text string) []chatTurn {
+	var summary []ChatMessage
+	if len(history) > 0 && history[0].Role == RoleSummary {
+		summary, history = history[:1], history[1:]
+	}
+	if len(history) > contextTurns {
+		history = history[len(history)-contextTurns:]
+	}
+	turns := make([]chatTurn, 0, len(history)+3)
+	turns = append(turns, chatTurn{Role: "system", Content: systemPrompt})
+	turns = append(turns, historyTurns(summary)...)
+	turns = append(turns, historyTurns(history)...)
+	return append(turns, chatTurn{Role: "user", Content: text})
+}
+
+// exchange records a question and its reply the way the worker does.
+func exchange(history []ChatMessage, text, userID, reply string) *MessageResponse {
+	now := time.Now().UnixMilli()
+	msgs := append(append([]ChatMessage(nil), history...),
+		ChatMessage{Role: "user", UserID: userID, Text: text, Ts: now},
+		ChatMessage{Role: "agent", Text: reply, Ts: now},
+	)
+	if len(msgs) > keepMessages {
+		msgs = msgs[len(msgs)-keepMessages:]
+	}
+	return &MessageResponse{Reply: reply, Messages: msgs}
+}
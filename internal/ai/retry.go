@@ -0,0 +1,239 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrPaused is returned without calling the provider while the circuit
+// breaker is open.
+var ErrPaused = errors.New("AI paused after repeated failures")
+
+// StatusError is a response status worth retrying: a server error or a
+// rate limit.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned status %d", e.Code)
+}
+
+// checkStatus picks out responses that mean "try again later", before the
+// caller tries to decode what is often an HTML error page.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return &StatusError{Code: resp.StatusCode}
+	}
+	return nil
+}
+
+// transient reports whether err is a blip that another attempt may get
+// past. API errors, auth failures and the like aren't.
+func transient(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// RetryConfig tunes Resilient.
+type RetryConfig struct {
+	Retries    int           // extra attempts after a transient failure
+	BaseDelay  time.Duration // wait before the first retry, doubling after
+	MaxDelay   time.Duration // cap on the wait between retries
+	BreakAfter int           // consecutive failed calls that open the breaker; 0 never opens it
+	Cooldown   time.Duration // how long the breaker stays open before a trial call
+}
+
+// DefaultRetryConfig rides out a worker restart without leaving people
+// staring at a spinner for long.
+var DefaultRetryConfig = RetryConfig{
+	Retries:    2,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+	BreakAfter: 5,
+	Cooldown:   30 * time.Second,
+}
+
+// BreakerState is where Resilient's circuit breaker stands.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // calls go through
+	BreakerOpen                         // calls fail fast with ErrPaused
+	BreakerHalfOpen                     // one trial call is deciding whether to close
+)
+
+// Health is a snapshot of a provider's breaker, for the sidebar.
+type Health struct {
+	State    BreakerState
+	Failures int       // consecutive failed calls
+	RetryAt  time.Time // when an open breaker lets a trial call through
+}
+
+// Resilient wraps a provider with retries for transient failures and a
+// circuit breaker that pauses AI features once the provider keeps failing,
+// so every keypress doesn't wait out a dead worker's timeouts.
+type Resilient struct {
+	p   Provider
+	cfg RetryConfig
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool // a half-open trial call is in flight
+}
+
+// WithRetry wraps p. Sandbox commands aren't retried, since one that timed
+// out may still have run, but their failures count toward the breaker.
+func WithRetry(p Provider, cfg RetryConfig) *Resilient {
+	return &Resilient{p: p, cfg: cfg}
+}
+
+func (r *Resilient) SendMessage(ctx context.Context, roomID, text, userID string) (*MessageResponse, error) {
+	var resp *MessageResponse
+	err := r.call(ctx, r.cfg.Retries, func() error {
+		var err error
+		resp, err = r.p.SendMessage(ctx, roomID, text, userID)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Resilient) ExecCommand(ctx context.Context, roomID, jobID, cmd string, env map[string]string) (*ExecResponse, error) {
+	var resp *ExecResponse
+	err := r.call(ctx, 0, func() error {
+		var err error
+		resp, err = r.p.ExecCommand(ctx, roomID, jobID, cmd, env)
+		return err
+	})
+	return resp, err
+}
+
+// CancelCommand skips the breaker: a runaway job should be stoppable even
+// while other calls are being turned away.
+func (r *Resilient) CancelCommand(ctx context.Context, roomID, jobID string) error {
+	return r.p.CancelCommand(ctx, roomID, jobID)
+}
+
+func (r *Resilient) ResetSandbox(ctx context.Context, roomID string) error {
+	return r.call(ctx, r.cfg.Retries, func() error {
+		return r.p.ResetSandbox(ctx, roomID)
+	})
+}
+
+func (r *Resilient) CleanupRoom(ctx context.Context, roomID string) error {
+	return r.call(ctx, r.cfg.Retries, func() error {
+		return r.p.CleanupRoom(ctx, roomID)
+	})
+}
+
+// Health reports the breaker's state.
+func (r *Resilient) Health() Health {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := Health{Failures: r.failures, RetryAt: r.openUntil}
+	switch {
+	case r.probing:
+		h.State = BreakerHalfOpen
+	case time.Now().Before(r.openUntil):
+		h.State = BreakerOpen
+	}
+	return h
+}
+
+// HealthOf reports p's breaker, if it has one.
+func HealthOf(p Provider) (Health, bool) {
+	r, ok := p.(*Resilient)
+	if !ok {
+		return Health{}, false
+	}
+	return r.Health(), true
+}
+
+func (r *Resilient) call(ctx context.Context, retries int, fn func() error) error {
+	if err := r.admit(); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || !transient(err) || attempt >= retries || ctx.Err() != nil {
+			break
+		}
+		log.FromContext(ctx).Warn("AI provider call failed, retrying", "attempt", attempt+1, "error", err)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1), attribute.String("error", err.Error())))
+		select {
+		case <-time.After(r.backoff(attempt)):
+		case <-ctx.Done():
+		}
+	}
+	if r.record(err) {
+		log.FromContext(ctx).Error("AI paused after repeated failures", "cooldown", r.cfg.Cooldown, "error", err)
+	}
+	return err
+}
+
+// admit lets a call through unless the breaker is open. Once the cooldown
+// is up, a single trial call is let through to test the water.
+func (r *Resilient) admit() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.openUntil.IsZero() {
+		return nil
+	}
+	if wait := time.Until(r.openUntil); wait > 0 {
+		return fmt.Errorf("%w, trying again in %s", ErrPaused, max(wait.Round(time.Second), time.Second))
+	}
+	if r.probing {
+		return fmt.Errorf("%w, checking whether it's back", ErrPaused)
+	}
+	r.probing = true
+	return nil
+}
+
+// record counts transient failures toward opening the breaker. Anything
+// else, success or a non-transient error, shows the provider is up. It
+// reports whether err opened the breaker.
+func (r *Resilient) record(err error) (opened bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probing = false
+	if err == nil || !transient(err) {
+		r.failures = 0
+		r.openUntil = time.Time{}
+		return false
+	}
+	r.failures++
+	if r.cfg.BreakAfter > 0 && r.failures >= r.cfg.BreakAfter {
+		r.openUntil = time.Now().Add(r.cfg.Cooldown)
+		return true
+	}
+	return false
+}
+
+// backoff is the wait before retry attempt+1: exponential, capped, with
+// jitter so clients that failed together don't retry together.
+func (r *Resilient) backoff(attempt int) time.Duration {
+	d := r.cfg.BaseDelay << attempt
+	if r.cfg.MaxDelay > 0 && (d > r.cfg.MaxDelay || d <= 0) {
+		d = r.cfg.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
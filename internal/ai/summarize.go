@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"strings"
+)
+
+// RoleSummary marks a message that stands in for the older part of a
+// conversation, so long rooms don't keep re-sending their whole history.
+const RoleSummary = "summary"
+
+// summarizeAfter is how long a room's history may grow before everything
+// but the last contextTurns messages is folded into one summary message.
+const summarizeAfter = 30
+
+const summarizePrompt = "Summarize the conversation above for your own later reference in at most 150 words. Keep the decisions made, the commands and file names that mattered and any open questions. Don't add anything new."
+
+// chatFunc sends one chat request to a model and returns its reply.
+type chatFunc func(ctx context.Context, turns []chatTurn) (string, error)
+
+// compact folds the older part of history into a summary once it's past
+// summarizeAfter messages. The summary becomes the first message, so it's
+// stored with the room like any other. If the model can't summarize right
+// now, history is returned as is and the next call tries again.
+func compact(ctx context.Context, history []ChatMessage, chat chatFunc) []ChatMessage {
+	if len(history) <= summarizeAfter {
+		return history
+	}
+	cut := len(history) - contextTurns
+	older, recent := history[:cut], history[cut:]
+
+	turns := make([]chatTurn, 0, len(older)+2)
+	turns = append(turns, chatTurn{Role: "system", Content: systemPrompt})
+	turns = append(turns, historyTurns(older)...)
+	turns = append(turns, chatTurn{Role: "user", Content: summarizePrompt})
+	summary, err := chat(ctx, turns)
+	if err != nil || strings.TrimSpace(summary) == "" {
+		return history
+	}
+
+	compacted := make([]ChatMessage, 0, len(recent)+1)
+	compacted = append(compacted, ChatMessage{
+		Role: RoleSummary,
+		Text: strings.TrimSpace(summary),
+		Ts:   older[len(older)-1].Ts,
+	})
+	return append(compacted, recent...)
+}
+
+// historyTurns converts stored messages into chat turns. A summary goes in
+// as a system turn so the model treats it as background, not as something
+// someone said.
+func historyTurns(history []ChatMessage) []chatTurn {
+	turns := make([]chatTurn, 0, len(history))
+	for _, m := range history {
+		switch m.Role {
+		case RoleSummary:
+			turns = append(turns, chatTurn{Role: "system", Content: "Summary of the conversation so far: " + m.Text})
+		case "agent":
+			turns = append(turns, chatTurn{Role: "assistant", Content: m.Text})
+		default:
+			turns = append(turns, chatTurn{Role: "user", Content: m.Text})
+		}
+	}
+	return turns
+}
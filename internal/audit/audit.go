@@ -0,0 +1,133 @@
+// Package audit keeps an append-only record of security-relevant events:
+// who connected with which key, what auth decided, who joined or was
+// removed from which room, and what they ran or asked the AI.
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is one line of the audit file.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"event"` // e.g. "auth", "connect", "room_join", "sandbox_command"
+	Session     string    `json:"session,omitempty"`
+	User        string    `json:"user,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Remote      string    `json:"remote,omitempty"`
+	RoomID      string    `json:"room_id,omitempty"`
+	Target      string    `json:"target,omitempty"` // who an action was done to, e.g. the kicked user
+	Result      string    `json:"result,omitempty"` // e.g. "accepted" or "rejected"
+	Detail      string    `json:"detail,omitempty"`
+}
+
+// Redact says which fields are replaced with a hash before writing, so the
+// file shows that two events match without what they contained.
+type Redact struct {
+	Prompts  bool // Detail of "ai_prompt" events
+	Commands bool // Detail of "sandbox_command" events
+	Remotes  bool // client addresses
+}
+
+// ParseRedact reads a comma separated list of "prompts", "commands" and
+// "remotes", or "all".
+func ParseRedact(s string) (Redact, error) {
+	var r Redact
+	for _, f := range strings.Split(s, ",") {
+		switch strings.TrimSpace(f) {
+		case "":
+		case "prompts":
+			r.Prompts = true
+		case "commands":
+			r.Commands = true
+		case "remotes":
+			r.Remotes = true
+		case "all":
+			r = Redact{Prompts: true, Commands: true, Remotes: true}
+		default:
+			return Redact{}, fmt.Errorf("unknown audit redaction %q (want prompts, commands, remotes or all)", f)
+		}
+	}
+	return r, nil
+}
+
+// Log appends events to a file as JSON lines. A nil *Log records nothing,
+// so callers needn't check whether auditing is on.
+type Log struct {
+	redact Redact
+	key    []byte // HMAC key for redacted values
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// Open appends to the audit file at path, creating it readable only by
+// the server's user. Redacted values are hashed with key, so they can't be
+// recovered by hashing guesses, such as every IPv4 address; without a key
+// a random one is used and hashes only match within one run.
+func Open(path string, redact Redact, key []byte) (*Log, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("audit redaction key: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &Log{redact: redact, key: key, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record writes e, timestamped now if it isn't already. Write errors are
+// returned but an event is never retried, so a full disk doesn't wedge
+// sessions.
+func (l *Log) Record(e Event) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if l.redact.Remotes && e.Remote != "" {
+		e.Remote = l.hash(e.Remote)
+	}
+	if (l.redact.Prompts && e.Type == "ai_prompt") || (l.redact.Commands && e.Type == "sandbox_command") {
+		e.Detail = l.hash(e.Detail)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(e); err != nil {
+		return fmt.Errorf("write audit event: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// hash stands in for a redacted value: equal values hash the same, so
+// repeats can still be spotted.
+func (l *Log) hash(s string) string {
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(s))
+	return "redacted:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
@@ -0,0 +1,161 @@
+// Package container runs a room's shared shells inside a Docker or Podman
+// container instead of directly on the host.
+package container
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Config is the server-wide container setup. An empty Image disables
+// containers and shells run on the host as before.
+type Config struct {
+	Runtime string   // "docker" or "podman"; empty means docker
+	Image   string   // image every room's container runs
+	Mounts  []string // extra -v specs, e.g. "/srv/cache:/cache:ro"
+	Memory  string   // --memory limit, e.g. "512m"
+	CPUs    string   // --cpus limit, e.g. "1.5"
+	Network string   // --network, e.g. "none"; empty uses the runtime default
+}
+
+func (c Config) Enabled() bool { return c.Image != "" }
+
+func (c Config) runtime() string {
+	if c.Runtime == "" {
+		return "docker"
+	}
+	return c.Runtime
+}
+
+// Validate checks the runtime is installed.
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	switch c.runtime() {
+	case "docker", "podman":
+	default:
+		return fmt.Errorf("unsupported container runtime %q", c.Runtime)
+	}
+	if _, err := exec.LookPath(c.runtime()); err != nil {
+		return fmt.Errorf("container runtime %q not found", c.runtime())
+	}
+	return nil
+}
+
+// Container is one room's long-running container. Every tab is a separate
+// exec into it, so they share processes and files the way shells on one
+// host would. The room's workspace is mounted at the same path inside, so
+// start directories mean the same thing in and out of the container.
+type Container struct {
+	cfg       Config
+	name      string
+	workspace string
+
+	mu      sync.Mutex
+	started bool
+}
+
+// New describes the container for a room; nothing runs until Start.
+func New(cfg Config, roomID, workspace string) *Container {
+	return &Container{cfg: cfg, name: "duet-" + roomID, workspace: workspace}
+}
+
+func (c *Container) Name() string { return c.name }
+
+// Start runs the container if it isn't already. A container left over from
+// before a server restart is reused while it's still running.
+func (c *Container) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return nil
+	}
+
+	state, err := c.output("container", "inspect", "-f", "{{.State.Running}}", c.name)
+	switch {
+	case err == nil && state == "true":
+		c.started = true
+		return nil
+	case err == nil:
+		// exists but stopped; start over rather than revive stale state
+		if _, err := c.output("rm", "-f", c.name); err != nil {
+			return err
+		}
+	}
+
+	args := []string{"run", "-d", "--init", "--name", c.name,
+		"--label", "duet.room=" + strings.TrimPrefix(c.name, "duet-")}
+	if c.workspace != "" {
+		args = append(args, "-v", c.workspace+":"+c.workspace, "-w", c.workspace)
+	}
+	for _, m := range c.cfg.Mounts {
+		args = append(args, "-v", m)
+	}
+	if c.cfg.Memory != "" {
+		args = append(args, "--memory", c.cfg.Memory)
+	}
+	if c.cfg.CPUs != "" {
+		args = append(args, "--cpus", c.cfg.CPUs)
+	}
+	if c.cfg.Network != "" {
+		args = append(args, "--network", c.cfg.Network)
+	}
+	// keep the container up between shells; tabs come and go via exec
+	args = append(args, c.cfg.Image, "sleep", "infinity")
+
+	if _, err := c.output(args...); err != nil {
+		return fmt.Errorf("start container: %w", err)
+	}
+	c.started = true
+	return nil
+}
+
+// ExecArgs returns the host command that opens argv inside the container
+// with a TTY, in dir and with env (KEY=VALUE) set. Only the names go on the
+// command line, where any local user can read them; the runtime takes the
+// values from its own environment, so the command must be run with env.
+func (c *Container) ExecArgs(argv []string, dir string, env []string) []string {
+	args := []string{c.cfg.runtime(), "exec", "-it", "-e", "TERM=xterm-256color"}
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		args = append(args, "-e", key)
+	}
+	if dir != "" {
+		args = append(args, "-w", dir)
+	}
+	args = append(args, c.name)
+	if len(argv) == 0 {
+		argv = []string{"/bin/sh"}
+	}
+	return append(args, argv...)
+}
+
+// Remove stops and deletes the container. It's safe to call when the
+// container was never started.
+func (c *Container) Remove() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = false
+	_, err := c.output("rm", "-f", c.name)
+	return err
+}
+
+func (c *Container) output(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.cfg.runtime(), args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", err
+		}
+		return "", errors.New(msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
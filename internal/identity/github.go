@@ -0,0 +1,127 @@
+// Package identity checks that people are who their SSH username says.
+package identity
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// DefaultGitHubURL serves a user's public keys at /<user>.keys.
+const DefaultGitHubURL = "https://github.com"
+
+// githubUserPattern matches valid GitHub handles, which also keeps
+// anything odd out of the URL.
+var githubUserPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+
+// GitHubKeys verifies SSH keys against the public keys a GitHub user has
+// published, so the username someone connects as has to be their handle.
+type GitHubKeys struct {
+	baseURL string
+	ttl     time.Duration
+	http    *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedKeys // by lower-cased username
+}
+
+type cachedKeys struct {
+	keys    [][]byte // wire-format public keys
+	fetched time.Time
+}
+
+// NewGitHubKeys creates a verifier that remembers each user's keys for ttl.
+func NewGitHubKeys(ttl time.Duration) *GitHubKeys {
+	return &GitHubKeys{
+		baseURL: DefaultGitHubURL,
+		ttl:     ttl,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		cache:   make(map[string]cachedKeys),
+	}
+}
+
+// Verify reports whether key is one of username's GitHub keys. A user
+// without keys, or that doesn't exist, never verifies.
+func (g *GitHubKeys) Verify(ctx context.Context, username string, key gossh.PublicKey) (bool, error) {
+	if !githubUserPattern.MatchString(username) {
+		return false, nil
+	}
+	keys, err := g.keys(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	want := key.Marshal()
+	for _, k := range keys {
+		if bytes.Equal(k, want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// keys returns username's keys, from the cache while it's fresh. Failed
+// fetches aren't cached, so GitHub being down doesn't lock people out for
+// a whole ttl.
+func (g *GitHubKeys) keys(ctx context.Context, username string) ([][]byte, error) {
+	name := strings.ToLower(username)
+	g.mu.Lock()
+	c, ok := g.cache[name]
+	g.mu.Unlock()
+	if ok && time.Since(c.fetched) < g.ttl {
+		return c.keys, nil
+	}
+
+	keys, err := g.fetch(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	g.cache[name] = cachedKeys{keys: keys, fetched: time.Now()}
+	g.mu.Unlock()
+	return keys, nil
+}
+
+func (g *GitHubKeys) fetch(ctx context.Context, username string) ([][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/"+username+".keys", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch keys for %s: %w", username, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch keys for %s: status %d", username, resp.StatusCode)
+	}
+
+	var keys [][]byte
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, _, _, _, err := gossh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			continue // a key type we don't know; the others may still match
+		}
+		keys = append(keys, key.Marshal())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read keys for %s: %w", username, err)
+	}
+	return keys, nil
+}
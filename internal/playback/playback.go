@@ -0,0 +1,170 @@
+// Package playback replays asciicast (v2) recordings into a virtual
+// terminal so they can be watched inside the TUI.
+package playback
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hinshun/vt10x"
+	"github.com/jaypopat/duet/internal/terminal"
+)
+
+// ErrUnsupportedCast is returned for anything other than asciicast v2.
+var ErrUnsupportedCast = errors.New("unsupported asciicast version")
+
+// Event is a chunk of terminal output at an offset from the start.
+type Event struct {
+	At   time.Duration
+	Data string
+}
+
+// Cast is a loaded recording.
+type Cast struct {
+	Width    int
+	Height   int
+	Title    string
+	Events   []Event
+	Duration time.Duration
+}
+
+type castHeader struct {
+	Version int    `json:"version"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Title   string `json:"title"`
+}
+
+// Load reads an asciicast v2 file. Only output ("o") events are kept.
+func Load(path string) (*Cast, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: empty recording", path)
+	}
+	var hdr castHeader
+	if err := json.Unmarshal(sc.Bytes(), &hdr); err != nil {
+		return nil, fmt.Errorf("%s: bad header: %w", path, err)
+	}
+	if hdr.Version != 2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedCast, hdr.Version)
+	}
+
+	c := &Cast{Width: hdr.Width, Height: hdr.Height, Title: hdr.Title}
+	if c.Width < 1 {
+		c.Width = 80
+	}
+	if c.Height < 1 {
+		c.Height = 24
+	}
+
+	line := 1
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var raw []json.RawMessage
+		if err := json.Unmarshal(sc.Bytes(), &raw); err != nil || len(raw) != 3 {
+			return nil, fmt.Errorf("%s:%d: bad event", path, line)
+		}
+		var secs float64
+		var kind, data string
+		if json.Unmarshal(raw[0], &secs) != nil || json.Unmarshal(raw[1], &kind) != nil || json.Unmarshal(raw[2], &data) != nil {
+			return nil, fmt.Errorf("%s:%d: bad event", path, line)
+		}
+		if kind != "o" {
+			continue
+		}
+		c.Events = append(c.Events, Event{At: time.Duration(secs * float64(time.Second)), Data: data})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if n := len(c.Events); n > 0 {
+		c.Duration = c.Events[n-1].At
+	}
+	return c, nil
+}
+
+// Player steps through a Cast, feeding events into its own vt10x screen.
+// It is driven by the caller (e.g. a tea.Tick) rather than a goroutine.
+type Player struct {
+	cast   *Cast
+	vt     vt10x.Terminal
+	screen *terminal.CellWriter
+	pos    time.Duration
+	next   int // index of the first event not yet applied
+	paused bool
+	speed  float64
+}
+
+func NewPlayer(c *Cast) *Player {
+	p := &Player{cast: c, speed: 1}
+	p.reset()
+	return p
+}
+
+func (p *Player) reset() {
+	p.vt = vt10x.New(vt10x.WithSize(p.cast.Width, p.cast.Height))
+	p.screen = terminal.NewCellWriter(p.vt)
+	p.pos = 0
+	p.next = 0
+}
+
+// Advance moves playback forward by d of wall-clock time, scaled by the
+// playback speed. It does nothing while paused.
+func (p *Player) Advance(d time.Duration) {
+	if p.paused || p.Done() {
+		return
+	}
+	p.seekForward(p.pos + time.Duration(float64(d)*p.speed))
+}
+
+// Seek jumps to an absolute offset. Going backwards replays from the start,
+// since a terminal screen can't be unwound.
+func (p *Player) Seek(to time.Duration) {
+	to = min(max(to, 0), p.cast.Duration)
+	if to < p.pos {
+		p.reset()
+	}
+	p.seekForward(to)
+}
+
+func (p *Player) seekForward(to time.Duration) {
+	for p.next < len(p.cast.Events) && p.cast.Events[p.next].At <= to {
+		p.screen.Write([]byte(p.cast.Events[p.next].Data))
+		p.next++
+	}
+	p.pos = min(to, p.cast.Duration)
+}
+
+func (p *Player) TogglePause() { p.paused = !p.paused }
+func (p *Player) Paused() bool { return p.paused }
+
+// SetSpeed sets the playback multiplier, clamped to 0.25x-8x.
+func (p *Player) SetSpeed(s float64) { p.speed = min(max(s, 0.25), 8) }
+func (p *Player) Speed() float64     { return p.speed }
+
+func (p *Player) Position() time.Duration { return p.pos }
+func (p *Player) Cast() *Cast             { return p.cast }
+
+// Done reports whether every event has been played.
+func (p *Player) Done() bool { return p.next >= len(p.cast.Events) }
+
+// Render draws the current screen.
+func (p *Player) Render() string { return terminal.RenderVT(p.vt) }
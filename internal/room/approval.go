@@ -0,0 +1,150 @@
+package room
+
+import "time"
+
+// SetRequireApproval toggles knock-to-enter. Turning it off admits nobody
+// automatically; pending clients still need an explicit decision.
+func (r *Room) SetRequireApproval(on bool) {
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.RequireApproval = on
+}
+
+// SetPublic lists the room on the launch screen of everyone on the server,
+// or takes it off. Rooms start unlisted: only people given the code, or
+// a link, can find them.
+func (r *Room) SetPublic(on bool) {
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Public = on
+}
+
+// NeedsApproval reports whether joiners must knock first.
+func (r *Room) NeedsApproval() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.RequireApproval
+}
+
+// Knock places client in the pending list and sends a "knock" event to the
+// host. The client is told the outcome via "approve" or "deny" on its own
+// Events channel.
+func (r *Room) Knock(client *Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkBansLocked(client); err != nil {
+		return err
+	}
+	if r.fullLocked() {
+		return ErrRoomFull
+	}
+
+	client.JoinedAt = time.Time{} // stamped on admission, not on knock
+	r.Pending = append(r.Pending, client)
+
+	for _, c := range r.Connections {
+		if c.IsHost && c.Events != nil {
+			select {
+			case c.Events <- RoomEvent{Type: "knock", Username: client.Username, Data: client.ID}:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// Approve admits the oldest pending client with the given username.
+func (r *Room) Approve(username string) error {
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.pendingIndexLocked(username)
+	if i < 0 {
+		return ErrClientNotFound
+	}
+	client := r.Pending[i]
+
+	if err := r.addClientLocked(client); err != nil {
+		return err
+	}
+	r.Pending = append(r.Pending[:i], r.Pending[i+1:]...)
+
+	if client.Events != nil {
+		select {
+		case client.Events <- RoomEvent{Type: "approve", Username: client.Username}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Deny turns away the oldest pending client with the given username.
+func (r *Room) Deny(username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.pendingIndexLocked(username)
+	if i < 0 {
+		return ErrClientNotFound
+	}
+	r.denyLocked(i, "denied by host")
+	return nil
+}
+
+// CancelKnock withdraws a pending request, e.g. when the knocker gives up.
+func (r *Room) CancelKnock(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.Pending {
+		if c.ID == clientID {
+			r.Pending = append(r.Pending[:i], r.Pending[i+1:]...)
+			if c.Events != nil {
+				close(c.Events)
+			}
+			return
+		}
+	}
+}
+
+// PendingUsernames lists knocking users in arrival order.
+func (r *Room) PendingUsernames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.Pending))
+	for i, c := range r.Pending {
+		names[i] = c.Username
+	}
+	return names
+}
+
+// rejectPending denies everyone still waiting, e.g. when the room goes away.
+func (r *Room) rejectPending(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.Pending) > 0 {
+		r.denyLocked(0, reason)
+	}
+}
+
+func (r *Room) denyLocked(i int, reason string) {
+	client := r.Pending[i]
+	r.Pending = append(r.Pending[:i], r.Pending[i+1:]...)
+	if client.Events != nil {
+		sendFinal(client.Events, RoomEvent{Type: "deny", Username: client.Username, Data: reason})
+		close(client.Events)
+	}
+}
+
+func (r *Room) pendingIndexLocked(username string) int {
+	for i, c := range r.Pending {
+		if c.Username == username {
+			return i
+		}
+	}
+	return -1
+}
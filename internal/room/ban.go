@@ -0,0 +1,122 @@
+package room
+
+import "time"
+
+// ban records someone the host turned away for good. Fingerprint is the SSH
+// public key fingerprint when the user authenticated with a key. Any one of
+// the username, fingerprint or client ID matching keeps them out, so a new
+// key under the same name doesn't get them back in.
+type ban struct {
+	username    string
+	fingerprint string
+	clientID    string
+	at          time.Time
+}
+
+func (b ban) matches(c *Client) bool {
+	switch {
+	case b.clientID != "" && b.clientID == c.ID:
+		return true
+	case b.fingerprint != "" && b.fingerprint == c.Fingerprint:
+		return true
+	}
+	return SameUser(b.username, c.Username)
+}
+
+// Ban blocks username (and, when known, their key fingerprint and client ID)
+// from joining or knocking, kicks them if they are connected and revokes
+// the room's browser links.
+func (r *Room) Ban(username string) error {
+	r.mu.Lock()
+	if username == r.Host {
+		r.mu.Unlock()
+		return ErrCannotKickHost
+	}
+
+	banned := false
+	for _, c := range append(r.Connections, r.Pending...) {
+		if c.Username == username {
+			r.bans = append(r.bans, ban{username: username, fingerprint: c.Fingerprint, clientID: c.ID, at: time.Now()})
+			banned = true
+		}
+	}
+	if !banned {
+		// not here right now; ban the name so they can't come back
+		r.bans = append(r.bans, ban{username: username, at: time.Now()})
+	}
+	for i := 0; i < len(r.Pending); {
+		if r.Pending[i].Username == username {
+			r.denyLocked(i, "banned by host")
+			continue
+		}
+		i++
+	}
+	// a browser link can't tell who's holding it, so the banned user's
+	// copies go with everyone's; the host can hand out fresh ones
+	r.revokeWebTokensLocked()
+	r.logEvent(username + " was banned")
+	r.mu.Unlock()
+
+	if err := r.kick(username, "banned"); err != nil && err != ErrClientNotFound {
+		return err
+	}
+	return nil
+}
+
+// Unban lifts every ban recorded for username.
+func (r *Room) Unban(username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.bans[:0]
+	for _, b := range r.bans {
+		if b.username != username {
+			kept = append(kept, b)
+		}
+	}
+	if len(kept) == len(r.bans) {
+		return ErrNotBanned
+	}
+	r.bans = kept
+	return nil
+}
+
+// BannedUsernames lists banned users, one entry per name.
+func (r *Room) BannedUsernames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool, len(r.bans))
+	var names []string
+	for _, b := range r.bans {
+		if !seen[b.username] {
+			seen[b.username] = true
+			names = append(names, b.username)
+		}
+	}
+	return names
+}
+
+// checkBansLocked returns ErrBanned if c matches a ban. Once a room has
+// bans it also turns away sessions without a key (ErrKeyRequired), since
+// a username alone is too easy to change; rejoins were admitted before.
+// Where nobody logs in with a key, that would turn everyone away, so there
+// bans go by name and client ID alone.
+func (r *Room) checkBansLocked(c *Client) error {
+	if r.isBannedLocked(c) {
+		return ErrBanned
+	}
+	if len(r.bans) > 0 && c.Fingerprint == "" && !c.Rejoin && !r.keylessLogins {
+		return ErrKeyRequired
+	}
+	return nil
+}
+
+func (r *Room) isBannedLocked(c *Client) bool {
+	for _, b := range r.bans {
+		if b.matches(c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package room
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Node leases: each node renews its lease in the shared store every
+// leaseRenewal, and a room's node counts as gone once its lease is
+// leaseTTL old, so a node that misses two renewals in a row loses its
+// rooms to whoever is asked for them next.
+const (
+	leaseTTL     = 30 * time.Second
+	leaseRenewal = 10 * time.Second
+)
+
+// ClusterStore is a Store that servers can share: it can fetch a single
+// room, keep each node's lease, and hand rooms from node to node. Every
+// claim bumps the room's epoch, and saves and deletes made with an older
+// epoch fail with ErrRoomMoved, so a node that lost a room while it was
+// cut off can't overwrite the new host's state.
+type ClusterStore interface {
+	Store
+	// LoadRoom returns ErrRoomNotFound if there's no such room.
+	LoadRoom(roomID string) (RoomRecord, error)
+	// ClaimRoom returns ErrRoomMoved if the room's epoch isn't epoch.
+	ClaimRoom(roomID, node, addr string, epoch int64) (RoomRecord, error)
+	DeleteOwnedRoom(roomID string, epoch int64) error
+	RenewLease(node, addr string, until time.Time) error
+	LeaseUntil(node string) (time.Time, error)
+}
+
+// RemoteRoomError means the room exists but is hosted by another server
+// sharing the store, which the client should connect to instead. To code
+// that doesn't know about nodes it is ErrRoomNotFound: the room isn't here.
+type RemoteRoomError struct {
+	RoomID string
+	Node   string
+	Addr   string // host[:port] to ssh to; may be empty
+}
+
+func (e *RemoteRoomError) Error() string {
+	return fmt.Sprintf("room is hosted on %s", e.Node)
+}
+
+func (e *RemoteRoomError) Unwrap() error { return ErrRoomNotFound }
+
+// JoinCommand is the command that joins the room on its own node.
+func (e *RemoteRoomError) JoinCommand() string {
+	return sshJoinCommand(e.Addr, e.RoomID)
+}
+
+// SetNode names this server among those sharing a store, with the address
+// of this particular server that people can ssh to. Rooms remember which
+// node hosts them: the others point joiners there, and take a room over
+// once its node's lease runs out. Call before Restore.
+func (m *Manager) SetNode(id, addr string) {
+	m.nodeID = id
+	m.nodeAddr = addr
+}
+
+// cluster is the shared store, or nil when this server runs alone.
+func (m *Manager) cluster() ClusterStore {
+	cs, ok := m.store.(ClusterStore)
+	if !ok || m.nodeID == "" {
+		return nil
+	}
+	return cs
+}
+
+// ownsRecord reports whether rec is this node's to restore. Records from
+// before nodes existed belong to whoever loads them first.
+func (m *Manager) ownsRecord(rec RoomRecord) bool {
+	return rec.Node == m.nodeID
+}
+
+// RunLeases renews this node's lease until ctx is cancelled. It does
+// nothing unless the server is one of several.
+func (m *Manager) RunLeases(ctx context.Context) {
+	cs := m.cluster()
+	if cs == nil {
+		return
+	}
+	ticker := time.NewTicker(leaseRenewal)
+	defer ticker.Stop()
+	for {
+		if err := cs.RenewLease(m.nodeID, m.nodeAddr, time.Now().Add(leaseTTL)); err != nil && m.logger != nil {
+			m.logger.Warn("failed to renew node lease; other nodes may take our rooms", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimLocked takes rec over for this node. Call with m.mu held.
+func (m *Manager) claimLocked(cs ClusterStore, rec RoomRecord) (*Room, error) {
+	claimed, err := cs.ClaimRoom(rec.ID, m.nodeID, m.nodeAddr, rec.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	return m.restoreLocked(claimed)
+}
+
+// findElsewhere looks for a room this node doesn't have in the shared
+// store. A room whose node holds a lease comes back as a *RemoteRoomError;
+// one whose node's lease ran out, or that was ours, is claimed here.
+func (m *Manager) findElsewhere(roomID string) (*Room, error) {
+	cs := m.cluster()
+	if cs == nil {
+		return nil, ErrRoomNotFound
+	}
+	rec, err := cs.LoadRoom(roomID)
+	if err != nil {
+		if !errors.Is(err, ErrRoomNotFound) && m.logger != nil {
+			m.logger.Warn("room lookup failed", "roomID", roomID, "error", err)
+		}
+		return nil, ErrRoomNotFound
+	}
+	if !m.ownsRecord(rec) && rec.Node != "" {
+		until, err := cs.LeaseUntil(rec.Node)
+		if err != nil {
+			if m.logger != nil {
+				m.logger.Warn("node lease lookup failed", "roomID", roomID, "node", rec.Node, "error", err)
+			}
+			return nil, ErrRoomNotFound
+		}
+		if time.Now().Before(until) {
+			return nil, &RemoteRoomError{RoomID: rec.ID, Node: rec.Node, Addr: rec.NodeAddr}
+		}
+	}
+
+	m.mu.Lock()
+	if r, ok := m.rooms[roomID]; ok { // someone beat us to it
+		m.mu.Unlock()
+		return r, nil
+	}
+	r, err := m.claimLocked(cs, rec)
+	m.mu.Unlock()
+	if errors.Is(err, ErrRoomMoved) {
+		// another node claimed it first; it's theirs now
+		if rec, err := cs.LoadRoom(roomID); err == nil {
+			return nil, &RemoteRoomError{RoomID: rec.ID, Node: rec.Node, Addr: rec.NodeAddr}
+		}
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.logger != nil && rec.Node != m.nodeID {
+		m.logger.Warn("took over room from a node whose lease ran out", "roomID", roomID, "node", rec.Node)
+	}
+	return r, nil
+}
+
+// roomMoved forgets a room another node has claimed, telling anyone still
+// connected here where it went. Its workspace and stored state are the
+// new host's now, so they're left alone.
+func (m *Manager) roomMoved(room *Room) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rooms[room.ID] != room {
+		return
+	}
+	reason := "this room moved to another server"
+	if cs := m.cluster(); cs != nil {
+		if rec, err := cs.LoadRoom(room.ID); err == nil {
+			reason += " - reconnect with: " + sshJoinCommand(rec.NodeAddr, rec.ID)
+		}
+	}
+	if m.logger != nil {
+		m.logger.Warn("room claimed by another node; dropping it", "roomID", room.ID)
+	}
+	room.closeAll(RoomEvent{Type: "closed", Data: reason})
+	room.rejectPending("room moved")
+	room.closeTerminals()
+	if c := room.container; c != nil {
+		go c.Remove()
+	}
+	delete(m.rooms, room.ID)
+	room.destroyed.Store(true)
+}
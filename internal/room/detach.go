@@ -0,0 +1,41 @@
+package room
+
+import "time"
+
+// detach marks an emptied room as kept alive, shells and all, for the
+// host to reattach to; see Limits.DetachGrace.
+func (r *Room) detach(now time.Time) {
+	r.detachedAt.Store(now.UnixNano())
+	r.logEvent("everyone left; keeping the shell for reattach")
+}
+
+// DetachedSince returns when the last client left a room that is being
+// kept for reattach, and false while anyone is connected.
+func (r *Room) DetachedSince() (time.Time, bool) {
+	at := r.detachedAt.Load()
+	if at == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, at), true
+}
+
+// DetachGrace is how long an emptied room keeps its shells for the host
+// to reattach to; 0 means it closes when the last client leaves.
+func (m *Manager) DetachGrace() time.Duration {
+	return m.limits.DetachGrace
+}
+
+// reapDetachedLocked destroys rooms nobody reattached to within the grace
+// period. Call with m.mu held.
+func (m *Manager) reapDetachedLocked(now time.Time) {
+	for _, room := range m.rooms {
+		since, ok := room.DetachedSince()
+		if !ok || now.Sub(since) < m.limits.DetachGrace {
+			continue
+		}
+		m.destroyRoomLocked(room)
+		if m.logger != nil {
+			m.logger.Info("closed detached room", "roomID", room.ID, "after", m.limits.DetachGrace)
+		}
+	}
+}
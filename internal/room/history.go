@@ -0,0 +1,85 @@
+package room
+
+import (
+	"sync"
+	"time"
+)
+
+// historySize is how many recent events a room remembers for late joiners.
+// Clients should buffer their Events channel well beyond this so a replay
+// never crowds out live events.
+const historySize = 16
+
+// historyTypes are the events worth replaying; typing, ai_sync and the like
+// are only meaningful in the moment.
+var historyTypes = map[string]bool{
+	"join":              true,
+	"leave":             true,
+	"host_changed":      true,
+	"metadata":          true,
+	"env":               true,
+	"tabs":              true,
+	"shell_exited":      true,
+	"shell_restarted":   true,
+	"passthrough":       true,
+	"input_blocked":     true,
+	"ai_pin":            true,
+	"sandbox_cancelled": true,
+	"sandbox_reset":     true,
+}
+
+// eventHistory is a fixed-size ring buffer of recent room events. It has its
+// own lock so it can be used from both read- and write-locked room paths.
+type eventHistory struct {
+	mu    sync.Mutex
+	buf   [historySize]RoomEvent
+	start int
+	n     int
+}
+
+func (h *eventHistory) push(ev RoomEvent) {
+	if !historyTypes[ev.Type] {
+		return
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.n < historySize {
+		h.buf[(h.start+h.n)%historySize] = ev
+		h.n++
+		return
+	}
+	h.buf[h.start] = ev
+	h.start = (h.start + 1) % historySize
+}
+
+// recent returns the remembered events, oldest first.
+func (h *eventHistory) recent() []RoomEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]RoomEvent, h.n)
+	for i := range out {
+		out[i] = h.buf[(h.start+i)%historySize]
+	}
+	return out
+}
+
+// replayHistory sends recent events to a newly added client, marked as
+// replays so the UI can show them without toasting.
+func (r *Room) replayHistory(client *Client) {
+	if client.Events == nil {
+		return
+	}
+	for _, ev := range r.history.recent() {
+		ev.Replay = true
+		select {
+		case client.Events <- ev:
+		default:
+			return
+		}
+	}
+}
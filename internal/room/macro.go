@@ -0,0 +1,64 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxMacroLen caps a recorded macro, in bytes of keystrokes.
+	MaxMacroLen = 64 << 10
+	// maxMacros caps the macros kept; past it, the one saved longest ago
+	// makes way.
+	maxMacros = 10000
+)
+
+// ErrMacroTooLong is returned for a macro over MaxMacroLen.
+var ErrMacroTooLong = fmt.Errorf("macros are limited to %d KiB", MaxMacroLen>>10)
+
+type macro struct {
+	keys  []byte
+	saved time.Time
+}
+
+// SetMacro stores the recorded keystrokes for a key fingerprint, replacing
+// any previous macro. Usernames are chosen freely, so keying by them would
+// let anyone replay someone else's macro, or replace it with their own;
+// sessions without a key can't keep one.
+func (m *Manager) SetMacro(fingerprint string, keys []byte) error {
+	if fingerprint == "" {
+		return errors.New("macros need an SSH key")
+	}
+	if len(keys) > MaxMacroLen {
+		return ErrMacroTooLong
+	}
+	m.macroMu.Lock()
+	defer m.macroMu.Unlock()
+	if _, ok := m.macros[fingerprint]; !ok && len(m.macros) >= maxMacros {
+		oldest := ""
+		for fp, mc := range m.macros {
+			if oldest == "" || mc.saved.Before(m.macros[oldest].saved) {
+				oldest = fp
+			}
+		}
+		delete(m.macros, oldest)
+	}
+	m.macros[fingerprint] = macro{keys: append([]byte(nil), keys...), saved: time.Now()}
+	return nil
+}
+
+// GetMacro returns a copy of the keystrokes recorded for a key fingerprint,
+// or nil if none.
+func (m *Manager) GetMacro(fingerprint string) []byte {
+	if fingerprint == "" {
+		return nil
+	}
+	m.macroMu.RLock()
+	defer m.macroMu.RUnlock()
+	mc, ok := m.macros[fingerprint]
+	if !ok {
+		return nil
+	}
+	return append([]byte(nil), mc.keys...)
+}
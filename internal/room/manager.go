@@ -5,11 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
-	"net/http"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,15 +18,79 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/container"
+	"github.com/jaypopat/duet/internal/terminal"
+	"github.com/jaypopat/duet/internal/transcript"
 )
 
 var (
-	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomNotFound     = errors.New("room not found")
+	ErrRoomMoved        = errors.New("room was claimed by another server")
+	ErrInvalidEnvKey    = errors.New("invalid environment variable name")
+	ErrClientNotFound   = errors.New("user not in room")
+	ErrCannotKickHost   = errors.New("the host cannot be kicked")
+	ErrRoomFull         = errors.New("room is full")
+	ErrInvalidToken     = errors.New("invalid rejoin token")
+	ErrRejoinExpired    = errors.New("rejoin window has expired")
+	ErrBanned           = errors.New("you are banned from this room")
+	ErrNotBanned        = errors.New("user is not banned")
+	ErrKeyRequired      = errors.New("this room only admits people who connect with an SSH key")
+	ErrDescTooLong      = errors.New("description is too long")
+	ErrInvalidShell     = errors.New("shell not found")
+	ErrShellNotAllowed  = errors.New("shell not allowed on this server")
+	ErrInvalidDir       = errors.New("invalid start directory")
+	ErrOutsideWorkspace = errors.New("path is outside the room's workspace")
+	ErrTooManyTabs      = errors.New("too many tabs open")
+	ErrTabNotFound      = errors.New("no such tab")
+	ErrCloseMainTab     = errors.New("the main terminal can't be closed")
+	ErrTmuxDisabled     = errors.New("tmux sessions are not enabled on this server")
+	ErrNoTmuxSession    = errors.New("tmux session not found")
+
+	ErrInvalidTemplate  = errors.New("invalid prompt template")
+	ErrTemplateNotFound = errors.New("no such prompt template")
+
+	ErrAlreadyPinned = errors.New("that message is already pinned")
+	ErrTooManyPins   = errors.New("too many pinned messages")
+	ErrPinNotFound   = errors.New("no such pinned message")
+
+	ErrNoSandboxJob = errors.New("no sandbox command running")
 )
 
 var adjectives = []string{"swift", "happy", "clever", "brave", "cosmic", "bright", "mystic", "golden"}
 var nouns = []string{"phoenix", "dragon", "tiger", "falcon", "wolf", "eagle", "panda", "orca"}
 
+// maxDescriptionLen matches the create form's input limit.
+const maxDescriptionLen = 100
+
+// maxTags caps how many tags a room can carry.
+const maxTags = 5
+
+// NormalizeTags slugifies, de-duplicates and caps a tag list.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = slugify(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+		if len(out) == maxTags {
+			break
+		}
+	}
+	return out
+}
+
+// ParseTags splits a comma or space separated tag string.
+func ParseTags(s string) []string {
+	return NormalizeTags(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	}))
+}
+
 func slugify(s string) string {
 	s = strings.ToLower(strings.TrimSpace(s))
 	s = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(s, "-")
@@ -36,34 +101,228 @@ func slugify(s string) string {
 	return s
 }
 
+// Limits bounds how long rooms may live. Zero values disable a limit.
+type Limits struct {
+	MaxLifetime time.Duration // hard cap measured from room creation
+	IdleTimeout time.Duration // evict after this long without input or events
+	WarnBefore  time.Duration // broadcast an "expiring" event this long before eviction
+
+	MaxParticipants int // default per-room capacity, 0 is unlimited
+
+	RejoinGrace time.Duration // how long a departed user may reclaim their identity
+
+	// DetachGrace keeps a room's shells running this long after the last
+	// client leaves, so the host can reattach with the room code. Zero
+	// closes the room straight away.
+	DetachGrace time.Duration
+
+	AllowTmux bool // rooms may attach to tmux sessions owned by the server user
+
+	// AllowedShells are the shell commands hosts may pick per room. Empty
+	// allows the login shells in /etc/shells.
+	AllowedShells []string
+}
+
+// reapInterval is how often the reaper scans rooms for expiry.
+const reapInterval = 15 * time.Second
+
 type Manager struct {
-	rooms     map[string]*Room
-	mu        sync.RWMutex
-	workerURL string
-	aiClient  *ai.Client // Shared across all sessions
-	logger    *log.Logger
+	rooms      map[string]*Room
+	mu         sync.RWMutex
+	worker     *ai.Client  // the CF worker, nil without one; rooms' sandboxes live there
+	aiClient   ai.Provider // Shared across all sessions
+	logger     *log.Logger
+	limits     Limits
+	store      Store  // optional; nil keeps rooms in memory only
+	secret     []byte // HMAC key for rejoin tokens
+	defaults   RoomSettings
+	containers container.Config
+	policy     *terminal.InputPolicy
+	keyless    bool   // logins prove no key, see SetKeylessLogins
+	webURL     string // base URL of the web spectator, empty when it's off
+	sshAddr    string // host[:port] people ssh to, for invite commands
+	audit      *audit.Log
+
+	nodeID   string // this server among those sharing the store, see SetNode
+	nodeAddr string
+
+	// Keyboard macros by key fingerprint, see SetMacro
+	macros  map[string]macro
+	macroMu sync.RWMutex
+
+	// Profiles by key fingerprint, when the store can't keep them
+	profiles  map[string]Profile
+	profileMu sync.RWMutex
 }
 
-func NewManager(workerURL string, aiClient *ai.Client, logger *log.Logger) *Manager {
+func NewManager(worker *ai.Client, aiClient ai.Provider, logger *log.Logger, limits Limits, store Store) *Manager {
 	return &Manager{
-		rooms:     make(map[string]*Room),
-		workerURL: workerURL,
-		aiClient:  aiClient,
-		logger:    logger,
+		rooms:    make(map[string]*Room),
+		worker:   worker,
+		aiClient: aiClient,
+		logger:   logger,
+		limits:   limits,
+		store:    store,
+		secret:   newTokenSecret(),
+		macros:   make(map[string]macro),
+		profiles: make(map[string]Profile),
 	}
 }
 
-func (m *Manager) GetAIClient() *ai.Client {
+func (m *Manager) GetAIClient() ai.Provider {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.aiClient
 }
 
-func (m *Manager) CreateRoom(host, description string) (*Room, error) {
+// SetAIClient replaces the AI provider. Sessions that already started keep
+// the old one, so call it before the server starts.
+func (m *Manager) SetAIClient(p ai.Provider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aiClient = p
+}
+
+// AIHistory returns a room's AI conversation for providers that don't keep
+// one themselves.
+func (m *Manager) AIHistory(roomID string) []ai.ChatMessage {
+	room, err := m.GetRoom(roomID)
+	if err != nil {
+		return nil
+	}
+	msgs := room.GetAIMessages()
+	history := make([]ai.ChatMessage, len(msgs))
+	for i, msg := range msgs {
+		history[i] = ai.ChatMessage{Role: msg.Role, UserID: msg.UserID, Text: msg.Text, Ts: msg.Ts}
+	}
+	return history
+}
+
+// TmuxAllowed reports whether rooms may attach to existing tmux sessions.
+// Never with containers, since the sessions live on the host.
+func (m *Manager) TmuxAllowed() bool {
+	return m.limits.AllowTmux && !m.containers.Enabled()
+}
+
+// SetContainerConfig makes rooms created or restored from now on run their
+// shells in containers built from cfg.
+func (m *Manager) SetContainerConfig(cfg container.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.containers = cfg
+}
+
+// SetInputPolicy makes every room's shells refuse command lines p blocks.
+// Rooms created or restored afterwards pick it up.
+func (m *Manager) SetInputPolicy(p *terminal.InputPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = p
+}
+
+// SetKeylessLogins says that sessions log in without a key by design, as
+// with a password or PINs, so rooms with bans don't turn keyless joiners
+// away; bans there go by name and client ID only. Rooms created or
+// restored afterwards pick it up.
+func (m *Manager) SetKeylessLogins(on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyless = on
+}
+
+// SetWebURL records where browsers can watch rooms, e.g.
+// "https://duet.example.com", so rooms can hand out links.
+func (m *Manager) SetWebURL(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webURL = strings.TrimRight(url, "/")
+}
+
+// WebURL returns the web spectator's base URL, or "" when it's off.
+func (m *Manager) WebURL() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.webURL
+}
+
+// SetSSHAddress records the host (and port, unless 22) people connect to,
+// e.g. "duet.example.com", so rooms can hand out invite commands.
+func (m *Manager) SetSSHAddress(addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sshAddr = addr
+}
+
+// JoinCommand is a command that joins the room with the given ID straight
+// from a shell, skipping the launch screen.
+func (m *Manager) JoinCommand(roomID string) string {
+	m.mu.RLock()
+	addr := m.sshAddr
+	m.mu.RUnlock()
+	return sshJoinCommand(addr, roomID)
+}
+
+func sshJoinCommand(addr, roomID string) string {
+	if addr == "" {
+		addr = "localhost"
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "22" {
+		return fmt.Sprintf("ssh -t %s join %s", strings.TrimSuffix(addr, ":22"), roomID)
+	}
+	return fmt.Sprintf("ssh -t -p %s %s join %s", port, host, roomID)
+}
+
+// SetAuditLog makes sessions record security-relevant events to l.
+func (m *Manager) SetAuditLog(l *audit.Log) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = l
+}
+
+// AuditLog returns the audit log, nil (which records nothing) when
+// auditing is off.
+func (m *Manager) AuditLog() *audit.Log {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.audit
+}
+
+// SetDefaultSettings sets the shell settings used when a room doesn't
+// specify its own.
+func (m *Manager) SetDefaultSettings(s RoomSettings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaults = s
+}
+
+// RoomOptions is the optional metadata supplied when creating a room.
+type RoomOptions struct {
+	Description string
+	MaxClients  int      // 0 falls back to the server-wide default
+	Tags        []string // e.g. "go", "interview"; normalised by CreateRoom
+	HostName    string   // display name for the host, defaults to the username
+	// HostFingerprint is the host's key, which lets them take the room back
+	// when they reconnect; see Room.ReclaimsHost
+	HostFingerprint string
+	Settings        RoomSettings
+}
+
+// CreateRoom creates a room owned by host.
+func (m *Manager) CreateRoom(host string, opts RoomOptions) (*Room, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if !m.shellAllowed(strings.TrimSpace(opts.Settings.Shell)) {
+		return nil, fmt.Errorf("%w: %q", ErrShellNotAllowed, opts.Settings.Shell)
+	}
+	settings := opts.Settings.withDefaults(m.defaults)
+	if err := settings.validate(m.TmuxAllowed(), !m.containers.Enabled()); err != nil {
+		return nil, err
+	}
+
 	roomID := uuid.New().String()
+	description := opts.Description
 
 	// Generate workspace name: slugify description or random readable name
 	var workspaceName string
@@ -90,26 +349,230 @@ func (m *Manager) CreateRoom(host, description string) (*Room, error) {
 		}
 	}
 
+	startDir, err := settings.resolveDir(workspaceDir)
+	if err != nil {
+		return nil, err
+	}
+	if startDir == workspaceDir {
+		startDir = ""
+	}
+
 	room := &Room{
 		ID:           roomID,
 		Description:  description,
 		Host:         host,
 		Connections:  make([]*Client, 0),
 		WorkspaceDir: workspaceDir,
+		StartDir:     startDir,
+		Shell:        settings.Shell,
+		Scrollback:   settings.Scrollback,
+		FrameRate:    settings.FrameRate,
+		TmuxSession:  settings.TmuxSession,
+		Env:          settings.Env,
+		Templates:    settings.Templates,
+		CreatedAt:    time.Now(),
+		MaxClients:   opts.MaxClients,
+		Tags:         NormalizeTags(opts.Tags),
+		Transcript:   transcript.New(),
+		HostName:     strings.TrimSpace(opts.HostName),
+
+		hostFingerprint: opts.HostFingerprint,
+	}
+	if room.HostName == "" {
+		room.HostName = host
+	}
+	if m.containers.Enabled() {
+		room.container = container.New(m.containers, roomID, workspaceDir)
+	}
+	room.inputPolicy = m.policy
+	room.keylessLogins = m.keyless
+	if room.MaxClients <= 0 {
+		room.MaxClients = m.limits.MaxParticipants
 	}
+	room.RejoinGrace = m.limits.RejoinGrace
+	room.Touch()
+	room.logger = m.roomLogger(roomID)
+	room.onChange = m.persist
 	m.rooms[roomID] = room
+	m.persist(room)
 	return room, nil
 }
+
+// roomLogger tags the manager's log lines with a room's ID.
+func (m *Manager) roomLogger(roomID string) *log.Logger {
+	if m.logger == nil {
+		return nil
+	}
+	return m.logger.With("roomID", roomID)
+}
+
+// Restore reloads persisted rooms into memory. Rooms come back empty and get
+// a new shell when someone rejoins.
+func (m *Manager) Restore() error {
+	if m.store == nil {
+		return nil
+	}
+	recs, err := m.store.LoadRooms()
+	if err != nil {
+		return fmt.Errorf("load rooms: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cs := m.cluster()
+	restored := 0
+	for _, rec := range recs {
+		switch {
+		case cs == nil || m.ownsRecord(rec):
+			_, err = m.restoreLocked(rec)
+		case rec.Node == "":
+			// from before this server joined a cluster: first come, first served
+			_, err = m.claimLocked(cs, rec)
+			if errors.Is(err, ErrRoomMoved) {
+				continue
+			}
+		default:
+			continue // another node's; see GetRoom
+		}
+		if err != nil {
+			return err
+		}
+		restored++
+	}
+	if m.logger != nil && restored > 0 {
+		m.logger.Info("restored rooms", "count", restored)
+	}
+	return nil
+}
+
+// restoreLocked brings a persisted room back to life on this node. Call
+// with m.mu held.
+func (m *Manager) restoreLocked(rec RoomRecord) (*Room, error) {
+	room := roomFromRecord(rec)
+	if room.WorkspaceDir != "" {
+		if err := os.MkdirAll(room.WorkspaceDir, 0755); err != nil {
+			return nil, fmt.Errorf("recreate workspace for %s: %w", room.ID, err)
+		}
+	}
+	room.RejoinGrace = m.limits.RejoinGrace
+	room.Scrollback = m.defaults.Scrollback
+	room.FrameRate = m.defaults.FrameRate
+	room.logger = m.roomLogger(room.ID)
+	room.onChange = m.persist
+	room.inputPolicy = m.policy
+	room.keylessLogins = m.keyless
+	if m.containers.Enabled() {
+		room.container = container.New(m.containers, room.ID, room.WorkspaceDir)
+	}
+	m.rooms[room.ID] = room
+	return room, nil
+}
+
+// persist writes the room to the store, if one is configured.
+func (m *Manager) persist(room *Room) {
+	if m.store == nil {
+		return
+	}
+	rec := room.record()
+	rec.Node, rec.NodeAddr = m.nodeID, m.nodeAddr
+	err := m.store.SaveRoom(rec)
+	if errors.Is(err, ErrRoomMoved) {
+		// callers may hold m.mu, which dropping the room needs
+		go m.roomMoved(room)
+		return
+	}
+	if err != nil && m.logger != nil {
+		m.logger.Warn("failed to persist room", "roomID", room.ID, "error", err)
+	}
+}
 func (m *Manager) GetRoom(roomID string) (*Room, error) {
+	m.mu.RLock()
+	room, exists := m.rooms[roomID]
+	m.mu.RUnlock()
+	if !exists {
+		return m.findElsewhere(roomID)
+	}
+
+	return room, nil
+}
+
+// ListRooms returns a snapshot of every active room, oldest first.
+func (m *Manager) ListRooms() []RoomInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	infos := make([]RoomInfo, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		infos = append(infos, room.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// CloseRoom disconnects everyone with a "closed" event and destroys the room.
+func (m *Manager) CloseRoom(roomID, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	room, exists := m.rooms[roomID]
 	if !exists {
-		return nil, ErrRoomNotFound
+		return ErrRoomNotFound
 	}
+	room.closeAll(RoomEvent{Type: "closed", Data: reason})
+	m.destroyRoomLocked(room)
+	return nil
+}
 
-	return room, nil
+// Rooms returns every active room, oldest first.
+func (m *Manager) Rooms() []*Room {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].CreatedAt.Before(rooms[j].CreatedAt)
+	})
+	return rooms
+}
+
+// Announce shows text to everyone in every room, e.g. before maintenance.
+// It returns how many rooms it reached.
+func (m *Manager) Announce(text string) int {
+	rooms := m.Rooms()
+	for _, r := range rooms {
+		r.notify(RoomEvent{Type: "announcement", Data: text}, "")
+	}
+	return len(rooms)
+}
+
+// Shutdown stops every room's shells as the server exits and waits, until
+// ctx is done, for their processes to go. Rooms stay in the store so they
+// come back after a restart.
+func (m *Manager) Shutdown(ctx context.Context) {
+	m.mu.RLock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		rooms = append(rooms, r)
+	}
+	m.mu.RUnlock()
+
+	var done []<-chan struct{}
+	for _, r := range rooms {
+		done = append(done, r.closeTerminals()...)
+	}
+	for _, d := range done {
+		select {
+		case <-d:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 func (m *Manager) RoomCount() int {
@@ -130,54 +593,119 @@ func (m *Manager) LeaveRoom(roomID, clientID string) bool {
 	room.RemoveClient(clientID)
 
 	if room.ClientCount() == 0 {
-		if room.Terminal != nil {
-			room.Terminal.Close()
-			room.Terminal = nil
+		if m.limits.DetachGrace > 0 {
+			room.detach(time.Now())
+			return false
 		}
-		// Clean up workspace directory when room is destroyed
-		if room.WorkspaceDir != "" {
-			os.RemoveAll(room.WorkspaceDir)
-		}
-		// Cleanup external resources (sandbox, agent state) if worker configured
-		if m.workerURL != "" {
-			go m.cleanupRoomResources(roomID)
-		}
-		delete(m.rooms, roomID)
+		m.destroyRoomLocked(room)
 		return true
 	}
 	return false
 }
 
-func (m *Manager) cleanupRoomResources(roomID string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	
-	url := fmt.Sprintf("%s/api/rooms/%s", m.workerURL, roomID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
-	if err != nil {
-		if m.logger != nil {
-			m.logger.Warn("failed to create cleanup request", "roomID", roomID, "error", err)
+// destroyRoomLocked tears down a room's terminal, workspace and remote
+// resources and forgets it. Callers must hold m.mu.
+func (m *Manager) destroyRoomLocked(room *Room) {
+	room.rejectPending("room closed")
+	room.closeTerminals()
+	if c := room.container; c != nil {
+		// docker rm can take a while; don't hold the manager lock for it
+		go func() {
+			if err := c.Remove(); err != nil && m.logger != nil {
+				m.logger.Warn("failed to remove room container", "roomID", room.ID, "container", c.Name(), "error", err)
+			}
+		}()
+	}
+	// Clean up workspace directory when room is destroyed
+	if room.WorkspaceDir != "" {
+		os.RemoveAll(room.WorkspaceDir)
+	}
+	// Cleanup external resources (sandbox, agent state) if worker configured
+	if m.worker != nil {
+		go m.cleanupRoomResources(room.ID)
+	}
+	delete(m.rooms, room.ID)
+
+	room.destroyed.Store(true)
+	if m.store != nil {
+		var err error
+		if cs := m.cluster(); cs != nil {
+			err = cs.DeleteOwnedRoom(room.ID, room.epoch)
+		} else {
+			err = m.store.DeleteRoom(room.ID)
+		}
+		if err != nil && m.logger != nil {
+			m.logger.Warn("failed to delete persisted room", "roomID", room.ID, "error", err)
 		}
+	}
+}
+
+// RunReaper evicts rooms that outlive the configured limits until ctx is
+// cancelled. Clients get an "expiring" warning shortly before eviction and an
+// "expired" event when the room is torn down.
+func (m *Manager) RunReaper(ctx context.Context) {
+	if m.limits.MaxLifetime <= 0 && m.limits.IdleTimeout <= 0 && m.limits.DetachGrace <= 0 {
 		return
 	}
-	
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		if m.logger != nil {
-			m.logger.Warn("failed to cleanup room resources", "roomID", roomID, "error", err)
+
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.reap(now)
 		}
-		return
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode >= 400 {
+}
+
+func (m *Manager) reap(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.limits.DetachGrace > 0 {
+		m.reapDetachedLocked(now)
+	}
+
+	for _, room := range m.rooms {
+		deadline, reason, ok := room.expiresAt(m.limits)
+		if !ok {
+			continue
+		}
+
+		if !now.Before(deadline) {
+			room.closeAll(RoomEvent{Type: "expired", Data: reason})
+			m.destroyRoomLocked(room)
+			if m.logger != nil {
+				m.logger.Info("evicted room", "roomID", room.ID, "reason", reason)
+			}
+			continue
+		}
+
+		if m.limits.WarnBefore > 0 && deadline.Sub(now) <= m.limits.WarnBefore &&
+			room.expiryWarned.CompareAndSwap(false, true) {
+			room.notify(RoomEvent{
+				Type: "expiring",
+				Data: fmt.Sprintf("%s in %s", reason, deadline.Sub(now).Round(time.Second)),
+			}, "")
+		}
+	}
+}
+
+// cleanupRoomResources has the worker destroy the room's sandbox and agent
+// state, through the worker client so the request carries its token.
+func (m *Manager) cleanupRoomResources(roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := m.worker.CleanupRoom(ctx, roomID); err != nil {
 		if m.logger != nil {
-			m.logger.Warn("cleanup request failed", "roomID", roomID, "status", resp.StatusCode)
+			m.logger.Warn("failed to cleanup room resources", "roomID", roomID, "error", err)
 		}
 		return
 	}
-	
 	if m.logger != nil {
 		m.logger.Info("cleaned up room resources", "roomID", roomID)
 	}
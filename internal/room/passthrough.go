@@ -0,0 +1,25 @@
+package room
+
+// SetPassthrough switches the room between the normal UI and raw
+// passthrough, where each client sees the shell's output straight on their
+// own terminal. It sends a "passthrough" event with Data "on" or "off" so
+// clients can switch over.
+func (r *Room) SetPassthrough(on bool, by string) {
+	r.mu.Lock()
+	r.Passthrough = on
+	r.mu.Unlock()
+
+	r.changed()
+	data := "off"
+	if on {
+		data = "on"
+	}
+	r.BroadcastEvent(RoomEvent{Type: "passthrough", Username: by, Data: data}, "")
+}
+
+// RawMode reports whether the room prefers raw passthrough.
+func (r *Room) RawMode() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Passthrough
+}
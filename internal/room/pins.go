@@ -0,0 +1,55 @@
+package room
+
+// maxPins keeps the pinned section from crowding out the conversation.
+const maxPins = 5
+
+// PinnedMessage is an AI message someone pinned to the top of the sidebar.
+// It's a copy, so it outlives the message scrolling out of the history.
+type PinnedMessage struct {
+	AIMessage
+	PinnedBy string `json:"pinned_by"`
+}
+
+// PinAIMessage pins msg for everyone and sends an "ai_pin" event.
+func (r *Room) PinAIMessage(msg AIMessage, by string) error {
+	r.mu.Lock()
+	for _, p := range r.Pinned {
+		if p.Ts == msg.Ts && p.Text == msg.Text {
+			r.mu.Unlock()
+			return ErrAlreadyPinned
+		}
+	}
+	if len(r.Pinned) >= maxPins {
+		r.mu.Unlock()
+		return ErrTooManyPins
+	}
+	r.Pinned = append(r.Pinned, PinnedMessage{AIMessage: msg, PinnedBy: by})
+	r.mu.Unlock()
+
+	r.changed()
+	r.BroadcastEvent(RoomEvent{Type: "ai_pin", Username: by, Data: "pinned an AI message"}, "")
+	return nil
+}
+
+// UnpinAIMessage removes the i-th pinned message (0-based, oldest first)
+// and sends an "ai_pin" event.
+func (r *Room) UnpinAIMessage(i int, by string) error {
+	r.mu.Lock()
+	if i < 0 || i >= len(r.Pinned) {
+		r.mu.Unlock()
+		return ErrPinNotFound
+	}
+	r.Pinned = append(r.Pinned[:i:i], r.Pinned[i+1:]...)
+	r.mu.Unlock()
+
+	r.changed()
+	r.BroadcastEvent(RoomEvent{Type: "ai_pin", Username: by, Data: "unpinned an AI message"}, "")
+	return nil
+}
+
+// PinnedAIMessages returns the pinned messages, oldest pin first.
+func (r *Room) PinnedAIMessages() []PinnedMessage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]PinnedMessage(nil), r.Pinned...)
+}
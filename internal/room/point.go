@@ -0,0 +1,21 @@
+package room
+
+import "fmt"
+
+// Point highlights screen rows from..to of the terminal tab on everyone's
+// pane for a few seconds. It sends a "point" event whose Data
+// ParsePoint reads back.
+func (r *Room) Point(by string, tab, from, to int) {
+	if from > to {
+		from, to = to, from
+	}
+	r.BroadcastEvent(RoomEvent{Type: "point", Username: by, Data: fmt.Sprintf("%d:%d:%d", tab, from, to)}, "")
+}
+
+// ParsePoint reads the Data of a "point" event.
+func ParsePoint(data string) (tab, from, to int, ok bool) {
+	if _, err := fmt.Sscanf(data, "%d:%d:%d", &tab, &from, &to); err != nil || from < 0 || from > to {
+		return 0, 0, 0, false
+	}
+	return tab, from, to, true
+}
@@ -0,0 +1,141 @@
+package room
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Roles and connection states in the participant list. The driver is
+// whoever last typed into a shell, unless that was the host or a while
+// ago; everyone else watches.
+const (
+	RoleHost     = "host"
+	RoleDriver   = "driver"
+	RoleObserver = "observer"
+
+	StatusActive       = "active"
+	StatusIdle         = "idle"
+	StatusReconnecting = "reconnecting"
+)
+
+const (
+	// IdleAfter is how long without a key press makes someone idle, and
+	// stops a quiet driver counting as one.
+	IdleAfter = 2 * time.Minute
+	// HeartbeatTimeout is how long a session may miss its heartbeat before
+	// it looks like a dropped connection waiting to be rejoined.
+	HeartbeatTimeout = 10 * time.Second
+)
+
+// Participant is one connection as the participant list shows it.
+type Participant struct {
+	ClientID    string
+	Username    string
+	DisplayName string
+	Role        string // RoleHost, RoleDriver or RoleObserver
+	Status      string // StatusActive, StatusIdle or StatusReconnecting
+	JoinedAt    time.Time
+}
+
+// Heartbeat tells the room clientID's session is still drawing; sessions
+// call it every tick while they're in the room. It reports false once the
+// room no longer has clientID connected.
+func (r *Room) Heartbeat(clientID string) bool {
+	c := r.client(clientID)
+	if c == nil {
+		return false
+	}
+	c.lastSeen.Store(time.Now().UnixNano())
+	return true
+}
+
+// NoteKey records a key press by clientID anywhere in the room, which
+// keeps them active.
+func (r *Room) NoteKey(clientID string) {
+	if c := r.client(clientID); c != nil {
+		now := time.Now().UnixNano()
+		c.lastKey.Store(now)
+		c.lastSeen.Store(now)
+	}
+}
+
+// NoteInput records clientID typing into one of the room's shells, which
+// makes them the driver.
+func (r *Room) NoteInput(clientID string) {
+	if c := r.client(clientID); c != nil {
+		now := time.Now().UnixNano()
+		c.lastInput.Store(now)
+		c.lastKey.Store(now)
+		c.lastSeen.Store(now)
+	}
+}
+
+func (r *Room) client(clientID string) *Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.Connections {
+		if c.ID == clientID {
+			return c
+		}
+	}
+	return nil
+}
+
+// Participants snapshots who's connected with their role and status, the
+// host first and the rest by name.
+func (r *Room) Participants() []Participant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	since := func(nanos int64, fallback time.Time) time.Duration {
+		if nanos == 0 {
+			return now.Sub(fallback)
+		}
+		return now.Sub(time.Unix(0, nanos))
+	}
+
+	var driver *Client
+	var latest int64
+	for _, c := range r.Connections {
+		if in := c.lastInput.Load(); in > latest {
+			driver, latest = c, in
+		}
+	}
+	if driver != nil && since(latest, now) > IdleAfter {
+		driver = nil
+	}
+
+	ps := make([]Participant, 0, len(r.Connections))
+	for _, c := range r.Connections {
+		p := Participant{
+			ClientID:    c.ID,
+			Username:    c.Username,
+			DisplayName: c.DisplayName,
+			Role:        RoleObserver,
+			Status:      StatusActive,
+			JoinedAt:    c.JoinedAt,
+		}
+		switch {
+		case c.IsHost:
+			p.Role = RoleHost
+		case c == driver:
+			p.Role = RoleDriver
+		}
+		switch {
+		case since(c.lastSeen.Load(), c.JoinedAt) > HeartbeatTimeout:
+			p.Status = StatusReconnecting
+		case since(c.lastKey.Load(), c.JoinedAt) > IdleAfter:
+			p.Status = StatusIdle
+		}
+		ps = append(ps, p)
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		if hi, hj := ps[i].Role == RoleHost, ps[j].Role == RoleHost; hi != hj {
+			return hi
+		}
+		return strings.ToLower(ps[i].Username) < strings.ToLower(ps[j].Username)
+	})
+	return ps
+}
@@ -0,0 +1,80 @@
+package room
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoProfile is returned for a key nobody has set a profile up for.
+var ErrNoProfile = errors.New("no profile")
+
+// maxMemoryProfiles caps the profiles kept in memory when there's no
+// database; past it, the one saved longest ago makes way.
+const maxMemoryProfiles = 10000
+
+// Profile is what someone chose on the profile screen, kept by their SSH
+// key's fingerprint so it follows them from session to session. Empty
+// fields leave the server's defaults in place.
+type Profile struct {
+	Fingerprint string
+	DisplayName string // shown next to the username; never replaces it
+	Color       string // a name from the UI's colour list
+	Theme       string
+	Keymap      string
+	UpdatedAt   time.Time
+}
+
+// ProfileStore is a Store that also keeps profiles, so they survive a
+// restart and are shared by servers using the same database.
+type ProfileStore interface {
+	// LoadProfile returns ErrNoProfile if fingerprint has none.
+	LoadProfile(fingerprint string) (Profile, error)
+	SaveProfile(p Profile) error
+}
+
+// Profile returns the profile for a key fingerprint, and whether there is
+// one.
+func (m *Manager) Profile(fingerprint string) (Profile, bool) {
+	if fingerprint == "" {
+		return Profile{}, false
+	}
+	if ps, ok := m.store.(ProfileStore); ok {
+		p, err := ps.LoadProfile(fingerprint)
+		if err == nil {
+			return p, true
+		}
+		if !errors.Is(err, ErrNoProfile) {
+			m.logger.Error("load profile", "err", err)
+		}
+		return Profile{}, false
+	}
+	m.profileMu.RLock()
+	defer m.profileMu.RUnlock()
+	p, ok := m.profiles[fingerprint]
+	return p, ok
+}
+
+// SaveProfile stores p under its fingerprint, in the database when there
+// is one and in memory otherwise.
+func (m *Manager) SaveProfile(p Profile) error {
+	if p.Fingerprint == "" {
+		return errors.New("profiles need an SSH key")
+	}
+	p.UpdatedAt = time.Now()
+	if ps, ok := m.store.(ProfileStore); ok {
+		return ps.SaveProfile(p)
+	}
+	m.profileMu.Lock()
+	defer m.profileMu.Unlock()
+	if _, ok := m.profiles[p.Fingerprint]; !ok && len(m.profiles) >= maxMemoryProfiles {
+		oldest := ""
+		for fp, q := range m.profiles {
+			if oldest == "" || q.UpdatedAt.Before(m.profiles[oldest].UpdatedAt) {
+				oldest = fp
+			}
+		}
+		delete(m.profiles, oldest)
+	}
+	m.profiles[p.Fingerprint] = p
+	return nil
+}
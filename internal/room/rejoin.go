@@ -0,0 +1,103 @@
+package room
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	rejoinTokenPrefix = "rj-"
+	rejoinTokenTTL    = 24 * time.Hour
+	rejoinSigLen      = 16 // truncated HMAC-SHA256, keeps tokens pasteable
+)
+
+// RejoinClaim is the identity carried by a rejoin token.
+type RejoinClaim struct {
+	RoomID   string
+	ClientID string
+	Username string
+}
+
+func newTokenSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("room: cannot seed rejoin token secret: " + err.Error())
+	}
+	return secret
+}
+
+// IsRejoinToken reports whether s looks like a rejoin token rather than a
+// room ID, so the join screen can accept either.
+func IsRejoinToken(s string) bool {
+	return strings.HasPrefix(s, rejoinTokenPrefix)
+}
+
+// IssueRejoinToken signs a token that lets clientID reclaim its identity in
+// roomID. Tokens are only valid for this server process.
+func (m *Manager) IssueRejoinToken(roomID, clientID, username string) (string, error) {
+	rid, err := uuid.Parse(roomID)
+	if err != nil {
+		return "", err
+	}
+	cid, err := uuid.Parse(clientID)
+	if err != nil {
+		return "", err
+	}
+
+	payload := make([]byte, 0, 40+len(username))
+	payload = append(payload, rid[:]...)
+	payload = append(payload, cid[:]...)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(time.Now().Add(rejoinTokenTTL).Unix()))
+	payload = append(payload, username...)
+
+	enc := base64.RawURLEncoding
+	return rejoinTokenPrefix + enc.EncodeToString(payload) + "." + enc.EncodeToString(m.sign(payload)), nil
+}
+
+// RedeemRejoinToken verifies a token and returns the identity it carries.
+func (m *Manager) RedeemRejoinToken(token string) (RejoinClaim, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(token), rejoinTokenPrefix)
+	if !ok {
+		return RejoinClaim{}, ErrInvalidToken
+	}
+	encPayload, encSig, ok := strings.Cut(body, ".")
+	if !ok {
+		return RejoinClaim{}, ErrInvalidToken
+	}
+
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(encPayload)
+	if err != nil || len(payload) < 40 {
+		return RejoinClaim{}, ErrInvalidToken
+	}
+	sig, err := enc.DecodeString(encSig)
+	if err != nil || !hmac.Equal(sig, m.sign(payload)) {
+		return RejoinClaim{}, ErrInvalidToken
+	}
+
+	expires := time.Unix(int64(binary.BigEndian.Uint64(payload[32:40])), 0)
+	if time.Now().After(expires) {
+		return RejoinClaim{}, ErrRejoinExpired
+	}
+
+	rid, _ := uuid.FromBytes(payload[:16])
+	cid, _ := uuid.FromBytes(payload[16:32])
+	return RejoinClaim{
+		RoomID:   rid.String(),
+		ClientID: cid.String(),
+		Username: string(payload[40:]),
+	}, nil
+}
+
+func (m *Manager) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)[:rejoinSigLen]
+}
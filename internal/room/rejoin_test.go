@@ -0,0 +1,80 @@
+package room
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRedeemRejoinToken(t *testing.T) {
+	m := &Manager{secret: newTokenSecret()}
+	other := &Manager{secret: newTokenSecret()}
+	roomID, clientID := uuid.NewString(), uuid.NewString()
+	want := RejoinClaim{RoomID: roomID, ClientID: clientID, Username: "alice"}
+
+	token, err := m.IssueRejoinToken(roomID, clientID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	encPayload, encSig, _ := strings.Cut(strings.TrimPrefix(token, rejoinTokenPrefix), ".")
+	payload, _ := enc.DecodeString(encPayload)
+
+	// signed builds a token for payload as edit leaves it, signed by mgr
+	signed := func(mgr *Manager, edit func(p []byte) []byte) string {
+		p := edit(append([]byte(nil), payload...))
+		return rejoinTokenPrefix + enc.EncodeToString(p) + "." + enc.EncodeToString(mgr.sign(p))
+	}
+	// resigned keeps the original signature over an edited payload
+	resigned := func(edit func(p []byte) []byte) string {
+		p := edit(append([]byte(nil), payload...))
+		return rejoinTokenPrefix + enc.EncodeToString(p) + "." + encSig
+	}
+	expire := func(p []byte) []byte {
+		binary.BigEndian.PutUint64(p[32:40], uint64(time.Now().Add(-time.Second).Unix()))
+		return p
+	}
+	flipSig := func(s string) string {
+		b, _ := enc.DecodeString(s)
+		b[0] ^= 1
+		return enc.EncodeToString(b)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: token},
+		{name: "valid with whitespace", token: " " + token + "\n"},
+		{name: "other username", token: resigned(func(p []byte) []byte { return append(p[:40], "mallory"...) }), wantErr: ErrInvalidToken},
+		{name: "other client", token: resigned(func(p []byte) []byte { p[16] ^= 1; return p }), wantErr: ErrInvalidToken},
+		{name: "later expiry", token: resigned(func(p []byte) []byte { p[39]++; return p }), wantErr: ErrInvalidToken},
+		{name: "tampered signature", token: rejoinTokenPrefix + encPayload + "." + flipSig(encSig), wantErr: ErrInvalidToken},
+		{name: "truncated signature", token: rejoinTokenPrefix + encPayload + "." + encSig[:len(encSig)-2], wantErr: ErrInvalidToken},
+		{name: "another server's", token: signed(other, func(p []byte) []byte { return p }), wantErr: ErrInvalidToken},
+		{name: "expired", token: signed(m, expire), wantErr: ErrRejoinExpired},
+		{name: "earlier expiry", token: resigned(expire), wantErr: ErrInvalidToken},
+		{name: "short payload", token: signed(m, func(p []byte) []byte { return p[:39] }), wantErr: ErrInvalidToken},
+		{name: "no prefix", token: strings.TrimPrefix(token, rejoinTokenPrefix), wantErr: ErrInvalidToken},
+		{name: "no signature", token: rejoinTokenPrefix + encPayload, wantErr: ErrInvalidToken},
+		{name: "not base64", token: rejoinTokenPrefix + "!!!." + encSig, wantErr: ErrInvalidToken},
+		{name: "empty", token: "", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.RedeemRejoinToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != want {
+				t.Errorf("got claim %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -1,9 +1,22 @@
 package room
 
 import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/charmbracelet/log"
+	"github.com/jaypopat/duet/internal/container"
 	"github.com/jaypopat/duet/internal/terminal"
+	"github.com/jaypopat/duet/internal/tracing"
+	"github.com/jaypopat/duet/internal/transcript"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // RoomEvent represents an event that occurred in a room
@@ -11,6 +24,8 @@ type RoomEvent struct {
 	Type     string
 	Username string
 	Data     string
+	At       time.Time // set when the event is stored in the room's history
+	Replay   bool      // true when re-sent from history to a late joiner
 }
 
 type AIMessage struct {
@@ -25,6 +40,24 @@ type Client struct {
 	Username string
 	IsHost   bool
 	Events   chan RoomEvent
+	JoinedAt time.Time
+	Rejoin   bool // reclaiming an earlier identity via a rejoin token
+
+	Fingerprint string // SSH public key fingerprint, empty without key auth
+	DisplayName string // from the person's profile, shown beside Username
+	Color       string // colour name from their profile, empty for the default
+
+	// unix nanos, for the participant list; see presence.go
+	lastSeen  atomic.Int64 // the session's last heartbeat
+	lastKey   atomic.Int64 // the last key they pressed in the room
+	lastInput atomic.Int64 // the last input they sent to a shell
+}
+
+// departedClient remembers who left so they can reclaim their role.
+type departedClient struct {
+	username string
+	isHost   bool
+	leftAt   time.Time
 }
 
 type Room struct {
@@ -33,18 +66,267 @@ type Room struct {
 	Host         string
 	Connections  []*Client
 	mu           sync.RWMutex
-	Terminal     *terminal.Terminal
+	tabs         []*Tab // shared shells; tabs[0] is the main terminal
 	AIMessages   []AIMessage
+	Pinned       []PinnedMessage // AI messages kept at the top of the sidebar
 	WorkspaceDir string
+	StartDir     string                // where the shell starts; empty means WorkspaceDir
+	Shell        string                // shell command line; empty means the server's $SHELL
+	Scrollback   int                   // terminal history lines; 0 means the terminal default
+	FrameRate    int                   // max terminal updates per second; 0 means the terminal default
+	TmuxSession  string                // main terminal attaches here instead of a new shell
+	container    *container.Container  // shells run inside this, if the server uses containers
+	inputPolicy  *terminal.InputPolicy // command lines the shells refuse, if any
+	Env          map[string]string     // shared env vars, exported into shells and sandbox
+	Templates    map[string]string     // AI prompt templates by name
+	CreatedAt    time.Time
+	MaxClients   int      // capacity limit, 0 means unlimited
+	Tags         []string // normalised topic/language tags, e.g. "go", "interview"
+	HostName     string   // host display name shown in listings
+
+	RequireApproval bool      // joiners must knock and be admitted by the host
+	Public          bool      // listed on everyone's launch screen, see SetPublic
+	Passthrough     bool      // clients see raw shell output instead of the UI
+	Pending         []*Client // knocking clients awaiting a host decision
+
+	lastActive   atomic.Int64 // unix nanos of the last input/event, used for idle GC
+	expiryWarned atomic.Bool
+	detachedAt   atomic.Int64 // unix nanos when the last client left, 0 while anyone is here; see detach
+
+	Transcript *transcript.Transcript // session record for :export
+
+	sandboxJobs    []SandboxJob // sandbox commands in flight, see StartSandboxJob
+	sandbox        SandboxState
+	sandboxHistory []string // commands run in the sandbox by anyone, oldest first
+
+	aiRequests  atomic.Int64 // see Stats
+	sandboxRuns atomic.Int64
+
+	hostFingerprint string // the host's key; only it takes the room back, see ReclaimsHost
+	keylessLogins   bool   // sessions log in without keys, see Manager.SetKeylessLogins
+
+	webToken   string // lets a browser type into the shells; see NewWebToken
+	watchToken string // lets a browser watch; see WatchToken
+	webViewers int    // browsers watching right now, see AddWebViewer
+
+	RejoinGrace time.Duration             // how long a departed client may reclaim its identity
+	departed    map[string]departedClient // keyed by client ID
+	bans        []ban
+	history     eventHistory // recent events replayed to late joiners
+
+	logger    *log.Logger // set by Manager, tagged with the room ID
+	onChange  func(*Room) // set by Manager to persist the room
+	epoch     int64       // the store's fencing token when the room was claimed, see ClusterStore
+	destroyed atomic.Bool // torn down by the Manager; stop persisting
+	timer     roomTimer   // shared countdown, see timer.go
+}
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// RoomInfo is a read-only snapshot of a room for listings and the HTTP API.
+type RoomInfo struct {
+	ID              string    `json:"id"`
+	Description     string    `json:"description"`
+	Host            string    `json:"host"`
+	HostName        string    `json:"host_name"`
+	Tags            []string  `json:"tags"`
+	Participants    []string  `json:"participants"`
+	MaxClients      int       `json:"max_participants"`
+	RequireApproval bool      `json:"require_approval"`
+	Passthrough     bool      `json:"passthrough"`
+	Public          bool      `json:"public"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastActive      time.Time `json:"last_active"`
+	Detached        bool      `json:"detached"` // empty but kept for the host to reattach
+}
+
+// Info snapshots the room's public metadata.
+func (r *Room) Info() RoomInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	participants := make([]string, 0, len(r.Connections))
+	for _, c := range r.Connections {
+		participants = append(participants, c.Username)
+	}
+	return RoomInfo{
+		ID:              r.ID,
+		Description:     r.Description,
+		Host:            r.Host,
+		HostName:        r.HostName,
+		Tags:            append([]string(nil), r.Tags...),
+		Participants:    participants,
+		MaxClients:      r.MaxClients,
+		RequireApproval: r.RequireApproval,
+		Passthrough:     r.Passthrough,
+		Public:          r.Public,
+		CreatedAt:       r.CreatedAt,
+		LastActive:      r.LastActive(),
+		Detached:        r.detachedAt.Load() != 0,
+	}
+}
+
+// ClientInfo describes one connection, for operators.
+type ClientInfo struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	IsHost      bool      `json:"is_host"`
+	JoinedAt    time.Time `json:"joined_at"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
 }
 
-func (r *Room) AddClient(client *Client) {
+// ClientDetails snapshots the room's connections.
+func (r *Room) ClientDetails() []ClientInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clients := make([]ClientInfo, 0, len(r.Connections))
+	for _, c := range r.Connections {
+		clients = append(clients, ClientInfo{
+			ID:          c.ID,
+			Username:    c.Username,
+			IsHost:      c.IsHost,
+			JoinedAt:    c.JoinedAt,
+			Fingerprint: c.Fingerprint,
+		})
+	}
+	return clients
+}
+
+// HasTag reports whether any tag starts with query (case-insensitive), so
+// "ja" finds rooms tagged "java" and "javascript".
+func (i RoomInfo) HasTag(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+	for _, t := range i.Tags {
+		if strings.HasPrefix(t, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Touch marks the room as active, pushing back its idle deadline.
+func (r *Room) Touch() {
+	r.lastActive.Store(time.Now().UnixNano())
+	r.expiryWarned.Store(false)
+}
+
+// LastActive returns when the room last saw activity.
+func (r *Room) LastActive() time.Time {
+	return time.Unix(0, r.lastActive.Load())
+}
+
+// expiresAt returns the earliest deadline imposed by the limits and why.
+// ok is false when neither a lifetime nor an idle timeout applies.
+func (r *Room) expiresAt(limits Limits) (deadline time.Time, reason string, ok bool) {
+	if limits.MaxLifetime > 0 {
+		deadline, reason, ok = r.CreatedAt.Add(limits.MaxLifetime), "max lifetime reached", true
+	}
+	if limits.IdleTimeout > 0 {
+		idle := r.LastActive().Add(limits.IdleTimeout)
+		if !ok || idle.Before(deadline) {
+			deadline, reason, ok = idle, "idle timeout", true
+		}
+	}
+	return
+}
+
+// closeAll delivers a final event to every client, closes their channels
+// and empties the room.
+func (r *Room) closeAll(event RoomEvent) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	for _, c := range append(r.Connections, r.Pending...) {
+		if c.Events != nil {
+			sendFinal(c.Events, event)
+			close(c.Events)
+		}
+	}
+	r.Connections = nil
+	r.Pending = nil
+}
+
+// sendFinal queues the last event a client will get before its channel is
+// closed, dropping its oldest unread event if the buffer is full, so the
+// client learns why it was disconnected rather than seeing the channel just
+// close. Callers must hold r.mu for writing, which keeps other senders out.
+func sendFinal(ch chan RoomEvent, event RoomEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// AddClient registers a connection, replacing any previous one with the same
+// ID. It returns ErrRoomFull when the room is at capacity.
+func (r *Room) AddClient(client *Client) error {
+	r.Touch()
+
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.addClientLocked(client); err != nil {
+		return err
+	}
+	r.detachedAt.Store(0)
+	return nil
+}
+
+// fullLocked reports whether the room is at capacity, counting browsers
+// watching it as well as connected people.
+func (r *Room) fullLocked() bool {
+	return r.MaxClients > 0 && len(r.Connections)+r.webViewers >= r.MaxClients
+}
+
+func (r *Room) addClientLocked(client *Client) error {
+	if err := r.checkBansLocked(client); err != nil {
+		return err
+	}
+
+	var previous *Client
+	for _, c := range r.Connections {
+		if c.ID == client.ID {
+			previous = c
+			break
+		}
+	}
+	if previous == nil && r.fullLocked() {
+		return ErrRoomFull
+	}
+
+	// A rejoining client takes over its old connection (e.g. after an SSH
+	// drop) or, within the grace window, the role it had when it left.
+	reclaimHost := false
+	if client.Rejoin {
+		if previous != nil {
+			client.IsHost = previous.IsHost
+			client.JoinedAt = previous.JoinedAt
+		} else if d, ok := r.departed[client.ID]; ok && SameUser(d.username, client.Username) && time.Since(d.leftAt) <= r.RejoinGrace {
+			reclaimHost = d.isHost
+			client.IsHost = false
+			client.Username = d.username // back under the name they had, if it's still free
+		} else {
+			return ErrRejoinExpired
+		}
+		delete(r.departed, client.ID)
+	}
+
 	for i, c := range r.Connections {
 		if c.ID == client.ID {
 			if c.Events != nil {
+				sendFinal(c.Events, RoomEvent{Type: "taken_over", Username: c.Username})
 				close(c.Events)
 			}
 			r.Connections = remove(r.Connections, i)
@@ -52,7 +334,25 @@ func (r *Room) AddClient(client *Client) {
 		}
 	}
 
+	if client.JoinedAt.IsZero() {
+		client.JoinedAt = time.Now()
+	}
+	client.lastSeen.Store(time.Now().UnixNano())
+	if previous == nil {
+		client.Username = r.uniqueUsernameLocked(client.Username)
+		r.replayHistory(client)
+	} else {
+		client.Username = previous.Username
+	}
 	r.Connections = append(r.Connections, client)
+	if previous == nil {
+		r.logEvent(client.Username + " joined")
+		r.history.push(RoomEvent{Type: "join", Username: client.Username})
+	}
+
+	if reclaimHost {
+		r.setHostLocked(client)
+	}
 
 	for _, c := range r.Connections {
 		if c.ID != client.ID && c.Events != nil {
@@ -62,16 +362,61 @@ func (r *Room) AddClient(client *Client) {
 			}
 		}
 	}
+	return nil
+}
+
+// uniqueUsernameLocked returns name, or name-2, name-3 and so on if
+// someone in the room already goes by it, so commands like kick and the
+// participant list can tell people apart.
+func (r *Room) uniqueUsernameLocked(name string) string {
+	taken := make(map[string]bool, len(r.Connections))
+	for _, c := range r.Connections {
+		taken[c.Username] = true
+	}
+	unique := name
+	for n := 2; taken[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+	return unique
+}
+
+// SameUser reports whether username, as shown in a room, belongs to the
+// person who connected as name: it is name, or name with the suffix
+// uniqueUsernameLocked gave it.
+func SameUser(username, name string) bool {
+	if username == name {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(username, name+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+	_, err := strconv.Atoi(suffix)
+	return err == nil
 }
 
 func (r *Room) RemoveClient(clientID string) {
+	defer r.changed()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	var removedUsername string
+	var wasHost bool
 	for i, c := range r.Connections {
 		if c.ID == clientID {
 			removedUsername = c.Username
+			wasHost = c.IsHost
+			if r.RejoinGrace > 0 {
+				if r.departed == nil {
+					r.departed = make(map[string]departedClient)
+				}
+				for id, d := range r.departed {
+					if time.Since(d.leftAt) > r.RejoinGrace {
+						delete(r.departed, id)
+					}
+				}
+				r.departed[c.ID] = departedClient{username: c.Username, isHost: c.IsHost, leftAt: time.Now()}
+			}
 			if c.Events != nil {
 				close(c.Events)
 			}
@@ -81,6 +426,8 @@ func (r *Room) RemoveClient(clientID string) {
 	}
 
 	if removedUsername != "" {
+		r.logEvent(removedUsername + " left")
+		r.history.push(RoomEvent{Type: "leave", Username: removedUsername})
 		for _, c := range r.Connections {
 			if c.Events != nil {
 				select {
@@ -90,9 +437,129 @@ func (r *Room) RemoveClient(clientID string) {
 			}
 		}
 	}
+
+	// Don't leave the room ownerless: promote the longest-connected guest
+	if wasHost && len(r.Connections) > 0 {
+		successor := r.Connections[0]
+		for _, c := range r.Connections[1:] {
+			if c.JoinedAt.Before(successor.JoinedAt) {
+				successor = c
+			}
+		}
+		r.setHostLocked(successor)
+	}
+}
+
+// Departed reports whether the room is keeping clientID's place for a
+// rejoin: it left, rather than being removed, within the grace window.
+func (r *Room) Departed(clientID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.departed[clientID]
+	return ok && time.Since(d.leftAt) <= r.RejoinGrace
+}
+
+// TransferHost hands host privileges to the named user.
+func (r *Room) TransferHost(username string) error {
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.Connections {
+		if c.Username == username {
+			r.setHostLocked(c)
+			return nil
+		}
+	}
+	return ErrClientNotFound
+}
+
+// setHostLocked makes c the sole host and tells every client via a
+// "host_changed" event carrying the new host's client ID. Callers must hold r.mu.
+func (r *Room) setHostLocked(host *Client) {
+	r.Host = host.Username
+	r.hostFingerprint = host.Fingerprint
+	r.logEvent(host.Username + " is now the host")
+	r.history.push(RoomEvent{Type: "host_changed", Username: host.Username, Data: host.ID})
+	for _, c := range r.Connections {
+		c.IsHost = c.ID == host.ID
+	}
+
+	for _, c := range r.Connections {
+		if c.Events != nil {
+			select {
+			case c.Events <- RoomEvent{Type: "host_changed", Username: host.Username, Data: host.ID}:
+			default:
+			}
+		}
+	}
+}
+
+// Kick removes every connection belonging to username. The kicked client
+// receives a "kicked" event before its channel is closed and the remaining
+// clients see a "leave" event marked as a kick.
+func (r *Room) Kick(username string) error {
+	return r.kick(username, "kicked")
+}
+
+// kick is Kick with the reason the kicked client is given.
+func (r *Room) kick(username, reason string) error {
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if username == r.Host {
+		return ErrCannotKickHost
+	}
+
+	kicked := false
+	for i := 0; i < len(r.Connections); {
+		c := r.Connections[i]
+		if c.Username != username {
+			i++
+			continue
+		}
+		if c.Events != nil {
+			sendFinal(c.Events, RoomEvent{Type: "kicked", Username: username, Data: reason})
+			close(c.Events)
+		}
+		r.Connections = remove(r.Connections, i)
+		kicked = true
+	}
+	if !kicked {
+		return ErrClientNotFound
+	}
+	r.logEvent(username + " was kicked")
+	r.history.push(RoomEvent{Type: "leave", Username: username, Data: "kicked"})
+
+	for _, c := range r.Connections {
+		if c.Events != nil {
+			select {
+			case c.Events <- RoomEvent{Type: "leave", Username: username, Data: "kicked"}:
+			default:
+			}
+		}
+	}
+	return nil
 }
 
 func (r *Room) BroadcastEvent(event RoomEvent, excludeClientID string) {
+	r.Touch()
+	r.history.push(event)
+	if event.Type == "typing" { // sent on every keystroke; not worth a span
+		r.notify(event, excludeClientID)
+		return
+	}
+	_, span := tracing.Start(context.Background(), "room.broadcast",
+		attribute.String("room.id", r.ID), attribute.String("event.type", event.Type))
+	sent, dropped := r.notify(event, excludeClientID)
+	span.SetAttributes(attribute.Int("recipients", sent), attribute.Int("dropped", dropped))
+	span.End()
+}
+
+// notify fans an event out to clients without counting it as activity,
+// returning how many got it and how many had full queues and missed it.
+func (r *Room) notify(event RoomEvent, excludeClientID string) (sent, dropped int) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -100,10 +567,90 @@ func (r *Room) BroadcastEvent(event RoomEvent, excludeClientID string) {
 		if c.ID != excludeClientID && c.Events != nil {
 			select {
 			case c.Events <- event:
+				sent++
 			default:
+				dropped++
 			}
 		}
 	}
+	return sent, dropped
+}
+
+// GetDescription returns the room's current description.
+func (r *Room) GetDescription() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Description
+}
+
+// SetDescription renames the room and sends a "metadata" event to every
+// client, including by, so sidebars pick up the new text.
+func (r *Room) SetDescription(desc, by string) error {
+	desc = strings.TrimSpace(desc)
+	if len(desc) > maxDescriptionLen {
+		return ErrDescTooLong
+	}
+
+	r.mu.Lock()
+	r.Description = desc
+	if desc == "" {
+		r.logEvent(by + " cleared the room description")
+	} else {
+		r.logEvent(fmt.Sprintf("%s renamed the room to %q", by, desc))
+	}
+	r.mu.Unlock()
+
+	r.changed()
+	r.BroadcastEvent(RoomEvent{Type: "metadata", Username: by, Data: desc}, "")
+	return nil
+}
+
+// ReclaimsHost reports whether someone connecting as username with the
+// key fingerprint may take back the host role of a room whose host isn't
+// connected, e.g. after a restart or a detach. Names can be chosen
+// freely, so it must also be the host's key; a host without one can only
+// come back with a rejoin token.
+func (r *Room) ReclaimsHost(username, fingerprint string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if fingerprint == "" || fingerprint != r.hostFingerprint || !SameUser(r.Host, username) {
+		return false
+	}
+	for _, c := range r.Connections {
+		if c.IsHost {
+			return false
+		}
+	}
+	return true
+}
+
+// HostPresent reports whether a host is currently connected.
+func (r *Room) HostPresent() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.Connections {
+		if c.IsHost {
+			return true
+		}
+	}
+	return false
+}
+
+// logEvent appends a room event line to the transcript, if any, and the
+// server log.
+func (r *Room) logEvent(text string) {
+	if r.Transcript != nil {
+		r.Transcript.Add(transcript.KindEvent, "", text)
+	}
+	r.Logger().Info(text)
+}
+
+// Logger returns the room's logger, whose lines carry the room ID.
+func (r *Room) Logger() *log.Logger {
+	if r.logger == nil {
+		return log.Default().With("roomID", r.ID)
+	}
+	return r.logger
 }
 
 // https://stackoverflow.com/questions/37334119/how-to-delete-an-element-from-a-slice-in-golang
@@ -127,11 +674,95 @@ func (r *Room) ClientCount() int {
 }
 
 func (r *Room) SetAIMessages(msgs []AIMessage) {
+	defer r.changed()
 	r.mu.Lock()
 	defer r.mu.Unlock()
+
+	// the worker caps history, so diff by timestamp rather than length
+	if r.Transcript != nil {
+		var lastTs int64
+		if n := len(r.AIMessages); n > 0 {
+			lastTs = r.AIMessages[n-1].Ts
+		}
+		for _, msg := range msgs {
+			if msg.Ts <= lastTs {
+				continue
+			}
+			who := msg.UserID
+			if msg.Role != "user" {
+				who = "AI"
+			}
+			r.Transcript.Add(transcript.KindAI, who, msg.Text)
+		}
+	}
 	r.AIMessages = msgs
 }
 
+// SetEnv defines or replaces a shared environment variable.
+func (r *Room) SetEnv(key, value string) error {
+	if !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidEnvKey, key)
+	}
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Env == nil {
+		r.Env = make(map[string]string)
+	}
+	r.Env[key] = value
+	return nil
+}
+
+// UnsetEnv removes a shared environment variable.
+func (r *Room) UnsetEnv(key string) error {
+	if !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("%w: %q", ErrInvalidEnvKey, key)
+	}
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.Env, key)
+	return nil
+}
+
+// GetEnv returns a copy of the shared environment variables.
+func (r *Room) GetEnv() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	env := make(map[string]string, len(r.Env))
+	for k, v := range r.Env {
+		env[k] = v
+	}
+	return env
+}
+
+// EnvList returns the shared environment as sorted KEY=VALUE pairs,
+// suitable for exec.Cmd.Env.
+func (r *Room) EnvList() []string {
+	env := r.GetEnv()
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// ExportCommand builds a shell line that re-exports the shared environment
+// into an already running shell. Returns "" when no variables are set.
+func (r *Room) ExportCommand() string {
+	list := r.EnvList()
+	if len(list) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(list))
+	for _, kv := range list {
+		k, v, _ := strings.Cut(kv, "=")
+		parts = append(parts, k+"='"+strings.ReplaceAll(v, "'", `'\''`)+"'")
+	}
+	return "export " + strings.Join(parts, " ")
+}
+
 func (r *Room) GetAIMessages() []AIMessage {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
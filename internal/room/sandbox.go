@@ -0,0 +1,153 @@
+package room
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sandboxSleepAfter is how long the worker's sandbox stays up without
+// commands before it's put to sleep and the next one has to cold start.
+const sandboxSleepAfter = 10 * time.Minute
+
+// maxSandboxHistory caps the shared list of sandbox commands.
+const maxSandboxHistory = 100
+
+// SandboxState is what the room knows about its sandbox, from the commands
+// run in it. Name is empty until the first one.
+type SandboxState struct {
+	Name     string
+	LastUsed time.Time
+}
+
+// Warm reports whether the sandbox is likely still up, so the next command
+// won't wait for a cold start.
+func (s SandboxState) Warm() bool {
+	return !s.LastUsed.IsZero() && time.Since(s.LastUsed) < sandboxSleepAfter
+}
+
+// SandboxJob is a sandbox command someone in the room is waiting on.
+type SandboxJob struct {
+	ID      string
+	Cmd     string
+	By      string
+	Started time.Time
+
+	cancel context.CancelFunc
+}
+
+// StartSandboxJob records a running sandbox command so anyone in the room
+// can see it and kill it. cancel aborts the request waiting on it.
+func (r *Room) StartSandboxJob(cmd, by string, cancel context.CancelFunc) SandboxJob {
+	job := SandboxJob{
+		ID:      uuid.New().String()[:8],
+		Cmd:     cmd,
+		By:      by,
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+	r.sandboxRuns.Add(1)
+	r.mu.Lock()
+	r.sandboxJobs = append(r.sandboxJobs, job)
+	r.mu.Unlock()
+	return job
+}
+
+// FinishSandboxJob forgets a job once its result is in.
+func (r *Room) FinishSandboxJob(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, j := range r.sandboxJobs {
+		if j.ID == id {
+			r.sandboxJobs = append(r.sandboxJobs[:i:i], r.sandboxJobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// CancelSandboxJob aborts the job with id, or the newest one when id is
+// empty, and tells the room with a "sandbox_cancelled" event. The caller
+// still has to ask the sandbox itself to stop the command.
+func (r *Room) CancelSandboxJob(id, by string) (SandboxJob, error) {
+	r.mu.Lock()
+	var job SandboxJob
+	found := false
+	for i := len(r.sandboxJobs) - 1; i >= 0; i-- {
+		if id == "" || r.sandboxJobs[i].ID == id {
+			job, found = r.sandboxJobs[i], true
+			r.sandboxJobs = append(r.sandboxJobs[:i:i], r.sandboxJobs[i+1:]...)
+			break
+		}
+	}
+	r.mu.Unlock()
+	if !found {
+		return SandboxJob{}, ErrNoSandboxJob
+	}
+
+	job.cancel()
+	r.logEvent(by + " killed sandbox command: " + job.Cmd)
+	r.BroadcastEvent(RoomEvent{Type: "sandbox_cancelled", Username: by, Data: job.Cmd}, "")
+	return job, nil
+}
+
+// SandboxJobs returns the sandbox commands still running, oldest first.
+func (r *Room) SandboxJobs() []SandboxJob {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]SandboxJob(nil), r.sandboxJobs...)
+}
+
+// NoteSandboxUsed records that a command just ran in the sandbox called name.
+func (r *Room) NoteSandboxUsed(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name != "" {
+		r.sandbox.Name = name
+	}
+	r.sandbox.LastUsed = time.Now()
+}
+
+// Sandbox returns the room's sandbox state.
+func (r *Room) Sandbox() SandboxState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sandbox
+}
+
+// SandboxReset marks the sandbox cold after it was destroyed and tells the
+// room with a "sandbox_reset" event. Running jobs died with it.
+func (r *Room) SandboxReset(by string) {
+	r.mu.Lock()
+	r.sandbox.LastUsed = time.Time{}
+	jobs := r.sandboxJobs
+	r.sandboxJobs = nil
+	r.mu.Unlock()
+
+	for _, j := range jobs {
+		j.cancel()
+	}
+	r.logEvent(by + " reset the sandbox")
+	r.BroadcastEvent(RoomEvent{Type: "sandbox_reset", Username: by}, "")
+}
+
+// AddSandboxCommand appends cmd to the room's shared sandbox history,
+// skipping a repeat of the previous command.
+func (r *Room) AddSandboxCommand(cmd string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n := len(r.sandboxHistory); n > 0 && r.sandboxHistory[n-1] == cmd {
+		return
+	}
+	r.sandboxHistory = append(r.sandboxHistory, cmd)
+	if len(r.sandboxHistory) > maxSandboxHistory {
+		r.sandboxHistory = r.sandboxHistory[len(r.sandboxHistory)-maxSandboxHistory:]
+	}
+}
+
+// SandboxHistory returns the sandbox commands run in the room, oldest first.
+func (r *Room) SandboxHistory() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.sandboxHistory...)
+}
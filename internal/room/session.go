@@ -0,0 +1,81 @@
+package room
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaypopat/duet/internal/terminal"
+)
+
+// usersFile is where the room keeps its member list for shells, relative
+// to the workspace so it's visible inside containers too.
+const usersFile = ".duet/users"
+
+// SessionInfo describes the room for the DUET_* variables in its shells.
+func (r *Room) SessionInfo() terminal.SessionInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info := terminal.SessionInfo{
+		RoomID:      r.ID,
+		Description: r.Description,
+		Host:        r.Host,
+		Users:       r.usernamesLocked(),
+	}
+	if r.WorkspaceDir != "" {
+		info.UsersFile = filepath.Join(r.WorkspaceDir, usersFile)
+	}
+	return info
+}
+
+func (r *Room) usernamesLocked() []string {
+	names := make([]string, 0, len(r.Connections))
+	for _, c := range r.Connections {
+		names = append(names, c.Username)
+	}
+	return names
+}
+
+// writeUsersFile refreshes DUET_USERS_FILE. Running shells can't see env
+// changes, so this is how they follow joins and leaves. Failures only cost
+// scripts a stale list, so they're ignored.
+func (r *Room) writeUsersFile() {
+	r.mu.RLock()
+	dir := r.WorkspaceDir
+	names := r.usernamesLocked()
+	r.mu.RUnlock()
+	if dir == "" {
+		return
+	}
+
+	// room members control the workspace, so never follow a symlink they
+	// planted at .duet to write outside it
+	path := filepath.Join(dir, usersFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	if info, err := os.Lstat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return
+	}
+	var data string
+	if len(names) > 0 {
+		data = strings.Join(names, "\n") + "\n"
+	}
+	// write a fresh temp file (O_EXCL, so not one planted for us) then rename
+	// it over the list, so readers never see it half-written
+	tmp, err := os.CreateTemp(filepath.Dir(path), "users-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.WriteString(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
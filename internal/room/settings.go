@@ -0,0 +1,251 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaypopat/duet/internal/container"
+	"github.com/jaypopat/duet/internal/terminal"
+)
+
+// RoomSettings controls how a room's shared shell is launched. Zero fields
+// fall back to the manager's defaults, which come from server flags.
+type RoomSettings struct {
+	Shell string            // shell command line, e.g. "zsh" or "docker compose exec app bash"; empty uses $SHELL
+	Dir   string            // starting directory, relative to the workspace unless absolute
+	Env   map[string]string // seeded into the room's shared environment
+
+	Templates map[string]string // AI prompt templates by name, see ExpandTemplate
+
+	Scrollback int // terminal history lines; 0 uses the terminal default
+	FrameRate  int // max terminal updates per second; 0 uses the terminal default
+
+	// TmuxSession, if set, makes the main terminal attach to this existing
+	// tmux session instead of starting a shell. Needs Limits.AllowTmux.
+	TmuxSession string
+}
+
+// withDefaults fills empty fields from d. Env entries in s win over d.
+func (s RoomSettings) withDefaults(d RoomSettings) RoomSettings {
+	if s.Shell == "" {
+		s.Shell = d.Shell
+	}
+	if s.Dir == "" {
+		s.Dir = d.Dir
+	}
+	if s.Scrollback == 0 {
+		s.Scrollback = d.Scrollback
+	}
+	if s.FrameRate == 0 {
+		s.FrameRate = d.FrameRate
+	}
+	env := make(map[string]string, len(d.Env)+len(s.Env))
+	for k, v := range d.Env {
+		env[k] = v
+	}
+	for k, v := range s.Env {
+		env[k] = v
+	}
+	s.Env = env
+	templates := make(map[string]string, len(d.Templates)+len(s.Templates))
+	for k, v := range d.Templates {
+		templates[k] = v
+	}
+	for k, v := range s.Templates {
+		templates[k] = v
+	}
+	s.Templates = templates
+	return s
+}
+
+// validate checks the shell can be found, the env keys are usable and any
+// tmux session exists (and is allowed). onHost is false when shells run in
+// containers, where the host's $PATH says nothing about the image.
+func (s RoomSettings) validate(allowTmux, onHost bool) error {
+	if s.TmuxSession != "" {
+		if !allowTmux {
+			return ErrTmuxDisabled
+		}
+		if err := exec.Command("tmux", "has-session", "-t", "="+s.TmuxSession).Run(); err != nil {
+			return fmt.Errorf("%w: %q", ErrNoTmuxSession, s.TmuxSession)
+		}
+	}
+	if s.Shell != "" {
+		argv, err := terminal.ParseCommand(s.Shell)
+		if err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrInvalidShell, s.Shell, err)
+		}
+		if _, err := exec.LookPath(argv[0]); onHost && err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidShell, argv[0])
+		}
+	}
+	for k := range s.Env {
+		if !envKeyPattern.MatchString(k) {
+			return fmt.Errorf("%w: %q", ErrInvalidEnvKey, k)
+		}
+	}
+	for name, text := range s.Templates {
+		if err := validateTemplate(name, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellAllowed reports whether a host may pick shell for their room. The
+// server's default is always fine; anything else has to be on the server's
+// allowlist or, without one, be a login shell listed in /etc/shells.
+func (m *Manager) shellAllowed(shell string) bool {
+	if shell == "" || shell == m.defaults.Shell {
+		return true
+	}
+	argv, err := terminal.ParseCommand(shell)
+	if err != nil {
+		return false
+	}
+	want := strings.Join(argv, " ")
+
+	if len(m.limits.AllowedShells) > 0 {
+		for _, allowed := range m.limits.AllowedShells {
+			if a, err := terminal.ParseCommand(allowed); err == nil && strings.Join(a, " ") == want {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(argv) != 1 {
+		return false
+	}
+	data, err := os.ReadFile("/etc/shells")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == want || filepath.Base(line) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDir turns s.Dir into an absolute path. Relative paths must stay
+// inside the workspace and are created if missing; absolute ones must exist.
+func (s RoomSettings) resolveDir(workspaceDir string) (string, error) {
+	dir := strings.TrimSpace(s.Dir)
+	if dir == "" {
+		return workspaceDir, nil
+	}
+
+	if filepath.IsAbs(dir) {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDir, dir)
+		}
+		return filepath.Clean(dir), nil
+	}
+
+	full := filepath.Join(workspaceDir, dir)
+	if rel, err := filepath.Rel(workspaceDir, full); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%w: %q escapes the workspace", ErrInvalidDir, dir)
+	}
+	if err := os.MkdirAll(full, 0755); err != nil {
+		return "", fmt.Errorf("create start directory: %w", err)
+	}
+	return full, nil
+}
+
+// WorkspaceFile resolves name, relative to the room's workspace, to a path
+// that stays inside it even after following symlinks, since room members
+// can plant those. Absolute paths and ".." are refused outright.
+func (r *Room) WorkspaceFile(name string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%w: %q", ErrOutsideWorkspace, name)
+	}
+	root, err := filepath.EvalSymlinks(r.WorkspaceDir)
+	if err != nil {
+		return "", err
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, full); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %q", ErrOutsideWorkspace, name)
+	}
+	return full, nil
+}
+
+// CreateWorkspaceFile writes data to a new file name at the top of the
+// room's workspace. It refuses if anything is there already: O_EXCL never
+// follows a symlink, so one a member planted under a predictable name
+// can't redirect the write outside the workspace.
+func (r *Room) CreateWorkspaceFile(name string, data []byte) error {
+	if !filepath.IsLocal(name) || filepath.Base(name) != name {
+		return fmt.Errorf("%w: %q", ErrOutsideWorkspace, name)
+	}
+	f, err := os.OpenFile(filepath.Join(r.WorkspaceDir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("./%s already exists", name)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ParseEnvAssignments parses space separated KEY=VALUE pairs, as typed into
+// the create form or passed on the command line.
+func ParseEnvAssignments(s string) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, field := range strings.Fields(s) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEnvKey, field)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// LaunchSettings returns how to start the room's terminals, with Dir
+// resolved to an absolute path. A start directory that has since
+// disappeared falls back to the workspace. Env is left empty; use EnvList.
+func (r *Room) LaunchSettings() RoomSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dir := r.StartDir
+	if dir == "" {
+		dir = r.WorkspaceDir
+	} else if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		dir = r.WorkspaceDir
+	}
+	return RoomSettings{
+		Shell:       r.Shell,
+		Dir:         dir,
+		Scrollback:  r.Scrollback,
+		FrameRate:   r.FrameRate,
+		TmuxSession: r.TmuxSession,
+	}
+}
+
+// Container returns the container the room's shells run in, or nil when
+// they run on the host.
+func (r *Room) Container() *container.Container {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.container
+}
@@ -0,0 +1,432 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS rooms (
+	id               TEXT PRIMARY KEY,
+	description      TEXT NOT NULL DEFAULT '',
+	host             TEXT NOT NULL DEFAULT '',
+	workspace_dir    TEXT NOT NULL DEFAULT '',
+	env              TEXT NOT NULL DEFAULT '{}',
+	max_clients      INTEGER NOT NULL DEFAULT 0,
+	require_approval INTEGER NOT NULL DEFAULT 0,
+	created_at       INTEGER NOT NULL,
+	host_name        TEXT NOT NULL DEFAULT '',
+	tags             TEXT NOT NULL DEFAULT '[]',
+	start_dir        TEXT NOT NULL DEFAULT '',
+	shell            TEXT NOT NULL DEFAULT '',
+	tmux_session     TEXT NOT NULL DEFAULT '',
+	passthrough      INTEGER NOT NULL DEFAULT 0,
+	templates        TEXT NOT NULL DEFAULT '{}',
+	pinned           TEXT NOT NULL DEFAULT '[]',
+	node             TEXT NOT NULL DEFAULT '',
+	node_addr        TEXT NOT NULL DEFAULT '',
+	epoch            INTEGER NOT NULL DEFAULT 0,
+	host_fingerprint TEXT NOT NULL DEFAULT '',
+	public           INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS nodes (
+	id          TEXT PRIMARY KEY,
+	addr        TEXT NOT NULL DEFAULT '',
+	lease_until INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS ai_messages (
+	room_id TEXT NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+	seq     INTEGER NOT NULL,
+	role    TEXT NOT NULL,
+	user_id TEXT NOT NULL DEFAULT '',
+	text    TEXT NOT NULL,
+	ts      INTEGER NOT NULL,
+	PRIMARY KEY (room_id, seq)
+);
+CREATE TABLE IF NOT EXISTS profiles (
+	fingerprint  TEXT PRIMARY KEY,
+	display_name TEXT NOT NULL DEFAULT '',
+	color        TEXT NOT NULL DEFAULT '',
+	theme        TEXT NOT NULL DEFAULT '',
+	keymap       TEXT NOT NULL DEFAULT '',
+	updated_at   INTEGER NOT NULL
+);`
+
+// sqliteMigrations upgrade databases created by older versions. Each one may
+// fail with "duplicate column" or "no such column" on a fresh schema, which
+// is ignored.
+var sqliteMigrations = []string{
+	`ALTER TABLE rooms ADD COLUMN host_name TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rooms ADD COLUMN start_dir TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN shell TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN tmux_session TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN passthrough INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE rooms ADD COLUMN templates TEXT NOT NULL DEFAULT '{}'`,
+	`ALTER TABLE rooms ADD COLUMN pinned TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE rooms ADD COLUMN node TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN node_addr TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN epoch INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE rooms ADD COLUMN host_fingerprint TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE rooms ADD COLUMN public INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE rooms DROP COLUMN members`,
+}
+
+// SQLiteStore is a Store backed by a single SQLite database file.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (creating if needed) the database at path.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// sqlite serialises writers anyway; one connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	// rooms' shared env is kept as plain text, and may hold tokens
+	if err := os.Chmod(path, 0o600); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("restrict database: %w", err)
+	}
+	for _, stmt := range sqliteMigrations {
+		if _, err := db.Exec(stmt); err != nil && !strings.Contains(err.Error(), "duplicate column") && !strings.Contains(err.Error(), "no such column") {
+			db.Close()
+			return nil, fmt.Errorf("migrate schema: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) SaveRoom(rec RoomRecord) error {
+	env, err := json.Marshal(rec.Env)
+	if err != nil {
+		return fmt.Errorf("marshal env: %w", err)
+	}
+	tags, err := json.Marshal(rec.Tags)
+	if err != nil {
+		return fmt.Errorf("marshal tags: %w", err)
+	}
+	templates, err := json.Marshal(rec.Templates)
+	if err != nil {
+		return fmt.Errorf("marshal templates: %w", err)
+	}
+	pinned, err := json.Marshal(rec.Pinned)
+	if err != nil {
+		return fmt.Errorf("marshal pinned: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the epoch fences the write: a node that lost the room to another
+	// changes nothing
+	res, err := tx.Exec(`
+		INSERT INTO rooms (id, description, host, workspace_dir, env, max_clients, require_approval, created_at, host_name, tags, start_dir, shell, tmux_session, passthrough, templates, pinned, node, node_addr, epoch, host_fingerprint, public)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			description = excluded.description,
+			host = excluded.host,
+			host_name = excluded.host_name,
+			host_fingerprint = excluded.host_fingerprint,
+			tags = excluded.tags,
+			workspace_dir = excluded.workspace_dir,
+			start_dir = excluded.start_dir,
+			shell = excluded.shell,
+			tmux_session = excluded.tmux_session,
+			env = excluded.env,
+			max_clients = excluded.max_clients,
+			require_approval = excluded.require_approval,
+			passthrough = excluded.passthrough,
+			public = excluded.public,
+			templates = excluded.templates,
+			pinned = excluded.pinned,
+			node = excluded.node,
+			node_addr = excluded.node_addr
+		WHERE rooms.epoch = excluded.epoch`,
+		rec.ID, rec.Description, rec.Host, rec.WorkspaceDir, string(env),
+		rec.MaxClients, rec.RequireApproval, rec.CreatedAt.UnixNano(),
+		rec.HostName, string(tags), rec.StartDir, rec.Shell, rec.TmuxSession, rec.Passthrough, string(templates), string(pinned),
+		rec.Node, rec.NodeAddr, rec.Epoch, rec.HostFingerprint, rec.Public,
+	)
+	if err != nil {
+		return fmt.Errorf("save room: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrRoomMoved
+	}
+
+	if err := saveAIMessages(tx, rec.ID, rec.AIMessages); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveAIMessages brings the stored AI history up to msgs without rewriting
+// it, as rooms are saved on every change: the worker only appends to the
+// history and drops its oldest messages, so usually the stored messages
+// are msgs with a few more at the front and a few missing at the end.
+// Anything else, such as the worker folding old messages into a summary,
+// rewrites the lot.
+func saveAIMessages(tx *sql.Tx, roomID string, msgs []AIMessage) error {
+	type stored struct {
+		seq    int64
+		role   string
+		userID string
+		ts     int64
+	}
+	rows, err := tx.Query(`SELECT seq, role, user_id, ts FROM ai_messages WHERE room_id = ? ORDER BY seq`, roomID)
+	if err != nil {
+		return fmt.Errorf("query ai messages: %w", err)
+	}
+	var have []stored
+	for rows.Next() {
+		var st stored
+		if err := rows.Scan(&st.seq, &st.role, &st.userID, &st.ts); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan ai message: %w", err)
+		}
+		have = append(have, st)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query ai messages: %w", err)
+	}
+
+	same := func(st stored, msg AIMessage) bool {
+		return st.role == msg.Role && st.userID == msg.UserID && st.ts == msg.Ts
+	}
+	// drop the stored messages before the first one from which the rest
+	// agree with the start of msgs; with none such, drop them all
+	drop := len(have)
+	for i := range have {
+		if len(have)-i > len(msgs) {
+			continue
+		}
+		match := true
+		for j := range have[i:] {
+			if !same(have[i+j], msgs[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			drop = i
+			break
+		}
+	}
+
+	next := int64(0)
+	if len(have) > 0 {
+		next = have[len(have)-1].seq + 1
+	}
+	if drop > 0 {
+		if _, err := tx.Exec(`DELETE FROM ai_messages WHERE room_id = ? AND seq <= ?`, roomID, have[drop-1].seq); err != nil {
+			return fmt.Errorf("clear ai messages: %w", err)
+		}
+	}
+	kept := len(have) - drop
+	for i, msg := range msgs[kept:] {
+		if _, err := tx.Exec(
+			`INSERT INTO ai_messages (room_id, seq, role, user_id, text, ts) VALUES (?, ?, ?, ?, ?, ?)`,
+			roomID, next+int64(i), msg.Role, msg.UserID, msg.Text, msg.Ts,
+		); err != nil {
+			return fmt.Errorf("insert ai message: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteRoom(roomID string) error {
+	if _, err := s.db.Exec(`DELETE FROM rooms WHERE id = ?`, roomID); err != nil {
+		return fmt.Errorf("delete room: %w", err)
+	}
+	return nil
+}
+
+// DeleteOwnedRoom deletes a room unless another node has claimed it
+// since epoch.
+func (s *SQLiteStore) DeleteOwnedRoom(roomID string, epoch int64) error {
+	if _, err := s.db.Exec(`DELETE FROM rooms WHERE id = ? AND epoch = ?`, roomID, epoch); err != nil {
+		return fmt.Errorf("delete room: %w", err)
+	}
+	return nil
+}
+
+// ClaimRoom makes node the room's host if nobody has claimed it since
+// epoch, and returns it with its new epoch.
+func (s *SQLiteStore) ClaimRoom(roomID, node, addr string, epoch int64) (RoomRecord, error) {
+	res, err := s.db.Exec(`UPDATE rooms SET node = ?, node_addr = ?, epoch = epoch + 1 WHERE id = ? AND epoch = ?`,
+		node, addr, roomID, epoch)
+	if err != nil {
+		return RoomRecord{}, fmt.Errorf("claim room: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return RoomRecord{}, ErrRoomMoved
+	}
+	return s.LoadRoom(roomID)
+}
+
+// RenewLease records that node is alive until until.
+func (s *SQLiteStore) RenewLease(node, addr string, until time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO nodes (id, addr, lease_until) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET addr = excluded.addr, lease_until = excluded.lease_until`,
+		node, addr, until.UnixNano())
+	if err != nil {
+		return fmt.Errorf("renew lease: %w", err)
+	}
+	return nil
+}
+
+// LeaseUntil is when node's lease runs out; the zero time if it never
+// took one.
+func (s *SQLiteStore) LeaseUntil(node string) (time.Time, error) {
+	var until int64
+	err := s.db.QueryRow(`SELECT lease_until FROM nodes WHERE id = ?`, node).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load lease: %w", err)
+	}
+	return time.Unix(0, until), nil
+}
+
+const selectRooms = `
+	SELECT id, description, host, workspace_dir, env, max_clients, require_approval, created_at, host_name, tags, start_dir, shell, tmux_session, passthrough, templates, pinned, node, node_addr, epoch, host_fingerprint, public
+	FROM rooms`
+
+func (s *SQLiteStore) LoadRooms() ([]RoomRecord, error) {
+	return s.queryRooms(selectRooms)
+}
+
+// LoadRoom fetches one room, so servers sharing the database can find
+// rooms the others host.
+func (s *SQLiteStore) LoadRoom(roomID string) (RoomRecord, error) {
+	recs, err := s.queryRooms(selectRooms+` WHERE id = ?`, roomID)
+	if err != nil {
+		return RoomRecord{}, err
+	}
+	if len(recs) == 0 {
+		return RoomRecord{}, ErrRoomNotFound
+	}
+	return recs[0], nil
+}
+
+func (s *SQLiteStore) queryRooms(query string, args ...any) ([]RoomRecord, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var recs []RoomRecord
+	for rows.Next() {
+		var rec RoomRecord
+		var env, tags, templates, pinned string
+		var createdAt int64
+		if err := rows.Scan(&rec.ID, &rec.Description, &rec.Host, &rec.WorkspaceDir, &env,
+			&rec.MaxClients, &rec.RequireApproval, &createdAt, &rec.HostName, &tags, &rec.StartDir, &rec.Shell, &rec.TmuxSession, &rec.Passthrough, &templates, &pinned,
+			&rec.Node, &rec.NodeAddr, &rec.Epoch, &rec.HostFingerprint, &rec.Public); err != nil {
+			return nil, fmt.Errorf("scan room: %w", err)
+		}
+		if err := json.Unmarshal([]byte(env), &rec.Env); err != nil {
+			return nil, fmt.Errorf("unmarshal env for %s: %w", rec.ID, err)
+		}
+		if err := json.Unmarshal([]byte(tags), &rec.Tags); err != nil {
+			return nil, fmt.Errorf("unmarshal tags for %s: %w", rec.ID, err)
+		}
+		if err := json.Unmarshal([]byte(templates), &rec.Templates); err != nil {
+			return nil, fmt.Errorf("unmarshal templates for %s: %w", rec.ID, err)
+		}
+		if err := json.Unmarshal([]byte(pinned), &rec.Pinned); err != nil {
+			return nil, fmt.Errorf("unmarshal pinned for %s: %w", rec.ID, err)
+		}
+		rec.CreatedAt = time.Unix(0, createdAt)
+		recs = append(recs, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rooms: %w", err)
+	}
+
+	for i := range recs {
+		msgs, err := s.loadAIMessages(recs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		recs[i].AIMessages = msgs
+	}
+	return recs, nil
+}
+
+func (s *SQLiteStore) loadAIMessages(roomID string) ([]AIMessage, error) {
+	rows, err := s.db.Query(
+		`SELECT role, user_id, text, ts FROM ai_messages WHERE room_id = ? ORDER BY seq`, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("query ai messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []AIMessage
+	for rows.Next() {
+		var msg AIMessage
+		if err := rows.Scan(&msg.Role, &msg.UserID, &msg.Text, &msg.Ts); err != nil {
+			return nil, fmt.Errorf("scan ai message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) LoadProfile(fingerprint string) (Profile, error) {
+	p := Profile{Fingerprint: fingerprint}
+	var updatedAt int64
+	err := s.db.QueryRow(`SELECT display_name, color, theme, keymap, updated_at FROM profiles WHERE fingerprint = ?`, fingerprint).
+		Scan(&p.DisplayName, &p.Color, &p.Theme, &p.Keymap, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Profile{}, ErrNoProfile
+	}
+	if err != nil {
+		return Profile{}, fmt.Errorf("load profile: %w", err)
+	}
+	p.UpdatedAt = time.Unix(0, updatedAt)
+	return p, nil
+}
+
+func (s *SQLiteStore) SaveProfile(p Profile) error {
+	_, err := s.db.Exec(`
+		INSERT INTO profiles (fingerprint, display_name, color, theme, keymap, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(fingerprint) DO UPDATE SET
+			display_name = excluded.display_name,
+			color = excluded.color,
+			theme = excluded.theme,
+			keymap = excluded.keymap,
+			updated_at = excluded.updated_at`,
+		p.Fingerprint, p.DisplayName, p.Color, p.Theme, p.Keymap, p.UpdatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("save profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package room
+
+// Stats are a room's running totals, for the admin dashboard.
+type Stats struct {
+	BytesIn    uint64 // typed into the room's shells
+	BytesOut   uint64 // printed by them
+	AIRequests int64  // questions sent to the AI
+	Sandbox    int64  // commands run in the sandbox
+}
+
+// NoteAIRequest counts a question sent to the AI from this room.
+func (r *Room) NoteAIRequest() {
+	r.aiRequests.Add(1)
+}
+
+// Stats totals the room's shell traffic and AI use. Traffic from closed
+// tabs drops out, so totals can shrink.
+func (r *Room) Stats() Stats {
+	r.mu.RLock()
+	s := Stats{
+		AIRequests: r.aiRequests.Load(),
+		Sandbox:    r.sandboxRuns.Load(),
+	}
+	for _, tab := range r.tabs {
+		in, out := tab.Terminal.Traffic()
+		s.BytesIn += in
+		s.BytesOut += out
+	}
+	r.mu.RUnlock()
+	return s
+}
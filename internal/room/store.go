@@ -0,0 +1,128 @@
+package room
+
+import (
+	"time"
+
+	"github.com/jaypopat/duet/internal/transcript"
+)
+
+// Store persists rooms so they survive server restarts. Terminals and live
+// connections are never persisted; a restored room gets a fresh shell on the
+// first join.
+type Store interface {
+	SaveRoom(rec RoomRecord) error
+	DeleteRoom(roomID string) error
+	LoadRooms() ([]RoomRecord, error)
+	Close() error
+}
+
+// RoomRecord is the persisted form of a Room.
+type RoomRecord struct {
+	ID              string
+	Description     string
+	Host            string
+	HostName        string
+	HostFingerprint string // the host's key, see Room.ReclaimsHost
+	Tags            []string
+	WorkspaceDir    string
+	StartDir        string
+	Shell           string
+	TmuxSession     string
+	Env             map[string]string
+	Templates       map[string]string
+	MaxClients      int
+	RequireApproval bool
+	Passthrough     bool
+	Public          bool
+	CreatedAt       time.Time
+	AIMessages      []AIMessage
+	Pinned          []PinnedMessage
+	Node            string // server hosting the room, see Manager.SetNode
+	NodeAddr        string // where to ssh to reach that server
+	Epoch           int64  // bumped each time a node claims the room, see ClusterStore
+}
+
+// record snapshots the persistable parts of the room.
+func (r *Room) record() RoomRecord {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	env := make(map[string]string, len(r.Env))
+	for k, v := range r.Env {
+		env[k] = v
+	}
+	templates := make(map[string]string, len(r.Templates))
+	for k, v := range r.Templates {
+		templates[k] = v
+	}
+
+	return RoomRecord{
+		ID:              r.ID,
+		Description:     r.Description,
+		Host:            r.Host,
+		HostName:        r.HostName,
+		HostFingerprint: r.hostFingerprint,
+		Tags:            append([]string(nil), r.Tags...),
+		WorkspaceDir:    r.WorkspaceDir,
+		StartDir:        r.StartDir,
+		Shell:           r.Shell,
+		TmuxSession:     r.TmuxSession,
+		Env:             env,
+		Templates:       templates,
+		MaxClients:      r.MaxClients,
+		RequireApproval: r.RequireApproval,
+		Passthrough:     r.Passthrough,
+		Public:          r.Public,
+		CreatedAt:       r.CreatedAt,
+		AIMessages:      append([]AIMessage(nil), r.AIMessages...),
+		Pinned:          append([]PinnedMessage(nil), r.Pinned...),
+		Epoch:           r.epoch,
+	}
+}
+
+// roomFromRecord rebuilds an empty (no connections, no terminal) room.
+func roomFromRecord(rec RoomRecord) *Room {
+	env := rec.Env
+	if env == nil {
+		env = make(map[string]string)
+	}
+	r := &Room{
+		ID:              rec.ID,
+		Description:     rec.Description,
+		Host:            rec.Host,
+		HostName:        rec.HostName,
+		hostFingerprint: rec.HostFingerprint,
+		Tags:            rec.Tags,
+		Connections:     make([]*Client, 0),
+		WorkspaceDir:    rec.WorkspaceDir,
+		StartDir:        rec.StartDir,
+		Shell:           rec.Shell,
+		TmuxSession:     rec.TmuxSession,
+		Env:             env,
+		Templates:       rec.Templates,
+		CreatedAt:       rec.CreatedAt,
+		MaxClients:      rec.MaxClients,
+		RequireApproval: rec.RequireApproval,
+		Passthrough:     rec.Passthrough,
+		Public:          rec.Public,
+		AIMessages:      rec.AIMessages,
+		Pinned:          rec.Pinned,
+		Transcript:      transcript.New(),
+		epoch:           rec.Epoch,
+	}
+	r.Touch()
+	return r
+}
+
+// changed tells the manager's store that persistable state moved on, and
+// refreshes the member list shells read from DUET_USERS_FILE.
+// Must be called without holding r.mu.
+func (r *Room) changed() {
+	if r.destroyed.Load() {
+		return
+	}
+	r.writeUsersFile()
+	if r.onChange != nil {
+		r.onChange(r)
+	}
+}
@@ -0,0 +1,234 @@
+package room
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jaypopat/duet/internal/terminal"
+)
+
+// maxTabs matches the alt+1..9 switching keys.
+const maxTabs = 9
+
+// Tab is one shared shell in the room. Tab 0 is the room's main terminal,
+// started on first entry; the rest are opened on demand. Which tab a client
+// is looking at is up to them, the list itself is shared.
+type Tab struct {
+	Title    string
+	Terminal *terminal.Terminal
+}
+
+// TabInfo is a snapshot of a tab for display.
+type TabInfo struct {
+	Index int
+	Title string
+}
+
+// AttachTerminal installs t as the main terminal (tab 0) and sends a
+// "terminal_started" event. If another client won the race, the existing
+// terminal is returned with ok=false and the caller should discard t.
+func (r *Room) AttachTerminal(t *terminal.Terminal) (shared *terminal.Terminal, ok bool) {
+	r.mu.Lock()
+	if len(r.tabs) > 0 {
+		existing := r.tabs[0].Terminal
+		r.mu.Unlock()
+		return existing, false
+	}
+	r.tabs = append(r.tabs, &Tab{Title: "shell", Terminal: t})
+	r.mu.Unlock()
+
+	r.watchTerminal(t)
+	r.notify(RoomEvent{Type: "terminal_started"}, "")
+	return t, true
+}
+
+// GetTerminal returns the main terminal, or nil if nobody started it yet.
+func (r *Room) GetTerminal() *terminal.Terminal {
+	return r.TabTerminal(0)
+}
+
+// TabTerminal returns the terminal for tab i, or nil if there is no such tab.
+func (r *Room) TabTerminal(i int) *terminal.Terminal {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if i < 0 || i >= len(r.tabs) {
+		return nil
+	}
+	return r.tabs[i].Terminal
+}
+
+// TabIndex returns the index of the tab running t, or -1.
+func (r *Room) TabIndex(t *terminal.Terminal) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, tab := range r.tabs {
+		if tab.Terminal == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// Tabs lists the open tabs in order.
+func (r *Room) Tabs() []TabInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]TabInfo, len(r.tabs))
+	for i, tab := range r.tabs {
+		out[i] = TabInfo{Index: i, Title: tab.Title}
+	}
+	return out
+}
+
+// AddTab opens t as a new tab and tells everyone with a "tabs" event. The
+// main terminal must already exist.
+func (r *Room) AddTab(title string, t *terminal.Terminal, by string) (int, error) {
+	r.mu.Lock()
+	if len(r.tabs) == 0 {
+		r.mu.Unlock()
+		return 0, ErrTabNotFound
+	}
+	if len(r.tabs) >= maxTabs {
+		r.mu.Unlock()
+		return 0, ErrTooManyTabs
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = "shell " + strconv.Itoa(len(r.tabs)+1)
+	}
+	r.tabs = append(r.tabs, &Tab{Title: title, Terminal: t})
+	i := len(r.tabs) - 1
+	r.logEvent(fmt.Sprintf("%s opened tab %d (%s)", by, i+1, title))
+	r.mu.Unlock()
+
+	r.watchTerminal(t)
+
+	r.BroadcastEvent(RoomEvent{Type: "tabs", Username: by, Data: "opened " + title}, "")
+	return i, nil
+}
+
+// CloseTab shuts down tab i. The main terminal can't be closed.
+func (r *Room) CloseTab(i int, by string) error {
+	r.mu.Lock()
+	if i == 0 {
+		r.mu.Unlock()
+		return ErrCloseMainTab
+	}
+	if i < 0 || i >= len(r.tabs) {
+		r.mu.Unlock()
+		return ErrTabNotFound
+	}
+	tab := r.tabs[i]
+	r.tabs = append(r.tabs[:i], r.tabs[i+1:]...)
+	r.logEvent(fmt.Sprintf("%s closed tab %d (%s)", by, i+1, tab.Title))
+	r.mu.Unlock()
+
+	tab.Terminal.Close()
+	r.BroadcastEvent(RoomEvent{Type: "tabs", Username: by, Data: "closed " + tab.Title}, "")
+	return nil
+}
+
+// RenameTab changes the title shown for tab i.
+func (r *Room) RenameTab(i int, title, by string) error {
+	title = strings.TrimSpace(title)
+	r.mu.Lock()
+	if i < 0 || i >= len(r.tabs) {
+		r.mu.Unlock()
+		return ErrTabNotFound
+	}
+	if title == "" {
+		r.mu.Unlock()
+		return fmt.Errorf("tab title can't be empty")
+	}
+	r.tabs[i].Title = title
+	r.mu.Unlock()
+
+	r.BroadcastEvent(RoomEvent{Type: "tabs", Username: by, Data: "renamed a tab to " + title}, "")
+	return nil
+}
+
+// watchTerminal hooks t's bell, shell exit and blocked input up to room
+// events, and applies the server's input policy.
+func (r *Room) watchTerminal(t *terminal.Terminal) {
+	t.SetBellHook(func() { r.ringBell(t) })
+	t.SetExitHook(func(code int) { r.shellExited(t, code) })
+	t.SetInputPolicy(r.inputPolicy)
+	t.SetBlockHook(func(user, line, reason string) { r.inputBlocked(t, user, line, reason) })
+}
+
+// tabLabel returns "n:title" for the tab running t, or "" if it's gone.
+func (r *Room) tabLabel(t *terminal.Terminal) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, tab := range r.tabs {
+		if tab.Terminal == t {
+			return fmt.Sprintf("%d:%s", i+1, tab.Title)
+		}
+	}
+	return ""
+}
+
+// shellExited tells everyone the shell in t exited on its own, with a
+// "shell_exited" event, so the host can restart it.
+func (r *Room) shellExited(t *terminal.Terminal, code int) {
+	label := r.tabLabel(t)
+	if label == "" {
+		return
+	}
+	r.logEvent(fmt.Sprintf("shell in tab %s exited with code %d", label, code))
+	r.BroadcastEvent(RoomEvent{Type: "shell_exited", Data: fmt.Sprintf("%s exited with code %d", label, code)}, "")
+}
+
+// RestartTab starts tab i's shell again in place after it exited.
+func (r *Room) RestartTab(i int, by string) error {
+	t := r.TabTerminal(i)
+	if t == nil {
+		return ErrTabNotFound
+	}
+	if err := t.Restart(); err != nil {
+		return err
+	}
+	r.logEvent(fmt.Sprintf("%s restarted the shell in tab %d", by, i+1))
+	r.BroadcastEvent(RoomEvent{Type: "shell_restarted", Username: by, Data: r.tabLabel(t)}, "")
+	return nil
+}
+
+// inputBlocked tells everyone the input policy stopped user's command line
+// in t, with an "input_blocked" event.
+func (r *Room) inputBlocked(t *terminal.Terminal, user, line, reason string) {
+	label := r.tabLabel(t)
+	if label == "" {
+		return
+	}
+	r.logEvent(fmt.Sprintf("blocked %s's command in tab %s: %q (%s)", user, label, line, reason))
+	r.BroadcastEvent(RoomEvent{Type: "input_blocked", Username: user, Data: fmt.Sprintf("%q (%s)", line, reason)}, "")
+}
+
+// ringBell tells everyone the program in t rang the bell, with a "bell"
+// event naming the tab, so someone looking elsewhere notices a finished
+// build.
+func (r *Room) ringBell(t *terminal.Terminal) {
+	label := r.tabLabel(t)
+	if label == "" {
+		return // tab already closed
+	}
+	r.notify(RoomEvent{Type: "bell", Data: label}, "")
+}
+
+// closeTerminals shuts every tab down; used when the room is destroyed or
+// the server stops. The returned channels close once each tab's processes
+// are gone.
+func (r *Room) closeTerminals() []<-chan struct{} {
+	r.mu.Lock()
+	tabs := r.tabs
+	r.tabs = nil
+	r.mu.Unlock()
+
+	done := make([]<-chan struct{}, 0, len(tabs))
+	for _, tab := range tabs {
+		tab.Terminal.Close()
+		done = append(done, tab.Terminal.Done())
+	}
+	return done
+}
@@ -0,0 +1,98 @@
+package room
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxTemplateLen keeps a template within what the AI prompt can carry.
+const maxTemplateLen = 500
+
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,29}$`)
+
+// ParseTemplate splits a "name=text" definition, as passed to -template.
+func ParseTemplate(s string) (name, text string, err error) {
+	name, text, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", fmt.Errorf("%w: want name=text, got %q", ErrInvalidTemplate, s)
+	}
+	name, text = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(text)
+	if err := validateTemplate(name, text); err != nil {
+		return "", "", err
+	}
+	return name, text, nil
+}
+
+func validateTemplate(name, text string) error {
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: bad name %q", ErrInvalidTemplate, name)
+	}
+	if text == "" || len(text) > maxTemplateLen {
+		return fmt.Errorf("%w: %q needs 1-%d characters of text", ErrInvalidTemplate, name, maxTemplateLen)
+	}
+	return nil
+}
+
+// SetTemplate defines or replaces a prompt template. Names are lower-cased.
+func (r *Room) SetTemplate(name, text string) error {
+	name, text = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(text)
+	if err := validateTemplate(name, text); err != nil {
+		return err
+	}
+	defer r.changed()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Templates == nil {
+		r.Templates = make(map[string]string)
+	}
+	r.Templates[name] = text
+	return nil
+}
+
+// DeleteTemplate removes a prompt template.
+func (r *Room) DeleteTemplate(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	r.mu.Lock()
+	if _, ok := r.Templates[name]; !ok {
+		r.mu.Unlock()
+		return ErrTemplateNotFound
+	}
+	delete(r.Templates, name)
+	r.mu.Unlock()
+	r.changed()
+	return nil
+}
+
+// Template returns the text of the named prompt template.
+func (r *Room) Template(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	text, ok := r.Templates[strings.ToLower(name)]
+	return text, ok
+}
+
+// TemplateNames returns the room's prompt template names, sorted.
+func (r *Room) TemplateNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.Templates))
+	for name := range r.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandTemplate fills a template's {selection} placeholder. A template
+// without one gets the selection appended, so "/explain foo" still works.
+func ExpandTemplate(text, selection string) string {
+	if strings.Contains(text, "{selection}") {
+		return strings.ReplaceAll(text, "{selection}", selection)
+	}
+	if selection == "" {
+		return text
+	}
+	return text + "\n\n" + selection
+}
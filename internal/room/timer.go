@@ -0,0 +1,78 @@
+package room
+
+import (
+	"sync"
+	"time"
+)
+
+// roomTimer is the room's shared countdown, e.g. for driver/navigator
+// turns. Everyone sees the same one; any participant can start or stop it.
+type roomTimer struct {
+	mu    sync.Mutex
+	end   time.Time // zero when it isn't running
+	total time.Duration
+	by    string
+	fire  *time.Timer
+}
+
+// StartTimer (re)starts the shared timer for d. Clients get a "timer"
+// event now and a "timer_done" event, with Data the duration, when it
+// runs out.
+func (r *Room) StartTimer(d time.Duration, by string) {
+	t := &r.timer
+	t.mu.Lock()
+	if t.fire != nil {
+		t.fire.Stop()
+	}
+	t.end, t.total, t.by = time.Now().Add(d), d, by
+	end := t.end
+	t.fire = time.AfterFunc(d, func() { r.timerDone(end) })
+	t.mu.Unlock()
+
+	r.BroadcastEvent(RoomEvent{Type: "timer", Username: by, Data: "started a " + d.String() + " timer"}, "")
+}
+
+// StopTimer cancels the shared timer, reporting false if it wasn't running.
+func (r *Room) StopTimer(by string) bool {
+	t := &r.timer
+	t.mu.Lock()
+	running := !t.end.IsZero()
+	if t.fire != nil {
+		t.fire.Stop()
+	}
+	t.end, t.fire = time.Time{}, nil
+	t.mu.Unlock()
+
+	if running {
+		r.BroadcastEvent(RoomEvent{Type: "timer", Username: by, Data: "stopped the timer"}, "")
+	}
+	return running
+}
+
+// Timer returns when the shared timer runs out, how long it was set for
+// and who started it; ok is false when it isn't running.
+func (r *Room) Timer() (end time.Time, total time.Duration, by string, ok bool) {
+	t := &r.timer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.end.IsZero() {
+		return time.Time{}, 0, "", false
+	}
+	return t.end, t.total, t.by, true
+}
+
+// timerDone ends the timer that was set to run out at end, unless it has
+// been stopped or restarted since.
+func (r *Room) timerDone(end time.Time) {
+	t := &r.timer
+	t.mu.Lock()
+	if !t.end.Equal(end) || r.destroyed.Load() {
+		t.mu.Unlock()
+		return
+	}
+	total, by := t.total, t.by
+	t.end, t.fire = time.Time{}, nil
+	t.mu.Unlock()
+
+	r.BroadcastEvent(RoomEvent{Type: "timer_done", Username: by, Data: total.String()}, "")
+}
@@ -0,0 +1,80 @@
+package room
+
+import (
+	"crypto/subtle"
+
+	"github.com/google/uuid"
+)
+
+// NewWebToken replaces the token that lets a browser type into the room's
+// shells, and returns it. Anyone holding the old one drops to watching.
+func (r *Room) NewWebToken() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webToken = uuid.New().String()
+	return r.webToken
+}
+
+// WatchToken returns the token that lets a browser watch the room, making
+// one if there isn't one yet. Everyone handing out watch links shares it,
+// so revoking cuts off every watcher at once.
+func (r *Room) WatchToken() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watchToken == "" {
+		r.watchToken = uuid.New().String()
+	}
+	return r.watchToken
+}
+
+// RevokeWebToken stops browsers watching or typing into the room until new
+// links are handed out.
+func (r *Room) RevokeWebToken() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokeWebTokensLocked()
+}
+
+func (r *Room) revokeWebTokensLocked() {
+	r.webToken = ""
+	r.watchToken = ""
+}
+
+// CheckWebToken reports whether token lets a browser type into the room.
+func (r *Room) CheckWebToken(token string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return tokenMatches(token, r.webToken)
+}
+
+// CheckWatchToken reports whether token lets a browser watch the room; a
+// control token does too.
+func (r *Room) CheckWatchToken(token string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return tokenMatches(token, r.watchToken) || tokenMatches(token, r.webToken)
+}
+
+func tokenMatches(token, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// AddWebViewer counts a browser watching the room towards its capacity,
+// returning ErrRoomFull when people and browsers already fill it. Call
+// RemoveWebViewer when the browser goes.
+func (r *Room) AddWebViewer() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fullLocked() {
+		return ErrRoomFull
+	}
+	r.webViewers++
+	return nil
+}
+
+// RemoveWebViewer undoes AddWebViewer.
+func (r *Room) RemoveWebViewer() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webViewers--
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// adminRoom is a room as operators see it, with who is connected.
+type adminRoom struct {
+	room.RoomInfo
+	Clients []room.ClientInfo `json:"clients"`
+}
+
+// EnableAdmin turns on the operator endpoints under /api/admin, served by
+// the HTTP API with their own bearer token. Does nothing when token is
+// empty. Call before Start.
+func (s *Server) EnableAdmin(token string) {
+	s.adminToken = token
+}
+
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/admin/rooms", s.handleAdminRooms)
+	mux.HandleFunc("DELETE /api/admin/rooms/{id}", s.handleAdminCloseRoom) // ?reason= is shown to members
+	mux.HandleFunc("DELETE /api/admin/rooms/{id}/clients/{username}", s.handleAdminKick)
+	mux.HandleFunc("POST /api/admin/broadcast", s.handleAdminBroadcast)
+	mux.HandleFunc("POST /api/admin/pins", s.handleAdminIssuePIN)
+	return mux
+}
+
+func (s *Server) handleAdminRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := make([]adminRoom, 0)
+	for _, rm := range s.roomManager.Rooms() {
+		rooms = append(rooms, adminRoom{RoomInfo: rm.Info(), Clients: rm.ClientDetails()})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rooms": rooms})
+}
+
+func (s *Server) handleAdminCloseRoom(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+	if reason == "" {
+		reason = "closed by an administrator"
+	}
+	if err := s.roomManager.CloseRoom(id, reason); err != nil {
+		if errors.Is(err, room.ErrRoomNotFound) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logger.Info("room closed by admin", "roomID", id, "reason", reason)
+	s.audit(audit.Event{Type: "admin_close_room", User: "api", Remote: r.RemoteAddr, RoomID: id, Detail: reason})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdminKick(w http.ResponseWriter, r *http.Request) {
+	rm, err := s.roomManager.GetRoom(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	username := r.PathValue("username")
+	switch err := rm.Kick(username); {
+	case errors.Is(err, room.ErrClientNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, room.ErrCannotKickHost):
+		// closing the room is the way to get rid of its host
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logger.Info("client kicked by admin", "roomID", rm.ID, "user", username)
+	s.audit(audit.Event{Type: "admin_kick", User: "api", Remote: r.RemoteAddr, RoomID: rm.ID, Target: username})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdminBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		writeJSONError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	n := s.roomManager.Announce(req.Message)
+	s.logger.Info("admin broadcast", "rooms", n, "message", req.Message)
+	s.audit(audit.Event{Type: "admin_broadcast", User: "api", Remote: r.RemoteAddr, Detail: req.Message})
+	writeJSON(w, http.StatusOK, map[string]int{"rooms": n})
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// createRoomRequest is the body for POST /api/rooms
+type createRoomRequest struct {
+	Host            string            `json:"host"`
+	Description     string            `json:"description"`
+	MaxParticipants int               `json:"max_participants"`
+	RequireApproval bool              `json:"require_approval"`
+	Tags            []string          `json:"tags"`
+	HostName        string            `json:"host_name"`
+	HostFingerprint string            `json:"host_fingerprint"`
+	Shell           string            `json:"shell"`
+	Dir             string            `json:"dir"`
+	Env             map[string]string `json:"env"`
+	TmuxSession     string            `json:"tmux_session"`
+	Passthrough     bool              `json:"passthrough"`
+	Public          bool              `json:"public"`
+}
+
+// EnableAPI turns on the room management HTTP API on addr. Every request
+// must carry "Authorization: Bearer <token>". Call before Start.
+func (s *Server) EnableAPI(addr, token string) {
+	s.apiAddr = addr
+	s.apiToken = token
+}
+
+func (s *Server) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/rooms", s.handleListRooms) // ?tag= filters by tag prefix
+	mux.HandleFunc("POST /api/rooms", s.handleCreateRoom)
+	mux.HandleFunc("GET /api/rooms/{id}", s.handleGetRoom)
+	mux.HandleFunc("DELETE /api/rooms/{id}", s.handleCloseRoom)
+
+	top := http.NewServeMux()
+	top.Handle("/api/", requireToken(s.apiToken, mux))
+	if s.adminToken != "" {
+		top.Handle("/api/admin/", requireToken(s.adminToken, s.adminHandler()))
+	}
+	return top
+}
+
+func requireToken(want string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleListRooms(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	rooms := make([]room.RoomInfo, 0)
+	for _, info := range s.roomManager.ListRooms() {
+		if info.HasTag(tag) {
+			rooms = append(rooms, info)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rooms": rooms})
+}
+
+func (s *Server) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
+	var req createRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	req.Host = strings.TrimSpace(req.Host)
+	if req.Host == "" {
+		writeJSONError(w, http.StatusBadRequest, "host is required")
+		return
+	}
+	if req.MaxParticipants < 0 {
+		writeJSONError(w, http.StatusBadRequest, "max_participants must not be negative")
+		return
+	}
+
+	rm, err := s.roomManager.CreateRoom(req.Host, room.RoomOptions{
+		Description: strings.TrimSpace(req.Description),
+		MaxClients:  req.MaxParticipants,
+		Tags:        req.Tags,
+		HostName:    req.HostName,
+
+		HostFingerprint: strings.TrimSpace(req.HostFingerprint),
+		Settings: room.RoomSettings{
+			Shell: strings.TrimSpace(req.Shell),
+			Dir:   strings.TrimSpace(req.Dir),
+			Env:   req.Env,
+
+			TmuxSession: strings.TrimSpace(req.TmuxSession),
+		},
+	})
+	if errors.Is(err, room.ErrInvalidShell) || errors.Is(err, room.ErrShellNotAllowed) || errors.Is(err, room.ErrInvalidDir) || errors.Is(err, room.ErrInvalidEnvKey) ||
+		errors.Is(err, room.ErrTmuxDisabled) || errors.Is(err, room.ErrNoTmuxSession) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("API room creation failed", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to create room")
+		return
+	}
+	if req.RequireApproval {
+		rm.SetRequireApproval(true)
+	}
+	if req.Passthrough {
+		rm.SetPassthrough(true, req.Host)
+	}
+	if req.Public {
+		rm.SetPublic(true)
+	}
+
+	s.logger.Info("room created via API", "roomID", rm.ID, "host", req.Host)
+	s.audit(audit.Event{Type: "room_create", User: "api", Remote: r.RemoteAddr, RoomID: rm.ID, Detail: "host " + req.Host})
+	writeJSON(w, http.StatusCreated, rm.Info())
+}
+
+func (s *Server) handleGetRoom(w http.ResponseWriter, r *http.Request) {
+	rm, err := s.roomManager.GetRoom(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rm.Info())
+}
+
+func (s *Server) handleCloseRoom(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.roomManager.CloseRoom(id, "closed by an administrator"); err != nil {
+		if errors.Is(err, room.ErrRoomNotFound) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.logger.Info("room closed via API", "roomID", id)
+	s.audit(audit.Event{Type: "room_close", User: "api", Remote: r.RemoteAddr, RoomID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
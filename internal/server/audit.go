@@ -0,0 +1,47 @@
+package server
+
+import (
+	"github.com/charmbracelet/ssh"
+	"github.com/jaypopat/duet/internal/audit"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// SetAuditLog records connections, auth decisions and operator actions to
+// l, and has sessions record what they do in rooms. Call before Start.
+func (s *Server) SetAuditLog(l *audit.Log) {
+	s.roomManager.SetAuditLog(l)
+}
+
+// sessionAudit is the part of an audit event that identifies a connection.
+func sessionAudit(ctx ssh.Context, key ssh.PublicKey) audit.Event {
+	id := ctx.SessionID()
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	e := audit.Event{Session: id, User: ctx.User(), Remote: ctx.RemoteAddr().String()}
+	if key != nil {
+		e.Fingerprint = gossh.FingerprintSHA256(key)
+	}
+	return e
+}
+
+// authDetailKey holds how the connection's accepted auth passed, for the
+// "auth" event written once its session starts.
+type authDetailKey struct{}
+
+// authDecided audits a rejected auth attempt straight away. An accepted
+// one is only noted: clients can offer keys they then fail to sign with,
+// so it's recorded by logSessions once auth has really finished.
+func (s *Server) authDecided(ctx ssh.Context, e audit.Event) {
+	if e.Result == "accepted" {
+		ctx.SetValue(authDetailKey{}, e.Detail)
+		return
+	}
+	s.audit(e)
+}
+
+func (s *Server) audit(e audit.Event) {
+	if err := s.roomManager.AuditLog().Record(e); err != nil {
+		s.logger.Warn("audit log write failed", "error", err)
+	}
+}
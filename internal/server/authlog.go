@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// Reasons written in the auth log. They are part of its format, so
+// filters can match on them: don't rename one.
+const (
+	authKeyNotOnGitHub = "key-not-on-github"    // -github-keys: key isn't published for the username
+	authGitHubLookup   = "github-lookup-failed" // -github-keys: GitHub couldn't be asked
+	authNoKey          = "no-key"               // -github-keys: client offered no public key
+	authHandshake      = "handshake-failed"     // SSH handshake failed, including when every auth method was refused
+	authRateLimited    = "rate-limited"         // dropped before the handshake, see RateLimit
+	authBanned         = "banned"               // dropped before the handshake while the IP is banned
+	authBadProxy       = "bad-proxy-header"     // -proxy-protocol: missing or garbled PROXY header
+	authBadPassword    = "bad-password"         // wrong password or PIN
+	authLockedOut      = "password-locked-out"  // too many wrong passwords from the IP lately
+)
+
+// authLog writes one line per refused connection or auth attempt in a
+// fixed format for fail2ban or CrowdSec:
+//
+//	2026-01-02T15:04:05Z duet-auth: failure ip=203.0.113.7 reason=key-not-on-github user="bob" fingerprint=SHA256:...
+//
+// The timestamp is UTC, ip comes straight after the fixed prefix so the
+// client-chosen username can't be mistaken for it, user is Go-quoted and
+// fingerprint is "-" without a key. A nil *authLog writes nothing.
+type authLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// SetAuthLog writes auth failures and rejected connections to w in the
+// format described on authLog. Call before Start.
+func (s *Server) SetAuthLog(w io.Writer) {
+	s.authLog = &authLog{w: w}
+}
+
+// failure records one refusal. user and fingerprint may be empty when the
+// connection was dropped before the client said who it was.
+func (a *authLog) failure(ip, reason, user, fingerprint string) {
+	if a == nil {
+		return
+	}
+	if fingerprint == "" {
+		fingerprint = "-"
+	}
+	if ip == "" {
+		ip = "-"
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(a.w, "%s duet-auth: failure ip=%s reason=%s user=%q fingerprint=%s\n",
+		time.Now().UTC().Format(time.RFC3339), ip, reason, user, fingerprint)
+}
+
+// sessionFailure records a refused auth attempt on ctx's connection.
+func (a *authLog) sessionFailure(ctx ssh.Context, reason, fingerprint string) {
+	a.failure(remoteIP(ctx.RemoteAddr()), reason, ctx.User(), fingerprint)
+}
+
+// authFailure records a refused auth attempt in the auth log and counts
+// it towards banning the IP, if -ban-after is set.
+func (s *Server) authFailure(ctx ssh.Context, reason, fingerprint string) {
+	s.authLog.sessionFailure(ctx, reason, fingerprint)
+	s.limiter.fail(remoteIP(ctx.RemoteAddr()), time.Now())
+}
+
+// option records failed handshakes, after whatever the rate limiter does
+// with them.
+func (a *authLog) option() ssh.Option {
+	return func(s *ssh.Server) error {
+		prev := s.ConnectionFailedCallback
+		s.ConnectionFailedCallback = func(conn net.Conn, err error) {
+			if prev != nil {
+				prev(conn, err)
+			}
+			a.failure(remoteIP(conn.RemoteAddr()), authHandshake, "", "")
+		}
+		return nil
+	}
+}
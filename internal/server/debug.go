@@ -0,0 +1,84 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// EnableDebug serves net/http/pprof under /debug/pprof/ and expvar
+// counters (rooms, clients, shell traffic) under /debug/vars on addr, for
+// profiling a live server. Without a host in addr it listens on localhost
+// only, since profiles reveal a lot about the process. Call before Start.
+func (s *Server) EnableDebug(addr string) {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	s.debugAddr = addr
+}
+
+// debugHandler serves profiles and counters, but never the command line:
+// flags can carry secrets, so neither pprof's cmdline page nor expvar's
+// "cmdline" variable is served.
+func (s *Server) debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", serveDebugVars)
+	return mux
+}
+
+// serveDebugVars is expvar.Handler without "cmdline".
+func serveDebugVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
+
+// publishDebugVars adds duet's counters to /debug/vars. expvar names are
+// process-wide, so this runs once per process.
+func (s *Server) publishDebugVars() {
+	expvar.Publish("duet", expvar.Func(func() any {
+		type totals struct {
+			Rooms         int    `json:"rooms"`
+			DetachedRooms int    `json:"detached_rooms"`
+			Clients       int    `json:"clients"`
+			ShellBytesIn  uint64 `json:"shell_bytes_in"`
+			ShellBytesOut uint64 `json:"shell_bytes_out"`
+			AIRequests    int64  `json:"ai_requests"`
+			SandboxRuns   int64  `json:"sandbox_runs"`
+			Goroutines    int    `json:"goroutines"`
+		}
+		var t totals
+		for _, r := range s.roomManager.Rooms() {
+			t.Rooms++
+			if _, ok := r.DetachedSince(); ok {
+				t.DetachedRooms++
+			}
+			t.Clients += r.ClientCount()
+			st := r.Stats()
+			t.ShellBytesIn += st.BytesIn
+			t.ShellBytesOut += st.BytesOut
+			t.AIRequests += st.AIRequests
+			t.SandboxRuns += st.Sandbox
+		}
+		t.Goroutines = runtime.NumGoroutine()
+		return t
+	}))
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// AddHostKey serves the private key at path alongside the main host key,
+// e.g. an RSA or ECDSA key for clients too old to speak ed25519. A path
+// that doesn't exist is skipped with a warning, so one config can list
+// every key type and still start where only ed25519 has been created.
+// Call before Start.
+func (s *Server) AddHostKey(path string) {
+	s.extraHostKeys = append(s.extraHostKeys, path)
+}
+
+// hostKeyOptions loads the main host key, creating an ed25519 one if it's
+// missing, and any extra keys. Each key type may appear once: a client
+// only ever gets offered one key of a type, so a second would be dead.
+func (s *Server) hostKeyOptions() ([]ssh.Option, error) {
+	signer, err := s.loadHostKey(s.hostKeyPath)
+	if os.IsNotExist(err) {
+		signer, err = s.generateHostKey(s.hostKeyPath)
+		if err == nil {
+			s.logger.Info("Generated a new host key; clients will be asked to trust it", "path", s.hostKeyPath)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	opts := []ssh.Option{func(srv *ssh.Server) error {
+		srv.AddHostKey(signer)
+		return nil
+	}}
+	s.logger.Info("Host key", "type", signer.PublicKey().Type(), "fingerprint", gossh.FingerprintSHA256(signer.PublicKey()), "path", s.hostKeyPath)
+	paths := map[string]string{signer.PublicKey().Type(): s.hostKeyPath}
+
+	for _, path := range s.extraHostKeys {
+		signer, err := s.loadHostKey(path)
+		if os.IsNotExist(err) {
+			s.logger.Warn("Skipping missing host key", "path", path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keyType := signer.PublicKey().Type()
+		if other, ok := paths[keyType]; ok {
+			return nil, fmt.Errorf("host keys %s and %s are both %s; use one key per type", other, path, keyType)
+		}
+		paths[keyType] = path
+		s.logger.Info("Host key", "type", keyType, "fingerprint", gossh.FingerprintSHA256(signer.PublicKey()), "path", path)
+		opts = append(opts, func(srv *ssh.Server) error {
+			srv.AddHostKey(signer)
+			return nil
+		})
+	}
+	return opts, nil
+}
+
+func (s *Server) loadHostKey(path string) (gossh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+		s.logger.Warn("Host key is readable by other users; chmod 600 it", "path", path)
+	}
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("host key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// generateHostKey writes a new ed25519 key to path, readable only by us,
+// and its public half to path.pub, like ssh-keygen would. It creates the
+// directory if needed but leaves an existing one's permissions alone. The
+// key is written to a temp file and renamed into place, so a failed write
+// never leaves a truncated key behind for the next start to trip over.
+func (s *Server) generateHostKey(path string) (gossh.Signer, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := gossh.MarshalPrivateKey(priv, "duet host key")
+	if err != nil {
+		return nil, err
+	}
+	sshPub, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create host key: %w", err)
+	}
+	// CreateTemp makes the file 0600 and never opens an existing one
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("create host key: %w", err)
+	}
+	err = pem.Encode(f, block)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("write host key: %w", err)
+	}
+	// the public half is a convenience for publishing the fingerprint
+	if err := os.WriteFile(path+".pub", gossh.MarshalAuthorizedKey(sshPub), 0o644); err != nil {
+		s.logger.Warn("Couldn't write the host key's public half", "path", path+".pub", "error", err)
+	}
+	return gossh.NewSignerFromKey(priv)
+}
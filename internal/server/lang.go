@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/jaypopat/duet/internal/ui"
+)
+
+// langEnv is the variable a client can send, with
+// "ssh -o SetEnv=DUET_LANG=de", to pick the language of its labels.
+const langEnv = "DUET_LANG"
+
+// SetLanguage sets the language sessions get unless they pick their own;
+// see ui.Languages. Call before Start.
+func (s *Server) SetLanguage(name string) error {
+	if !ui.ValidLanguage(name) {
+		return fmt.Errorf("unknown language %q (want one of %s)", name, strings.Join(ui.Languages(), ", "))
+	}
+	s.lang = name
+	return nil
+}
+
+// sessionLanguage is the language a session asked for, with "--lang <code>"
+// in its command, or $DUET_LANG, and args with the flag taken out. It is
+// empty when the session didn't ask, or asked for one we don't have, so
+// the server's applies.
+func (s *Server) sessionLanguage(sess ssh.Session, args []string) (string, []string) {
+	chosen, rest := sessionOption(sess, args, "lang", langEnv)
+	if chosen != "" && !ui.ValidLanguage(chosen) {
+		s.sessionLog(sess.Context()).Info("ignoring unknown language", "lang", chosen)
+		return "", rest
+	}
+	return chosen, rest
+}
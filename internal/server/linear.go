@@ -0,0 +1,35 @@
+package server
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// linearEnv is the variable a client can send, with
+// "ssh -o SetEnv=DUET_LINEAR=1", to get the linear view for screen readers.
+const linearEnv = "DUET_LINEAR"
+
+// SetLinear gives every session the linear view; see ui.Model.SetLinear.
+// Call before Start.
+func (s *Server) SetLinear(on bool) {
+	s.linear = on
+}
+
+// sessionLinear reports whether a session asked for the linear view, with
+// "--linear" in its command or a $DUET_LINEAR other than 0 or false, and
+// returns args with the flag taken out.
+func sessionLinear(sess ssh.Session, args []string) (bool, []string) {
+	var on bool
+	for _, kv := range sess.Environ() {
+		if v, ok := strings.CutPrefix(kv, linearEnv+"="); ok {
+			on = v != "" && v != "0" && !strings.EqualFold(v, "false")
+		}
+	}
+	if slices.Contains(args, "--linear") {
+		on = true
+		args = slices.DeleteFunc(slices.Clone(args), func(a string) bool { return a == "--linear" })
+	}
+	return on, args
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixPrefix marks an address as a Unix domain socket path rather than a
+// TCP host:port, e.g. "unix:///run/duet/duet.sock".
+const unixPrefix = "unix://"
+
+// socketPath returns the path of a "unix://" address, and whether addr is
+// one.
+func socketPath(addr string) (string, bool) {
+	return strings.CutPrefix(addr, unixPrefix)
+}
+
+// listen opens the SSH listener on a TCP address or a Unix socket. A
+// socket file left behind by a server that died is removed first; one
+// that something still answers on is an error rather than stolen.
+func listen(addr string) (net.Listener, error) {
+	path, ok := socketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// closing the listener on shutdown removes the file again
+	return net.Listen("unix", path)
+}
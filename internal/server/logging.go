@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/jaypopat/duet/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SetLogFormat switches the server's logs to "text" or "json" and sets the
+// lowest level written ("debug", "info", "warn" or "error"). Call before
+// Start.
+func (s *Server) SetLogFormat(format, level string) error {
+	switch format {
+	case "text":
+		s.logger.SetFormatter(log.TextFormatter)
+	case "json":
+		s.logger.SetFormatter(log.JSONFormatter)
+		s.logger.SetReportTimestamp(true)
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	s.logger.SetLevel(lvl)
+	return nil
+}
+
+// sessionLog is the logger for one SSH connection: every line carries its
+// session ID, so a connection's lines can be picked out of a busy server's
+// logs, plus who it is and where from.
+func (s *Server) sessionLog(ctx ssh.Context) *log.Logger {
+	id := ctx.SessionID()
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	return s.logger.With("session", id, "user", ctx.User(), "remote", ctx.RemoteAddr().String())
+}
+
+// logSessions logs each session's start and end, and traces it. It also
+// audits the accepted auth, which only now is known to have finished.
+func (s *Server) logSessions(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		start := time.Now()
+		logger := s.sessionLog(sess.Context())
+		pty, _, _ := sess.Pty()
+		logger.Info("connect",
+			"publicKey", sess.PublicKey() != nil,
+			"command", sess.Command(),
+			"term", pty.Term,
+			"width", pty.Window.Width,
+			"height", pty.Window.Height,
+			"clientVersion", sess.Context().ClientVersion(),
+		)
+		e := sessionAudit(sess.Context(), sess.PublicKey())
+		e.Type, e.Result = "auth", "accepted"
+		e.Detail, _ = sess.Context().Value(authDetailKey{}).(string)
+		s.audit(e)
+		e.Type, e.Result, e.Detail = "connect", "", ""
+		s.audit(e)
+		_, span := tracing.Start(context.Background(), "ssh.session",
+			attribute.String("session.id", e.Session),
+			attribute.String("user", sess.User()),
+			attribute.String("remote", sess.RemoteAddr().String()),
+		)
+		sess.Context().SetValue(sessionSpanKey{}, span.SpanContext())
+		next(sess)
+		span.End()
+		logger.Info("disconnect", "duration", time.Since(start).Round(time.Millisecond))
+		e.Type = "disconnect"
+		s.audit(e)
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// nameEnv is the variable a client can send, with
+// "ssh -o SetEnv=DUET_NAME=alice", to pick the name shown in rooms.
+const nameEnv = "DUET_NAME"
+
+// validName is what a chosen display name may look like: short, and
+// without spaces so it still works as an argument to commands like kick.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,31}$`)
+
+// displayName is the name a session goes by in rooms and the session's
+// command with any "--name <name>" taken out. The SSH username is the
+// default; --name, then $DUET_NAME, override it, except when usernames
+// are verified GitHub handles.
+func (s *Server) displayName(sess ssh.Session) (string, []string) {
+	name := sess.User()
+	if name == "" {
+		name = "guest"
+	}
+
+	var chosen string
+	for _, kv := range sess.Environ() {
+		if v, ok := strings.CutPrefix(kv, nameEnv+"="); ok {
+			chosen = v
+		}
+	}
+	var args []string
+	cmd := sess.Command()
+	for i := 0; i < len(cmd); i++ {
+		switch {
+		case cmd[i] == "--name" && i+1 < len(cmd):
+			chosen = cmd[i+1]
+			i++
+		case strings.HasPrefix(cmd[i], "--name="):
+			chosen = strings.TrimPrefix(cmd[i], "--name=")
+		default:
+			args = append(args, cmd[i])
+		}
+	}
+
+	switch {
+	case chosen == "" || chosen == name:
+	case s.github != nil:
+		s.sessionLog(sess.Context()).Info("ignoring display name; names are GitHub handles", "name", chosen)
+	case !validName.MatchString(chosen):
+		s.sessionLog(sess.Context()).Info("ignoring invalid display name", "name", chosen)
+	default:
+		name = chosen
+	}
+	return name, args
+}
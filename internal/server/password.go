@@ -0,0 +1,255 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/jaypopat/duet/internal/audit"
+	"golang.org/x/crypto/bcrypt"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	maxPasswordFailures = 5                // wrong answers from one IP before it's locked out
+	passwordLockout     = 15 * time.Minute // how long a lockout lasts, and the window failures count in
+	pinDigits           = 8
+	defaultPINTTL       = time.Hour
+	maxPINTTL           = 7 * 24 * time.Hour
+)
+
+// maxGlobalPasswordFailures is the wrong answers from everyone together per
+// passwordLockout before every attempt is refused until the window ends,
+// so guessing from many addresses gets no further than from one.
+const maxGlobalPasswordFailures = 50
+
+var errPINsOff = errors.New("one-time PINs are not enabled")
+
+// passwords checks the shared password and one-time PINs people without a
+// (trusted) key answer with. Only hashes are kept.
+type passwords struct {
+	hash []byte // bcrypt of the shared password, nil when there's none
+	pins bool   // one-time PINs can be issued, see issuePIN
+
+	mu       sync.Mutex
+	issued   map[[sha256.Size]byte]time.Time // unused PINs and when they expire
+	failures map[string]*passwordFailures    // by IP
+	global   passwordFailures                // everyone's, see maxGlobalPasswordFailures
+	swept    time.Time                       // when failures was last cleared of old windows
+}
+
+type passwordFailures struct {
+	count int
+	first time.Time // start of the current window
+}
+
+// SetPassword lets people in with this shared password when they have no
+// key or their key isn't trusted. Once a password or PINs are set, any
+// old key is no longer enough: only admin keys, and GitHub-verified keys
+// with RequireGitHubKeys, skip the prompt. Call before Start.
+func (s *Server) SetPassword(password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	s.passwordsOrNew().hash = hash
+	return nil
+}
+
+// SetPasswordHash is SetPassword with a bcrypt hash, e.g. from
+// "htpasswd -nbB", so the password itself needn't be in the config.
+// htpasswd puts "user:" in front, which is dropped: bcrypt hashes never
+// contain a colon.
+func (s *Server) SetPasswordHash(hash string) error {
+	hash = strings.TrimSpace(hash)
+	if _, h, ok := strings.Cut(hash, ":"); ok {
+		hash = h
+	}
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("password hash: %w", err)
+	}
+	s.passwordsOrNew().hash = []byte(hash)
+	return nil
+}
+
+// EnablePINs lets the admin API issue one-time PINs, each good for a
+// single login, for handing to someone like an interviewee. Call before
+// Start.
+func (s *Server) EnablePINs() {
+	s.passwordsOrNew().pins = true
+}
+
+func (s *Server) passwordsOrNew() *passwords {
+	if s.passwords == nil {
+		s.passwords = &passwords{
+			issued:   make(map[[sha256.Size]byte]time.Time),
+			failures: make(map[string]*passwordFailures),
+		}
+	}
+	return s.passwords
+}
+
+// issuePIN makes a PIN valid for one login within ttl.
+func (p *passwords) issuePIN(ttl time.Duration, now time.Time) (string, time.Time, error) {
+	if p == nil || !p.pins {
+		return "", time.Time{}, errPINsOff
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1e8))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	pin := fmt.Sprintf("%0*d", pinDigits, n)
+	expires := now.Add(ttl)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for h, exp := range p.issued {
+		if now.After(exp) {
+			delete(p.issued, h)
+		}
+	}
+	p.issued[sha256.Sum256([]byte(pin))] = expires
+	return pin, expires, nil
+}
+
+// check reports whether answer is the password or an unused PIN, which it
+// then uses up. how says which, or why not: "locked out" means ip has
+// failed too often lately and answer wasn't even looked at.
+func (p *passwords) check(ip, answer string, now time.Time) (ok bool, how string) {
+	p.mu.Lock()
+	p.sweep(now)
+	if now.Sub(p.global.first) > passwordLockout {
+		p.global = passwordFailures{first: now}
+	} else if p.global.count >= maxGlobalPasswordFailures {
+		p.mu.Unlock()
+		return false, "locked out"
+	}
+	if f := p.failures[ip]; f != nil {
+		if now.Sub(f.first) > passwordLockout {
+			delete(p.failures, ip)
+		} else if f.count >= maxPasswordFailures {
+			p.mu.Unlock()
+			return false, "locked out"
+		}
+	}
+	if p.pins {
+		h := sha256.Sum256([]byte(answer))
+		for issued, exp := range p.issued {
+			if subtle.ConstantTimeCompare(issued[:], h[:]) == 1 && !now.After(exp) {
+				delete(p.issued, issued)
+				p.mu.Unlock()
+				return true, "one-time PIN"
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	// bcrypt is slow on purpose; don't hold the lock for it
+	if p.hash != nil && bcrypt.CompareHashAndPassword(p.hash, []byte(answer)) == nil {
+		return true, "password"
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f := p.failures[ip]
+	if f == nil {
+		f = &passwordFailures{first: now}
+		p.failures[ip] = f
+	}
+	f.count++
+	p.global.count++
+	return false, "wrong password or PIN"
+}
+
+// sweep forgets IPs whose failure window has passed, at most once per
+// window, so addresses that fail once and never return don't pile up.
+// Call with p.mu held.
+func (p *passwords) sweep(now time.Time) {
+	if now.Sub(p.swept) < passwordLockout {
+		return
+	}
+	p.swept = now
+	for ip, f := range p.failures {
+		if now.Sub(f.first) > passwordLockout {
+			delete(p.failures, ip)
+		}
+	}
+}
+
+// checkPassword checks a password, given with the "password" SSH method
+// or at the keyboard-interactive prompt, and records the outcome.
+func (s *Server) checkPassword(ctx ssh.Context, answer string) bool {
+	e := sessionAudit(ctx, nil)
+	e.Type = "auth"
+	ok, how := s.passwords.check(remoteIP(ctx.RemoteAddr()), answer, time.Now())
+	e.Detail, e.Result = how, "accepted"
+	if !ok {
+		e.Result = "rejected"
+		reason := authBadPassword
+		if how == "locked out" {
+			reason = authLockedOut
+		}
+		s.sessionLog(ctx).Info("password rejected", "reason", how)
+		s.authFailure(ctx, reason, "")
+	}
+	s.authDecided(ctx, e)
+	return ok
+}
+
+// promptPassword asks a keyboard-interactive client for the password or
+// a PIN.
+func (s *Server) promptPassword(ctx ssh.Context, challenge gossh.KeyboardInteractiveChallenge) bool {
+	prompt := "Password: "
+	if s.passwords.pins {
+		prompt = "Password or PIN: "
+	}
+	answers, err := challenge(ctx.User(), "", []string{prompt}, []bool{false})
+	if err != nil || len(answers) != 1 {
+		return false
+	}
+	return s.checkPassword(ctx, answers[0])
+}
+
+// handleAdminIssuePIN makes a one-time PIN, good for ttl (a duration such
+// as "30m", default an hour).
+func (s *Server) handleAdminIssuePIN(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+	ttl := defaultPINTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > maxPINTTL {
+			writeJSONError(w, http.StatusBadRequest, "ttl must be a duration up to 168h")
+			return
+		}
+		ttl = d
+	}
+	pin, expires, err := s.passwords.issuePIN(ttl, time.Now())
+	if errors.Is(err, errPINsOff) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "could not generate a PIN")
+		return
+	}
+	s.logger.Info("admin issued one-time PIN", "expires", expires)
+	s.audit(audit.Event{Type: "admin_pin", User: "api", Remote: r.RemoteAddr, Detail: "expires " + expires.UTC().Format(time.RFC3339)})
+	writeJSON(w, http.StatusCreated, map[string]any{"pin": pin, "expires_at": expires})
+}
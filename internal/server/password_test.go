@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordLockout(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type attempt struct {
+		ip     string
+		answer string
+		at     time.Duration // after the first attempt
+		want   string        // check's how
+	}
+	// wrong gives n wrong answers from ip at at
+	wrong := func(ip string, n int, at time.Duration) []attempt {
+		var as []attempt
+		for range n {
+			as = append(as, attempt{ip, "nope", at, "wrong password or PIN"})
+		}
+		return as
+	}
+	// wrongFromMany spreads n wrong answers over IPs that each stay under
+	// the per-IP limit
+	wrongFromMany := func(n int, at time.Duration) []attempt {
+		var as []attempt
+		for i := range n {
+			ip := fmt.Sprintf("198.51.100.%d", i/(maxPasswordFailures-1))
+			as = append(as, attempt{ip, "nope", at, "wrong password or PIN"})
+		}
+		return as
+	}
+	right := func(ip string, at time.Duration, want string) attempt {
+		return attempt{ip, "hunter2", at, want}
+	}
+	cat := func(parts ...[]attempt) []attempt {
+		var as []attempt
+		for _, p := range parts {
+			as = append(as, p...)
+		}
+		return as
+	}
+
+	tests := []struct {
+		name     string
+		attempts []attempt
+	}{
+		{
+			name:     "right answer",
+			attempts: []attempt{right("192.0.2.1", 0, "password")},
+		},
+		{
+			name: "under the per-IP limit",
+			attempts: cat(
+				wrong("192.0.2.1", maxPasswordFailures-1, 0),
+				[]attempt{right("192.0.2.1", 0, "password")},
+			),
+		},
+		{
+			name: "per-IP lockout",
+			attempts: cat(
+				wrong("192.0.2.1", maxPasswordFailures, 0),
+				[]attempt{
+					right("192.0.2.1", time.Minute, "locked out"),
+					right("192.0.2.2", time.Minute, "password"),
+				},
+			),
+		},
+		{
+			name: "per-IP lockout ends with the window",
+			attempts: cat(
+				wrong("192.0.2.1", maxPasswordFailures, 0),
+				[]attempt{
+					right("192.0.2.1", passwordLockout, "locked out"),
+					right("192.0.2.1", passwordLockout+time.Second, "password"),
+				},
+			),
+		},
+		{
+			name: "failures in an old window don't count",
+			attempts: cat(
+				wrong("192.0.2.1", maxPasswordFailures-1, 0),
+				wrong("192.0.2.1", maxPasswordFailures-1, passwordLockout+time.Second),
+				[]attempt{right("192.0.2.1", passwordLockout+time.Second, "password")},
+			),
+		},
+		{
+			name: "global lockout",
+			attempts: cat(
+				wrongFromMany(maxGlobalPasswordFailures, 0),
+				[]attempt{right("192.0.2.1", time.Minute, "locked out")},
+			),
+		},
+		{
+			name: "under the global limit",
+			attempts: cat(
+				wrongFromMany(maxGlobalPasswordFailures-1, 0),
+				[]attempt{right("192.0.2.1", time.Minute, "password")},
+			),
+		},
+		{
+			name: "global lockout ends with the window",
+			attempts: cat(
+				wrongFromMany(maxGlobalPasswordFailures, 0),
+				[]attempt{
+					right("192.0.2.1", passwordLockout, "locked out"),
+					right("192.0.2.1", passwordLockout+time.Second, "password"),
+				},
+			),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &passwords{
+				hash:     hash,
+				issued:   make(map[[sha256.Size]byte]time.Time),
+				failures: make(map[string]*passwordFailures),
+			}
+			start := time.Now()
+			for i, a := range tt.attempts {
+				ok, how := p.check(a.ip, a.answer, start.Add(a.at))
+				if how != a.want || ok != (a.want == "password") {
+					t.Fatalf("attempt %d (%s, %q at +%s): got %v, %q; want %q", i, a.ip, a.answer, a.at, ok, how, a.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// proxyHeaderTimeout is how long a load balancer gets to send the PROXY
+// header after connecting.
+const proxyHeaderTimeout = 5 * time.Second
+
+// maxPendingProxyHeaders caps the connections whose header is still being
+// read; more are dropped rather than each getting a goroutine.
+const maxPendingProxyHeaders = 64
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// EnableProxyProtocol expects a PROXY protocol (v1 or v2) header, as
+// HAProxy and AWS NLB send, at the start of SSH connections, and uses the
+// client address in it for logs, bans and rate limits. Only connections
+// from trusted (CIDRs or bare IPs) are expected to carry one and others
+// are taken as they come, so nobody else can claim an address. On a Unix
+// socket, which only local processes can reach, every connection must
+// carry one and trusted may be empty. Call before Start.
+func (s *Server) EnableProxyProtocol(trusted []string) error {
+	if _, onSocket := socketPath(s.addr); len(trusted) == 0 && !onSocket {
+		return errors.New("say which load balancers send PROXY headers with -proxy-from, or anyone could forge their address")
+	}
+	nets := make([]*net.IPNet, 0, len(trusted))
+	for _, t := range trusted {
+		if !strings.Contains(t, "/") {
+			if ip := net.ParseIP(t); ip != nil && ip.To4() != nil {
+				t += "/32"
+			} else {
+				t += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(t)
+		if err != nil {
+			return fmt.Errorf("proxy address %q: %w", t, err)
+		}
+		nets = append(nets, n)
+	}
+	s.proxyProtocol = true
+	s.proxyTrusted = nets
+	return nil
+}
+
+// proxyListener reads the PROXY header off each connection before handing
+// it on, so everything above sees the real client address. Headers are
+// read in their own goroutines, at most maxPendingProxyHeaders at once, so
+// one slow connection doesn't hold up the rest.
+type proxyListener struct {
+	net.Listener
+	trusted []*net.IPNet
+	logger  *log.Logger
+	auth    *authLog
+
+	pending   chan struct{} // a slot per header being read
+	conns     chan net.Conn
+	errs      chan error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newProxyListener(l net.Listener, trusted []*net.IPNet, logger *log.Logger, auth *authLog) *proxyListener {
+	p := &proxyListener{
+		Listener: l,
+		trusted:  trusted,
+		logger:   logger,
+		auth:     auth,
+		pending:  make(chan struct{}, maxPendingProxyHeaders),
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		closed:   make(chan struct{}),
+	}
+	go p.acceptLoop()
+	return p
+}
+
+func (p *proxyListener) acceptLoop() {
+	for {
+		conn, err := p.Listener.Accept()
+		if err != nil {
+			select {
+			case p.errs <- err:
+			case <-p.closed:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if !p.expectsHeader(conn.RemoteAddr()) {
+			go p.hand(conn)
+			continue
+		}
+		select {
+		case p.pending <- struct{}{}:
+		default:
+			p.logger.Warn("dropped connection, too many PROXY headers pending", "from", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		// the deadline is set before the goroutine starts, so it covers
+		// the whole wait for the header
+		conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		go func() {
+			conn, ok := p.handshake(conn)
+			<-p.pending
+			if ok {
+				p.hand(conn)
+			}
+		}()
+	}
+}
+
+// handshake replaces conn's remote address with the one in its header,
+// dropping connections whose header is missing or garbled.
+func (p *proxyListener) handshake(conn net.Conn) (net.Conn, bool) {
+	r := bufio.NewReader(conn)
+	remote, err := readProxyHeader(r)
+	if err != nil {
+		p.logger.Warn("dropped connection without a valid PROXY header", "from", conn.RemoteAddr(), "error", err)
+		p.auth.failure(remoteIP(conn.RemoteAddr()), authBadProxy, "", "")
+		conn.Close()
+		return nil, false
+	}
+	conn.SetReadDeadline(time.Time{})
+	if remote == nil {
+		// a health check or "UNKNOWN": the proxy itself is talking
+		remote = conn.RemoteAddr()
+	}
+	return &proxyConn{Conn: conn, r: r, remote: remote}, true
+}
+
+// hand passes conn on to Accept, or closes it if the listener closes first.
+func (p *proxyListener) hand(conn net.Conn) {
+	select {
+	case p.conns <- conn:
+	case <-p.closed:
+		conn.Close()
+	}
+}
+
+func (p *proxyListener) expectsHeader(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true // a Unix socket: only the local proxy can reach it
+	}
+	for _, n := range p.trusted {
+		if n.Contains(tcp.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *proxyListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-p.conns:
+		return conn, nil
+	case err := <-p.errs:
+		return nil, err
+	case <-p.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (p *proxyListener) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return p.Listener.Close()
+}
+
+// proxyConn is a connection whose header has been read: reads continue
+// from the buffer the header was read through, and RemoteAddr is the
+// client the proxy spoke for.
+type proxyConn struct {
+	net.Conn
+	r      *bufio.Reader
+	remote net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+func (c *proxyConn) RemoteAddr() net.Addr       { return c.remote }
+
+// readProxyHeader reads a v1 or v2 PROXY header and returns the client
+// address in it, or nil when the header says the connection is the
+// proxy's own (v1 UNKNOWN, v2 LOCAL or a non-IP family).
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	// peek a byte at a time: a short v1 header, or a client that isn't
+	// sending one at all, must not be left waiting for bytes that never
+	// come
+	v1 := []byte("PROXY ")
+	for n := 1; ; n++ {
+		start, err := r.Peek(n)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case bytes.Equal(start, v1):
+			return readProxyV1(r)
+		case bytes.Equal(start, proxyV2Signature):
+			return readProxyV2(r)
+		case !bytes.HasPrefix(v1, start) && !bytes.HasPrefix(proxyV2Signature, start):
+			return nil, errors.New("no PROXY header")
+		}
+	}
+}
+
+// readProxyV1 parses "PROXY TCP4 <src> <dst> <sport> <dport>\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < 107 { // the longest header the spec allows
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	text, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errors.New("v1 header not terminated")
+	}
+	fields := strings.Split(text, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("bad v1 header %q", text)
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("bad v1 source address %q", text)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 parses the binary header: signature, version and command,
+// address family, length, then addresses.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if hdr[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported v2 version %d", hdr[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if hdr[12]&0x0f == 0 { // LOCAL
+		return nil, nil
+	}
+	switch hdr[13] {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, errors.New("short v2 IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, errors.New("short v2 IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+	return nil, nil
+}
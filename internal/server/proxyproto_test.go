@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+// proxyV2 builds a v2 header with the given version/command and family
+// bytes, and body; length overrides the body's length when not -1.
+func proxyV2(verCmd, family byte, body []byte, length int) string {
+	if length < 0 {
+		length = len(body)
+	}
+	hdr := append([]byte(nil), proxyV2Signature...)
+	hdr = append(hdr, verCmd, family)
+	hdr = binary.BigEndian.AppendUint16(hdr, uint16(length))
+	return string(append(hdr, body...))
+}
+
+// v2Addrs is a v2 address block: source and destination IPs, then ports.
+func v2Addrs(src, dst []byte, sport, dport uint16) []byte {
+	body := append(append([]byte(nil), src...), dst...)
+	body = binary.BigEndian.AppendUint16(body, sport)
+	return binary.BigEndian.AppendUint16(body, dport)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ip4 := []byte{192, 0, 2, 1}
+	ip6 := []byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string // the client address, "" for the proxy's own connection
+		wantErr bool
+	}{
+		{name: "v1 TCP4", in: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 22\r\n", want: "192.0.2.1:56324"},
+		{name: "v1 TCP6", in: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 22\r\n", want: "[2001:db8::1]:56324"},
+		{name: "v1 UNKNOWN", in: "PROXY UNKNOWN\r\n"},
+		{name: "v1 UNKNOWN with addresses", in: "PROXY UNKNOWN 192.0.2.1 198.51.100.1 56324 22\r\n"},
+		{name: "v1 truncated", in: "PROXY TCP4 192.0.2.1 198.51.", wantErr: true},
+		{name: "v1 truncated signature", in: "PROX", wantErr: true},
+		{name: "v1 oversize", in: "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", wantErr: true},
+		{name: "v1 without CR", in: "PROXY TCP4 192.0.2.1 198.51.100.1 56324 22\n", wantErr: true},
+		{name: "v1 bad protocol", in: "PROXY UDP4 192.0.2.1 198.51.100.1 56324 22\r\n", wantErr: true},
+		{name: "v1 missing field", in: "PROXY TCP4 192.0.2.1 198.51.100.1 56324\r\n", wantErr: true},
+		{name: "v1 bad address", in: "PROXY TCP4 192.0.2.x 198.51.100.1 56324 22\r\n", wantErr: true},
+		{name: "v1 bad port", in: "PROXY TCP4 192.0.2.1 198.51.100.1 65536 22\r\n", wantErr: true},
+		{name: "no header", in: "SSH-2.0-OpenSSH_9.6\r\n", wantErr: true},
+		{name: "empty", in: "", wantErr: true},
+
+		{name: "v2 TCP4", in: proxyV2(0x21, 0x11, v2Addrs(ip4, ip4, 56324, 22), -1), want: "192.0.2.1:56324"},
+		{name: "v2 TCP6", in: proxyV2(0x21, 0x21, v2Addrs(ip6, ip6, 56324, 22), -1), want: "[2001:db8::1]:56324"},
+		{name: "v2 TCP4 with TLVs", in: proxyV2(0x21, 0x11, append(v2Addrs(ip4, ip4, 56324, 22), 0x04, 0, 1, 'x'), -1), want: "192.0.2.1:56324"},
+		{name: "v2 LOCAL", in: proxyV2(0x20, 0x00, nil, -1)},
+		{name: "v2 LOCAL with addresses", in: proxyV2(0x20, 0x11, v2Addrs(ip4, ip4, 56324, 22), -1)},
+		{name: "v2 unspecified family", in: proxyV2(0x21, 0x00, nil, -1)},
+		{name: "v2 Unix family", in: proxyV2(0x21, 0x31, make([]byte, 216), -1)},
+		{name: "v2 truncated header", in: proxyV2(0x21, 0x11, nil, -1)[:14], wantErr: true},
+		{name: "v2 truncated body", in: proxyV2(0x21, 0x11, v2Addrs(ip4, ip4, 56324, 22), -1)[:20], wantErr: true},
+		{name: "v2 oversize length", in: proxyV2(0x21, 0x11, v2Addrs(ip4, ip4, 56324, 22), 0xffff), wantErr: true},
+		{name: "v2 short IPv4 addresses", in: proxyV2(0x21, 0x11, ip4, -1), wantErr: true},
+		{name: "v2 short IPv6 addresses", in: proxyV2(0x21, 0x21, v2Addrs(ip4, ip4, 56324, 22), -1), wantErr: true},
+		{name: "v2 version 1", in: proxyV2(0x11, 0x11, v2Addrs(ip4, ip4, 56324, 22), -1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// what follows the header must be left for SSH to read
+			r := bufio.NewReader(strings.NewReader(tt.in + "SSH-2.0-client\r\n"))
+			if tt.wantErr {
+				r = bufio.NewReader(strings.NewReader(tt.in))
+			}
+			addr, err := readProxyHeader(r)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", addr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("got address %q, want %q", got, tt.want)
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "SSH-2.0-client\r\n" {
+				t.Errorf("left %q after the header", rest)
+			}
+		})
+	}
+}
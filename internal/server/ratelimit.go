@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/jaypopat/duet/internal/audit"
+)
+
+// RateLimit bounds how often one IP may connect. Zero Rate turns it off.
+type RateLimit struct {
+	Rate     float64       // connections per second refilled into each IP's bucket
+	Burst    int           // connections an IP may make back to back
+	BanAfter int           // failed auth attempts in a row before an IP is banned, 0 never
+	BanFor   time.Duration // how long a ban lasts
+}
+
+// DefaultRateLimit is plenty for people and slows scanners to a crawl.
+// Banning is off: behind a load balancer every client shares one IP.
+var DefaultRateLimit = RateLimit{
+	Rate:   20.0 / 60,
+	Burst:  10,
+	BanFor: 15 * time.Minute,
+}
+
+// ipIdle is how long an IP's state is kept once its bucket is full again
+// and it isn't banned.
+const ipIdle = 10 * time.Minute
+
+// ipState is one source address's token bucket and auth failure streak.
+type ipState struct {
+	tokens   float64
+	last     time.Time
+	failures int
+	banned   time.Time // banned until then
+	limited  bool      // refused for an empty bucket; logged once per streak
+}
+
+// rateLimiter throttles connection attempts per source IP and, if asked
+// to, bans ones that keep failing to authenticate, which is what password
+// guessers do. Connections dropped before auth, such as load balancer
+// health checks and port probes, never count towards a ban.
+type rateLimiter struct {
+	cfg    RateLimit
+	logger *log.Logger
+	audit  func(audit.Event)
+	auth   *authLog
+
+	mu     sync.Mutex
+	ips    map[string]*ipState
+	pruned time.Time
+}
+
+func newRateLimiter(cfg RateLimit, logger *log.Logger, record func(audit.Event), auth *authLog) *rateLimiter {
+	return &rateLimiter{cfg: cfg, logger: logger, audit: record, auth: auth, ips: make(map[string]*ipState)}
+}
+
+// option hooks the limiter into the SSH server: connections are counted
+// before the handshake, so throttled and banned IPs cost us nothing more
+// than an accept. Auth failures are reported through fail.
+func (l *rateLimiter) option() ssh.Option {
+	return func(s *ssh.Server) error {
+		s.ConnCallback = func(_ ssh.Context, conn net.Conn) net.Conn {
+			if !l.allow(remoteIP(conn.RemoteAddr()), time.Now()) {
+				return nil
+			}
+			return conn
+		}
+		return nil
+	}
+}
+
+// middleware clears an IP's failure streak once one of its sessions gets
+// through, so a person who mistyped a few times isn't one slip from a ban.
+func (l *rateLimiter) middleware(next ssh.Handler) ssh.Handler {
+	if l == nil {
+		return next
+	}
+	return func(sess ssh.Session) {
+		l.succeed(remoteIP(sess.RemoteAddr()))
+		next(sess)
+	}
+}
+
+// allow takes a token from ip's bucket, refusing banned IPs and empty
+// buckets.
+func (l *rateLimiter) allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	st, ok := l.ips[ip]
+	if !ok {
+		st = &ipState{tokens: float64(l.cfg.Burst), last: now}
+		l.ips[ip] = st
+	}
+	if now.Before(st.banned) {
+		l.auth.failure(ip, authBanned, "", "")
+		return false
+	}
+	st.tokens = min(float64(l.cfg.Burst), st.tokens+now.Sub(st.last).Seconds()*l.cfg.Rate)
+	st.last = now
+	if st.tokens < 1 {
+		if !st.limited {
+			l.logger.Warn("connection rate limited", "ip", ip)
+		}
+		st.limited = true
+		l.auth.failure(ip, authRateLimited, "", "")
+		return false
+	}
+	st.limited = false
+	st.tokens--
+	return true
+}
+
+// fail counts a refused auth attempt, banning ip once it has failed
+// BanAfter times in a row. A nil limiter counts nothing.
+func (l *rateLimiter) fail(ip string, now time.Time) {
+	if l == nil || l.cfg.BanAfter <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st, ok := l.ips[ip]
+	if !ok {
+		return
+	}
+	st.failures++
+	if st.failures >= l.cfg.BanAfter {
+		st.failures = 0
+		st.banned = now.Add(l.cfg.BanFor)
+		l.logger.Warn("banned IP after repeated auth failures", "ip", ip, "for", l.cfg.BanFor)
+		l.audit(audit.Event{Type: "ip_ban", Remote: ip, Detail: fmt.Sprintf("%d failed auth attempts, banned for %s", l.cfg.BanAfter, l.cfg.BanFor)})
+	}
+}
+
+func (l *rateLimiter) succeed(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if st, ok := l.ips[ip]; ok {
+		st.failures = 0
+	}
+}
+
+// prune forgets IPs that have been quiet long enough for their bucket to
+// refill and aren't banned, at most once a minute. Call with l.mu held.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute {
+		return
+	}
+	l.pruned = now
+	for ip, st := range l.ips {
+		if now.Sub(st.last) > ipIdle && now.After(st.banned) {
+			delete(l.ips, ip)
+		}
+	}
+}
+
+// remoteIP is addr without its port.
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jaypopat/duet/internal/audit"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type step struct {
+		fail bool          // report an auth failure rather than connect
+		ip   string        // "" is 192.0.2.1
+		at   time.Duration // after the first step
+		want bool          // whether a connection is allowed
+	}
+	connect := func(at time.Duration, want bool) step { return step{at: at, want: want} }
+	fail := func(at time.Duration) step { return step{fail: true, at: at} }
+
+	limit := RateLimit{Rate: 1, Burst: 2, BanAfter: 3, BanFor: time.Minute}
+	tests := []struct {
+		name  string
+		cfg   RateLimit
+		steps []step
+	}{
+		{
+			name:  "burst then empty",
+			cfg:   limit,
+			steps: []step{connect(0, true), connect(0, true), connect(0, false)},
+		},
+		{
+			name: "refills at the rate",
+			cfg:  limit,
+			steps: []step{
+				connect(0, true), connect(0, true), connect(0, false),
+				connect(500*time.Millisecond, false),
+				connect(time.Second, true), connect(time.Second, false),
+			},
+		},
+		{
+			name: "refills no further than the burst",
+			cfg:  limit,
+			steps: []step{
+				connect(0, true), connect(0, true),
+				connect(time.Hour, true), connect(time.Hour, true), connect(time.Hour, false),
+			},
+		},
+		{
+			name: "IPs have their own buckets",
+			cfg:  limit,
+			steps: []step{
+				connect(0, true), connect(0, true), connect(0, false),
+				{ip: "192.0.2.2", want: true},
+			},
+		},
+		{
+			name: "banned until BanFor is up",
+			cfg:  limit,
+			steps: []step{
+				connect(0, true), fail(0), fail(0), fail(0),
+				connect(30*time.Second, false),
+				connect(time.Minute, true),
+			},
+		},
+		{
+			name: "under BanAfter",
+			cfg:  limit,
+			steps: []step{
+				connect(0, true), fail(0), fail(0),
+				connect(time.Second, true),
+			},
+		},
+		{
+			name: "a ban is per IP",
+			cfg:  limit,
+			steps: []step{
+				connect(0, true), fail(0), fail(0), fail(0),
+				connect(time.Second, false),
+				{ip: "192.0.2.2", at: time.Second, want: true},
+			},
+		},
+		{
+			name: "BanAfter 0 never bans",
+			cfg:  RateLimit{Rate: 1, Burst: 2, BanFor: time.Minute},
+			steps: []step{
+				connect(0, true), fail(0), fail(0), fail(0), fail(0),
+				connect(time.Second, true),
+			},
+		},
+		{
+			name: "failures from IPs never seen don't count",
+			cfg:  limit,
+			steps: []step{
+				fail(0), fail(0), fail(0),
+				connect(0, true),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(tt.cfg, log.New(io.Discard), func(audit.Event) {}, nil)
+			start := time.Now()
+			for i, s := range tt.steps {
+				ip := s.ip
+				if ip == "" {
+					ip = "192.0.2.1"
+				}
+				if s.fail {
+					l.fail(ip, start.Add(s.at))
+					continue
+				}
+				if got := l.allow(ip, start.Add(s.at)); got != s.want {
+					t.Fatalf("step %d (%s at +%s): allowed %v, want %v", i, ip, s.at, got, s.want)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,31 +18,71 @@ import (
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/bubbletea"
-	"github.com/charmbracelet/wish/logging"
 	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/container"
+	"github.com/jaypopat/duet/internal/identity"
 	"github.com/jaypopat/duet/internal/room"
+	"github.com/jaypopat/duet/internal/terminal"
 	"github.com/jaypopat/duet/internal/ui"
 	"github.com/muesli/termenv"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 type Server struct {
-	addr        string
-	hostKeyPath string
-	roomManager *room.Manager
-	logger      *log.Logger
+	addr          string
+	publicAddr    string // where people ssh to, see SetPublicAddress
+	hostKeyPath   string
+	extraHostKeys []string // e.g. RSA and ECDSA keys for older clients, see AddHostKey
+	roomManager   *room.Manager
+	logger        *log.Logger
+
+	apiAddr  string // room management HTTP API; disabled when empty
+	apiToken string
+
+	adminToken string // enables the /api/admin endpoints; see EnableAdmin
+
+	webAddr string // web spectator; disabled when empty, see EnableWeb
+
+	debugAddr string // pprof and expvar; disabled when empty, see EnableDebug
+
+	rateLimit RateLimit
+	limiter   *rateLimiter // set by Start; nil when rate limiting is off
+
+	proxyProtocol bool         // read PROXY headers off SSH connections, see EnableProxyProtocol
+	proxyTrusted  []*net.IPNet // only from these; empty means from everyone
+
+	github *identity.GitHubKeys // when set, usernames must be GitHub handles owning the key
+
+	adminKeys []ssh.PublicKey // these land on the admin dashboard, see AddAdminKey
+
+	authLog *authLog // refused connections for fail2ban, see SetAuthLog
+
+	passwords *passwords // when set, keyless clients need the password or a PIN; see SetPassword
+
+	theme string // colours sessions get by default, see SetTheme
+	lang  string // language sessions get by default, see SetLanguage
+
+	linear bool // every session gets the linear view, see SetLinear
 }
 
-func New(addr, hostKeyPath, workerURL string) *Server {
+func New(addr, hostKeyPath, workerURL, workerToken string, limits room.Limits, store room.Store) *Server {
 	logger := log.NewWithOptions(os.Stderr, log.Options{
 		Prefix: "duet",
 	})
+	// code that logs through a context without one of ours still ends up here
+	log.SetDefault(logger)
 
-	var aiClient *ai.Client
+	var worker *ai.Client
+	var aiClient ai.Provider
 	if workerURL != "" {
-		aiClient = ai.NewClient(workerURL)
+		worker = ai.NewClient(workerURL, workerToken)
+		aiClient = worker
 	}
 
-	mgr := room.NewManager(workerURL, aiClient, logger)
+	mgr := room.NewManager(worker, aiClient, logger, limits, store)
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		mgr.SetSSHAddress(net.JoinHostPort("localhost", port))
+	}
 
 	return &Server{
 		addr:        addr,
@@ -47,15 +92,161 @@ func New(addr, hostKeyPath, workerURL string) *Server {
 	}
 }
 
+// SetPublicAddress sets the host (and port, unless 22) people ssh to, e.g.
+// "duet.example.com", for the invite commands rooms show. It defaults to
+// localhost on the listening port, and must be set when listening on a
+// Unix socket, which nobody can ssh to directly.
+func (s *Server) SetPublicAddress(addr string) {
+	s.publicAddr = addr
+	s.roomManager.SetSSHAddress(addr)
+}
+
+// JoinCluster makes this server node id among several sharing the room
+// store, reachable directly at addr (host[:port] to ssh to, not the load
+// balancer in front of them all). Joining a room another node hosts
+// points the client there. Call before Start.
+func (s *Server) JoinCluster(id, addr string) {
+	s.roomManager.SetNode(id, addr)
+}
+
+// SetRoomDefaults sets the shell, start directory and env used by rooms
+// that don't choose their own.
+func (s *Server) SetRoomDefaults(settings room.RoomSettings) {
+	s.roomManager.SetDefaultSettings(settings)
+}
+
+// UseOllama answers the AI sidebar with model on a local Ollama at host
+// instead of the Cloudflare worker. There's no sandbox in this mode.
+func (s *Server) UseOllama(host, model string) {
+	s.roomManager.SetAIClient(ai.NewOllama(host, model, s.roomManager.AIHistory))
+}
+
+// UseOpenAI answers the AI sidebar with model behind an OpenAI-compatible
+// API at baseURL instead of the Cloudflare worker. There's no sandbox in
+// this mode.
+func (s *Server) UseOpenAI(baseURL, apiKey, model string) {
+	s.roomManager.SetAIClient(ai.NewOpenAI(baseURL, apiKey, model, s.roomManager.AIHistory))
+}
+
+// SetAIRetry retries transient AI failures and pauses AI features while
+// the provider keeps failing. Call it after picking the provider.
+func (s *Server) SetAIRetry(cfg ai.RetryConfig) {
+	if p := s.roomManager.GetAIClient(); p != nil {
+		s.roomManager.SetAIClient(ai.WithRetry(p, cfg))
+	}
+}
+
+// RequireGitHubKeys only lets people in whose key is published on the
+// GitHub account matching their SSH username, so names in rooms are
+// verified handles. Keys are cached for ttl.
+func (s *Server) RequireGitHubKeys(ttl time.Duration) {
+	s.github = identity.NewGitHubKeys(ttl)
+}
+
+// AddAdminKey lets whoever holds the key in to the admin dashboard instead
+// of the launch screen. key is an authorized_keys line, or the path to a
+// file of them.
+func (s *Server) AddAdminKey(key string) error {
+	if k, _, _, _, err := gossh.ParseAuthorizedKey([]byte(key)); err == nil {
+		s.adminKeys = append(s.adminKeys, k)
+		return nil
+	}
+	data, err := os.ReadFile(key)
+	if err != nil {
+		return fmt.Errorf("admin key %q is neither a public key nor a readable file: %w", key, err)
+	}
+	for len(bytes.TrimSpace(data)) > 0 {
+		k, _, _, rest, err := gossh.ParseAuthorizedKey(data)
+		if err != nil {
+			return fmt.Errorf("admin keys in %s: %w", key, err)
+		}
+		s.adminKeys = append(s.adminKeys, k)
+		data = rest
+	}
+	return nil
+}
+
+func (s *Server) isAdmin(key ssh.PublicKey) bool {
+	for _, k := range s.adminKeys {
+		if ssh.KeysEqual(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRateLimit throttles connections per source IP; see RateLimit. Call
+// before Start.
+func (s *Server) SetRateLimit(cfg RateLimit) {
+	s.rateLimit = cfg
+}
+
+// SetInputPolicy makes room shells refuse command lines p blocks.
+func (s *Server) SetInputPolicy(p *terminal.InputPolicy) {
+	s.roomManager.SetInputPolicy(p)
+}
+
+// SetContainerConfig runs room shells in containers built from cfg.
+func (s *Server) SetContainerConfig(cfg container.Config) {
+	s.roomManager.SetContainerConfig(cfg)
+}
+
 func (s *Server) Start() error {
-	srv, err := wish.NewServer(
+	// password sessions carry no key, so rooms can't demand one
+	s.roomManager.SetKeylessLogins(s.passwords != nil)
+	if err := s.roomManager.Restore(); err != nil {
+		return fmt.Errorf("failed to restore rooms: %w", err)
+	}
+
+	if p := s.roomManager.GetAIClient(); p == nil {
+		s.logger.Warn("AI features disabled: no worker or model configured")
+	} else {
+		s.logger.Info("AI features enabled", "sandbox", ai.HasSandbox(p))
+	}
+
+	_, onSocket := socketPath(s.addr)
+	if onSocket && s.publicAddr == "" {
+		// invites would otherwise send people to a localhost port nothing listens on
+		return errors.New("a Unix socket needs -public-addr, the address of the SSH proxy in front of it, for invite commands")
+	}
+	switch {
+	case onSocket && !s.proxyProtocol:
+		// every client of a socket looks alike, so limits would lump them
+		// together; with PROXY headers they have their real addresses
+		s.logger.Info("Not rate limiting connections on a Unix socket")
+	case s.rateLimit.Rate > 0:
+		s.limiter = newRateLimiter(s.rateLimit, s.logger, s.audit, s.authLog)
+	}
+	limiter := s.limiter // nil when off; its middleware passes through
+
+	hostKeys, err := s.hostKeyOptions()
+	if err != nil {
+		return err
+	}
+	opts := append(hostKeys,
 		wish.WithAddress(s.addr),
-		wish.WithHostKeyPath(s.hostKeyPath),
+		// Accept everyone (unless GitHub keys are required), but let clients
+		// present a key so bans can target its fingerprint rather than just
+		// the (self-chosen) username
+		wish.WithPublicKeyAuth(s.publicKeyAuth),
+		wish.WithKeyboardInteractiveAuth(s.keyboardInteractiveAuth),
 		wish.WithMiddleware(
-			bubbletea.Middleware(s.teaHandler),
-			logging.Middleware(),
+			bubbletea.MiddlewareWithProgramHandler(s.programHandler, termenv.Ascii),
+			s.endSessions,
+			s.logSessions,
+			limiter.middleware,
 		),
 	)
+	if s.passwords != nil {
+		opts = append(opts, wish.WithPasswordAuth(s.checkPassword))
+	}
+	if limiter != nil {
+		opts = append(opts, limiter.option())
+	}
+	if s.authLog != nil {
+		opts = append(opts, s.authLog.option())
+	}
+	srv, err := wish.NewServer(opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create server: %w", err)
 	}
@@ -63,9 +254,68 @@ func (s *Server) Start() error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	go s.roomManager.RunReaper(ctx)
+	go s.roomManager.RunLeases(ctx)
+
+	var apiSrv *http.Server
+	if s.apiAddr != "" {
+		if s.apiToken == "" {
+			return errors.New("API enabled without a token")
+		}
+		apiSrv = &http.Server{
+			Addr:              s.apiAddr,
+			Handler:           s.apiHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			s.logger.Info("Starting HTTP API", "address", s.apiAddr)
+			if err := apiSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("HTTP API error", "error", err)
+			}
+		}()
+	}
+
+	var webSrv *http.Server
+	if s.webAddr != "" {
+		webSrv = &http.Server{
+			Addr:              s.webAddr,
+			Handler:           s.webHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			s.logger.Info("Starting web spectator", "address", s.webAddr)
+			if err := webSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("Web spectator error", "error", err)
+			}
+		}()
+	}
+
+	var debugSrv *http.Server
+	if s.debugAddr != "" {
+		s.publishDebugVars()
+		debugSrv = &http.Server{
+			Addr:              s.debugAddr,
+			Handler:           s.debugHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			s.logger.Info("Starting debug server", "address", s.debugAddr)
+			if err := debugSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("Debug server error", "error", err)
+			}
+		}()
+	}
+
+	ln, err := listen(s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	if s.proxyProtocol {
+		ln = newProxyListener(ln, s.proxyTrusted, s.logger, s.authLog)
+	}
 	go func() {
-		s.logger.Info("Starting SSH server", "address", s.addr)
-		if err := srv.ListenAndServe(); err != nil {
+		s.logger.Info("Starting SSH server", "address", s.addr, "proxyProtocol", s.proxyProtocol)
+		if err := srv.Serve(ln); err != nil {
 			s.logger.Error("Server error", "error", err)
 		}
 	}()
@@ -76,14 +326,100 @@ func (s *Server) Start() error {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	return srv.Shutdown(shutdownCtx)
+	if apiSrv != nil {
+		apiSrv.Shutdown(shutdownCtx)
+	}
+	if webSrv != nil {
+		webSrv.Shutdown(shutdownCtx)
+	}
+	if debugSrv != nil {
+		debugSrv.Shutdown(shutdownCtx)
+	}
+
+	err = srv.Shutdown(shutdownCtx)
+	s.roomManager.Shutdown(shutdownCtx)
+	return err
 }
 
-func (s *Server) teaHandler(sess ssh.Session) (tea.Model, []tea.ProgramOption) {
-	username := sess.User()
-	if username == "" {
-		username = "guest"
+// publicKeyAuth accepts any key, unless usernames have to be GitHub
+// handles, in which case the key must be one the user published there
+// (or an admin key), or a password is required, in which case only admin
+// keys skip it.
+func (s *Server) publicKeyAuth(ctx ssh.Context, key ssh.PublicKey) bool {
+	e := sessionAudit(ctx, key)
+	e.Type = "auth"
+	ok := true
+	switch {
+	case s.isAdmin(key):
+		e.Detail = "admin key"
+	case s.github != nil:
+		var err error
+		ok, err = s.github.Verify(ctx, ctx.User(), key)
+		switch {
+		case err != nil:
+			s.sessionLog(ctx).Warn("GitHub key lookup failed", "error", err)
+			e.Detail = "GitHub key lookup failed"
+			s.authLog.sessionFailure(ctx, authGitHubLookup, e.Fingerprint) // GitHub's fault, not theirs
+		case !ok:
+			s.sessionLog(ctx).Info("key not on GitHub account", "fingerprint", e.Fingerprint)
+			e.Detail = "key not on GitHub account"
+			s.authFailure(ctx, authKeyNotOnGitHub, e.Fingerprint)
+		default:
+			e.Detail = "key on GitHub account"
+		}
+	case s.passwords != nil:
+		// the client falls back to the password prompt
+		ok = false
+		e.Detail = "password or PIN required"
+	}
+	e.Result = "accepted"
+	if !ok {
+		e.Result = "rejected"
+	}
+	s.authDecided(ctx, e)
+	return ok
+}
+
+// keyboardInteractiveAuth lets clients without a key in, unless usernames
+// have to be proven with GitHub keys or they must know the password.
+func (s *Server) keyboardInteractiveAuth(ctx ssh.Context, challenge gossh.KeyboardInteractiveChallenge) bool {
+	if s.github == nil && s.passwords != nil {
+		return s.promptPassword(ctx, challenge)
+	}
+	e := sessionAudit(ctx, nil)
+	e.Type, e.Detail, e.Result = "auth", "no key", "accepted"
+	if s.github != nil {
+		e.Result = "rejected"
+		s.authFailure(ctx, authNoKey, "")
 	}
+	s.authDecided(ctx, e)
+	return s.github == nil
+}
+
+// sessionModelKey holds a session's *ui.Model, for endSessions.
+type sessionModelKey struct{}
+
+// endSessions leaves whatever room a session was in once its UI stops.
+// Quitting from the UI does that itself, but when the connection drops
+// the session's context is cancelled and the program stops without the
+// model seeing it, which would leave a ghost in the room pinning its
+// terminal size.
+func (s *Server) endSessions(next ssh.Handler) ssh.Handler {
+	return func(sess ssh.Session) {
+		next(sess) // returns once the program has stopped
+		if model, ok := sess.Context().Value(sessionModelKey{}).(*ui.Model); ok {
+			model.Close()
+		}
+	}
+}
+
+// programHandler builds the UI for one SSH session. Input goes through
+// ui.Input so raw passthrough can take the keyboard over cleanly.
+func (s *Server) programHandler(sess ssh.Session) *tea.Program {
+	username, args := s.displayName(sess)
+	theme, args := s.sessionTheme(sess, args)
+	lang, args := s.sessionLanguage(sess, args)
+	linear, args := sessionLinear(sess, args)
 	renderer := bubbletea.MakeRenderer(sess)
 
 	pty, _, _ := sess.Pty()
@@ -92,11 +428,50 @@ func (s *Server) teaHandler(sess ssh.Session) (tea.Model, []tea.ProgramOption) {
 		renderer.SetColorProfile(termenv.TrueColor)
 	}
 
-	s.logger.Info("final renderer",
+	logger := s.sessionLog(sess.Context())
+	logger.Debug("final renderer",
 		"profile", renderer.ColorProfile(),
 		"hasDark", renderer.HasDarkBackground(),
 	)
-	return ui.New(renderer, s.roomManager, username), []tea.ProgramOption{
-		tea.WithAltScreen(),
+	var fingerprint string
+	if key := sess.PublicKey(); key != nil {
+		fingerprint = gossh.FingerprintSHA256(key)
+	}
+
+	in := ui.NewInput(sess)
+	base := sessionAudit(sess.Context(), sess.PublicKey())
+	if key := sess.PublicKey(); key != nil && s.isAdmin(key) {
+		logger.Info("admin dashboard", "fingerprint", fingerprint)
+		base.Type = "admin_dashboard"
+		s.audit(base)
+		dashboard := ui.NewAdmin(renderer, s.roomManager, username)
+		dashboard.SetTheme(cmp.Or(theme, s.theme))
+		dashboard.SetAudit(base)
+		return tea.NewProgram(dashboard,
+			tea.WithAltScreen(),
+			tea.WithInput(in),
+			tea.WithOutput(sess),
+		)
+	}
+	out := ui.NewOutput(sess)
+	model := ui.New(renderer, s.roomManager, username, fingerprint, out, in)
+	model.SetLogger(logger)
+	if theme != "" {
+		model.SetTheme(theme)
+	} else {
+		model.SetDefaultTheme(s.theme)
+	}
+	model.SetLanguage(cmp.Or(lang, s.lang))
+	linear = linear || s.linear
+	model.SetLinear(linear)
+	model.SetAudit(base)
+	model.SetTraceParent(sessionSpan(sess.Context()))
+	model.SetCommand(args)
+	sess.Context().SetValue(sessionModelKey{}, model)
+	opts := []tea.ProgramOption{tea.WithInput(in), tea.WithOutput(out)}
+	if !linear {
+		// the linear view prints lines that must stay in the scrollback
+		opts = append(opts, tea.WithAltScreen())
 	}
+	return tea.NewProgram(model, opts...)
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/jaypopat/duet/internal/ui"
+)
+
+// themeEnv is the variable a client can send, with
+// "ssh -o SetEnv=DUET_THEME=light", to pick its colours.
+const themeEnv = "DUET_THEME"
+
+// SetTheme sets the colours sessions get unless they pick their own; see
+// ui.ThemeNames. Call before Start.
+func (s *Server) SetTheme(name string) error {
+	if !ui.ValidTheme(name) {
+		return fmt.Errorf("unknown theme %q (want one of %s)", name, strings.Join(ui.ThemeNames(), ", "))
+	}
+	s.theme = name
+	return nil
+}
+
+// sessionTheme is the theme a session asked for, with "--theme <name>"
+// in its command, or $DUET_THEME, and args with the flag taken out. It is
+// empty when the session didn't ask, or asked for one we don't know, so
+// the profile's theme or the server's applies.
+func (s *Server) sessionTheme(sess ssh.Session, args []string) (string, []string) {
+	chosen, rest := sessionOption(sess, args, "theme", themeEnv)
+	if chosen != "" && !ui.ValidTheme(chosen) {
+		s.sessionLog(sess.Context()).Info("ignoring unknown theme", "theme", chosen)
+		return "", rest
+	}
+	return chosen, rest
+}
+
+// sessionOption is what a session set with "--<name> <value>" or
+// "--<name>=<value>" in its command, else with the env variable, and args
+// with the flag taken out.
+func sessionOption(sess ssh.Session, args []string, name, env string) (string, []string) {
+	var chosen string
+	for _, kv := range sess.Environ() {
+		if v, ok := strings.CutPrefix(kv, env+"="); ok {
+			chosen = v
+		}
+	}
+	flag := "--" + name
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag && i+1 < len(args):
+			chosen = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], flag+"="):
+			chosen = strings.TrimPrefix(args[i], flag+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return chosen, rest
+}
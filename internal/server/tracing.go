@@ -0,0 +1,15 @@
+package server
+
+import (
+	"github.com/charmbracelet/ssh"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sessionSpanKey holds the trace.SpanContext of a session's span in its
+// ssh.Context, so what the session does can be traced under it.
+type sessionSpanKey struct{}
+
+func sessionSpan(ctx ssh.Context) trace.SpanContext {
+	sc, _ := ctx.Value(sessionSpanKey{}).(trace.SpanContext)
+	return sc
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+	"time"
+
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/room"
+	"github.com/jaypopat/duet/internal/terminal"
+	"golang.org/x/net/websocket"
+)
+
+//go:embed web/watch.html
+var watchPage []byte
+
+// webAuthor is who browser input is attributed to; it's checked like a
+// guest's by the input policy.
+var webAuthor = terminal.Author{Name: "web", Guest: true}
+
+// webControl is a message to the browser, sent as a text frame; terminal
+// output goes in binary frames.
+type webControl struct {
+	Type    string `json:"type"` // "size" or "error"
+	Cols    int    `json:"cols,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// EnableWeb serves room terminals to browsers on addr: /watch/<room ID>
+// with ?watch= from the room's "web" command to watch, or ?token= from
+// "web control" to type as well. publicURL is the base URL given out in links. Call before Start.
+func (s *Server) EnableWeb(addr, publicURL string) {
+	s.webAddr = addr
+	if publicURL == "" {
+		publicURL = "http://localhost" + addr
+	}
+	s.roomManager.SetWebURL(publicURL)
+}
+
+func (s *Server) webHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /watch/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(watchPage)
+	})
+	mux.Handle("GET /watch/{id}/ws", websocket.Handler(s.serveWatch))
+	return mux
+}
+
+// serveWatch streams a room's main terminal to a browser: a snapshot of
+// the screen, then raw PTY output as it comes, like raw view over SSH.
+func (s *Server) serveWatch(ws *websocket.Conn) {
+	defer ws.Close()
+	req := ws.Request()
+
+	rm, err := s.roomManager.GetRoom(req.PathValue("id"))
+	if err != nil {
+		websocket.JSON.Send(ws, webControl{Type: "error", Message: "room not found"})
+		return
+	}
+	// ?token= is a control link, ?watch= a watch link; both are revocable
+	token, watch := req.URL.Query().Get("token"), req.URL.Query().Get("watch")
+	interactive := token != "" && rm.CheckWebToken(token)
+	if !interactive && !rm.CheckWatchToken(watch) {
+		websocket.JSON.Send(ws, webControl{Type: "error", Message: "this link has been revoked; ask someone in the room for a new one"})
+		return
+	}
+	if err := rm.AddWebViewer(); err != nil {
+		websocket.JSON.Send(ws, webControl{Type: "error", Message: "the room is full"})
+		return
+	}
+	defer rm.RemoveWebViewer()
+	allowed := func() bool {
+		if interactive {
+			return rm.CheckWebToken(token)
+		}
+		return rm.CheckWatchToken(watch)
+	}
+	t := rm.GetTerminal()
+	if t == nil {
+		websocket.JSON.Send(ws, webControl{Type: "error", Message: "the room's shell hasn't started yet"})
+		return
+	}
+
+	mode := "watching"
+	if interactive {
+		mode = "controlling"
+	}
+	s.logger.Info("web viewer connected", "roomID", rm.ID, "mode", mode, "remote", req.RemoteAddr)
+	rm.BroadcastEvent(room.RoomEvent{Type: "web_viewer", Data: mode}, "")
+	s.audit(audit.Event{Type: "web_viewer", User: webAuthor.Name, Remote: req.RemoteAddr, RoomID: rm.ID, Detail: mode})
+
+	stream := t.SubscribeRaw()
+	defer t.UnsubscribeRaw(stream)
+
+	stop := make(chan struct{})
+	if interactive {
+		go func() {
+			defer close(stop)
+			for {
+				var data []byte
+				if err := websocket.Message.Receive(ws, &data); err != nil {
+					return
+				}
+				// re-check so "web revoke" cuts off browsers already typing
+				if allowed() {
+					t.WriteFrom(webAuthor, data)
+				}
+			}
+		}()
+	}
+
+	cols, rows := t.Size()
+	if websocket.JSON.Send(ws, webControl{Type: "size", Cols: cols, Rows: rows}) != nil ||
+		websocket.Message.Send(ws, t.Snapshot()) != nil {
+		return
+	}
+
+	check := time.NewTicker(250 * time.Millisecond)
+	defer check.Stop()
+	for {
+		select {
+		case chunk, ok := <-stream.C:
+			if !ok {
+				return
+			}
+			if stream.Lagged() {
+				stream.Drain()
+				chunk = t.Snapshot()
+			}
+			if websocket.Message.Send(ws, chunk) != nil {
+				return
+			}
+		case <-check.C:
+			if !allowed() {
+				websocket.JSON.Send(ws, webControl{Type: "error", Message: "this link has been revoked"})
+				return
+			}
+			if exited, _ := t.Exited(); exited {
+				websocket.JSON.Send(ws, webControl{Type: "error", Message: "the shell exited"})
+				return
+			}
+			if c, r := t.Size(); c != cols || r != rows {
+				cols, rows = c, r
+				if websocket.JSON.Send(ws, webControl{Type: "size", Cols: cols, Rows: rows}) != nil {
+					return
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
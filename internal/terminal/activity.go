@@ -0,0 +1,34 @@
+package terminal
+
+import "time"
+
+// Activity is what the terminal has been doing lately.
+type Activity struct {
+	LastInput  time.Time // last keystroke or paste sent to the shell
+	LastOutput time.Time // last output read from the shell
+	Running    bool      // something other than the shell owns the foreground
+}
+
+// Traffic returns the bytes typed into and printed by the shell so far,
+// across restarts. Sample it twice for a rate.
+func (t *Terminal) Traffic() (in, out uint64) {
+	return t.bytesIn.Load(), t.bytesOut.Load()
+}
+
+// Activity reports recent input and output and whether a command is running.
+// Running is only known for plain shells; behind tmux or a container exec
+// the foreground process is always the client, so it stays false.
+func (t *Terminal) Activity() Activity {
+	t.mu.Lock()
+	a := Activity{LastInput: t.lastInput, LastOutput: t.lastOutput}
+	ptmx, cmd, tmux := t.ptmx, t.cmd, t.tmux
+	command := len(t.command) > 0
+	t.mu.Unlock()
+
+	if ptmx != nil && cmd != nil && cmd.Process != nil && tmux == "" && !command {
+		if pgrp, ok := foregroundGroup(ptmx); ok {
+			a.Running = pgrp != cmd.Process.Pid
+		}
+	}
+	return a
+}
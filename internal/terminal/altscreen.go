@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"bytes"
+
+	"github.com/hinshun/vt10x"
+)
+
+// altScreenToggles are the private modes full-screen programs use to switch
+// to and from the alternate screen.
+var altScreenToggles = [][]byte{
+	[]byte("\x1b[?1049h"), []byte("\x1b[?1049l"),
+	[]byte("\x1b[?1047h"), []byte("\x1b[?1047l"),
+	[]byte("\x1b[?47h"), []byte("\x1b[?47l"),
+}
+
+// clearScreen blanks the screen without moving the cursor.
+var clearScreen = []byte("\x1b[2J")
+
+// nextAltScreenToggle returns the end offset of the first alternate screen
+// switch in p, or -1 if there is none.
+func nextAltScreenToggle(p []byte) int {
+	end := -1
+	for _, seq := range altScreenToggles {
+		if i := bytes.Index(p, seq); i >= 0 && (end < 0 || i+len(seq) < end) {
+			end = i + len(seq)
+		}
+	}
+	return end
+}
+
+// InAltScreen reports whether a full-screen program (vim, less, htop) has
+// the alternate screen up.
+func (t *Terminal) InAltScreen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inAltScreenLocked()
+}
+
+func (t *Terminal) inAltScreenLocked() bool {
+	return t.vt != nil && t.vt.Mode()&vt10x.ModeAltScreen != 0
+}
+
+// feed writes PTY output to the emulator and scrollback, split at alternate
+// screen switches. Output drawn on the alternate screen is kept out of
+// scrollback, since it's a full-screen program's redraws rather than lines
+// of history. vt10x reuses whatever was left on the alternate screen last
+// time, so it's cleared on entry, as xterm does; on exit vt10x swaps the
+// primary screen back and we redraw every row. Callers hold t.mu.
+func (t *Terminal) feed(p []byte) {
+	for len(p) > 0 {
+		seg := p
+		if end := nextAltScreenToggle(p); end >= 0 {
+			seg = p[:end]
+		}
+		p = p[len(seg):]
+
+		wasAlt := t.inAltScreenLocked()
+		if !wasAlt && t.history != nil {
+			t.history.write(seg)
+		}
+		if t.vt == nil {
+			continue
+		}
+		t.cells.Write(seg)
+		t.gen++
+
+		switch isAlt := t.inAltScreenLocked(); {
+		case isAlt && !wasAlt:
+			t.vt.Write(clearScreen)
+		case wasAlt && !isAlt:
+			t.rows = nil
+		}
+	}
+}
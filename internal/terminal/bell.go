@@ -0,0 +1,29 @@
+package terminal
+
+import "time"
+
+// bellInterval limits how often a terminal reports its bell; shells ring on
+// every failed tab completion and nobody needs a toast for each one.
+const bellInterval = 2 * time.Second
+
+// SetBellHook registers fn to be called when the program rings the bell.
+// It runs on the read loop, at most once per bellInterval, so it must not
+// block.
+func (t *Terminal) SetBellHook(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onBell = fn
+}
+
+// takeBell reports whether the output fed since the last call rang the bell
+// and the hook is due to fire. Callers hold t.mu.
+func (t *Terminal) takeBell() bool {
+	if t.cells == nil || !t.cells.Rang() {
+		return false
+	}
+	if time.Since(t.lastBell) < bellInterval {
+		return false
+	}
+	t.lastBell = time.Now()
+	return true
+}
@@ -0,0 +1,20 @@
+package terminal
+
+import (
+	"errors"
+
+	"github.com/anmitsu/go-shlex"
+)
+
+// ParseCommand splits a shell setting such as "zsh -l" or
+// `docker compose exec app bash` into argv, honouring shell quoting.
+func ParseCommand(s string) ([]string, error) {
+	argv, err := shlex.Split(s, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return argv, nil
+}
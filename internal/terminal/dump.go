@@ -0,0 +1,75 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hinshun/vt10x"
+)
+
+// Dump returns everything the terminal still holds, scrollback followed by
+// the current screen, once as plain text and once with the screen's colours
+// and attributes kept. History lines that are also on screen are only
+// included once.
+func (t *Terminal) Dump() (plain, colored string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var history []string
+	if t.history != nil {
+		history = make([]string, t.history.len())
+		for i := range history {
+			history[i] = strings.TrimRight(t.history.line(i), " ")
+		}
+	}
+
+	var screenPlain, screenColored []string
+	if t.vt != nil {
+		cols, rows := t.vt.Size()
+		var cells []vt10x.Glyph
+		for y := range rows {
+			cells = readRow(t.vt, y, cols, cells)
+			var sb strings.Builder
+			renderRow(&sb, cells, -1)
+			screenColored = append(screenColored, sb.String())
+			screenPlain = append(screenPlain, strings.TrimRight(ansi.Strip(sb.String()), " "))
+		}
+	}
+
+	// drop trailing blank rows below the prompt
+	used := len(screenPlain)
+	for used > 0 && screenPlain[used-1] == "" {
+		used--
+	}
+	screenPlain, screenColored = screenPlain[:used], screenColored[:used]
+
+	// in the common case the screen is just the tail of the history
+	history = history[:len(history)-screenOverlap(history, screenPlain)]
+
+	plain = strings.Join(append(history, screenPlain...), "\n") + "\n"
+	colored = strings.Join(append(history, screenColored...), "\n") + "\n"
+	return plain, colored
+}
+
+// screenOverlap returns how many lines at the end of history repeat the
+// screen's rows. The match may start below the top row, since rows there can
+// be wrapped or redrawn lines that history stores differently.
+func screenOverlap(history, screen []string) int {
+	for start := range screen {
+		tail := screen[start:]
+		if len(tail) > len(history) {
+			continue
+		}
+		match := true
+		for i, row := range tail {
+			if history[len(history)-len(tail)+i] != row {
+				match = false
+				break
+			}
+		}
+		if match {
+			return len(tail)
+		}
+	}
+	return 0
+}
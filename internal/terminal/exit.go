@@ -0,0 +1,98 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var ErrStillRunning = errors.New("shell is still running")
+
+// SetExitHook registers fn to be called with the exit code when the shell
+// exits on its own (exit, crash). It isn't called when the terminal is
+// closed. The code is -1 if the process was killed by a signal.
+func (t *Terminal) SetExitHook(fn func(code int)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onExit = fn
+}
+
+// Exited reports whether the shell has exited on its own, and its exit code.
+func (t *Terminal) Exited() (bool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.exited, t.exitCode
+}
+
+// shellExited reaps the process after the PTY stops reading and, unless
+// the terminal was closed on purpose, records the exit and runs the hook.
+func (t *Terminal) shellExited(ptmx *os.File) {
+	t.mu.Lock()
+	deliberate := t.closed
+	cmd := t.cmd
+	if t.ptmx == ptmx {
+		ptmx.Close()
+		t.ptmx = nil
+	}
+	t.mu.Unlock()
+
+	code := -1
+	if cmd != nil {
+		cmd.Wait()
+		if cmd.ProcessState != nil {
+			code = cmd.ProcessState.ExitCode()
+		}
+	}
+	if deliberate {
+		return
+	}
+
+	t.mu.Lock()
+	t.exited = true
+	t.exitCode = code
+	t.closed = true
+	t.gen++
+	onExit := t.onExit
+	logger := t.logger
+	t.mu.Unlock()
+
+	if logger != nil {
+		logger.Debug("shell exited", "code", code)
+	}
+
+	// let viewers redraw with the shell gone
+	t.broadcast()
+	if onExit != nil {
+		onExit(code)
+	}
+}
+
+// Restart starts the shell again in place after it exited, with the same
+// command, directory and environment. Subscribers and scrollback carry
+// over, so everyone watching just sees a fresh prompt.
+func (t *Terminal) Restart() error {
+	t.mu.Lock()
+	if !t.exited {
+		t.mu.Unlock()
+		return ErrStillRunning
+	}
+	t.exited = false
+	t.exitCode = 0
+	t.closed = false
+	t.bracketedPaste = false
+	t.lastBell = time.Time{}
+	t.rows = nil
+	t.gen++
+	t.mu.Unlock()
+
+	if err := t.Start(); err != nil {
+		t.mu.Lock()
+		t.exited = true
+		t.exitCode = -1
+		t.closed = true
+		t.mu.Unlock()
+		return err
+	}
+	t.broadcast()
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package terminal
+
+import "os"
+
+func foregroundGroup(ptmx *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// foregroundGroup returns the process group in the foreground of the PTY.
+// The shell leads its own group, so anything else means a command is running.
+// It goes through SyscallConn because Fd would put the PTY master back into
+// blocking mode, breaking the reader's deadlines and Close.
+func foregroundGroup(ptmx *os.File) (int, bool) {
+	conn, err := ptmx.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	var pgrp int
+	var ioctlErr error
+	if err := conn.Control(func(fd uintptr) {
+		pgrp, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	}); err != nil {
+		return 0, false
+	}
+	return pgrp, ioctlErr == nil
+}
@@ -0,0 +1,51 @@
+package terminal
+
+import "time"
+
+// DefaultFrameRate caps how many updates per second subscribers get. A
+// `cat` of a big file produces a read every few KB; nobody can see more
+// than this many frames anyway, and every update costs each client a render.
+const DefaultFrameRate = 30
+
+// SetFrameRate caps subscriber updates at fps per second. Zero restores
+// the default; a negative rate sends an update for every read.
+func (t *Terminal) SetFrameRate(fps int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case fps == 0:
+		fps = DefaultFrameRate
+	case fps < 0:
+		t.frameInterval = 0
+		return
+	}
+	t.frameInterval = time.Second / time.Duration(fps)
+}
+
+// scheduleBroadcast notifies subscribers of new output, at most once per
+// frame interval. Output arriving within a frame is merged into a single
+// update sent when the frame is up.
+func (t *Terminal) scheduleBroadcast() {
+	t.mu.Lock()
+	if t.framePending {
+		t.mu.Unlock()
+		return
+	}
+	wait := t.frameInterval - time.Since(t.lastFrame)
+	if wait <= 0 {
+		t.lastFrame = time.Now()
+		t.mu.Unlock()
+		t.broadcast()
+		return
+	}
+	t.framePending = true
+	t.mu.Unlock()
+
+	time.AfterFunc(wait, func() {
+		t.mu.Lock()
+		t.framePending = false
+		t.lastFrame = time.Now()
+		t.mu.Unlock()
+		t.broadcast()
+	})
+}
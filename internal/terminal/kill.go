@@ -0,0 +1,30 @@
+package terminal
+
+import "time"
+
+// killGrace is how long processes get to exit after SIGTERM before they're
+// killed outright.
+const killGrace = 3 * time.Second
+
+// Done is closed once a closed terminal's processes are all gone (or were
+// sent SIGKILL). Waiting on it lets shutdown finish the job before exiting.
+func (t *Terminal) Done() <-chan struct{} {
+	return t.done
+}
+
+// killTree stops everything the shell started: SIGTERM first, then SIGKILL
+// for whatever is still around after killGrace.
+func (t *Terminal) killTree(pid int) {
+	defer close(t.done)
+	if !signalTree(pid, sigTerm) {
+		return
+	}
+	deadline := time.Now().Add(killGrace)
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		if !signalTree(pid, 0) {
+			return
+		}
+	}
+	signalTree(pid, sigKill)
+}
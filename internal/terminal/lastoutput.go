@@ -0,0 +1,55 @@
+package terminal
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// promptChars end a typical shell prompt: bash/sh ($ #), zsh (%), fish and
+// friends (> ❯).
+const promptChars = "$#%>❯"
+
+// LastCommand returns the most recent command line and what it printed,
+// read back from scrollback and the screen, at most maxLines lines. There
+// are no prompt markers to go by, so the previous prompt is found by
+// looking for a line that starts the way the current prompt does; when
+// none is found the last maxLines lines are returned.
+func (t *Terminal) LastCommand(maxLines int) string {
+	plain, _ := t.Dump()
+	lines := strings.Split(strings.TrimRight(plain, "\n"), "\n")
+	if len(lines) < 2 {
+		return ""
+	}
+
+	// the last line is the prompt waiting for the next command
+	prefix := promptPrefix(lines[len(lines)-1])
+	lines = lines[:len(lines)-1]
+	start := max(len(lines)-maxLines, 0)
+	if prefix != "" {
+		for i := len(lines) - 1; i >= start; i-- {
+			if strings.HasPrefix(lines[i], prefix) {
+				start = i
+				break
+			}
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines[start:], "\n"))
+}
+
+// promptPrefix is the start of a prompt line that stays the same from one
+// prompt to the next: everything before the working directory, which is
+// usually the first thing to change. For "me@box:~/src$ " that's "me@box:".
+func promptPrefix(line string) string {
+	end := strings.LastIndexAny(line, promptChars)
+	if end < 0 {
+		return ""
+	}
+	prompt := line[:end]
+	if i := strings.IndexAny(prompt, ":~/ "); i > 0 {
+		prompt = prompt[:i+1]
+	} else {
+		_, size := utf8.DecodeLastRuneInString(line[end:])
+		prompt = line[:end+size]
+	}
+	return prompt
+}
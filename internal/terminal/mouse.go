@@ -0,0 +1,103 @@
+package terminal
+
+import (
+	"fmt"
+
+	"github.com/hinshun/vt10x"
+)
+
+// Mouse buttons as encoded in xterm mouse reports.
+const (
+	MouseLeft      = 0
+	MouseMiddle    = 1
+	MouseRight     = 2
+	MouseNone      = 3 // motion with no button held
+	MouseWheelUp   = 64
+	MouseWheelDown = 65
+)
+
+// MouseEvent is a mouse action in 0-based terminal cell coordinates.
+type MouseEvent struct {
+	X, Y             int
+	Button           int
+	Release          bool
+	Motion           bool
+	Shift, Alt, Ctrl bool
+}
+
+// WantsMouse reports whether the program in the terminal turned on mouse
+// reporting (e.g. vim with mouse=a, htop, tmux).
+func (t *Terminal) WantsMouse() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.vt != nil && t.vt.Mode()&vt10x.ModeMouseMask != 0
+}
+
+// SendMouse encodes ev for whatever reporting mode the program asked for
+// (X10, normal, button-motion or any-motion; SGR or legacy encoding) and
+// writes it to the PTY. Events the mode doesn't cover are dropped; the
+// return value says whether anything was sent.
+func (t *Terminal) SendMouse(ev MouseEvent) bool {
+	t.mu.Lock()
+	var mode vt10x.ModeFlag
+	if t.vt != nil {
+		mode = t.vt.Mode()
+	}
+	t.mu.Unlock()
+
+	if !mouseReported(mode, ev) {
+		return false
+	}
+
+	code := ev.Button
+	if mode&vt10x.ModeMouseX10 == 0 {
+		if ev.Shift {
+			code |= 4
+		}
+		if ev.Alt {
+			code |= 8
+		}
+		if ev.Ctrl {
+			code |= 16
+		}
+	}
+	if ev.Motion {
+		code |= 32
+	}
+
+	var seq string
+	if mode&vt10x.ModeMouseSgr != 0 {
+		final := 'M'
+		if ev.Release {
+			final = 'm'
+		}
+		seq = fmt.Sprintf("\x1b[<%d;%d;%d%c", code, ev.X+1, ev.Y+1, final)
+	} else {
+		// legacy encoding: releases don't say which button, and
+		// coordinates top out at 223
+		if ev.Release {
+			code = code&^3 | MouseNone
+		}
+		if ev.X > 222 || ev.Y > 222 {
+			return false
+		}
+		seq = string([]byte{0x1b, '[', 'M', byte(32 + code), byte(33 + ev.X), byte(33 + ev.Y)})
+	}
+
+	_, err := t.Write([]byte(seq))
+	return err == nil
+}
+
+func mouseReported(mode vt10x.ModeFlag, ev MouseEvent) bool {
+	switch {
+	case mode&vt10x.ModeMouseMany != 0:
+		return true
+	case mode&vt10x.ModeMouseMotion != 0:
+		return !ev.Motion || ev.Button != MouseNone
+	case mode&vt10x.ModeMouseButton != 0:
+		return !ev.Motion
+	case mode&vt10x.ModeMouseX10 != 0:
+		return !ev.Motion && !ev.Release && ev.Button < MouseWheelUp
+	}
+	return false
+}
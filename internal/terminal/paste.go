@@ -0,0 +1,61 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+
+	// pasteChunk keeps individual PTY writes small so a huge paste doesn't
+	// sit in one blocking write while the shell drains it.
+	pasteChunk = 1024
+)
+
+var (
+	bracketedPasteOn  = []byte("\x1b[?2004h")
+	bracketedPasteOff = []byte("\x1b[?2004l")
+)
+
+// trackBracketedPaste notes whether the program turned bracketed paste mode
+// on or off in this chunk of output. vt10x ignores mode 2004, so we watch
+// for it ourselves. Callers hold t.mu.
+func (t *Terminal) trackBracketedPaste(p []byte) {
+	on := bytes.LastIndex(p, bracketedPasteOn)
+	off := bytes.LastIndex(p, bracketedPasteOff)
+	if on > off {
+		t.bracketedPaste = true
+	} else if off > on {
+		t.bracketedPaste = false
+	}
+}
+
+// Paste writes text as a single paste: newlines become carriage returns, as
+// a real terminal sends them, and the whole thing is wrapped in bracketed
+// paste markers when the program asked for them so shells don't run each
+// line or autocomplete mid-paste. Large pastes are written in chunks.
+func (t *Terminal) Paste(text string) error {
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	text = strings.ReplaceAll(text, "\n", "\r")
+
+	t.mu.Lock()
+	bracketed := t.bracketedPaste
+	t.mu.Unlock()
+
+	if bracketed {
+		// a pasted end marker would let the rest run as typed input
+		text = pasteStart + strings.ReplaceAll(text, pasteEnd, "") + pasteEnd
+	}
+
+	data := []byte(text)
+	for len(data) > 0 {
+		n := min(len(data), pasteChunk)
+		if _, err := t.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
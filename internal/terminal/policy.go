@@ -0,0 +1,252 @@
+package terminal
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/hinshun/vt10x"
+)
+
+// InputPolicy blocks command lines typed into a shell prompt. It's a guard
+// rail against accidents and curious guests, not a sandbox: anything that
+// gets a command to the shell without a typed enter (a script, an editor's
+// shell escape) isn't seen.
+type InputPolicy struct {
+	Deny       []*regexp.Regexp // lines matching any of these are blocked for everyone
+	GuestAllow []string         // when set, guests may only run these commands
+}
+
+// ParseInputPolicy compiles deny patterns and pairs them with a guest
+// allowlist. It returns nil when both are empty.
+func ParseInputPolicy(deny, guestAllow []string) (*InputPolicy, error) {
+	if len(deny) == 0 && len(guestAllow) == 0 {
+		return nil, nil
+	}
+	p := &InputPolicy{GuestAllow: guestAllow}
+	for _, d := range deny {
+		re, err := regexp.Compile(d)
+		if err != nil {
+			return nil, err
+		}
+		p.Deny = append(p.Deny, re)
+	}
+	return p, nil
+}
+
+// commandSeparators split a line into the commands the shell will run.
+var commandSeparators = regexp.MustCompile(`;|&&|\|\||\||&|\$\(|` + "`")
+
+// Check reports whether line may run, and if not, why.
+func (p *InputPolicy) Check(line string, guest bool) (reason string, ok bool) {
+	line = strings.TrimSpace(line)
+	if p == nil || line == "" {
+		return "", true
+	}
+	for _, re := range p.Deny {
+		if re.MatchString(line) {
+			return "matches " + re.String(), false
+		}
+	}
+	if !guest || len(p.GuestAllow) == 0 {
+		return "", true
+	}
+	for _, part := range commandSeparators.Split(line, -1) {
+		fields := strings.Fields(part)
+		// skip VAR=value prefixes to find the command itself
+		for len(fields) > 0 && strings.Contains(fields[0], "=") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if !p.guestAllowed(fields[0]) {
+			return fields[0] + " isn't allowed for guests", false
+		}
+	}
+	return "", true
+}
+
+func (p *InputPolicy) guestAllowed(cmd string) bool {
+	for _, a := range p.GuestAllow {
+		if cmd == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Author is who typed some input, for the input policy.
+type Author struct {
+	Name  string
+	Guest bool
+}
+
+// SetInputPolicy makes WriteFrom and PasteFrom check command lines against
+// p. nil turns checking off.
+func (t *Terminal) SetInputPolicy(p *InputPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.policy = p
+}
+
+// SetBlockHook registers fn to be told when the policy stops a line, with
+// who typed it and why.
+func (t *Terminal) SetBlockHook(fn func(user, line, reason string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onBlock = fn
+}
+
+// killLine moves to the end of the line and erases it, so a blocked command
+// isn't left at the prompt for the next enter to run.
+var killLine = []byte("\x05\x15")
+
+// WriteFrom sends input typed by a, holding back the enter of any command
+// line the policy blocks. Lines are read back from the screen, from where
+// the cursor sat before the first keystroke, so history recall and tab
+// completion are covered. Full-screen programs and running commands get
+// their input untouched.
+func (t *Terminal) WriteFrom(a Author, data []byte) (int, error) {
+	t.mu.Lock()
+	policy := t.policy
+	t.mu.Unlock()
+	if policy == nil || t.atProgram() {
+		t.resetLine()
+		return t.Write(data)
+	}
+
+	// only the first line can already be on screen; later ones in the
+	// same write haven't been echoed yet
+	fromScreen := true
+	written := 0
+	for len(data) > 0 {
+		if fromScreen {
+			t.markLineStart()
+		}
+		i := strings.IndexAny(string(data), "\r\n\x03")
+		if i < 0 {
+			n, err := t.Write(data)
+			return written + n, err
+		}
+
+		send := data[:i+1]
+		var line, reason string
+		blocked := false
+		if data[i] != 0x03 {
+			line = t.typedLine(data[:i], fromScreen)
+			var ok bool
+			reason, ok = policy.Check(line, a.Guest)
+			blocked = !ok
+		}
+		if blocked {
+			send = append(append([]byte(nil), data[:i]...), killLine...)
+		}
+		t.resetLine()
+		fromScreen = false
+		n, err := t.Write(send)
+		written += n
+		if blocked {
+			t.blocked(a.Name, line, reason)
+		}
+		if err != nil {
+			return written, err
+		}
+		data = data[i+1:]
+	}
+	return written, nil
+}
+
+// PasteFrom pastes text for a. Without bracketed paste every newline runs
+// a line, so the whole paste is refused if any line would be blocked.
+func (t *Terminal) PasteFrom(a Author, text string) error {
+	t.mu.Lock()
+	policy, bracketed := t.policy, t.bracketedPaste
+	t.mu.Unlock()
+	if policy == nil || bracketed || t.atProgram() {
+		return t.Paste(text)
+	}
+
+	t.markLineStart()
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if i == len(lines)-1 {
+			break // no newline after it, so it doesn't run yet
+		}
+		line = t.typedLine([]byte(line), i == 0)
+		if reason, ok := policy.Check(line, a.Guest); !ok {
+			t.blocked(a.Name, line, reason)
+			return nil
+		}
+	}
+	return t.Paste(text)
+}
+
+// atProgram reports whether input is going to something other than the
+// shell's prompt: a full-screen program or a running command.
+func (t *Terminal) atProgram() bool {
+	return t.InAltScreen() || t.Activity().Running
+}
+
+func (t *Terminal) blocked(user, line, reason string) {
+	t.mu.Lock()
+	fn := t.onBlock
+	t.mu.Unlock()
+	if fn != nil {
+		fn(user, strings.TrimSpace(line), reason)
+	}
+}
+
+// markLineStart remembers where the cursor is when a new line begins.
+func (t *Terminal) markLineStart() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.lineStarted || t.vt == nil {
+		return
+	}
+	cursor := t.vt.Cursor()
+	t.lineX, t.lineY = cursor.X, cursor.Y
+	t.lineStarted = true
+}
+
+func (t *Terminal) resetLine() {
+	t.mu.Lock()
+	t.lineStarted = false
+	t.mu.Unlock()
+}
+
+// typedLine is the command line as it stands: what the screen shows from
+// the line's start up to the cursor, plus pending input the shell hasn't
+// echoed yet.
+func (t *Terminal) typedLine(pending []byte, fromScreen bool) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+	if fromScreen && t.vt != nil && t.lineStarted {
+		cols, _ := t.vt.Size()
+		cursor := t.vt.Cursor()
+		var cells []vt10x.Glyph
+		for y := t.lineY; y <= cursor.Y; y++ {
+			cells = readRow(t.vt, y, cols, cells)
+			from := 0
+			if y == t.lineY {
+				from = t.lineX
+			}
+			for _, c := range cells[min(from, len(cells)):] {
+				if c.Char == wideSpacer {
+					continue
+				}
+				s, _ := cellText(c.Char)
+				sb.WriteString(s)
+			}
+		}
+	}
+	line := strings.TrimRight(sb.String(), " ")
+	return line + ansi.Strip(strings.Map(func(r rune) rune {
+		if r < ' ' && r != 0x1b || r == 0x7f {
+			return -1
+		}
+		return r
+	}, string(pending)))
+}
@@ -0,0 +1,30 @@
+//go:build !unix
+
+package terminal
+
+import "os"
+
+type signal int
+
+const (
+	sigTerm signal = iota + 1
+	sigKill
+)
+
+// sessionGone is always true here: signalTree only reaches the shell, and
+// once it's been reaped its PID may be reused.
+func sessionGone(pid int) bool {
+	return true
+}
+
+// signalTree can only kill the shell itself here, and can't tell whether
+// it's gone, so the caller just waits out the grace period first.
+func signalTree(pid int, sig signal) bool {
+	if sig != sigKill {
+		return true
+	}
+	if p, err := os.FindProcess(pid); err == nil {
+		p.Kill()
+	}
+	return false
+}
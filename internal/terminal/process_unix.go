@@ -0,0 +1,73 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sigTerm = unix.SIGTERM
+	sigKill = unix.SIGKILL
+)
+
+// signalTree sends sig to everything the shell started. The shell leads
+// its own session, and job control puts each pipeline in a group of its
+// own, so on Linux we find them all by session ID; elsewhere only the
+// shell's group is reached. Processes that start their own session (most
+// daemons) escape either way. It reports whether any were still alive.
+func signalTree(sid int, sig unix.Signal) bool {
+	unix.Kill(-sid, sig)
+	pids, ok := sessionProcs(sid)
+	if !ok {
+		return unix.Kill(-sid, 0) == nil
+	}
+	for _, pid := range pids {
+		unix.Kill(pid, sig)
+	}
+	return len(pids) > 0
+}
+
+// sessionGone reports whether session sid, whose leader has been reaped,
+// is known to have nothing left in it. While anything is, the kernel keeps
+// sid from being reused as a PID; once nothing is, it may be anyone's.
+func sessionGone(sid int) bool {
+	pids, ok := sessionProcs(sid)
+	return ok && len(pids) == 0
+}
+
+// sessionProcs lists live (non-zombie) processes in session sid from /proc.
+// ok is false where there is no /proc to read.
+func sessionProcs(sid int) (pids []int, ok bool) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, false
+	}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile("/proc/" + e.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ppid pgrp session ...; comm may hold spaces
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 4 || fields[0] == "Z" {
+			continue
+		}
+		if s, err := strconv.Atoi(fields[3]); err == nil && s == sid {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, true
+}
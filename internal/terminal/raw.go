@@ -0,0 +1,114 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hinshun/vt10x"
+)
+
+// rawBuffer is how many output chunks a passthrough viewer may fall behind
+// before it's marked as lagged and has to resync from a snapshot.
+const rawBuffer = 256
+
+// RawStream delivers PTY output to one passthrough viewer exactly as the
+// program wrote it, skipping vt10x rendering.
+type RawStream struct {
+	C      chan []byte
+	lagged atomic.Bool
+}
+
+// Lagged reports, and clears, whether output was dropped because the viewer
+// fell behind. The viewer should redraw from Snapshot.
+func (s *RawStream) Lagged() bool {
+	return s.lagged.Swap(false)
+}
+
+// Drain discards output already queued, before a resync from Snapshot.
+func (s *RawStream) Drain() {
+	for {
+		select {
+		case _, ok := <-s.C:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// SubscribeRaw starts a raw output stream. Call UnsubscribeRaw when done.
+func (t *Terminal) SubscribeRaw() *RawStream {
+	s := &RawStream{C: make(chan []byte, rawBuffer)}
+	t.subMu.Lock()
+	t.raw = append(t.raw, s)
+	t.subMu.Unlock()
+	return s
+}
+
+// UnsubscribeRaw ends s and closes its channel.
+func (t *Terminal) UnsubscribeRaw(s *RawStream) {
+	t.subMu.Lock()
+	defer t.subMu.Unlock()
+	for i, r := range t.raw {
+		if r == s {
+			t.raw = append(t.raw[:i], t.raw[i+1:]...)
+			close(s.C)
+			return
+		}
+	}
+}
+
+// broadcastRaw hands a copy of p to every raw stream. A full stream drops
+// the chunk and is flagged to resync instead of holding up the read loop.
+func (t *Terminal) broadcastRaw(p []byte) {
+	t.subMu.RLock()
+	defer t.subMu.RUnlock()
+	if len(t.raw) == 0 {
+		return
+	}
+	chunk := append([]byte(nil), p...)
+	for _, s := range t.raw {
+		select {
+		case s.C <- chunk:
+		default:
+			s.lagged.Store(true)
+		}
+	}
+}
+
+// Snapshot returns escape sequences that redraw the current screen and
+// cursor on a real terminal, to start a passthrough viewer off (or resync
+// one that lagged). Modes the program set earlier, like mouse reporting or
+// application keypad, aren't replayed.
+func (t *Terminal) Snapshot() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[0m\x1b[H\x1b[2J")
+	if t.vt == nil {
+		return []byte(sb.String())
+	}
+
+	cols, rows := t.vt.Size()
+	var cells []vt10x.Glyph
+	for y := range rows {
+		cells = readRow(t.vt, y, cols, cells)
+		renderRow(&sb, cells, -1)
+		if y < rows-1 {
+			sb.WriteString("\r\n")
+		}
+	}
+
+	cursor := t.vt.Cursor()
+	fmt.Fprintf(&sb, "\x1b[%d;%dH", cursor.Y+1, cursor.X+1)
+	if t.vt.CursorVisible() {
+		sb.WriteString("\x1b[?25h")
+	} else {
+		sb.WriteString("\x1b[?25l")
+	}
+	return []byte(sb.String())
+}
@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hinshun/vt10x"
+)
+
+// benchTerminal is a 120x40 terminal with a full, coloured screen and no
+// shell behind it, as a busy room's would look.
+func benchTerminal(b *testing.B) *Terminal {
+	b.Helper()
+	t := New(120, 40, "", b.TempDir(), nil)
+	t.vt = vt10x.New(vt10x.WithSize(t.width, t.height))
+	for i := range t.height {
+		fmt.Fprintf(t.vt, "\x1b[3%dm%03d\x1b[0m the quick brown fox jumps over the lazy dog %s\r\n", i%8, i, "~~~~~~~~~~")
+	}
+	t.gen++
+	return t
+}
+
+// BenchmarkRenderFull draws every cell of the screen each time, as Render
+// did before rows were cached.
+func BenchmarkRenderFull(b *testing.B) {
+	t := benchTerminal(b)
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		fmt.Fprintf(t.vt, "\r$ %d", i)
+		RenderVT(t.vt)
+	}
+}
+
+// BenchmarkRenderOneRowChanged is the common case of typing at a prompt:
+// one row changes between frames and the rest come from the cache.
+func BenchmarkRenderOneRowChanged(b *testing.B) {
+	t := benchTerminal(b)
+	t.Render()
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		fmt.Fprintf(t.vt, "\r$ %d", i)
+		t.gen++
+		t.Render()
+	}
+}
+
+// BenchmarkRenderUnchanged is every client but the first asking for the
+// same frame.
+func BenchmarkRenderUnchanged(b *testing.B) {
+	t := benchTerminal(b)
+	t.Render()
+	b.ReportAllocs()
+	for b.Loop() {
+		t.Render()
+	}
+}
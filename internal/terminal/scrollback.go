@@ -0,0 +1,141 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// DefaultScrollback is how many lines of history a terminal keeps unless
+// SetScrollback says otherwise.
+const DefaultScrollback = 10000
+
+// maxLineRunes wraps runaway lines (progress bars without \r, minified
+// output) so one line can't hold the whole buffer.
+const maxLineRunes = 4096
+
+// scrollback keeps plain-text output lines. vt10x only holds the visible
+// screen, so we rebuild history from the raw PTY stream instead: escape
+// sequences are stripped and \r / backspace are applied to the current line.
+// Full-screen programs (vim, top) end up as noise here, which is fine for
+// reviewing ordinary command output.
+type scrollback struct {
+	lines   []string // ring buffer once full
+	start   int
+	max     int
+	partial []rune // line currently being written
+	cr      bool   // saw \r; the line is redrawn unless \n follows
+	pushed  int    // lines finished so far, including those dropped
+}
+
+func newScrollback(max int) *scrollback {
+	return &scrollback{max: max}
+}
+
+func (s *scrollback) write(p []byte) {
+	for _, r := range ansi.Strip(string(p)) {
+		if s.cr && r != '\n' && r != '\r' {
+			s.partial = s.partial[:0]
+		}
+		s.cr = false
+
+		switch r {
+		case '\n':
+			s.push(string(s.partial))
+			s.partial = s.partial[:0]
+		case '\r':
+			s.cr = true
+		case '\b':
+			if len(s.partial) > 0 {
+				s.partial = s.partial[:len(s.partial)-1]
+			}
+		case '\t':
+			s.partial = append(s.partial, ' ', ' ', ' ', ' ')
+		default:
+			if r >= ' ' {
+				s.partial = append(s.partial, r)
+			}
+			if len(s.partial) >= maxLineRunes {
+				s.push(string(s.partial))
+				s.partial = s.partial[:0]
+			}
+		}
+	}
+}
+
+func (s *scrollback) push(line string) {
+	s.pushed++
+	if s.max <= 0 {
+		return
+	}
+	line = strings.TrimRight(line, " ")
+	if len(s.lines) < s.max {
+		s.lines = append(s.lines, line)
+		return
+	}
+	s.lines[s.start] = line
+	s.start = (s.start + 1) % s.max
+}
+
+// len counts stored lines plus the unfinished one, if any.
+func (s *scrollback) len() int {
+	n := len(s.lines)
+	if len(s.partial) > 0 {
+		n++
+	}
+	return n
+}
+
+// line returns the i-th line, oldest first.
+func (s *scrollback) line(i int) string {
+	if i == len(s.lines) {
+		return string(s.partial)
+	}
+	return s.lines[(s.start+i)%len(s.lines)]
+}
+
+// SetScrollback sets how many lines of history to keep; 0 disables it.
+// Call before Start.
+func (t *Terminal) SetScrollback(lines int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.history = newScrollback(lines)
+}
+
+// Scrollback returns up to n lines of output ending offset lines above the
+// newest one, oldest first, along with the total number of lines stored.
+func (t *Terminal) Scrollback(n, offset int) ([]string, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.history == nil {
+		return nil, 0
+	}
+	total := t.history.len()
+	end := max(total-offset, 0)
+	begin := max(end-n, 0)
+	out := make([]string, 0, end-begin)
+	for i := begin; i < end; i++ {
+		out = append(out, t.history.line(i))
+	}
+	return out, total
+}
+
+// LinesSince returns the output lines finished after the first n, oldest
+// first, as far back as history still goes, along with the n to pass next
+// time and the line being written now. Lines only ever follow one another
+// here, unlike on the screen, so they can be printed as they come.
+func (t *Terminal) LinesSince(n int) (lines []string, next int, partial string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.history
+	if s == nil {
+		return nil, 0, ""
+	}
+	first := s.pushed - len(s.lines) // the oldest line still held
+	for i := max(n, first); i < s.pushed; i++ {
+		lines = append(lines, s.lines[(s.start+i-first)%len(s.lines)])
+	}
+	return lines, s.pushed, string(s.partial)
+}
@@ -0,0 +1,38 @@
+package terminal
+
+import "strings"
+
+// SessionInfo describes the room a terminal belongs to. Start exports it
+// as DUET_* variables so prompts and scripts can show who's pairing.
+type SessionInfo struct {
+	RoomID      string
+	Description string
+	Host        string
+	Users       []string // connected usernames when the shell starts
+	UsersFile   string   // kept up to date with one username per line
+}
+
+// Env returns the DUET_* variables for s as KEY=VALUE pairs. DUET_USERS is
+// only a snapshot; scripts that need the current list read DUET_USERS_FILE.
+func (s SessionInfo) Env() []string {
+	if s.RoomID == "" {
+		return nil
+	}
+	env := []string{
+		"DUET_ROOM_ID=" + s.RoomID,
+		"DUET_ROOM_DESC=" + s.Description,
+		"DUET_HOST=" + s.Host,
+		"DUET_USERS=" + strings.Join(s.Users, ","),
+	}
+	if s.UsersFile != "" {
+		env = append(env, "DUET_USERS_FILE="+s.UsersFile)
+	}
+	return env
+}
+
+// SetSessionInfo sets the room details Start exports. Call before Start.
+func (t *Terminal) SetSessionInfo(s SessionInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.session = s
+}
@@ -6,7 +6,10 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/creack/pty"
 	"github.com/hinshun/vt10x"
 )
@@ -20,19 +23,67 @@ type Terminal struct {
 
 	width   int
 	height  int
-	workDir string // isolated working directory for this terminal
+	shell   string      // shell command line; empty uses $SHELL
+	tmux    string      // if set, attach to this tmux session instead of a shell
+	command []string    // if set, run this argv instead of the shell
+	session SessionInfo // exported to the shell as DUET_* variables
+	workDir string      // isolated working directory for this terminal
+	env     []string    // extra KEY=VALUE pairs exported into the shell
 
 	// Subscriber channels for multi-client broadcast
 	subscribers map[chan struct{}]struct{}
+	views       map[chan struct{}]viewSize // pane size per subscriber
+	raw         []*RawStream               // passthrough viewers
 	subMu       sync.RWMutex
+	limitedBy   []string // who set the current size; see SetViewSize
 	closed      bool
 
+	logger   *log.Logger  // optional, see SetLogger
+	onOutput func([]byte) // optional tap on raw PTY output, e.g. for transcripts
+	history  *scrollback  // lines that scrolled off (and the ones on screen)
+	cells    *CellWriter  // feeds vt10x, width-aware
+	onBell   func()       // optional, see SetBellHook
+	onExit   func(int)    // optional, see SetExitHook
+	exited   bool         // the shell exited on its own; see Restart
+	exitCode int
+	done     chan struct{} // closed once Close has stopped every process; see Done
+	killing  bool          // Close has started killTree
+
+	policy       *InputPolicy // checks command lines from WriteFrom/PasteFrom
+	onBlock      func(user, line, reason string)
+	lineStarted  bool // a command line is being typed; lineX/lineY is where it began
+	lineX, lineY int
+
+	lastInput  time.Time // see Activity
+	lastOutput time.Time
+	lastBell   time.Time
+	bytesIn    atomic.Uint64 // see Traffic
+	bytesOut   atomic.Uint64
+
+	bracketedPaste bool // program enabled mode 2004
+
+	// Update coalescing, see scheduleBroadcast
+	frameInterval time.Duration
+	lastFrame     time.Time
+	framePending  bool
+
 	// Render optimization
-	lastRender string // cached render output
-	dirty      bool   // needs re-render
+	lastRender  string      // cached render output
+	gen         uint64      // bumped on every screen change
+	renderedGen uint64      // gen that lastRender was built from
+	rows        []rowRender // per-row cache so only changed rows are re-rendered
+	scratch     []vt10x.Glyph
+}
+
+// rowRender is the last rendered form of one screen row along with the
+// cells it was built from, used to detect which rows a PTY write touched.
+type rowRender struct {
+	cells   []vt10x.Glyph
+	cursorX int
+	text    string
 }
 
-func New(width, height int, workDir string) *Terminal {
+func New(width, height int, shell, workDir string, env []string) *Terminal {
 	if width < 1 {
 		width = 80
 	}
@@ -44,10 +95,16 @@ func New(width, height int, workDir string) *Terminal {
 	}
 
 	return &Terminal{
-		width:       width,
-		height:      height,
-		workDir:     workDir,
-		subscribers: make(map[chan struct{}]struct{}),
+		width:         width,
+		height:        height,
+		shell:         shell,
+		workDir:       workDir,
+		env:           env,
+		history:       newScrollback(DefaultScrollback),
+		frameInterval: time.Second / DefaultFrameRate,
+		subscribers:   make(map[chan struct{}]struct{}),
+		views:         make(map[chan struct{}]viewSize),
+		done:          make(chan struct{}),
 	}
 }
 
@@ -61,24 +118,85 @@ func (t *Terminal) Subscribe() chan struct{} {
 	return ch
 }
 
-// Unsubscribe removes a channel from the subscriber list and closes it.
+// Unsubscribe removes a channel from the subscriber list and closes it. If
+// that client was holding the terminal small, it grows back.
 func (t *Terminal) Unsubscribe(ch chan struct{}) {
 	t.subMu.Lock()
-	delete(t.subscribers, ch)
+	_, ok := t.subscribers[ch]
+	if ok {
+		delete(t.subscribers, ch)
+		delete(t.views, ch)
+		close(ch)
+	}
 	t.subMu.Unlock()
+
+	if ok {
+		t.arbitrateSize()
+	}
 }
 
-// broadcast sends an update signal to all subscribers
+// broadcast sends an update signal to all subscribers. Viewers that have
+// left updates unread for staleView stop counting towards the shared size
+// until they report it again.
 func (t *Terminal) broadcast() {
-	t.subMu.RLock()
-	defer t.subMu.RUnlock()
-
+	now := time.Now()
+	dropped := false
+	t.subMu.Lock()
 	for ch := range t.subscribers {
+		v, sized := t.views[ch]
 		select {
 		case ch <- struct{}{}:
+			v.stalled = time.Time{}
+		default:
+			if v.stalled.IsZero() {
+				v.stalled = now
+			}
+		}
+		switch {
+		case !sized:
+		case !v.stalled.IsZero() && now.Sub(v.stalled) > staleView:
+			delete(t.views, ch)
+			dropped = true
 		default:
+			t.views[ch] = v
 		}
 	}
+	t.subMu.Unlock()
+
+	if dropped {
+		t.arbitrateSize()
+	}
+}
+
+// AttachTmux makes Start attach to an existing tmux session rather than
+// launching a shell. Call before Start.
+func (t *Terminal) AttachTmux(session string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tmux = session
+}
+
+// SetCommand makes Start run argv (e.g. an exec into a container) rather
+// than the shell. Call before Start.
+func (t *Terminal) SetCommand(argv []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.command = argv
+}
+
+// SetOutputHook registers fn to receive every chunk read from the PTY. The
+// slice is reused after fn returns, so fn must not retain it.
+func (t *Terminal) SetOutputHook(fn func([]byte)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onOutput = fn
+}
+
+// SetLogger makes the terminal log its shell starting and exiting to l.
+func (t *Terminal) SetLogger(l *log.Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logger = l
 }
 
 func (t *Terminal) Start() error {
@@ -86,17 +204,34 @@ func (t *Terminal) Start() error {
 	defer t.mu.Unlock()
 
 	t.vt = vt10x.New(vt10x.WithSize(t.width, t.height))
+	t.cells = NewCellWriter(t.vt)
 
-	shell := os.Getenv("SHELL")
+	shell := t.shell
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
 	if shell == "" {
 		shell = "/bin/sh"
 	}
 
-	t.cmd = exec.Command(shell)
+	if len(t.command) > 0 {
+		t.cmd = exec.Command(t.command[0], t.command[1:]...)
+	} else if t.tmux != "" {
+		// "=" makes tmux match the session name exactly
+		t.cmd = exec.Command("tmux", "attach-session", "-t", "="+t.tmux)
+	} else {
+		argv, err := ParseCommand(shell)
+		if err != nil {
+			return fmt.Errorf("shell %q: %w", shell, err)
+		}
+		t.cmd = exec.Command(argv[0], argv[1:]...)
+	}
 	t.cmd.Dir = t.workDir
 	t.cmd.Env = append(os.Environ(),
 		"TERM=xterm-256color",
 	)
+	t.cmd.Env = append(t.cmd.Env, t.env...)
+	t.cmd.Env = append(t.cmd.Env, t.session.Env()...)
 
 	var err error
 	t.ptmx, err = pty.StartWithSize(t.cmd, &pty.Winsize{
@@ -106,38 +241,50 @@ func (t *Terminal) Start() error {
 	if err != nil {
 		return err
 	}
+	if t.logger != nil {
+		t.logger.Debug("shell started", "pid", t.cmd.Process.Pid, "cmd", t.cmd.Path)
+	}
 
 	// keep reading from PTY and feeding vt10x
-	go t.readLoop()
+	go t.readLoop(t.ptmx)
 
 	return nil
 }
 
 // readLoop reads from PTY and writes to vt10x terminal
-func (t *Terminal) readLoop() {
+func (t *Terminal) readLoop(ptmx *os.File) {
 	buf := make([]byte, 4096)
 
 	for {
-		n, err := t.ptmx.Read(buf)
+		n, err := ptmx.Read(buf)
 		if err != nil {
-			// Shell process exited
-			t.mu.Lock()
-			t.closed = true
-			t.mu.Unlock()
+			// Shell process exited, or we closed the PTY
+			t.shellExited(ptmx)
 			return
 		}
 
+		t.bytesOut.Add(uint64(n))
 		t.mu.Lock()
-		if t.vt != nil {
-			t.vt.Write(buf[:n])
-			t.dirty = true
-		}
+		t.lastOutput = time.Now()
+		t.feed(buf[:n])
+		t.trackBracketedPaste(buf[:n])
+		rang := t.takeBell()
 		closed := t.closed
+		onOutput := t.onOutput
+		onBell := t.onBell
 		t.mu.Unlock()
 
-		// Broadcast to all subscribers
+		if onOutput != nil {
+			onOutput(buf[:n])
+		}
+		t.broadcastRaw(buf[:n])
+		if rang && onBell != nil {
+			onBell()
+		}
+
+		// Broadcast to all subscribers, coalesced to the frame rate
 		if !closed {
-			t.broadcast()
+			t.scheduleBroadcast()
 		}
 	}
 }
@@ -146,14 +293,18 @@ func (t *Terminal) readLoop() {
 func (t *Terminal) Write(data []byte) (int, error) {
 	t.mu.Lock()
 	ptmx := t.ptmx
+	t.lastInput = time.Now()
 	t.mu.Unlock()
 
 	if ptmx == nil {
 		return 0, nil
 	}
+	t.bytesIn.Add(uint64(len(data)))
 	return ptmx.Write(data)
 }
 
+// Render returns the screen as ANSI text. It's rendered at most once per
+// screen generation however many clients ask; the rest get the cached string.
 func (t *Terminal) Render() string {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -161,105 +312,215 @@ func (t *Terminal) Render() string {
 	if t.vt == nil {
 		return ""
 	}
+	if t.renderedGen != t.gen || t.lastRender == "" {
+		t.lastRender = t.renderRows()
+		t.renderedGen = t.gen
+	}
+	return t.lastRender
+}
 
-	// Return cached render if not dirty
-	if !t.dirty && t.lastRender != "" {
-		return t.lastRender
+// CursorRow is the screen row the shell's cursor is on, counting from 0.
+func (t *Terminal) CursorRow() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.vt == nil {
+		return 0
 	}
+	return t.vt.Cursor().Y
+}
 
+// renderRows re-renders only the rows whose cells or cursor changed since
+// the last call. vt10x doesn't expose its own dirty lines, so we diff glyphs,
+// which is far cheaper than formatting every cell again. Callers hold t.mu.
+func (t *Terminal) renderRows() string {
 	cols, rows := t.vt.Size()
+	if len(t.rows) != rows {
+		t.rows = make([]rowRender, rows)
+	}
 	cursor := t.vt.Cursor()
 	cursorVisible := t.vt.CursorVisible()
 
 	var sb strings.Builder
-	sb.Grow(cols * rows * 2)
+	for y := 0; y < rows; y++ {
+		cursorX := -1
+		if cursorVisible && cursor.Y == y {
+			cursorX = cursor.X
+		}
 
-	// Track previous colors for run-length encoding
-	var prevFG, prevBG vt10x.Color
-	var inStyle bool
+		t.scratch = readRow(t.vt, y, cols, t.scratch)
+		row := &t.rows[y]
+		if !row.matches(t.scratch, cursorX) {
+			// swap buffers: the fresh cells become the cache, the old ones scratch
+			row.cells, t.scratch = t.scratch, row.cells
+			row.cursorX = cursorX
+
+			var rb strings.Builder
+			rb.Grow(cols * 2)
+			renderRow(&rb, row.cells, cursorX)
+			row.text = rb.String()
+		}
 
-	for y := 0; y < rows; y++ {
-		prevFG, prevBG = 0, 0
-		inStyle = false
-
-		for x := range cols {
-			cell := t.vt.Cell(x, y)
-			char := cell.Char
-			if char == 0 {
-				char = ' '
-			}
+		sb.WriteString(row.text)
+		if y < rows-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
 
-			isCursor := cursorVisible && x == cursor.X && y == cursor.Y
+func (r *rowRender) matches(cells []vt10x.Glyph, cursorX int) bool {
+	if len(r.cells) != len(cells) || r.cursorX != cursorX {
+		return false
+	}
+	for x := range cells {
+		if cells[x] != r.cells[x] {
+			return false
+		}
+	}
+	return true
+}
+
+// readRow copies row y of vt into buf, growing it as needed.
+func readRow(vt vt10x.Terminal, y, cols int, buf []vt10x.Glyph) []vt10x.Glyph {
+	if cap(buf) < cols {
+		buf = make([]vt10x.Glyph, cols)
+	}
+	buf = buf[:cols]
+	for x := range cols {
+		buf[x] = vt.Cell(x, y)
+	}
+	return buf
+}
 
-			fg := cell.FG
-			bg := cell.BG
+// RenderVT draws vt's screen as ANSI-coloured text, one line per row, with
+// the cursor in reverse video.
+func RenderVT(vt vt10x.Terminal) string {
+	cols, rows := vt.Size()
+	cursor := vt.Cursor()
 
-			if isCursor {
-				// Swap fg/bg for cursor (reverse video effect)
-				fg, bg = bg, fg
-			}
+	var sb strings.Builder
+	sb.Grow(cols * rows * 2)
+
+	var cells []vt10x.Glyph
+	for y := 0; y < rows; y++ {
+		cursorX := -1
+		if vt.CursorVisible() && cursor.Y == y {
+			cursorX = cursor.X
+		}
+		cells = readRow(vt, y, cols, cells)
+		renderRow(&sb, cells, cursorX)
+		if y < rows-1 {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// Glyph mode bits, mirroring vt10x's unexported attr* constants.
+const (
+	attrReverse int16 = 1 << iota
+	attrUnderline
+	attrBold
+	attrGfx
+	attrItalic
+	attrBlink
+)
 
-			needsColorChange := fg != prevFG || bg != prevBG || (isCursor && !inStyle)
-
-			if needsColorChange {
-				if inStyle {
-					sb.WriteString("\x1b[0m")
-					inStyle = false
-				}
-
-				if fg != 0 && fg < 256 {
-					sb.WriteString(fgColor(fg))
-					inStyle = true
-				}
-				if bg != 0 && bg < 256 {
-					sb.WriteString(bgColor(bg))
-					inStyle = true
-				}
-				if isCursor && !inStyle {
-					// Fallback reverse video for cursor
-					sb.WriteString("\x1b[7m")
-					inStyle = true
-				}
-
-				prevFG, prevBG = fg, bg
+// renderRow writes one row of cells, emitting an SGR sequence only where
+// colours or attributes change. cursorX is the cursor column on this row, or
+// -1; the cursor is drawn by flipping reverse video.
+func renderRow(sb *strings.Builder, cells []vt10x.Glyph, cursorX int) {
+	prevFG, prevBG, prevMode := vt10x.DefaultFG, vt10x.DefaultBG, int16(0)
+	styled := false
+
+	for x := 0; x < len(cells); x++ {
+		cell := cells[x]
+		char, text, width := cell.Char, "", 1
+		switch {
+		case char == 0 || char == wideSpacer:
+			// a spacer we reach here lost its wide half
+			char = ' '
+		case char >= 0x1100:
+			text, width = cellText(char)
+			if width > 1 && (x+1 >= len(cells) || cells[x+1].Char != wideSpacer) {
+				// the spacer was overwritten, leaving half a character
+				char, text, width = ' ', "", 1
 			}
+		}
 
-			sb.WriteRune(char)
+		mode := cell.Mode &^ attrGfx
+		if x == cursorX || (width > 1 && x+1 == cursorX) {
+			mode ^= attrReverse
 		}
 
-		if inStyle {
-			sb.WriteString("\x1b[0m")
-			inStyle = false
+		if cell.FG != prevFG || cell.BG != prevBG || mode != prevMode {
+			styled = writeSGR(sb, cell.FG, cell.BG, mode)
+			prevFG, prevBG, prevMode = cell.FG, cell.BG, mode
 		}
 
-		if y < rows-1 {
-			sb.WriteString("\n")
+		if text != "" {
+			sb.WriteString(text)
+		} else {
+			sb.WriteRune(char)
+		}
+		if width > 1 {
+			x++ // skip the spacer
 		}
 	}
 
-	// Cache the result
-	t.lastRender = sb.String()
-	t.dirty = false
-
-	return t.lastRender
+	if styled {
+		sb.WriteString("\x1b[0m")
+	}
 }
 
-func fgColor(c vt10x.Color) string {
-	if c < 8 {
-		return fmt.Sprintf("\x1b[%dm", 30+c)
-	} else if c < 16 {
-		return fmt.Sprintf("\x1b[%dm", 90+(c-8))
+// writeSGR resets the pen and applies fg, bg and mode in one sequence. It
+// reports whether anything other than the default style is now active.
+func writeSGR(sb *strings.Builder, fg, bg vt10x.Color, mode int16) bool {
+	sb.WriteString("\x1b[0")
+	styled := false
+	for _, a := range [...]struct {
+		bit  int16
+		code string
+	}{
+		{attrBold, ";1"},
+		{attrItalic, ";3"},
+		{attrUnderline, ";4"},
+		{attrBlink, ";5"},
+		{attrReverse, ";7"},
+	} {
+		if mode&a.bit != 0 {
+			sb.WriteString(a.code)
+			styled = true
+		}
+	}
+	if writeColor(sb, fg, 30, 90, 38) {
+		styled = true
+	}
+	if writeColor(sb, bg, 40, 100, 48) {
+		styled = true
 	}
-	return fmt.Sprintf("\x1b[38;5;%dm", c)
+	sb.WriteByte('m')
+	return styled
 }
 
-func bgColor(c vt10x.Color) string {
-	if c < 8 {
-		return fmt.Sprintf("\x1b[%dm", 40+c)
-	} else if c < 16 {
-		return fmt.Sprintf("\x1b[%dm", 100+(c-8))
+// writeColor appends the SGR parameters for c. vt10x stores palette colours
+// as 0-255, 24-bit colours as 0xRRGGBB and the defaults above 1<<24; a
+// true colour that happens to be below 256 is indistinguishable from a
+// palette index, which matches how vt10x itself treats it.
+func writeColor(sb *strings.Builder, c vt10x.Color, base, brightBase, extended int) bool {
+	switch {
+	case c < 8:
+		fmt.Fprintf(sb, ";%d", base+int(c))
+	case c < 16:
+		fmt.Fprintf(sb, ";%d", brightBase+int(c-8))
+	case c < 256:
+		fmt.Fprintf(sb, ";%d;5;%d", extended, c)
+	case c < 1<<24:
+		fmt.Fprintf(sb, ";%d;2;%d;%d;%d", extended, c>>16&0xff, c>>8&0xff, c&0xff)
+	default:
+		return false
 	}
-	return fmt.Sprintf("\x1b[48;5;%dm", c)
+	return true
 }
 
 func (t *Terminal) Resize(width, height int) {
@@ -272,8 +533,9 @@ func (t *Terminal) Resize(width, height int) {
 
 	t.width = width
 	t.height = height
-	t.dirty = true
+	t.gen++
 	t.lastRender = ""
+	t.rows = nil
 
 	if t.vt != nil {
 		t.vt.Resize(width, height)
@@ -287,6 +549,8 @@ func (t *Terminal) Resize(width, height int) {
 	}
 }
 
+// Close stops the shell and everything it started, in the background (see
+// Done), and ends every subscription.
 func (t *Terminal) Close() error {
 	t.mu.Lock()
 	t.closed = true
@@ -298,6 +562,11 @@ func (t *Terminal) Close() error {
 		close(ch)
 	}
 	t.subscribers = nil
+	t.views = nil
+	for _, s := range t.raw {
+		close(s.C)
+	}
+	t.raw = nil
 	t.subMu.Unlock()
 
 	t.mu.Lock()
@@ -308,10 +577,21 @@ func (t *Terminal) Close() error {
 		t.ptmx = nil
 	}
 
-	if t.cmd != nil && t.cmd.Process != nil {
-		t.cmd.Process.Kill()
+	if t.killing {
+		return nil
+	}
+	t.killing = true
+	switch {
+	case t.cmd == nil || t.cmd.Process == nil:
+		close(t.done)
+	case t.exited && sessionGone(t.cmd.Process.Pid):
+		// the shell was reaped and left nothing behind, so its PID may
+		// already be someone else's: don't signal it
+		close(t.done)
+	default:
+		// closing the PTY hangs up the shell; killTree sees to the rest
+		go t.killTree(t.cmd.Process.Pid)
 	}
-
 	return nil
 }
 
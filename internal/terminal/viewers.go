@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"slices"
+	"time"
+)
+
+// staleView is how long a viewer may leave an update unread before its
+// size stops counting, so a session that died without unsubscribing
+// doesn't keep the terminal small for everyone else.
+const staleView = 30 * time.Second
+
+// viewSize is the pane size one client has for this terminal.
+type viewSize struct {
+	name          string
+	width, height int
+	stalled       time.Time // when an update first found its channel still full
+}
+
+// SetViewSize records the pane size of the client subscribed on ch and
+// resizes the terminal to the smallest width and height across everyone
+// watching, so no one's view is silently cut off.
+func (t *Terminal) SetViewSize(ch chan struct{}, name string, width, height int) {
+	if width < 1 || height < 1 {
+		return
+	}
+	t.subMu.Lock()
+	if _, ok := t.subscribers[ch]; !ok {
+		t.subMu.Unlock()
+		return
+	}
+	t.views[ch] = viewSize{name: name, width: width, height: height}
+	t.subMu.Unlock()
+
+	t.arbitrateSize()
+}
+
+// arbitrateSize applies the smallest common size of the current viewers.
+// With nobody watching the terminal keeps its last size.
+func (t *Terminal) arbitrateSize() {
+	t.subMu.RLock()
+	var w, h int
+	var byW, byH string
+	for _, v := range t.views {
+		if w == 0 || v.width < w || (v.width == w && v.name < byW) {
+			w, byW = v.width, v.name
+		}
+		if h == 0 || v.height < h || (v.height == h && v.name < byH) {
+			h, byH = v.height, v.name
+		}
+	}
+	t.subMu.RUnlock()
+	if w == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	t.limitedBy = []string{byW}
+	if byH != byW {
+		t.limitedBy = append(t.limitedBy, byH)
+	}
+	changed := w != t.width || h != t.height
+	t.mu.Unlock()
+
+	if changed {
+		t.Resize(w, h)
+		// everyone redraws at the new size, not just whoever caused it
+		t.broadcast()
+	}
+}
+
+// SharedSize returns the terminal's current size and the clients whose
+// panes decided its width and height.
+func (t *Terminal) SharedSize() (width, height int, limitedBy []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.width, t.height, slices.Clone(t.limitedBy)
+}
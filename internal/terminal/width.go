@@ -0,0 +1,257 @@
+package terminal
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/hinshun/vt10x"
+	"github.com/rivo/uniseg"
+)
+
+// vt10x stores one rune per cell and moves the cursor one column per rune,
+// whatever the rune's display width. Programs lay text out by display width,
+// so CJK and emoji would shift everything after them and combining marks
+// would take a cell of their own. CellWriter sits in front of vt10x to fix
+// that: wide graphemes are followed by a spacer cell, and multi-rune
+// graphemes (accents, ZWJ emoji, flags) are stored as one private-use rune
+// that renderRow expands again.
+
+const (
+	// clusterBase up to wideSpacer are handed out to multi-rune graphemes.
+	clusterBase rune = 0x100000
+	// wideSpacer fills the second cell of a wide grapheme. It's never drawn.
+	wideSpacer rune = 0x10FFFD
+
+	// cursorWrapNext mirrors vt10x's unexported cursor state bit: the
+	// cursor is in the last column and the next character wraps.
+	cursorWrapNext = 1 << 1
+)
+
+type cluster struct {
+	text  string
+	width int
+}
+
+// graphemes interns multi-rune clusters. It's shared by every screen so
+// RenderVT can expand cells without knowing where they came from; once the
+// private-use range runs out, clusters fall back to their first rune.
+var graphemes = struct {
+	sync.RWMutex
+	ids  map[string]rune
+	list []cluster
+}{ids: make(map[string]rune)}
+
+func internCluster(s string, width int) rune {
+	graphemes.RLock()
+	id, ok := graphemes.ids[s]
+	graphemes.RUnlock()
+	if ok {
+		return id
+	}
+
+	graphemes.Lock()
+	defer graphemes.Unlock()
+	if id, ok := graphemes.ids[s]; ok {
+		return id
+	}
+	id = clusterBase + rune(len(graphemes.list))
+	if id >= wideSpacer {
+		r, _ := utf8.DecodeRuneInString(s)
+		return r
+	}
+	graphemes.ids[s] = id
+	graphemes.list = append(graphemes.list, cluster{text: s, width: width})
+	return id
+}
+
+// cellText returns what to draw for a cell holding r and how many columns
+// it takes. Runes below U+1100 are always one column and can be drawn as is.
+func cellText(r rune) (string, int) {
+	if r >= clusterBase && r < wideSpacer {
+		graphemes.RLock()
+		defer graphemes.RUnlock()
+		if i := int(r - clusterBase); i < len(graphemes.list) {
+			c := graphemes.list[i]
+			return c.text, c.width
+		}
+		return " ", 1
+	}
+	s := string(r)
+	return s, uniseg.StringWidth(s)
+}
+
+// escState tracks where CellWriter is within an escape sequence, so only
+// printable text gets regrouped.
+type escState uint8
+
+const (
+	escGround escState = iota
+	escEsc             // after ESC
+	escArg             // ESC ( and friends take one more byte
+	escCSI             // ESC [ until a final byte
+	escStr             // OSC/DCS/APC/PM/SOS until BEL or ST
+	escStrEsc          // ESC inside a string, maybe the start of ST
+)
+
+// CellWriter feeds program output into a vt10x screen so each grapheme
+// takes as many cells as it does on a real terminal. Not safe for
+// concurrent use.
+type CellWriter struct {
+	vt      vt10x.Terminal
+	state   escState
+	partial []byte // incomplete UTF-8 at the end of the last write
+	text    []byte // printable run waiting to be split into graphemes
+	out     []byte // bytes ready for vt10x
+	bell    bool   // a BEL outside any escape sequence went by
+}
+
+func NewCellWriter(vt vt10x.Terminal) *CellWriter {
+	return &CellWriter{vt: vt}
+}
+
+func (w *CellWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(w.partial) > 0 {
+		p = append(w.partial, p...)
+		w.partial = nil
+	}
+	// hold back a rune split across reads rather than let vt10x drop it
+	if i := lastRuneStart(p); i >= 0 && !utf8.FullRune(p[i:]) {
+		w.partial = append([]byte(nil), p[i:]...)
+		p = p[:i]
+	}
+
+	for _, b := range p {
+		switch w.state {
+		case escGround:
+			if b == 0x1b || b < 0x20 || b == 0x7f {
+				w.flushText()
+				w.out = append(w.out, b)
+				if b == 0x1b {
+					w.state = escEsc
+				} else if b == 0x07 {
+					w.bell = true
+				}
+				continue
+			}
+			w.text = append(w.text, b)
+			continue
+		case escEsc:
+			switch b {
+			case '[':
+				w.state = escCSI
+			case ']', 'P', '_', '^', 'X':
+				w.state = escStr
+			case '(', ')', '*', '+', '#', '%', ' ':
+				w.state = escArg
+			default:
+				w.state = escGround
+			}
+		case escArg:
+			w.state = escGround
+		case escCSI:
+			if b >= 0x40 && b <= 0x7e {
+				w.state = escGround
+			}
+		case escStr:
+			if b == 0x07 {
+				w.state = escGround
+			} else if b == 0x1b {
+				w.state = escStrEsc
+			}
+		case escStrEsc:
+			if b == '\\' {
+				w.state = escGround
+			} else {
+				w.state = escStr
+			}
+		}
+		w.out = append(w.out, b)
+	}
+	w.flushText()
+	w.flush()
+	return n, nil
+}
+
+// flushText splits the pending printable run into graphemes and queues
+// them for vt10x, one cell per column they occupy.
+func (w *CellWriter) flushText() {
+	if len(w.text) == 0 {
+		return
+	}
+	if isASCII(w.text) {
+		w.out = append(w.out, w.text...)
+		w.text = w.text[:0]
+		return
+	}
+
+	s := string(w.text)
+	w.text = w.text[:0]
+	state := -1
+	for s != "" {
+		var g string
+		var width int
+		g, s, width, state = uniseg.FirstGraphemeClusterInString(s, state)
+		if width == 0 {
+			// a stray combining mark or joiner with nothing to attach to
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(g)
+		if size < len(g) {
+			r = internCluster(g, width)
+		} else if r >= clusterBase {
+			r = utf8.RuneError
+		}
+
+		if width >= 2 {
+			w.flush()
+			cols, _ := w.vt.Size()
+			if cur := w.vt.Cursor(); cur.X == cols-1 && cur.State&cursorWrapNext == 0 {
+				// no room in the last column: pad and let it wrap, as
+				// xterm does
+				w.out = append(w.out, ' ')
+			}
+			w.out = utf8.AppendRune(w.out, r)
+			w.out = utf8.AppendRune(w.out, wideSpacer)
+			continue
+		}
+		w.out = utf8.AppendRune(w.out, r)
+	}
+}
+
+// Rang reports whether the program rang the bell since the last call. A BEL
+// ending an OSC string doesn't count.
+func (w *CellWriter) Rang() bool {
+	rang := w.bell
+	w.bell = false
+	return rang
+}
+
+func (w *CellWriter) flush() {
+	if len(w.out) == 0 {
+		return
+	}
+	w.vt.Write(w.out)
+	w.out = w.out[:0]
+}
+
+// lastRuneStart returns the index of the last UTF-8 lead byte in the final
+// few bytes of p, or -1.
+func lastRuneStart(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isASCII(p []byte) bool {
+	for _, b := range p {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Entry kinds recorded in a transcript
+const (
+	KindOutput  = "output"  // shared terminal output
+	KindEvent   = "event"   // join/leave/kick/host changes
+	KindAI      = "ai"      // AI prompts and replies
+	KindSandbox = "sandbox" // sandbox commands and their output
+)
+
+const (
+	// outputMergeWindow folds terminal output arriving in quick succession
+	// into a single entry so transcripts aren't one line per PTY read.
+	outputMergeWindow = 2 * time.Second
+	// maxBytes bounds the text held in memory; the oldest entries go first.
+	maxBytes = 2 << 20
+)
+
+// Entry is one timestamped line of the session record.
+type Entry struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	User string    `json:"user,omitempty"`
+	Text string    `json:"text"`
+}
+
+// Transcript is a bounded, concurrency-safe log of everything that
+// happened in a room.
+type Transcript struct {
+	mu      sync.Mutex
+	entries []Entry
+	size    int
+}
+
+func New() *Transcript {
+	return &Transcript{}
+}
+
+// Add records an entry of the given kind.
+func (t *Transcript) Add(kind, user, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.appendLocked(Entry{Time: time.Now(), Kind: kind, User: user, Text: text})
+}
+
+// AddOutput records raw terminal output, stripping escape sequences.
+func (t *Transcript) AddOutput(data []byte) {
+	text := strings.ReplaceAll(ansi.Strip(string(data)), "\r", "")
+	if text == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if n := len(t.entries); n > 0 {
+		last := &t.entries[n-1]
+		if last.Kind == KindOutput && now.Sub(last.Time) < outputMergeWindow {
+			last.Text += text
+			t.size += len(text)
+			t.trimLocked()
+			return
+		}
+	}
+	t.appendLocked(Entry{Time: now, Kind: KindOutput, Text: text})
+}
+
+func (t *Transcript) appendLocked(e Entry) {
+	t.entries = append(t.entries, e)
+	t.size += len(e.Text)
+	t.trimLocked()
+}
+
+func (t *Transcript) trimLocked() {
+	for t.size > maxBytes && len(t.entries) > 1 {
+		t.size -= len(t.entries[0].Text)
+		t.entries = t.entries[1:]
+	}
+}
+
+// Entries returns a copy of the recorded entries, oldest first.
+func (t *Transcript) Entries() []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Entry(nil), t.entries...)
+}
+
+// JSON renders the transcript as an indented JSON document.
+func (t *Transcript) JSON(title string) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Title      string    `json:"title"`
+		ExportedAt time.Time `json:"exported_at"`
+		Entries    []Entry   `json:"entries"`
+	}{title, time.Now(), t.Entries()}, "", "  ")
+}
+
+// Markdown renders the transcript as a readable Markdown document, with
+// terminal output in fenced code blocks.
+func (t *Transcript) Markdown(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n_Exported %s_\n\n", title, time.Now().Format(time.RFC1123))
+
+	for _, e := range t.Entries() {
+		ts := e.Time.Format("15:04:05")
+		switch e.Kind {
+		case KindOutput:
+			fmt.Fprintf(&b, "`%s` terminal output:\n\n```\n%s\n```\n\n", ts, strings.TrimRight(e.Text, "\n"))
+		case KindSandbox:
+			fmt.Fprintf(&b, "`%s` **%s** ran in sandbox:\n\n```\n%s\n```\n\n", ts, e.User, strings.TrimRight(e.Text, "\n"))
+		case KindAI:
+			fmt.Fprintf(&b, "`%s` **%s**: %s\n\n", ts, e.User, e.Text)
+		default:
+			fmt.Fprintf(&b, "`%s` _%s_\n\n", ts, e.Text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,298 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// adminRefresh is how often the dashboard re-samples rooms.
+const adminRefresh = time.Second
+
+type adminTickMsg time.Time
+
+// adminRow is one room on the dashboard with its traffic rates.
+type adminRow struct {
+	room    *room.Room
+	info    room.RoomInfo
+	stats   room.Stats
+	inRate  float64 // bytes per second typed into the shells
+	outRate float64 // bytes per second printed by them
+}
+
+// Admin is the dashboard operators get instead of the launch screen when
+// they connect with an admin key: every room, live, with the option to
+// look inside one and close it.
+type Admin struct {
+	renderer    *lipgloss.Renderer
+	styles      *Styles
+	roomManager *room.Manager
+	username    string
+	width       int
+	height      int
+
+	rows     []adminRow
+	prev     map[string]room.Stats // last sample, by room ID
+	prevAt   time.Time
+	selected int
+	detail   string // ID of the room being looked at; empty shows the list
+	closing  bool   // waiting for y to confirm closing the selected room
+	status   string
+
+	auditBase audit.Event // who this operator is, see SetAudit
+}
+
+// NewAdmin creates the dashboard for an operator session.
+func NewAdmin(renderer *lipgloss.Renderer, roomManager *room.Manager, username string) *Admin {
+	theme, _ := LookupTheme(DefaultTheme, renderer)
+	a := &Admin{
+		renderer:    renderer,
+		styles:      NewStyles(renderer, theme),
+		roomManager: roomManager,
+		username:    username,
+		prev:        make(map[string]room.Stats),
+	}
+	a.refresh(time.Now())
+	return a
+}
+
+// SetAudit sets who this operator is for the audit log.
+func (a *Admin) SetAudit(base audit.Event) {
+	a.auditBase = base
+}
+
+func (a *Admin) Init() tea.Cmd {
+	return adminTick()
+}
+
+func adminTick() tea.Cmd {
+	return tea.Tick(adminRefresh, func(t time.Time) tea.Msg { return adminTickMsg(t) })
+}
+
+// refresh re-reads every room and works out rates since the last sample.
+func (a *Admin) refresh(now time.Time) {
+	elapsed := now.Sub(a.prevAt).Seconds()
+	next := make(map[string]room.Stats)
+	a.rows = a.rows[:0]
+	for _, r := range a.roomManager.Rooms() {
+		row := adminRow{room: r, info: r.Info(), stats: r.Stats()}
+		if prev, ok := a.prev[r.ID]; ok && elapsed > 0 {
+			row.inRate = rate(prev.BytesIn, row.stats.BytesIn, elapsed)
+			row.outRate = rate(prev.BytesOut, row.stats.BytesOut, elapsed)
+		}
+		next[r.ID] = row.stats
+		a.rows = append(a.rows, row)
+	}
+	a.prev, a.prevAt = next, now
+
+	if a.selected >= len(a.rows) {
+		a.selected = max(len(a.rows)-1, 0)
+	}
+	if a.detail != "" && a.selectedRow() == nil {
+		a.detail = ""
+		a.status = "That room has closed"
+	}
+}
+
+// rate is bytes per second between two samples; totals shrink when a tab
+// closes, which counts as no traffic rather than negative.
+func rate(prev, cur uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+func (a *Admin) selectedRow() *adminRow {
+	for i := range a.rows {
+		if a.detail != "" && a.rows[i].info.ID == a.detail {
+			return &a.rows[i]
+		}
+	}
+	if a.detail == "" && a.selected < len(a.rows) {
+		return &a.rows[a.selected]
+	}
+	return nil
+}
+
+func (a *Admin) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		a.width, a.height = msg.Width, msg.Height
+	case adminTickMsg:
+		a.refresh(time.Time(msg))
+		return a, adminTick()
+	case tea.KeyMsg:
+		return a.handleKey(msg)
+	}
+	return a, nil
+}
+
+func (a *Admin) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if a.closing {
+		a.closing = false
+		row := a.selectedRow()
+		if msg.String() != "y" || row == nil {
+			a.status = "Not closed"
+			return a, nil
+		}
+		if err := a.roomManager.CloseRoom(row.info.ID, "closed by an administrator"); err != nil {
+			a.status = "Close failed: " + err.Error()
+			return a, nil
+		}
+		a.status = "Closed " + adminRoomTitle(row.info)
+		e := a.auditBase
+		e.Type, e.RoomID = "admin_close_room", row.info.ID
+		a.roomManager.AuditLog().Record(e)
+		a.detail = ""
+		a.refresh(time.Now())
+		return a, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return a, tea.Quit
+	case "up", "k":
+		if a.detail == "" && a.selected > 0 {
+			a.selected--
+		}
+	case "down", "j":
+		if a.detail == "" && a.selected < len(a.rows)-1 {
+			a.selected++
+		}
+	case "enter":
+		if row := a.selectedRow(); row != nil {
+			a.detail = row.info.ID
+		}
+	case "esc":
+		a.detail = ""
+	case "x":
+		if row := a.selectedRow(); row != nil {
+			a.closing = true
+			a.status = fmt.Sprintf("Close %s and disconnect %d people? (y/n)", adminRoomTitle(row.info), len(row.info.Participants))
+		}
+	}
+	return a, nil
+}
+
+func (a *Admin) View() string {
+	var body string
+	if row := a.selectedRow(); a.detail != "" && row != nil {
+		body = a.viewRoom(row)
+	} else {
+		body = a.viewRooms()
+	}
+
+	help := "↑/↓ select • enter details • x close room • q quit"
+	if a.detail != "" {
+		help = "esc back • x close room • q quit"
+	}
+	lines := []string{
+		a.styles.titleStyle.Render("duet admin") + a.styles.dimStyle.Render(fmt.Sprintf("  %s · %d rooms", a.username, len(a.rows))),
+		"",
+		body,
+	}
+	if a.status != "" {
+		lines = append(lines, "", a.styles.accentStyle.Render("▸ "+a.status))
+	}
+	lines = append(lines, "", a.styles.helpStyle.Render(help))
+	return lipgloss.NewStyle().Padding(1, 2).Render(strings.Join(lines, "\n"))
+}
+
+func (a *Admin) viewRooms() string {
+	if len(a.rows) == 0 {
+		return a.styles.dimStyle.Render("no active rooms")
+	}
+	const format = "%-26s %-12s %7s %9s %9s %5s %7s %6s"
+	var b strings.Builder
+	b.WriteString(a.styles.dimStyle.Render("  "+fmt.Sprintf(format, "ROOM", "HOST", "USERS", "OUT", "IN", "AI", "SANDBOX", "IDLE")) + "\n")
+	for i, row := range a.rows {
+		users := fmt.Sprintf("%d", len(row.info.Participants))
+		if row.info.MaxClients > 0 {
+			users += fmt.Sprintf("/%d", row.info.MaxClients)
+		}
+		line := fmt.Sprintf(format,
+			truncate(adminRoomTitle(row.info), 26),
+			truncate(row.info.HostName, 12),
+			users,
+			formatBytes(row.outRate)+"/s",
+			formatBytes(row.inRate)+"/s",
+			fmt.Sprintf("%d", row.stats.AIRequests),
+			fmt.Sprintf("%d", row.stats.Sandbox),
+			shortDuration(time.Since(row.info.LastActive)),
+		)
+		if i == a.selected {
+			b.WriteString(a.styles.accentStyle.Render("▸ "+line) + "\n")
+		} else {
+			b.WriteString(a.styles.textStyle.Render("  "+line) + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (a *Admin) viewRoom(row *adminRow) string {
+	info := row.info
+	var b strings.Builder
+	b.WriteString(a.styles.accentStyle.Render(adminRoomTitle(info)) + a.styles.dimStyle.Render("  "+info.ID) + "\n")
+	fmt.Fprintf(&b, "host %s · up %s · idle %s", info.HostName, shortDuration(time.Since(info.CreatedAt)), shortDuration(time.Since(info.LastActive)))
+	if len(info.Tags) > 0 {
+		b.WriteString(" · #" + strings.Join(info.Tags, " #"))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "shell out %s (%s/s) · in %s (%s/s)\n",
+		formatBytes(float64(row.stats.BytesOut)), formatBytes(row.outRate),
+		formatBytes(float64(row.stats.BytesIn)), formatBytes(row.inRate))
+	fmt.Fprintf(&b, "AI questions %d · sandbox commands %d · tabs %d\n", row.stats.AIRequests, row.stats.Sandbox, len(row.room.Tabs()))
+
+	b.WriteString("\n" + a.styles.dimStyle.Render("connected:") + "\n")
+	clients := row.room.ClientDetails()
+	if len(clients) == 0 {
+		b.WriteString(a.styles.dimStyle.Render("  nobody") + "\n")
+	}
+	for _, c := range clients {
+		line := "  " + a.styles.user(c.Username).Render(c.Username)
+		if c.IsHost {
+			line += " (host)"
+		}
+		line += a.styles.dimStyle.Render(" · " + shortDuration(time.Since(c.JoinedAt)))
+		if c.Fingerprint != "" {
+			line += a.styles.dimStyle.Render(" · " + c.Fingerprint)
+		}
+		b.WriteString(line + "\n")
+	}
+	if pending := row.room.PendingUsernames(); len(pending) > 0 {
+		b.WriteString(a.styles.dimStyle.Render("knocking: "+strings.Join(pending, ", ")) + "\n")
+	}
+	if jobs := row.room.SandboxJobs(); len(jobs) > 0 {
+		b.WriteString("\n" + a.styles.dimStyle.Render("sandbox running:") + "\n")
+		for _, j := range jobs {
+			b.WriteString(fmt.Sprintf("  %s %s (%s)", j.By, truncate(j.Cmd, 40), shortDuration(time.Since(j.Started))) + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// adminRoomTitle is the room's description, or its short ID without one.
+func adminRoomTitle(info room.RoomInfo) string {
+	if info.Description != "" {
+		return info.Description
+	}
+	return info.ID[:8]
+}
+
+// formatBytes renders n bytes as "512B", "3.4K" or "1.2M".
+func formatBytes(n float64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%.0fB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fK", n/1024)
+	default:
+		return fmt.Sprintf("%.1fM", n/(1024*1024))
+	}
+}
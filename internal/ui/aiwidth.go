@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// aiWidths are the AI sidebar sizes alt+a steps through, narrow to wide,
+// before collapsing it to a badge.
+var aiWidths = []struct {
+	name string
+	frac float64
+}{
+	{"narrow", 0.18},
+	{"medium", defaultAIFrac},
+	{"wide", 0.4},
+}
+
+// aiBadgeW is the AI sidebar's width collapsed to a badge, border included.
+const aiBadgeW = 8
+
+// cycleAIWidth widens the AI sidebar to the next of aiWidths, collapses it
+// to a badge after the widest, and opens it narrow again from there.
+func (m *Model) cycleAIWidth() {
+	name := ""
+	switch {
+	case !m.showAISidebar || m.aiBadge:
+		m.showAISidebar, m.aiBadge = true, false
+		m.aiFrac, name = aiWidths[0].frac, aiWidths[0].name
+	default:
+		m.aiBadge = true
+		for _, w := range aiWidths {
+			// alt+left/right may have left it between two sizes
+			if w.frac > m.aiFrac+0.001 {
+				m.aiBadge = false
+				m.aiFrac, name = w.frac, w.name
+				break
+			}
+		}
+	}
+	if !m.aiBadge {
+		m.aiUnread = 0
+		m.addToast("AI sidebar: " + name)
+	}
+	m.relayout()
+}
+
+// aiCollapsed reports whether new AI messages go unseen: the sidebar is
+// hidden, only a badge, or left out of a compact window.
+func (m *Model) aiCollapsed() bool {
+	return !m.showAISidebar || m.aiBadge || m.compact()
+}
+
+// countUnreadAI adds AI messages that arrived since the last call to the
+// badge's count while the sidebar is collapsed.
+func (m *Model) countUnreadAI() {
+	for _, msg := range m.getAIMessages() {
+		if msg.Ts <= m.aiReadTs {
+			continue
+		}
+		m.aiReadTs = msg.Ts
+		if m.aiCollapsed() {
+			m.aiUnread++
+		}
+	}
+}
+
+// renderAIBadge draws the collapsed AI sidebar: its name and how many
+// messages came in since it was collapsed.
+func (m *Model) renderAIBadge(w, h int) string {
+	var b strings.Builder
+	b.WriteString(m.styles.titleStyle.Render("AI") + "\n\n")
+	switch {
+	case m.aiClient == nil:
+		b.WriteString(m.styles.dimStyle.Render("off"))
+	case m.aiLoading:
+		b.WriteString(m.aiSpinner.View())
+	case m.aiUnread > 0:
+		b.WriteString(m.styles.accentStyle.Bold(true).Render(fmt.Sprintf("%d new", m.aiUnread)))
+	}
+	b.WriteString("\n\n" + m.styles.dimStyle.Render("^]\nalt+a"))
+	return m.styles.aiSidebarStyle.Width(w).Height(h).Render(b.String())
+}
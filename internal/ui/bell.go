@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bellMode is how this client hears a shared terminal's bell.
+type bellMode int
+
+const (
+	bellToast  bellMode = iota // toast only
+	bellRing                   // toast and a BEL on the client's terminal
+	bellNotify                 // toast and an OSC 9 desktop notification
+	bellOff
+)
+
+var bellModes = map[string]bellMode{
+	"toast":  bellToast,
+	"ring":   bellRing,
+	"notify": bellNotify,
+	"off":    bellOff,
+}
+
+// setBellMode handles ":bell toast|ring|notify|off".
+func (m *Model) setBellMode(args []string) {
+	if len(args) != 1 {
+		m.addToast("Usage: bell toast|ring|notify|off")
+		return
+	}
+	mode, ok := bellModes[args[0]]
+	if !ok {
+		m.addToast("Usage: bell toast|ring|notify|off")
+		return
+	}
+	m.bellMode = mode
+	m.addToast("Bell: " + args[0])
+}
+
+// onBell reacts to a "bell" event; tab is the "n:title" label of the tab
+// that rang.
+func (m *Model) onBell(tab string) tea.Cmd {
+	if m.bellMode == bellOff {
+		return nil
+	}
+	m.addToast("Bell in tab " + tab)
+	if m.bellMode == bellToast {
+		return nil
+	}
+	return m.ring("bell in tab " + tab)
+}
+
+// ring alerts the client's own terminal as the bell mode says: a desktop
+// notification saying what happened with "notify", nothing with "off" and
+// otherwise a BEL. It goes through the program's Output, so it never splits
+// a frame the renderer is writing.
+func (m *Model) ring(what string) tea.Cmd {
+	if m.bellMode == bellOff || m.out == nil {
+		return nil
+	}
+	seq := "\a"
+	if m.bellMode == bellNotify {
+		seq = fmt.Sprintf("\x1b]9;duet: %s in %s\a", sanitizeOSC(what), m.roomID)
+	}
+	out := m.out
+	return func() tea.Msg {
+		out.Write([]byte(seq))
+		return nil
+	}
+}
+
+// sanitizeOSC drops control characters that would end or break an OSC
+// string.
+func sanitizeOSC(s string) string {
+	b := []rune(s)
+	out := b[:0]
+	for _, r := range b {
+		if r >= 0x20 && r != 0x7f {
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
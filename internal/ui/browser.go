@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// maxListedRooms is how many rooms the launch screen shows at once; the
+// list scrolls with the cursor past that.
+const maxListedRooms = 6
+
+// launchButtons come before the rooms in the launch screen's cursor
+// order: m.selected 0 is create, 1 is join, and 2 on are rooms.
+const launchButtons = 2
+
+// launchRooms lists the rooms matching the tag filter that we may see:
+// ones their host made public, and ours to take back as host. The rest
+// are only reachable by code. The launch screen redraws every tick, so it
+// stays live as rooms come and go.
+func (m *Model) launchRooms() []room.RoomInfo {
+	var matches []room.RoomInfo
+	for _, r := range m.roomManager.Rooms() {
+		info := r.Info()
+		if !info.Public && !r.ReclaimsHost(m.name, m.fingerprint) {
+			continue
+		}
+		if info.HasTag(m.roomFilter.Value()) {
+			matches = append(matches, info)
+		}
+	}
+	return matches
+}
+
+// moveLaunchCursor moves the launch screen selection by delta through the
+// buttons and then the rooms.
+func (m *Model) moveLaunchCursor(delta int) {
+	last := launchButtons + len(m.launchRooms()) - 1
+	m.selected = min(max(m.selected+delta, 0), last)
+}
+
+// joinSelectedRoom joins the room under the launch screen cursor, the
+// same way as typing its code on the join screen.
+func (m *Model) joinSelectedRoom() tea.Cmd {
+	rooms := m.launchRooms()
+	i := m.selected - launchButtons
+	if i < 0 || i >= len(rooms) {
+		// it closed under the cursor
+		m.selected = min(m.selected, launchButtons+len(rooms)-1)
+		return nil
+	}
+	m.input.SetValue(rooms[i].ID)
+	return m.joinRoom
+}
+
+// renderRoomList shows active rooms matching the tag filter, a page at a
+// time around the cursor.
+func (m *Model) renderRoomList() string {
+	matches := m.launchRooms()
+	// rooms may have closed since the cursor was last moved
+	m.selected = min(m.selected, launchButtons+len(matches)-1)
+
+	var b strings.Builder
+	if m.filtering || m.roomFilter.Value() != "" {
+		b.WriteString(m.roomFilter.View() + "\n")
+	}
+	if len(matches) == 0 {
+		if m.roomFilter.Value() != "" {
+			b.WriteString(m.styles.dimStyle.Render(m.tr("rooms.untagged", m.roomFilter.Value())))
+		}
+		return b.String()
+	}
+
+	cursor := m.selected - launchButtons
+	start := 0
+	if cursor >= maxListedRooms {
+		start = cursor - maxListedRooms + 1
+	}
+	end := min(start+maxListedRooms, len(matches))
+
+	b.WriteString(m.styles.dimStyle.Render(m.trn("rooms.active", len(matches))) + "\n")
+	if start > 0 {
+		b.WriteString(m.styles.dimStyle.Render("  "+m.tr("rooms.above", start)) + "\n")
+	}
+	for i := start; i < end; i++ {
+		info := matches[i]
+		title := info.Description
+		if title == "" {
+			title = info.ID[:8]
+		}
+		status := m.tr("rooms.online", len(info.Participants))
+		if info.Detached {
+			status = m.tr("rooms.detached")
+		}
+		age := m.tr("rooms.age", shortDuration(time.Since(info.CreatedAt)))
+		line := fmt.Sprintf("%s · %s · %s · %s", ellipsize(title, 30), info.HostName, status, age)
+		if i == cursor {
+			b.WriteString(m.styles.accentStyle.Bold(true).Render("▸ " + line))
+		} else {
+			b.WriteString(m.styles.textStyle.Render("  " + line))
+		}
+		if len(info.Tags) > 0 {
+			b.WriteString("  " + m.styles.accentStyle.Render("#"+strings.Join(info.Tags, " #")))
+		}
+		b.WriteString("\n")
+	}
+	if end < len(matches) {
+		b.WriteString(m.styles.dimStyle.Render("  "+m.tr("rooms.below", len(matches)-end)) + "\n")
+	}
+	return b.String()
+}
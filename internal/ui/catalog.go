@@ -0,0 +1,381 @@
+package ui
+
+// catalogs are the UI's text in each language, by message key. Keep the
+// keys in the same order in each so a missing translation is easy to spot;
+// whatever is missing shows in English. Help and toasts aren't in here yet.
+var catalogs = map[string]catalog{
+	"en": {
+		"launch.create":        "Create Room",
+		"launch.join":          "Join Room",
+		"launch.help":          "↑/↓ select • enter confirm or join the room • / filter rooms • p profile • ? help • q quit",
+		"rooms.active#one":     "active room (%d):",
+		"rooms.active#other":   "active rooms (%d):",
+		"rooms.above":          "↑ %d more",
+		"rooms.below":          "↓ %d more",
+		"rooms.online":         "%d online",
+		"rooms.detached":       "detached",
+		"rooms.age":            "%s old",
+		"rooms.untagged":       "no rooms tagged %s",
+		"create.prompt":        "Enter a description for your room:",
+		"create.help":          "enter create • tab next field • esc back",
+		"join.prompt":          "Enter the room ID:",
+		"join.help":            "enter join • esc back",
+		"lobby.title":          "Waiting Room",
+		"lobby.host":           "host: ",
+		"lobby.count#one":      "%d in the room",
+		"lobby.count#other":    "%d in the room",
+		"lobby.knocked":        "Knocked - waiting for the host to let you in...",
+		"lobby.noHost":         "The host isn't here yet...",
+		"lobby.noShell":        "Waiting for the host to start the shared terminal...",
+		"lobby.waiting":        "waiting %s",
+		"lobby.leave":          "esc leave",
+		"lobby.cancel":         "esc cancel",
+		"lobby.start":          "s start the terminal anyway • esc leave",
+		"created.title":        "Room Created!",
+		"created.share":        "Share this code with others to join:",
+		"created.hint":         "(select and copy the code above, or have them run)",
+		"created.help":         "enter → enter room • esc back",
+		"created.rejoin":       "If you get disconnected, rejoin with:",
+		"side.you":             "you: ",
+		"side.room":            "room: ",
+		"side.shell":           "shell: ",
+		"side.connected#one":   "connected (%d):",
+		"side.connected#other": "connected (%d):",
+		"side.host":            "(host)",
+		"side.self":            "(you)",
+		"side.knocking#one":    "knocking (%d):",
+		"side.knocking#other":  "knocking (%d):",
+		"side.knockKeys":       "f7 admit • f8 deny",
+		"side.banned#one":      "banned (%d):",
+		"side.banned#other":    "banned (%d):",
+		"side.recent":          "recent:",
+		"side.typing":          "is typing...",
+		"side.env#one":         "env (%d):",
+		"side.env#other":       "env (%d):",
+		"side.sandbox":         "sandbox:",
+		"side.running":         "running (ctrl+] kill):",
+		"side.keys":            "keys:",
+		"keys.help":            "all keys",
+		"keys.ai":              "AI prompt",
+		"keys.explain":         "explain output",
+		"keys.toggleAI":        "toggle AI",
+		"keys.toggleUsers":     "hide this sidebar",
+		"keys.scrollAI":        "scroll AI",
+		"keys.run":             "run command",
+		"keys.explainRun":      "explain result",
+		"keys.sendCode":        "send AI code",
+		"keys.command":         "command",
+		"keys.token":           "(token = rejoin)",
+		"keys.env":             "edit/export env",
+		"keys.macro":           "rec/play macro",
+		"keys.scrollback":      "scrollback",
+		"keys.tab":             "switch tab",
+		"keys.restart":         "restart shell",
+		"keys.raw":             "raw view",
+		"keys.leave":           "leave room",
+		"term.starting":        "Starting terminal...",
+		"bar.help":             "f1 help • ctrl+g AI • ctrl+] ctrl+e explain output • ctrl+a toggle AI • ctrl+r sandbox",
+		"bar.helpReadline":     "f1 help • ctrl+] then ctrl+g AI, ctrl+e explain, ctrl+a toggle AI, ctrl+r sandbox",
+		"bar.scroll":           "pgup/pgdn page • j/k line • g/G top/bottom • ? help • esc back to shell",
+		"bar.point":            "j/k move • J/K extend • enter point for everyone • esc cancel",
+		"bar.send":             "Send to the shell? y/n: ",
+		"mode.normal":          "NORMAL",
+		"mode.ai":              "AI",
+		"mode.run":             "RUN",
+		"mode.env":             "ENV",
+		"mode.cmd":             "CMD",
+		"mode.scroll":          "SCROLL",
+		"mode.point":           "POINT",
+		"mode.send":            "SEND",
+		"mode.recording":       "RECORDING",
+		"ai.title":             "AI Assistant",
+		"ai.disabled":          "Disabled by the server:\nit was started without an\nAI worker or model.",
+		"ai.thinking":          "Thinking...",
+		"ai.empty":             "No messages yet.\nPress ctrl+g to ask AI.",
+		"role.host":            "host",
+		"role.driver":          "driver",
+		"role.observer":        "observer",
+		"status.active":        "active",
+		"status.idle":          "idle",
+		"status.reconnecting":  "reconnecting…",
+	},
+	"es": {
+		"launch.create":        "Crear sala",
+		"launch.join":          "Unirse a una sala",
+		"launch.help":          "↑/↓ elegir • enter confirmar o entrar en la sala • / filtrar salas • p perfil • ? ayuda • q salir",
+		"rooms.active#one":     "sala activa (%d):",
+		"rooms.active#other":   "salas activas (%d):",
+		"rooms.above":          "↑ %d más",
+		"rooms.below":          "↓ %d más",
+		"rooms.online":         "%d en línea",
+		"rooms.detached":       "desconectada",
+		"rooms.age":            "hace %s",
+		"rooms.untagged":       "ninguna sala con la etiqueta %s",
+		"create.prompt":        "Escribe una descripción para tu sala:",
+		"create.help":          "enter crear • tab siguiente campo • esc volver",
+		"join.prompt":          "Escribe el ID de la sala:",
+		"join.help":            "enter entrar • esc volver",
+		"lobby.title":          "Sala de espera",
+		"lobby.host":           "anfitrión: ",
+		"lobby.count#one":      "%d persona en la sala",
+		"lobby.count#other":    "%d personas en la sala",
+		"lobby.knocked":        "Has llamado: esperando a que el anfitrión te deje entrar...",
+		"lobby.noHost":         "El anfitrión aún no ha llegado...",
+		"lobby.noShell":        "Esperando a que el anfitrión inicie la terminal compartida...",
+		"lobby.waiting":        "esperando %s",
+		"lobby.leave":          "esc salir",
+		"lobby.cancel":         "esc cancelar",
+		"lobby.start":          "s iniciar la terminal de todos modos • esc salir",
+		"created.title":        "¡Sala creada!",
+		"created.share":        "Comparte este código para que otros se unan:",
+		"created.hint":         "(copia el código de arriba, o pídeles que ejecuten)",
+		"created.help":         "enter → entrar en la sala • esc volver",
+		"created.rejoin":       "Si se corta la conexión, vuelve a entrar con:",
+		"side.you":             "tú: ",
+		"side.room":            "sala: ",
+		"side.shell":           "shell: ",
+		"side.connected#one":   "conectado (%d):",
+		"side.connected#other": "conectados (%d):",
+		"side.host":            "(anfitrión)",
+		"side.self":            "(tú)",
+		"side.knocking#one":    "llamando (%d):",
+		"side.knocking#other":  "llamando (%d):",
+		"side.knockKeys":       "f7 admitir • f8 rechazar",
+		"side.banned#one":      "vetado (%d):",
+		"side.banned#other":    "vetados (%d):",
+		"side.recent":          "reciente:",
+		"side.typing":          "está escribiendo...",
+		"side.env#one":         "entorno (%d):",
+		"side.env#other":       "entorno (%d):",
+		"side.sandbox":         "sandbox:",
+		"side.running":         "en marcha (ctrl+] kill):",
+		"side.keys":            "teclas:",
+		"keys.help":            "todas las teclas",
+		"keys.ai":              "preguntar a la IA",
+		"keys.explain":         "explicar la salida",
+		"keys.toggleAI":        "mostrar/ocultar IA",
+		"keys.toggleUsers":     "ocultar esta barra",
+		"keys.scrollAI":        "desplazar IA",
+		"keys.run":             "ejecutar comando",
+		"keys.explainRun":      "explicar resultado",
+		"keys.sendCode":        "enviar código de IA",
+		"keys.command":         "comando",
+		"keys.token":           "(token = volver a entrar)",
+		"keys.env":             "editar/exportar entorno",
+		"keys.macro":           "grabar/reproducir macro",
+		"keys.scrollback":      "historial",
+		"keys.tab":             "cambiar pestaña",
+		"keys.restart":         "reiniciar shell",
+		"keys.raw":             "vista raw",
+		"keys.leave":           "salir de la sala",
+		"term.starting":        "Iniciando la terminal...",
+		"bar.help":             "f1 ayuda • ctrl+g IA • ctrl+] ctrl+e explicar salida • ctrl+a mostrar IA • ctrl+r sandbox",
+		"bar.helpReadline":     "f1 ayuda • ctrl+] y luego ctrl+g IA, ctrl+e explicar, ctrl+a mostrar IA, ctrl+r sandbox",
+		"bar.scroll":           "pgup/pgdn página • j/k línea • g/G inicio/final • ? ayuda • esc volver a la shell",
+		"bar.point":            "j/k mover • J/K ampliar • enter señalar para todos • esc cancelar",
+		"bar.send":             "¿Enviar a la shell? y/n: ",
+		"mode.normal":          "NORMAL",
+		"mode.ai":              "IA",
+		"mode.run":             "EJECUTAR",
+		"mode.env":             "ENTORNO",
+		"mode.cmd":             "CMD",
+		"mode.scroll":          "HISTORIAL",
+		"mode.point":           "SEÑALAR",
+		"mode.send":            "ENVIAR",
+		"mode.recording":       "GRABANDO",
+		"ai.title":             "Asistente IA",
+		"ai.disabled":          "Desactivado por el servidor:\nse inició sin un worker\nni modelo de IA.",
+		"ai.thinking":          "Pensando...",
+		"ai.empty":             "Aún no hay mensajes.\nPulsa ctrl+g para preguntar.",
+		"role.host":            "anfitrión",
+		"role.driver":          "conduce",
+		"role.observer":        "observa",
+		"status.active":        "activo",
+		"status.idle":          "inactivo",
+		"status.reconnecting":  "reconectando…",
+	},
+	"de": {
+		"launch.create":        "Raum erstellen",
+		"launch.join":          "Raum beitreten",
+		"launch.help":          "↑/↓ wählen • enter bestätigen oder Raum betreten • / Räume filtern • p Profil • ? Hilfe • q beenden",
+		"rooms.active#one":     "aktiver Raum (%d):",
+		"rooms.active#other":   "aktive Räume (%d):",
+		"rooms.above":          "↑ %d weitere",
+		"rooms.below":          "↓ %d weitere",
+		"rooms.online":         "%d online",
+		"rooms.detached":       "getrennt",
+		"rooms.age":            "seit %s",
+		"rooms.untagged":       "keine Räume mit Tag %s",
+		"create.prompt":        "Beschreibung für deinen Raum eingeben:",
+		"create.help":          "enter erstellen • tab nächstes Feld • esc zurück",
+		"join.prompt":          "Raum-ID eingeben:",
+		"join.help":            "enter beitreten • esc zurück",
+		"lobby.title":          "Warteraum",
+		"lobby.host":           "Host: ",
+		"lobby.count#one":      "%d Person im Raum",
+		"lobby.count#other":    "%d Personen im Raum",
+		"lobby.knocked":        "Angeklopft – warte, bis der Host dich hereinlässt...",
+		"lobby.noHost":         "Der Host ist noch nicht da...",
+		"lobby.noShell":        "Warte, bis der Host das geteilte Terminal startet...",
+		"lobby.waiting":        "wartet seit %s",
+		"lobby.leave":          "esc verlassen",
+		"lobby.cancel":         "esc abbrechen",
+		"lobby.start":          "s Terminal trotzdem starten • esc verlassen",
+		"created.title":        "Raum erstellt!",
+		"created.share":        "Teile diesen Code, damit andere beitreten können:",
+		"created.hint":         "(Code oben markieren und kopieren, oder ausführen lassen)",
+		"created.help":         "enter → Raum betreten • esc zurück",
+		"created.rejoin":       "Falls die Verbindung abbricht, wieder beitreten mit:",
+		"side.you":             "du: ",
+		"side.room":            "Raum: ",
+		"side.shell":           "Shell: ",
+		"side.connected#one":   "verbunden (%d):",
+		"side.connected#other": "verbunden (%d):",
+		"side.host":            "(Host)",
+		"side.self":            "(du)",
+		"side.knocking#one":    "klopft an (%d):",
+		"side.knocking#other":  "klopfen an (%d):",
+		"side.knockKeys":       "f7 zulassen • f8 ablehnen",
+		"side.banned#one":      "gesperrt (%d):",
+		"side.banned#other":    "gesperrt (%d):",
+		"side.recent":          "zuletzt:",
+		"side.typing":          "tippt...",
+		"side.env#one":         "Umgebung (%d):",
+		"side.env#other":       "Umgebung (%d):",
+		"side.sandbox":         "Sandbox:",
+		"side.running":         "läuft (ctrl+] kill):",
+		"side.keys":            "Tasten:",
+		"keys.help":            "alle Tasten",
+		"keys.ai":              "KI fragen",
+		"keys.explain":         "Ausgabe erklären",
+		"keys.toggleAI":        "KI ein/aus",
+		"keys.toggleUsers":     "diese Leiste ausblenden",
+		"keys.scrollAI":        "KI scrollen",
+		"keys.run":             "Befehl ausführen",
+		"keys.explainRun":      "Ergebnis erklären",
+		"keys.sendCode":        "KI-Code senden",
+		"keys.command":         "Befehl",
+		"keys.token":           "(token = wieder beitreten)",
+		"keys.env":             "Umgebung bearbeiten/exportieren",
+		"keys.macro":           "Makro aufnehmen/abspielen",
+		"keys.scrollback":      "Verlauf",
+		"keys.tab":             "Tab wechseln",
+		"keys.restart":         "Shell neu starten",
+		"keys.raw":             "Rohansicht",
+		"keys.leave":           "Raum verlassen",
+		"term.starting":        "Terminal startet...",
+		"bar.help":             "f1 Hilfe • ctrl+g KI • ctrl+] ctrl+e Ausgabe erklären • ctrl+a KI ein/aus • ctrl+r Sandbox",
+		"bar.helpReadline":     "f1 Hilfe • ctrl+] dann ctrl+g KI, ctrl+e erklären, ctrl+a KI ein/aus, ctrl+r Sandbox",
+		"bar.scroll":           "pgup/pgdn Seite • j/k Zeile • g/G Anfang/Ende • ? Hilfe • esc zurück zur Shell",
+		"bar.point":            "j/k bewegen • J/K erweitern • enter allen zeigen • esc abbrechen",
+		"bar.send":             "An die Shell senden? y/n: ",
+		"mode.normal":          "NORMAL",
+		"mode.ai":              "KI",
+		"mode.run":             "AUSFÜHREN",
+		"mode.env":             "UMGEBUNG",
+		"mode.cmd":             "BEFEHL",
+		"mode.scroll":          "VERLAUF",
+		"mode.point":           "ZEIGEN",
+		"mode.send":            "SENDEN",
+		"mode.recording":       "AUFNAHME",
+		"ai.title":             "KI-Assistent",
+		"ai.disabled":          "Vom Server deaktiviert:\ner läuft ohne KI-Worker\noder -Modell.",
+		"ai.thinking":          "Denkt nach...",
+		"ai.empty":             "Noch keine Nachrichten.\nctrl+g fragt die KI.",
+		"role.host":            "Host",
+		"role.driver":          "tippt",
+		"role.observer":        "schaut zu",
+		"status.active":        "aktiv",
+		"status.idle":          "untätig",
+		"status.reconnecting":  "verbindet neu…",
+	},
+	"ja": {
+		"launch.create":        "ルームを作成",
+		"launch.join":          "ルームに参加",
+		"launch.help":          "↑/↓ 選択 • enter 決定・ルームに参加 • / ルームを絞り込む • p プロフィール • ? ヘルプ • q 終了",
+		"rooms.active#other":   "アクティブなルーム (%d):",
+		"rooms.above":          "↑ 他 %d 件",
+		"rooms.below":          "↓ 他 %d 件",
+		"rooms.online":         "%d 人オンライン",
+		"rooms.detached":       "切断中",
+		"rooms.age":            "%s 前",
+		"rooms.untagged":       "%s タグのルームはありません",
+		"create.prompt":        "ルームの説明を入力してください:",
+		"create.help":          "enter 作成 • tab 次の項目 • esc 戻る",
+		"join.prompt":          "ルームIDを入力してください:",
+		"join.help":            "enter 参加 • esc 戻る",
+		"lobby.title":          "待合室",
+		"lobby.host":           "ホスト: ",
+		"lobby.count#other":    "ルームに %d 人",
+		"lobby.knocked":        "ノックしました。ホストの承認を待っています...",
+		"lobby.noHost":         "ホストはまだいません...",
+		"lobby.noShell":        "ホストが共有ターミナルを開始するのを待っています...",
+		"lobby.waiting":        "待機中 %s",
+		"lobby.leave":          "esc 退出",
+		"lobby.cancel":         "esc キャンセル",
+		"lobby.start":          "s それでもターミナルを開始 • esc 退出",
+		"created.title":        "ルームを作成しました!",
+		"created.share":        "参加してもらうにはこのコードを共有してください:",
+		"created.hint":         "(上のコードをコピーするか、次を実行してもらってください)",
+		"created.help":         "enter → ルームに入る • esc 戻る",
+		"created.rejoin":       "切断された場合はこれで再参加できます:",
+		"side.you":             "あなた: ",
+		"side.room":            "ルーム: ",
+		"side.shell":           "シェル: ",
+		"side.connected#other": "接続中 (%d):",
+		"side.host":            "(ホスト)",
+		"side.self":            "(あなた)",
+		"side.knocking#other":  "ノック中 (%d):",
+		"side.knockKeys":       "f7 許可 • f8 拒否",
+		"side.banned#other":    "禁止 (%d):",
+		"side.recent":          "最近:",
+		"side.typing":          "が入力中...",
+		"side.env#other":       "環境変数 (%d):",
+		"side.sandbox":         "サンドボックス:",
+		"side.running":         "実行中 (ctrl+] kill):",
+		"side.keys":            "キー:",
+		"keys.help":            "キー一覧",
+		"keys.ai":              "AIに質問",
+		"keys.explain":         "出力を説明",
+		"keys.toggleAI":        "AI表示切替",
+		"keys.toggleUsers":     "このサイドバーを隠す",
+		"keys.scrollAI":        "AIをスクロール",
+		"keys.run":             "コマンド実行",
+		"keys.explainRun":      "結果を説明",
+		"keys.sendCode":        "AIのコードを送信",
+		"keys.command":         "コマンド",
+		"keys.token":           "(token = 再参加)",
+		"keys.env":             "環境変数の編集/書き出し",
+		"keys.macro":           "マクロ記録/再生",
+		"keys.scrollback":      "スクロールバック",
+		"keys.tab":             "タブ切替",
+		"keys.restart":         "シェル再起動",
+		"keys.raw":             "rawビュー",
+		"keys.leave":           "ルームを退出",
+		"term.starting":        "ターミナルを起動中...",
+		"bar.help":             "f1 ヘルプ • ctrl+g AI • ctrl+] ctrl+e 出力を説明 • ctrl+a AI表示切替 • ctrl+r サンドボックス",
+		"bar.helpReadline":     "f1 ヘルプ • ctrl+] の後 ctrl+g AI, ctrl+e 説明, ctrl+a AI表示切替, ctrl+r サンドボックス",
+		"bar.scroll":           "pgup/pgdn ページ • j/k 行 • g/G 先頭/末尾 • ? ヘルプ • esc シェルに戻る",
+		"bar.point":            "j/k 移動 • J/K 範囲を広げる • enter 全員に示す • esc キャンセル",
+		"bar.send":             "シェルに送信しますか? y/n: ",
+		"mode.normal":          "ノーマル",
+		"mode.ai":              "AI",
+		"mode.run":             "実行",
+		"mode.env":             "環境",
+		"mode.cmd":             "コマンド",
+		"mode.scroll":          "スクロール",
+		"mode.point":           "ポイント",
+		"mode.send":            "送信",
+		"mode.recording":       "記録中",
+		"ai.title":             "AIアシスタント",
+		"ai.disabled":          "サーバーで無効:\nAIワーカーやモデルなしで\n起動されています。",
+		"ai.thinking":          "考え中...",
+		"ai.empty":             "まだメッセージはありません。\nctrl+g でAIに質問。",
+		"role.host":            "ホスト",
+		"role.driver":          "操作中",
+		"role.observer":        "閲覧",
+		"status.active":        "アクティブ",
+		"status.idle":          "離席",
+		"status.reconnecting":  "再接続中…",
+	},
+}
@@ -0,0 +1,168 @@
+package ui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxCommandHistory bounds the command lines a session remembers.
+const maxCommandHistory = 100
+
+// commandNames are what the command line completes a first word to; keep
+// it in step with runCommand.
+var commandNames = []string{
+	"admit", "approval", "ban", "bell", "deny", "describe", "dump", "explain",
+	"export", "help", "host", "kick", "kill", "lang", "layout", "linear", "messages",
+	"mouse", "page", "pin", "play", "public", "quit", "raw", "record", "replay",
+	"sandbox", "tab", "template", "theme", "timer", "token", "unban", "unpin",
+	"web",
+}
+
+// commandLine is the state of the : prompt beyond what's typed: the lines
+// run so far this session and where up/down has got to in them.
+type commandLine struct {
+	history []string // oldest first
+	index   int      // 0 is the newest line; -1 means not recalling
+	draft   string   // what was typed before recalling started
+}
+
+// openCommandLine starts the : prompt, which ctrl+] opens anywhere and :
+// opens wherever keys don't go to the shell.
+func (m *Model) openCommandLine() tea.Cmd {
+	m.inputMode = ModeCommand
+	m.cmdInput.Reset()
+	m.cmdInput.Prompt = ":"
+	m.cmdInput.Placeholder = "command (help lists them; tab completes, up/down history)"
+	m.cmdLine.index = -1
+	m.offerCommands()
+	m.cmdInput.Focus()
+	return textinput.Blink
+}
+
+// colonOpensCommandLine reports whether : opens the command line here,
+// rather than being typed into the shell.
+func (m *Model) colonOpensCommandLine(key string) bool {
+	if key != ":" {
+		return false
+	}
+	return m.inputMode == ModeScroll || (m.inputMode == ModeNormal && m.focus != focusTerminal)
+}
+
+// rememberCommand adds line to the history, once, as the newest.
+func (m *Model) rememberCommand(line string) {
+	h := slices.DeleteFunc(m.cmdLine.history, func(s string) bool { return s == line })
+	h = append(h, line)
+	if len(h) > maxCommandHistory {
+		h = h[len(h)-maxCommandHistory:]
+	}
+	m.cmdLine.history = h
+}
+
+// handleCommandHistoryKey steps through the history with up/down and
+// reports whether key was one of them.
+func (m *Model) handleCommandHistoryKey(key string) bool {
+	h := m.cmdLine.history
+	switch key {
+	case "up":
+		if m.cmdLine.index+1 >= len(h) {
+			return true
+		}
+		if m.cmdLine.index < 0 {
+			m.cmdLine.draft = m.cmdInput.Value()
+		}
+		m.cmdLine.index++
+	case "down":
+		if m.cmdLine.index < 0 {
+			return true
+		}
+		m.cmdLine.index--
+	default:
+		return false
+	}
+	if m.cmdLine.index < 0 {
+		m.cmdInput.SetValue(m.cmdLine.draft)
+	} else {
+		m.cmdInput.SetValue(h[len(h)-1-m.cmdLine.index])
+	}
+	m.cmdInput.CursorEnd()
+	m.offerCommands()
+	return true
+}
+
+// offerCommands sets the prompt's suggestions to the whole lines the word
+// being typed could complete to: a command name first, then its
+// arguments. tab accepts the one shown, ctrl+n/ctrl+p pick another.
+func (m *Model) offerCommands() {
+	line := m.cmdInput.Value()
+	fields := strings.Fields(line)
+	var done string // the words already finished, with their spaces
+	var options []string
+	switch {
+	case len(fields) == 0 || (len(fields) == 1 && !strings.HasSuffix(line, " ")):
+		options = commandNames
+	default:
+		if strings.HasSuffix(line, " ") {
+			done = line
+		} else {
+			done = line[:strings.LastIndex(line, fields[len(fields)-1])]
+		}
+		if argc := len(strings.Fields(done)); argc == 1 {
+			options = m.commandArgs(fields[0])
+		}
+	}
+
+	suggestions := make([]string, 0, len(options))
+	for _, o := range options {
+		suggestions = append(suggestions, done+o+" ")
+	}
+	m.cmdInput.SetSuggestions(suggestions)
+	m.cmdInput.ShowSuggestions = len(suggestions) > 0
+}
+
+// commandArgs are the first arguments command takes, for completion.
+func (m *Model) commandArgs(command string) []string {
+	switch command {
+	case "kick", "host", "ban":
+		var names []string
+		for _, u := range m.users {
+			if !u.you {
+				names = append(names, u.name)
+			}
+		}
+		return names
+	case "unban":
+		if m.currentRoom != nil {
+			return m.currentRoom.BannedUsernames()
+		}
+	case "admit", "deny":
+		if m.currentRoom != nil {
+			return m.currentRoom.PendingUsernames()
+		}
+	case "theme":
+		return ThemeNames()
+	case "lang":
+		return Languages()
+	case "approval", "public", "raw", "mouse", "linear":
+		return []string{"on", "off"}
+	case "bell":
+		return []string{"toast", "ring", "notify", "off"}
+	case "tab":
+		return []string{"new", "close", "rename"}
+	case "export":
+		return []string{"md", "json"}
+	case "layout":
+		return []string{"reset"}
+	case "timer":
+		return []string{"start", "stop"}
+	case "sandbox":
+		return []string{"reset"}
+	case "web":
+		return []string{"control", "revoke"}
+	case "template":
+		return []string{"set", "rm"}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaypopat/duet/internal/ai"
+)
+
+// codeBlocks returns the fenced code blocks in the AI's replies, newest
+// first, so block 1 is always the latest suggestion.
+func codeBlocks(msgs []AIMessage) []string {
+	var blocks []string
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" || msgs[i].Role == ai.RoleSummary {
+			continue
+		}
+		found := fencedBlocks(msgs[i].Text)
+		for j := len(found) - 1; j >= 0; j-- {
+			blocks = append(blocks, found[j])
+		}
+	}
+	return blocks
+}
+
+// fencedBlocks pulls the bodies of ``` (or ~~~) fences out of markdown, in
+// order. An unclosed fence runs to the end of the text.
+func fencedBlocks(text string) []string {
+	var blocks []string
+	var body []string
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				fence = trimmed[:3]
+				body = body[:0]
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			if b := strings.TrimSpace(strings.Join(body, "\n")); b != "" {
+				blocks = append(blocks, b)
+			}
+			fence = ""
+			continue
+		}
+		body = append(body, strings.TrimRight(line, "\r"))
+	}
+	if fence != "" {
+		if b := strings.TrimSpace(strings.Join(body, "\n")); b != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// pickCodeBlock handles the ctrl+o prompt: an empty answer means the latest
+// block, otherwise N counts back from it. The block isn't sent until the
+// user confirms it.
+func (m *Model) pickCodeBlock(text string) {
+	blocks := codeBlocks(m.getAIMessages())
+	if len(blocks) == 0 {
+		m.addToast("No code blocks from the AI yet")
+		return
+	}
+	n := 1
+	if text = strings.TrimSpace(text); text != "" {
+		var err error
+		n, err = strconv.Atoi(text)
+		if err != nil || n < 1 {
+			m.addToast("Enter a block number, 1 is the latest")
+			return
+		}
+	}
+	if n > len(blocks) {
+		m.addToast("Only " + strconv.Itoa(len(blocks)) + " code blocks so far")
+		return
+	}
+	m.pendingCode = blocks[n-1]
+	m.inputMode = ModeConfirmCode
+}
+
+// handleConfirmCodeKey answers the "send this block?" prompt. The block is
+// typed without a final enter, so single commands still wait at the prompt;
+// only the line breaks inside a multi-line block run anything.
+func (m *Model) handleConfirmCodeKey(key string) tea.Cmd {
+	switch key {
+	case "y", "Y", "enter":
+		code := m.pendingCode
+		m.pendingCode = ""
+		m.inputMode = ModeNormal
+		if m.terminal == nil {
+			return nil
+		}
+		return m.pasteToTerminal(code)
+	case "n", "N", "esc", "ctrl+c":
+		m.pendingCode = ""
+		m.inputMode = ModeNormal
+	}
+	return nil
+}
+
+// codePreview is the pending block squeezed onto the bottom bar.
+func (m *Model) codePreview() string {
+	lines := strings.Split(m.pendingCode, "\n")
+	preview := lines[0]
+	if len(lines) > 1 {
+		preview += " … (+" + strconv.Itoa(len(lines)-1) + " lines)"
+	}
+	return preview
+}
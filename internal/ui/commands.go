@@ -0,0 +1,459 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// runCommand executes a line typed into the ctrl+] command prompt.
+func (m *Model) runCommand(line string) (tea.Model, tea.Cmd) {
+	fields := strings.Fields(strings.TrimLeft(line, ":"))
+	if len(fields) == 0 {
+		return m, nil
+	}
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "help", "?":
+		m.openHelp()
+	case "quit", "q":
+		m.confirmLeave()
+	case "record":
+		m.toggleMacroRecording()
+	case "replay":
+		m.replayMacro()
+	case "messages", "msgs":
+		m.openMessages()
+	case "kick":
+		m.kickUser(args)
+	case "host":
+		m.transferHost(args)
+	case "admit":
+		m.admitUser(args)
+	case "deny":
+		m.denyUser(args)
+	case "approval":
+		m.setApproval(args)
+	case "public":
+		m.setPublic(args)
+	case "ban":
+		m.banUser(args)
+	case "unban":
+		m.unbanUser(args)
+	case "describe":
+		m.describeRoom(args)
+	case "export":
+		m.exportTranscript(args)
+	case "dump":
+		m.dumpTerminal()
+	case "play":
+		return m.startPlayback(args)
+	case "tab":
+		return m, m.tabCommand(args)
+	case "bell":
+		m.setBellMode(args)
+	case "theme":
+		m.themeCommand(args)
+	case "layout":
+		m.layoutCommand(args)
+	case "timer":
+		m.timerCommand(args)
+	case "explain":
+		return m, m.explainLastOutput()
+	case "page", "pager":
+		m.openPager()
+	case "linear":
+		return m, m.linearCommand(args)
+	case "lang":
+		m.langCommand(args)
+	case "mouse":
+		return m, m.mouseCommand(args)
+	case "raw":
+		m.setPassthrough(args)
+	case "template":
+		m.templateCommand(args)
+	case "sandbox":
+		return m, m.sandboxCommand(args)
+	case "kill":
+		return m, m.killSandboxJob(args)
+	case "pin":
+		m.pinMessage(args)
+	case "unpin":
+		m.unpinMessage(args)
+	case "web":
+		m.webCommand(args)
+	case "token":
+		if m.rejoinToken == "" {
+			m.addToast("No rejoin token for this session")
+			break
+		}
+		m.addToastFor("rejoin token: "+m.rejoinToken, 15*time.Second)
+	default:
+		m.addToast(fmt.Sprintf("Unknown command: %s", name))
+	}
+	return m, nil
+}
+
+func (m *Model) kickUser(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can kick users")
+		return
+	}
+	if len(args) != 1 {
+		m.addToast("Usage: kick <user>")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	target := args[0]
+	if target == m.username {
+		m.addToast("You can't kick yourself")
+		return
+	}
+	if err := m.currentRoom.Kick(target); err != nil {
+		if errors.Is(err, room.ErrClientNotFound) {
+			m.addToast(fmt.Sprintf("No user named %s", target))
+			return
+		}
+		m.addError("Error: " + err.Error())
+		return
+	}
+
+	m.users = m.getUserList()
+	m.audit(audit.Event{Type: "kick", Target: target})
+	m.addToast(fmt.Sprintf("Kicked %s", target))
+}
+
+func (m *Model) transferHost(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can transfer host")
+		return
+	}
+	if len(args) != 1 {
+		m.addToast("Usage: host <user>")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	target := args[0]
+	if target == m.username {
+		m.addToast("You are already the host")
+		return
+	}
+	if err := m.currentRoom.TransferHost(target); err != nil {
+		if errors.Is(err, room.ErrClientNotFound) {
+			m.addToast(fmt.Sprintf("No user named %s", target))
+			return
+		}
+		m.addError("Error: " + err.Error())
+	}
+}
+
+func (m *Model) setPublic(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can list the room")
+		return
+	}
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		m.addToast("Usage: public on|off")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	on := args[0] == "on"
+	m.currentRoom.SetPublic(on)
+	if on {
+		m.addToast("The room is listed on everyone's launch screen")
+	} else {
+		m.addToast("The room is unlisted; only people with the code can join")
+	}
+}
+
+func (m *Model) setApproval(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can change join approval")
+		return
+	}
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		m.addToast("Usage: approval on|off")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	on := args[0] == "on"
+	m.currentRoom.SetRequireApproval(on)
+	if on {
+		m.addToast("Joiners must now knock")
+	} else {
+		m.addToast("Anyone with the code can join")
+	}
+}
+
+func (m *Model) admitUser(args []string) {
+	m.decideKnock(args, "admit", (*room.Room).Approve)
+}
+
+func (m *Model) denyUser(args []string) {
+	m.decideKnock(args, "deny", (*room.Room).Deny)
+}
+
+func (m *Model) decideKnock(args []string, verb string, decide func(*room.Room, string) error) {
+	if !m.isHost {
+		m.addToast("Only the host can " + verb + " users")
+		return
+	}
+	if len(args) != 1 {
+		m.addToast(fmt.Sprintf("Usage: %s <user>", verb))
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	target := args[0]
+	if err := decide(m.currentRoom, target); err != nil {
+		if errors.Is(err, room.ErrClientNotFound) {
+			m.addToast(fmt.Sprintf("%s is not knocking", target))
+			return
+		}
+		m.addError("Error: " + err.Error())
+		return
+	}
+	if verb == "admit" {
+		m.users = m.getUserList()
+		m.addToast(fmt.Sprintf("Admitted %s", target))
+	} else {
+		m.addToast(fmt.Sprintf("Denied %s", target))
+	}
+}
+
+// exportTranscript writes the room transcript into the shared workspace so
+// it can be read from the terminal or copied off the server.
+func (m *Model) exportTranscript(args []string) {
+	if m.currentRoom == nil || m.currentRoom.Transcript == nil {
+		return
+	}
+	format := "md"
+	if len(args) > 0 {
+		format = args[0]
+	}
+
+	title := m.currentRoom.GetDescription()
+	if title == "" {
+		title = "duet room " + m.roomID
+	}
+
+	var data []byte
+	switch format {
+	case "md", "markdown":
+		format = "md"
+		data = []byte(m.currentRoom.Transcript.Markdown(title))
+	case "json":
+		var err error
+		if data, err = m.currentRoom.Transcript.JSON(title); err != nil {
+			m.addError("Error: " + err.Error())
+			return
+		}
+	default:
+		m.addToast("Usage: export [md|json]")
+		return
+	}
+
+	name := fmt.Sprintf("transcript-%s.%s", time.Now().Format("20060102-150405"), format)
+	if err := m.currentRoom.CreateWorkspaceFile(name, data); err != nil {
+		m.addError("Error: " + err.Error())
+		return
+	}
+	m.addToastFor("Transcript written to ./"+name, 5*time.Second)
+}
+
+// dumpTerminal writes the current tab's scrollback and screen into the
+// workspace, as plain text and with ANSI colours, e.g. to keep a build log.
+func (m *Model) dumpTerminal() {
+	if !m.isHost {
+		m.addToast("Only the host can dump the terminal")
+		return
+	}
+	if m.currentRoom == nil || m.terminal == nil {
+		return
+	}
+
+	plain, colored := m.terminal.Dump()
+	base := "terminal-" + time.Now().Format("20060102-150405")
+	for _, f := range []struct{ ext, data string }{{"txt", plain}, {"ansi", colored}} {
+		if err := m.currentRoom.CreateWorkspaceFile(base+"."+f.ext, []byte(f.data)); err != nil {
+			m.addError("Error: " + err.Error())
+			return
+		}
+	}
+	m.addToastFor(fmt.Sprintf("Terminal written to ./%s.txt and ./%s.ansi", base, base), 5*time.Second)
+}
+
+func (m *Model) banUser(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can ban users")
+		return
+	}
+	if len(args) != 1 {
+		m.addToast("Usage: ban <user>")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	target := args[0]
+	if target == m.username {
+		m.addToast("You can't ban yourself")
+		return
+	}
+	if err := m.currentRoom.Ban(target); err != nil {
+		m.addError("Error: " + err.Error())
+		return
+	}
+	m.users = m.getUserList()
+	m.audit(audit.Event{Type: "ban", Target: target})
+	m.addToast(fmt.Sprintf("Banned %s", target))
+}
+
+func (m *Model) unbanUser(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can unban users")
+		return
+	}
+	if len(args) != 1 {
+		m.addToast("Usage: unban <user>")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	if err := m.currentRoom.Unban(args[0]); err != nil {
+		if errors.Is(err, room.ErrNotBanned) {
+			m.addToast(fmt.Sprintf("%s is not banned", args[0]))
+			return
+		}
+		m.addError("Error: " + err.Error())
+		return
+	}
+	m.addToast(fmt.Sprintf("Unbanned %s", args[0]))
+}
+
+func (m *Model) describeRoom(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can change the description")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+
+	if err := m.currentRoom.SetDescription(strings.Join(args, " "), m.username); err != nil {
+		m.addError("Error: " + err.Error())
+	}
+}
+
+// killSandboxJob stops a running sandbox command, the newest one unless a
+// job ID is given. Anyone in the room may do it; everyone is told.
+func (m *Model) killSandboxJob(args []string) tea.Cmd {
+	if m.currentRoom == nil {
+		return nil
+	}
+	var id string
+	if len(args) > 0 {
+		id = args[0]
+	}
+	job, err := m.currentRoom.CancelSandboxJob(id, m.username)
+	if err != nil {
+		if errors.Is(err, room.ErrNoSandboxJob) && id != "" {
+			m.addToast("No sandbox job " + id)
+			return nil
+		}
+		m.addError("Error: " + err.Error())
+		return nil
+	}
+
+	// dropping our request doesn't stop the command in the sandbox
+	client, roomID := m.aiClient, m.roomID
+	if client == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := client.CancelCommand(ctx, roomID, job.ID); err != nil && !errors.Is(err, ai.ErrJobNotFound) {
+			return ErrorMsg{fmt.Errorf("kill %s in the sandbox: %w", job.ID, err)}
+		}
+		return nil
+	}
+}
+
+// sandboxCommand shows the sandbox's state, or with "reset" has the worker
+// destroy it so the next command starts from a clean environment.
+func (m *Model) sandboxCommand(args []string) tea.Cmd {
+	if m.currentRoom == nil {
+		return nil
+	}
+	if !ai.HasSandbox(m.aiClient) {
+		m.addToast(sandboxDisabledMsg)
+		return nil
+	}
+	if len(args) == 0 {
+		m.addToastFor("sandbox: "+m.sandboxStatus(), 5*time.Second)
+		return nil
+	}
+	if len(args) != 1 || args[0] != "reset" {
+		m.addToast("Usage: sandbox [reset]")
+		return nil
+	}
+	if !m.isHost {
+		m.addToast("Only the host can reset the sandbox")
+		return nil
+	}
+
+	m.addToast("Resetting the sandbox...")
+	r, client, username := m.currentRoom, m.aiClient, m.username
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := client.ResetSandbox(ctx, r.ID); err != nil {
+			return ErrorMsg{fmt.Errorf("reset sandbox: %w", err)}
+		}
+		r.SandboxReset(username)
+		return nil
+	}
+}
+
+// sandboxStatus sums up the room's sandbox, e.g. "warm • sandbox-1234 •
+// used 2m ago".
+func (m *Model) sandboxStatus() string {
+	s := m.currentRoom.Sandbox()
+	if s.Name == "" {
+		return "cold • not used yet"
+	}
+	state := "cold"
+	if s.Warm() {
+		state = "warm"
+	}
+	if s.LastUsed.IsZero() {
+		return state + " • " + s.Name + " • reset"
+	}
+	return state + " • " + s.Name + " • used " + shortDuration(time.Since(s.LastUsed)) + " ago"
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaypopat/duet/internal/ai"
+)
+
+// compact reports whether the window is too small for the panels, so the
+// room is drawn with the users list as a one-line header and no AI
+// sidebar. Zen mode and the linear view have their own layouts.
+func (m *Model) compact() bool {
+	if m.zen || m.linear {
+		return false
+	}
+	return m.width < MinWidthForSidebar || m.height < MinHeightForSidebar
+}
+
+// viewCompact draws the room for a small window: who's here on the top
+// row, then the tab strip and note, the terminal, and the bottom bar
+// without its border. See termPane for the rows the terminal gets.
+func (m *Model) viewCompact() string {
+	_, terminalW, _, mainH := m.roomLayout()
+
+	header := m.renderTabStrip(terminalW)
+	if m.currentRoom == nil || len(m.currentRoom.Tabs()) <= 1 {
+		header = ""
+	}
+	content := m.termContent
+	if content == "" {
+		content = m.styles.dimStyle.Render(m.tr("term.starting"))
+	}
+	note := m.renderSizeNote(terminalW)
+	if pointed := m.pointNote(terminalW); pointed != "" {
+		content = m.highlightPointed(content)
+		note = pointed
+	}
+	if m.inputMode == ModeScroll && m.terminal != nil {
+		title, history := m.renderScrollback(terminalW)
+		header = m.styles.titleStyle.Render(title)
+		content = m.styles.textStyle.Render(history)
+		note = ""
+	}
+	if header != "" && note != "" {
+		note = header + "  " + note
+	} else if note == "" {
+		note = header
+	}
+	note = truncate(note, terminalW-2)
+
+	terminal := m.styles.baseStyle.Padding(0, 1).Width(terminalW).Height(mainH - 1).MaxHeight(mainH - 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, note, content))
+	return lipgloss.JoinVertical(lipgloss.Left, m.renderUsersLine(), terminal, m.renderBottomBar())
+}
+
+// renderUsersLine is the users sidebar folded into one row: the room, who's
+// in it, anyone knocking, and how many AI messages went unseen while the
+// AI sidebar is hidden.
+func (m *Model) renderUsersLine() string {
+	sep := m.styles.dimStyle.Render(" · ")
+	parts := []string{m.styles.titleStyle.Render(m.roomID)}
+	for _, u := range m.users {
+		name := u.name
+		if u.display != "" {
+			name = u.display
+		}
+		p := m.userStyle(u.name).Render(name)
+		if u.name == m.typingUser {
+			p = m.userStyle(u.name).Render("✎ " + name)
+		}
+		if u.host {
+			p += m.styles.dimStyle.Render(" " + m.tr("side.host"))
+		}
+		if u.you {
+			p += m.styles.dimStyle.Render(" " + m.tr("side.self"))
+		}
+		parts = append(parts, p)
+	}
+	if m.isHost && m.currentRoom != nil {
+		if pending := m.currentRoom.PendingUsernames(); len(pending) > 0 {
+			parts = append(parts, m.styles.accentStyle.Render(fmt.Sprintf("? %s (f7/f8)", strings.Join(pending, ", "))))
+		}
+	}
+	line := strings.Join(parts, sep)
+	if m.aiClient != nil && m.aiUnread > 0 {
+		badge := m.styles.accentStyle.Bold(true).Render(fmt.Sprintf("AI %d new (ctrl+a)", m.aiUnread))
+		line = ellipsize(line, m.width-textWidth(badge)-1)
+		return line + strings.Repeat(" ", max(m.width-textWidth(line)-textWidth(badge), 1)) + badge
+	}
+	return ellipsize(line, m.width)
+}
+
+// openAIPager shows the AI conversation in the pager, which is where
+// ctrl+a takes it while the window is too small for the AI sidebar.
+func (m *Model) openAIPager() {
+	if m.aiClient == nil {
+		m.addToast(aiDisabledMsg)
+		return
+	}
+	msgs := m.getAIMessages()
+	if len(msgs) == 0 {
+		m.addToast("No AI messages yet (ctrl+g asks)")
+		return
+	}
+	// the pager's border, padding and line numbers
+	w := max(m.width*9/10-12, 20)
+	var lines []string
+	for _, msg := range msgs {
+		who := msg.UserID
+		switch {
+		case msg.Role == ai.RoleSummary:
+			who = "Summary"
+		case msg.Role != "user":
+			who = "AI"
+		}
+		lines = append(lines, who+":")
+		for _, para := range strings.Split(msg.Text, "\n") {
+			lines = append(lines, wrapLines("  "+para, w)...)
+		}
+		lines = append(lines, "")
+	}
+	m.pager = &pager{title: "AI conversation", lines: lines}
+	m.aiUnread = 0
+}
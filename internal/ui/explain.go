@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// explainLines and explainBytes bound how much of the last command's output
+// :explain hands the AI, so a noisy build doesn't blow the model's context.
+const (
+	explainLines = 80
+	explainBytes = 6000
+)
+
+const (
+	explainPrompt        = "Explain this output from our shared terminal: what happened, and if something went wrong, how to fix it.\n\n```\n%s\n```"
+	explainSandboxPrompt = "Explain what happened when we ran this in the sandbox, and if something went wrong, how to fix it.\n\n$ %s\n\nstdout:\n```\n%s\n```\n\nstderr:\n```\n%s\n```"
+)
+
+// explainLastOutput asks the AI about the last command in the shared shell
+// and its output. The answer lands in the sidebar like any other prompt.
+func (m *Model) explainLastOutput() tea.Cmd {
+	if m.terminal == nil || !m.aiReady() {
+		return nil
+	}
+	out := m.lastOutput()
+	if out == "" {
+		m.addToast("No command output to explain yet")
+		return nil
+	}
+	m.addToast("Asking the AI about the last output")
+	return m.askAI(fmt.Sprintf(explainPrompt, out))
+}
+
+// explainSandboxResult asks the AI about the last sandbox command this
+// user ran, with its stdout and stderr kept apart.
+func (m *Model) explainSandboxResult() tea.Cmd {
+	res := m.lastSandbox
+	if res == nil {
+		m.addToast("No sandbox result to explain yet (ctrl+r runs one)")
+		return nil
+	}
+	if !m.aiReady() {
+		return nil
+	}
+	stdout, stderr := tailBytes(res.Stdout, explainBytes/2), tailBytes(res.Stderr, explainBytes/2)
+	if stdout == "" {
+		stdout = "[empty]"
+	}
+	if stderr == "" {
+		stderr = "[empty]"
+	}
+	m.addToast("Asking the AI about " + ellipsize(res.Cmd, 30))
+	return m.askAI(fmt.Sprintf(explainSandboxPrompt, res.Cmd, stdout, stderr))
+}
+
+// aiReady checks there's an AI to ask and it isn't busy or paused.
+func (m *Model) aiReady() bool {
+	if m.aiClient == nil {
+		m.addToast(aiDisabledMsg)
+		return false
+	}
+	if m.aiPaused() {
+		return false
+	}
+	if m.aiLoading {
+		m.addToast("Still waiting on the AI")
+		return false
+	}
+	return true
+}
+
+// askAI sends prompt as if it had been typed into the AI prompt.
+func (m *Model) askAI(prompt string) tea.Cmd {
+	m.aiLoading = true
+	spinnerCmd := func() tea.Msg { return m.aiSpinner.Tick() }
+	return tea.Batch(spinnerCmd, m.sendAIMessage(prompt))
+}
+
+// lastOutput is the last command line in the shared shell and what it
+// printed, trimmed to explainBytes from the end, where errors usually are.
+func (m *Model) lastOutput() string {
+	if m.terminal == nil {
+		return ""
+	}
+	return tailBytes(m.terminal.LastCommand(explainLines), explainBytes)
+}
+
+// tailBytes keeps about the last n bytes of s, starting on a whole line.
+func tailBytes(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	for len(s) > 0 && !utf8.RuneStart(s[0]) {
+		s = s[1:]
+	}
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return "…\n" + s
+}
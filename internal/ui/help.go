@@ -0,0 +1,292 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// helpSection is one titled block of the help overlay.
+type helpSection struct {
+	title string
+	keys  [][2]string // key, what it does
+}
+
+// helpSections lists what can be pressed or typed on the current screen
+// and in the current input mode.
+func (m *Model) helpSections() []helpSection {
+	switch m.screen {
+	case ScreenLaunch:
+		return []helpSection{{"Launch", [][2]string{
+			{"c / enter", "create a room"},
+			{"J", "join a room by code or rejoin token"},
+			{"up/down, j/k", "move through create, join and the active rooms"},
+			{"pgup/pgdn", "page through the active rooms"},
+			{"enter on a room", "join it"},
+			{"/", "filter the room list by tag"},
+			{"p", "your profile: display name, colour, theme, keymap"},
+			{"q, esc", "quit"},
+		}}}
+	case ScreenProfile:
+		return []helpSection{{"Profile", [][2]string{
+			{"tab, down / shift+tab, up", "next / previous field"},
+			{"left/right", "change the colour, theme or keymap"},
+			{"enter", "save"},
+			{"esc", "back without saving (skip on your first visit)"},
+		}}}
+	case ScreenCreate:
+		return []helpSection{{"Create room", [][2]string{
+			{"tab, down", "next field"},
+			{"shift+tab, up", "previous field"},
+			{"enter", "create the room"},
+			{"esc", "back"},
+		}}}
+	case ScreenJoin:
+		return []helpSection{{"Join room", [][2]string{
+			{"enter", "join with the room code or rejoin token"},
+			{"esc", "back"},
+		}}}
+	case ScreenRoomCreated:
+		return []helpSection{{"Room created", [][2]string{
+			{"enter", "enter the room"},
+			{"esc", "leave and go back"},
+		}}}
+	case ScreenLobby:
+		return []helpSection{{"Waiting room", [][2]string{
+			{"s", "start the terminal when the host isn't here"},
+			{"esc", "cancel the knock or leave"},
+		}}}
+	case ScreenPlayback:
+		return []helpSection{{"Playback", [][2]string{
+			{"space, p", "pause or resume"},
+			{"left/right, h/l", "seek back or forward"},
+			{"home, r / end", "start / end"},
+			{"+ / -", "faster / slower"},
+			{"esc, q", "back to the room"},
+		}}}
+	}
+
+	if m.inputMode == ModeScroll {
+		return []helpSection{{"Scrollback", [][2]string{
+			{"pgup/pgdn, ctrl+b/f", "page up or down"},
+			{"up/down, k/j", "one line"},
+			{"home, g / end, G", "oldest / newest"},
+			{"esc, q, f6", "back to the shell"},
+			{":", "command line"},
+		}}}
+	}
+
+	if m.inputMode == ModePoint {
+		return []helpSection{{"Pointing", [][2]string{
+			{"up/down, k/j", "move to another row"},
+			{"shift+up/down, K/J", "extend over several rows"},
+			{"enter, space", "highlight them for everyone for a few seconds"},
+			{"esc, q, alt+p", "cancel"},
+		}}}
+	}
+
+	return []helpSection{
+		{"Room (everything else goes to the shared shell)", [][2]string{
+			{"f1", "this help"},
+			{"ctrl+g", "ask the AI"},
+			{"ctrl+] ctrl+e", "have the AI explain the last output"},
+			{"ctrl+r", "run a command in the sandbox"},
+			{"alt+e", "have the AI explain the last sandbox result"},
+			{"ctrl+o", "send an AI code block to the shell"},
+			{"ctrl+] alt+p", "point everyone at rows of the terminal"},
+			{"ctrl+] alt+z", "zen mode: the terminal takes the whole window"},
+			{"ctrl+] alt+o", "page through the last command's output, with / search"},
+			{"click, wheel", "with mouse on: focus or scroll a panel; click someone for what you can do to them"},
+			{"pgup/pgdn, alt+up/down", "scroll the focused AI sidebar or pick in the users list; alt+enter opens the pick's menu"},
+			{"ctrl+a", "show or hide the AI sidebar; below 120x24, page through the AI chat"},
+			{"ctrl+] alt+a", "AI sidebar narrow, medium, wide or a badge"},
+			{"ctrl+] alt+u", "show or hide the users sidebar"},
+			{"ctrl+j / ctrl+k", "scroll the AI sidebar"},
+			{"ctrl+] ctrl+left/right", "narrow / widen the users sidebar"},
+			{"ctrl+] alt+left/right", "widen / narrow the AI sidebar"},
+			{"ctrl+]", "the : command line (below); ctrl+] then a chord the shell gets runs duet's action"},
+			{":", "the command line too, in scrollback or when a sidebar has the focus"},
+			{"f2 / f5", "edit env / export it into the shell (host)"},
+			{"f3 / f4", "record / replay a keyboard macro"},
+			{"pgup, f6", "scrollback; pgup goes to full-screen programs like less and vim"},
+			{"alt+1..9", "switch terminal tab"},
+			{"f7 / f8", "admit / deny whoever is knocking (host)"},
+			{"f9", "restart an exited shell (host)"},
+			{"f10", "raw view; ctrl+] comes back"},
+			{"ctrl+l", "leave the room, after asking; the host can close it instead"},
+		}},
+		{"Prompts (ctrl+g, ctrl+r, :)", [][2]string{
+			{"enter / esc", "submit / cancel"},
+			{"/name + tab", "complete an AI prompt template"},
+			{"up/down, ctrl+r", "sandbox history and search"},
+			{"tab, ctrl+n/p", "complete a command or its argument / pick another"},
+			{"up/down", "command history"},
+		}},
+		{"Commands (: or ctrl+] then type)", [][2]string{
+			{"help", "this help"},
+			{"quit, q", "leave the room (ctrl+l)"},
+			{"record / replay", "record a keyboard macro or stop / replay it (f3 / f4)"},
+			{"messages", "every toast and error so far"},
+			{"page", "the last command's output in a pager (ctrl+] alt+o)"},
+			{"explain", "have the AI explain the last output (ctrl+] ctrl+e)"},
+			{"mouse on|off", "use the mouse for the panels, or leave it to your terminal"},
+			{"kick, ban, unban <user>", "remove someone (host)"},
+			{"host <user>", "hand over host (host)"},
+			{"admit, deny <user>", "answer a knock (host)"},
+			{"approval on|off", "make joiners knock (host)"},
+			{"public on|off", "list the room on everyone's launch screen (host)"},
+			{"describe <text>", "rename the room"},
+			{"tab new|close|rename", "open a terminal tab; close or rename one (host)"},
+			{"raw on|off", "default the room to raw view (host)"},
+			{"bell toast|ring|notify|off", "what the terminal bell does for you"},
+			{"theme [name]", "switch your colours: " + strings.Join(ThemeNames(), ", ")},
+			{"layout [reset]", "show or reset the panel sizes"},
+			{"linear on|off", "plain labelled lines for screen readers, printed once, instead of panels"},
+			{"lang [code]", "switch the room's labels: " + strings.Join(Languages(), ", ")},
+			{"timer [25m|start|stop]", "shared countdown in everyone's status bar"},
+			{"template [set|rm]", "AI prompt templates"},
+			{"pin [N] / unpin <N>", "keep an AI answer in view"},
+			{"kill [job] / sandbox reset", "stop a sandbox command / reset it (host)"},
+			{"web [control|revoke]", "browser links to the room"},
+			{"export [md|json] / dump", "save the transcript / the screen"},
+			{"play <file.cast>", "replay a recording from the workspace"},
+			{"token", "show your rejoin token"},
+		}},
+	}
+}
+
+// openHelp shows the help overlay for the current screen.
+func (m *Model) openHelp() {
+	if m.linear {
+		m.printOverlay(m.helpLines())
+		return
+	}
+	m.showHelp = true
+	m.showMessages = false
+	m.helpOffset = 0
+}
+
+// helpKeyOpens reports whether key opens the help overlay here. ? only
+// works where it isn't typed into something.
+func (m *Model) helpKeyOpens(key string) bool {
+	if key == "f1" {
+		return true
+	}
+	if key != "?" {
+		return false
+	}
+	switch m.screen {
+	case ScreenLaunch:
+		return !m.filtering
+	case ScreenLobby, ScreenRoomCreated, ScreenPlayback:
+		return true
+	case ScreenRoom:
+		return m.inputMode == ModeScroll
+	}
+	return false
+}
+
+// handleHelpKey scrolls or closes the overlay; it swallows every other key
+// so nothing reaches the shell behind it.
+func (m *Model) handleHelpKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "esc", "q", "?", "f1", "enter":
+		m.showHelp = false
+	case "down", "j":
+		m.helpOffset++
+	case "up", "k":
+		m.helpOffset = max(m.helpOffset-1, 0)
+	case "pgdown", " ":
+		m.helpOffset += max(m.height/2, 1)
+	case "pgup":
+		m.helpOffset = max(m.helpOffset-m.height/2, 0)
+	case "ctrl+c":
+		m.showHelp = false
+		if m.screen != ScreenRoom {
+			m.cleanup()
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// renderHelp draws the overlay box, with the keys or the message log,
+// scrolled to helpOffset when it doesn't fit the window.
+func (m *Model) renderHelp() string {
+	var lines []string
+	if m.showMessages {
+		lines = m.messageLines()
+	} else {
+		lines = m.helpLines()
+	}
+
+	// border and padding take 4 rows, the footer 2
+	visible := max(m.height-6, 3)
+	maxOffset := max(len(lines)-visible, 0)
+	m.helpOffset = min(m.helpOffset, maxOffset)
+	lines = lines[m.helpOffset:min(m.helpOffset+visible, len(lines))]
+
+	footer := "esc close"
+	if maxOffset > 0 {
+		footer = "j/k scroll • " + footer
+	}
+	lines = append(lines, "", m.styles.dimStyle.Render(footer))
+
+	return m.styles.baseStyle.
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.styles.theme.Accent).
+		Padding(1, 2).
+		MaxWidth(m.width).
+		Render(strings.Join(lines, "\n"))
+}
+
+// helpLines are the overlay's contents when it shows the keys.
+func (m *Model) helpLines() []string {
+	sections := m.helpSections()
+	keyW := 0
+	for _, s := range sections {
+		for _, k := range s.keys {
+			keyW = max(keyW, lipgloss.Width(k[0]))
+		}
+	}
+
+	var lines []string
+	for i, s := range sections {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, m.styles.titleStyle.Render(s.title))
+		for _, k := range s.keys {
+			lines = append(lines, "  "+padRight(m.styles.accentStyle.Render(k[0]), keyW)+"  "+m.styles.textStyle.Render(k[1]))
+		}
+	}
+	return lines
+}
+
+// overlay draws fg centred on top of bg, which is width by height cells,
+// keeping what bg shows either side of it.
+func overlay(fg, bg string, width, height int) string {
+	bgLines := strings.Split(bg, "\n")
+	for len(bgLines) < height {
+		bgLines = append(bgLines, "")
+	}
+	fgLines := strings.Split(fg, "\n")
+	fgW := lipgloss.Width(fg)
+	x := max((width-fgW)/2, 0)
+	y := max((height-len(fgLines))/2, 0)
+
+	for i, line := range fgLines {
+		row := y + i
+		if row >= len(bgLines) {
+			break
+		}
+		under := bgLines[row]
+		under = padRight(under, width)
+		left := ansi.Truncate(under, x, "")
+		right := ansi.TruncateLeft(under, x+lipgloss.Width(line), "")
+		bgLines[row] = left + "\x1b[0m" + line + "\x1b[0m" + right
+	}
+	return strings.Join(bgLines, "\n")
+}
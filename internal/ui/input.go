@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// errInputStopped is returned by ReadRaw when its stop channel closes.
+var errInputStopped = errors.New("input stopped")
+
+// Input is the program's keyboard input over SSH. Reads on an SSH channel
+// can't be cancelled, so when bubbletea hands the terminal over (tea.Exec)
+// its reader would stay blocked and swallow the next keystroke. Input reads
+// the session in its own goroutine instead, and Detach makes the program's
+// pending Read give up cleanly before the handover.
+type Input struct {
+	chunks chan []byte
+	wake   chan struct{}
+
+	mu   sync.Mutex
+	rest []byte // unread tail of the last chunk
+	err  error  // set once the session's input ends
+}
+
+func NewInput(r io.Reader) *Input {
+	in := &Input{
+		chunks: make(chan []byte, 16),
+		wake:   make(chan struct{}, 1),
+	}
+	go in.pump(r)
+	return in
+}
+
+func (in *Input) pump(r io.Reader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			in.chunks <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			in.mu.Lock()
+			in.err = err
+			in.mu.Unlock()
+			close(in.chunks)
+			return
+		}
+	}
+}
+
+// Read is what bubbletea reads keys from. After Detach it returns io.EOF
+// once, which ends the program's input loop without consuming anything.
+func (in *Input) Read(p []byte) (int, error) {
+	n, stopped, err := in.read(p, in.wake)
+	if stopped {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+// Detach makes the program's blocked Read return so another reader (a
+// tea.Exec command) gets every byte that follows. The program starts a
+// fresh input loop when the command finishes.
+func (in *Input) Detach() {
+	select {
+	case in.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ReadRaw reads like Read but ignores Detach, and gives up with
+// errInputStopped when stop closes.
+func (in *Input) ReadRaw(p []byte, stop <-chan struct{}) (int, error) {
+	select {
+	case <-in.wake: // left over if the program never read again
+	default:
+	}
+	n, stopped, err := in.read(p, stop)
+	if stopped {
+		return 0, errInputStopped
+	}
+	return n, err
+}
+
+func (in *Input) read(p []byte, stop <-chan struct{}) (int, bool, error) {
+	in.mu.Lock()
+	if len(in.rest) > 0 {
+		n := copy(p, in.rest)
+		in.rest = in.rest[n:]
+		in.mu.Unlock()
+		return n, false, nil
+	}
+	in.mu.Unlock()
+
+	select {
+	case chunk, ok := <-in.chunks:
+		if !ok {
+			in.mu.Lock()
+			defer in.mu.Unlock()
+			return 0, false, in.err
+		}
+		n := copy(p, chunk)
+		if n < len(chunk) {
+			in.mu.Lock()
+			in.rest = chunk[n:]
+			in.mu.Unlock()
+		}
+		return n, false, nil
+	case <-stop:
+		return 0, true, nil
+	}
+}
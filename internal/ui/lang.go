@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultLanguage is the catalog sessions get unless the server or they
+// pick another. Every other catalog falls back to it key by key, so a
+// translation can be partial.
+const DefaultLanguage = "en"
+
+// catalog maps message keys to a language's text, as fmt formats. Plural
+// messages have one key per form, e.g. "side.connected#one" and
+// "side.connected#other"; see pluralForm.
+type catalog map[string]string
+
+// Languages lists the catalogs people can pick, DefaultLanguage first.
+func Languages() []string {
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		if name != DefaultLanguage {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultLanguage}, names...)
+}
+
+// LookupLanguage resolves name to a catalog, accepting locale spellings
+// like "de_DE.UTF-8" or "es-MX" for the language they're in. "" is
+// DefaultLanguage.
+func LookupLanguage(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return DefaultLanguage, true
+	}
+	if i := strings.IndexAny(name, "_-."); i > 0 {
+		name = name[:i]
+	}
+	_, ok := catalogs[name]
+	return name, ok
+}
+
+// ValidLanguage reports whether name is one LookupLanguage knows.
+func ValidLanguage(name string) bool {
+	_, ok := LookupLanguage(name)
+	return ok
+}
+
+// SetLanguage switches the session's text to the named catalog, reporting
+// false (and changing nothing) for one LookupLanguage doesn't know.
+func (m *Model) SetLanguage(name string) bool {
+	lang, ok := LookupLanguage(name)
+	if !ok {
+		return false
+	}
+	m.lang = lang
+	return true
+}
+
+// tr is the message for key in the session's language, formatted with args.
+func (m *Model) tr(key string, args ...any) string {
+	text, ok := catalogs[m.lang][key]
+	if !ok {
+		text, ok = catalogs[DefaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// trn is the plural message for key that fits n, formatted with n.
+func (m *Model) trn(key string, n int) string {
+	form := key + "#" + pluralForm(m.lang, n)
+	if _, ok := catalogs[m.lang][form]; !ok {
+		if _, ok := catalogs[DefaultLanguage][form]; !ok {
+			form = key + "#other"
+		}
+	}
+	return m.tr(form, n)
+}
+
+// pluralForm is the CLDR plural category of n in lang, for the languages
+// there are catalogs for: Japanese has no plural, the rest have one and
+// other.
+func pluralForm(lang string, n int) string {
+	if lang == "ja" || n != 1 {
+		return "other"
+	}
+	return "one"
+}
+
+// langCommand shows or switches this session's language.
+func (m *Model) langCommand(args []string) {
+	if len(args) == 0 {
+		m.addToastFor("lang: "+m.lang+" (pick from "+strings.Join(Languages(), ", ")+")", 5*time.Second)
+		return
+	}
+	if !m.SetLanguage(args[0]) {
+		m.addToast("Unknown language; pick from " + strings.Join(Languages(), ", "))
+		return
+	}
+	m.addToast("Language: " + m.lang)
+}
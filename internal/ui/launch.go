@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// launchUsage is shown when a session's command isn't one we know.
+const launchUsage = `Unknown command - try "join <room code>" or "create <description>"`
+
+// SetCommand acts on the command given after the host in ssh, e.g.
+// "ssh -t duet.example.com join <room code>", so an invite can be one line:
+//
+//	join <room code or rejoin token>   joins (or knocks on) the room
+//	create [description]               creates a room with default settings
+//
+// With no command the launch screen is shown as usual. Call before the
+// program starts.
+func (m *Model) SetCommand(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	if m.screen == ScreenProfile {
+		// they asked to go straight in; the profile can wait
+		m.screen = ScreenLaunch
+	}
+	switch rest := strings.TrimSpace(strings.Join(args[1:], " ")); args[0] {
+	case "join":
+		if rest == "" {
+			m.startup = toastCmd("Usage: join <room code>")
+			return
+		}
+		m.input.SetValue(rest)
+		m.startup = m.joinRoom
+	case "create":
+		m.input.SetValue(rest)
+		m.startup = m.createRoom
+	default:
+		m.startup = toastCmd(launchUsage)
+	}
+}
+
+func toastCmd(text string) tea.Cmd {
+	return func() tea.Msg { return ToastMsg{Text: text} }
+}
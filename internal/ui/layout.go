@@ -0,0 +1,175 @@
+package ui
+
+import "fmt"
+
+// Panel widths are kept as fractions of the window so they survive a
+// resize; these are where they start and how far ctrl/alt+arrows move them.
+const (
+	defaultSidebarFrac = 1.0 / 6
+	defaultAIFrac      = 1.0 / 4
+	minSidebarFrac     = 0.08
+	maxSidebarFrac     = 0.4
+	minAIFrac          = 0.15
+	maxAIFrac          = 0.6
+	panelStep          = 0.02
+	minTerminalW       = 40 // columns the shell keeps however wide the sidebars get
+)
+
+// roomLayout splits the window between the users sidebar, the terminal and
+// the AI sidebar. The sidebars give way before the terminal drops below
+// minTerminalW. In zen mode the terminal has the whole window; in a
+// compact one it has all but the header and bottom bar rows.
+func (m *Model) roomLayout() (sidebarW, terminalW, aiSidebarW, mainH int) {
+	if m.zen || m.linear {
+		return 0, m.width, 0, m.height
+	}
+	if m.compact() {
+		return 0, m.width, 0, m.height - 1
+	}
+	// a column for the border of each sidebar that's shown
+	borders := 0
+	if m.showUsers {
+		sidebarW = int(float64(m.width) * m.sidebarFrac)
+		borders++
+	}
+	if m.showAISidebar {
+		borders++
+	}
+	if m.showAISidebar && m.aiBadge {
+		aiSidebarW = aiBadgeW
+	} else if m.showAISidebar {
+		aiSidebarW = int(float64(m.width) * m.aiFrac)
+		if over := minTerminalW - (m.width - sidebarW - aiSidebarW - borders); over > 0 {
+			aiSidebarW = max(aiSidebarW-over, int(float64(m.width)*minAIFrac))
+		}
+	}
+	terminalW = m.width - sidebarW - aiSidebarW - borders
+	mainH = m.height - 2
+	return
+}
+
+// termPane is where the shell's screen sits in the window and how big it
+// is: inside the terminal pane's padding, below its header and note lines,
+// or the whole window in zen mode. The linear view prints it above its
+// last rows, and a compact window has it below the users and note lines.
+func (m *Model) termPane() (x, y, w, h int) {
+	if m.linear {
+		return 0, 0, m.width, max(m.height-linearRows, 1)
+	}
+	_, terminalW, _, mainH := m.roomLayout()
+	if m.zen {
+		return 0, 0, terminalW, mainH
+	}
+	if m.compact() {
+		return 1, 2, terminalW, max(mainH-2, 1)
+	}
+	return m.terminalLeft() + 1, 3, terminalW, mainH - 4
+}
+
+// terminalLeft is the window column the terminal pane starts at: after the
+// users sidebar and its border, if it's shown.
+func (m *Model) terminalLeft() int {
+	if !m.showUsers || m.zen || m.linear || m.compact() {
+		return 0
+	}
+	sidebarW, _, _, _ := m.roomLayout()
+	return sidebarW + 1
+}
+
+// toggleZen gives the terminal the whole window, hiding both sidebars and
+// the bottom bar, or brings them back.
+func (m *Model) toggleZen() {
+	m.zen = !m.zen
+	m.relayout()
+	if m.zen {
+		m.addToast("Zen mode: ctrl+] alt+z brings the panels back")
+	}
+}
+
+// toggleUsersSidebar hides the users sidebar, giving its columns to the
+// terminal, or brings it back; the AI sidebar stays as it is.
+func (m *Model) toggleUsersSidebar() {
+	m.showUsers = !m.showUsers
+	if !m.showUsers && m.focus == focusUsers {
+		m.focus = focusTerminal
+	}
+	m.relayout()
+	if m.showUsers {
+		m.addToast("Users sidebar shown")
+	} else {
+		m.addToast("Users sidebar hidden: ctrl+] alt+u brings it back")
+	}
+}
+
+// resizePanel moves the divider between the users sidebar and the
+// terminal (users) or between the terminal and the AI sidebar by steps of
+// panelStep; positive moves it right.
+func (m *Model) resizePanel(users bool, steps int) {
+	if m.zen {
+		m.addToast("No panels in zen mode (ctrl+] alt+z leaves it)")
+		return
+	}
+	if m.linear {
+		m.addToast("No panels in the linear view (linear off leaves it)")
+		return
+	}
+	if m.compact() {
+		m.addToast(fmt.Sprintf("No panels below %dx%d (the window is %dx%d)", MinWidthForSidebar, MinHeightForSidebar, m.width, m.height))
+		return
+	}
+	delta := float64(steps) * panelStep
+	if users && !m.showUsers {
+		m.addToast("The users sidebar is hidden (ctrl+] alt+u shows it)")
+		return
+	}
+	if users {
+		m.sidebarFrac = min(max(m.sidebarFrac+delta, minSidebarFrac), maxSidebarFrac)
+	} else {
+		if !m.showAISidebar {
+			m.addToast("The AI sidebar is hidden (ctrl+a shows it)")
+			return
+		}
+		if m.aiBadge {
+			m.aiBadge, m.aiUnread = false, 0
+		}
+		// the AI sidebar is on the right, so moving its edge right shrinks it
+		m.aiFrac = min(max(m.aiFrac-delta, minAIFrac), maxAIFrac)
+	}
+	m.relayout()
+}
+
+// layoutCommand shows the panel sizes, or with "reset" puts both dividers
+// back where they started.
+func (m *Model) layoutCommand(args []string) {
+	switch {
+	case len(args) == 0:
+		m.addToast(fmt.Sprintf("Users %.0f%%, AI %.0f%% (ctrl+] then ctrl+left/right or alt+left/right)", m.sidebarFrac*100, m.aiFrac*100))
+	case len(args) == 1 && args[0] == "reset":
+		m.sidebarFrac, m.aiFrac = defaultSidebarFrac, defaultAIFrac
+		m.aiBadge = false
+		m.relayout()
+		m.addToast("Panel sizes reset")
+	default:
+		m.addToast("Usage: layout [reset]")
+	}
+}
+
+// relayout refits everything after the panel widths change: the terminal
+// hears our new pane size and re-arbitrates its own among the watchers,
+// and AI messages are wrapped again to the sidebar's width.
+func (m *Model) relayout() {
+	_, _, aiSidebarW, mainH := m.roomLayout()
+	m.reportViewSize()
+	if m.compact() {
+		m.focus = focusTerminal
+	} else if !m.aiCollapsed() {
+		// the window grew back; the sidebar shows what the header counted
+		m.aiUnread = 0
+	}
+	if m.showAISidebar && !m.aiBadge && aiSidebarW > 0 {
+		m.fitAIViewport(aiSidebarW, mainH)
+		if m.currentRoom != nil {
+			m.syncAIViewportContent()
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaypopat/duet/internal/audit"
+)
+
+// leaveDialog asks before ctrl+l or quit takes us out of the room, saying
+// what becomes of the shell; the host can also close the room from it.
+type leaveDialog struct {
+	note   []string
+	items  []menuItem
+	cursor int
+}
+
+// confirmLeave opens the leave dialog, worded for whether we're the host
+// and whether anyone else is here.
+func (m *Model) confirmLeave() {
+	d := &leaveDialog{}
+	leave := menuItem{"Leave the room", func() tea.Cmd { return m.leaveRoom() }}
+	closeRoom := menuItem{"Close the room for everyone", func() tea.Cmd { return m.closeRoom() }}
+	stay := menuItem{"Stay", func() tea.Cmd { return nil }}
+
+	successor := m.successor()
+	switch {
+	case !m.isHost:
+		d.note = []string{"The shell keeps running for the others."}
+		d.items = []menuItem{leave, stay}
+	case successor != "":
+		d.note = []string{"You're the host: " + successor + " takes over,", "and the shell keeps running."}
+		d.items = []menuItem{leave, closeRoom, stay}
+	case m.roomManager.DetachGrace() > 0:
+		d.note = []string{"Nobody else is here. The shell keeps running", "for " + m.roomManager.DetachGrace().String() + " so you can come back to it."}
+		d.items = []menuItem{leave, closeRoom, stay}
+	default:
+		d.note = []string{"Nobody else is here, so the shell", "will be closed."}
+		leave.label = "Leave and close the shell"
+		d.items = []menuItem{leave, stay}
+	}
+	m.leaving = d
+}
+
+// successor is who'd become host if we left now: the longest-connected
+// guest, as the room picks. It's empty when there's nobody else.
+func (m *Model) successor() string {
+	if m.currentRoom == nil {
+		return ""
+	}
+	var name string
+	var joined time.Time
+	for _, c := range m.currentRoom.GetClients() {
+		if c.ID == m.clientID {
+			continue
+		}
+		if name == "" || c.JoinedAt.Before(joined) {
+			name, joined = c.Username, c.JoinedAt
+		}
+	}
+	return name
+}
+
+// leaveRoom takes us back to the launch screen; the room carries on.
+func (m *Model) leaveRoom() tea.Cmd {
+	m.cleanup()
+	return gotoScreen(ScreenLaunch)
+}
+
+// closeRoom ends the room for everyone. We leave with the rest, when the
+// "closed" event comes back to us.
+func (m *Model) closeRoom() tea.Cmd {
+	if !m.isHost {
+		m.addToast("Only the host can close the room")
+		return nil
+	}
+	m.audit(audit.Event{Type: "room_close"})
+	if err := m.roomManager.CloseRoom(m.roomID, m.username+" closed it"); err != nil {
+		m.addError("Closing the room failed: " + err.Error())
+	}
+	return nil
+}
+
+func (m *Model) handleLeaveKey(key string) (tea.Model, tea.Cmd) {
+	d := m.leaving
+	switch key {
+	case "up", "k", "shift+tab":
+		d.cursor = (d.cursor + len(d.items) - 1) % len(d.items)
+	case "down", "j", "tab":
+		d.cursor = (d.cursor + 1) % len(d.items)
+	case "enter", " ":
+		item := d.items[d.cursor]
+		m.leaving = nil
+		return m, item.run()
+	case "y":
+		m.leaving = nil
+		return m, d.items[0].run()
+	case "esc", "n", "q", "ctrl+c":
+		m.leaving = nil
+	}
+	return m, nil
+}
+
+// renderLeaveDialog draws the leave dialog box.
+func (m *Model) renderLeaveDialog() string {
+	d := m.leaving
+	lines := []string{m.styles.titleStyle.Render("Leave room?"), ""}
+	for _, n := range d.note {
+		lines = append(lines, m.styles.textStyle.Render(n))
+	}
+	lines = append(lines, "")
+	for i, item := range d.items {
+		if i == d.cursor {
+			lines = append(lines, m.styles.accentStyle.Render("▸ "+item.label))
+		} else {
+			lines = append(lines, m.styles.textStyle.Render("  "+item.label))
+		}
+	}
+	lines = append(lines, "", m.styles.dimStyle.Render("enter pick • y leave • esc stay"))
+	return m.styles.baseStyle.
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.styles.theme.Accent).
+		Padding(0, 2).
+		Render(strings.Join(lines, "\n"))
+}
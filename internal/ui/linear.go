@@ -0,0 +1,268 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// The linear view is for screen readers. The session stays out of the alt
+// screen and the room comes out as labelled lines of plain text, each
+// printed once and never redrawn, so a screen reader reads it as it arrives
+// and the terminal's own scrollback keeps it: the shell's output, toasts
+// such as who joins and leaves, and the AI's messages. Only the last
+// linearRows rows are redrawn in place: the line the shell is writing, and
+// the status or whatever is being typed. There are no panels, borders or
+// colour, and the mouse is left to the terminal.
+
+// linearRows are the rows the linear view redraws; the shell gets the rest.
+const linearRows = 2
+
+// linearReadyMsg says the alt screen has been left, so lines printed from
+// now on stay.
+type linearReadyMsg struct{}
+
+// SetLinear starts the session in the linear view, or with panels. The
+// program must be started without the alt screen for the linear view;
+// linearCommand switches a running one.
+func (m *Model) SetLinear(on bool) {
+	m.linear = on
+	m.linearReady = on
+}
+
+// linearCommand shows or switches the linear view, leaving or going back
+// to the alt screen with it.
+func (m *Model) linearCommand(args []string) tea.Cmd {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		state := "off"
+		if m.linear {
+			state = "on"
+		}
+		m.addToast("linear: " + state + " (usage: linear on|off)")
+		return nil
+	}
+	on := args[0] == "on"
+	if on == m.linear {
+		return nil
+	}
+	m.linear, m.linearReady = on, false
+	m.linearOut, m.linearRoom = nil, ""
+	m.relayout()
+	if !on {
+		m.addToast("Linear view off")
+		return tea.Batch(tea.EnterAltScreen, m.syncMouse())
+	}
+	m.addToast("Linear view on")
+	return tea.Batch(
+		tea.Sequence(tea.ExitAltScreen, func() tea.Msg { return linearReadyMsg{} }),
+		m.syncMouse(),
+	)
+}
+
+// say queues a line to print above the linear view; see printLinear.
+func (m *Model) say(line string) {
+	if m.linear {
+		m.linearOut = append(m.linearOut, line)
+	}
+}
+
+// printLinear prints what was said since the last update, and in a room
+// what's new there, above the lines the linear view redraws.
+func (m *Model) printLinear() tea.Cmd {
+	if !m.linear || !m.linearReady {
+		return nil
+	}
+	if m.screen == ScreenRoom {
+		m.followRoom()
+	} else {
+		m.linearRoom = "" // coming back says where we are again
+	}
+	if len(m.linearOut) == 0 {
+		return nil
+	}
+	out := ansi.Strip(strings.Join(m.linearOut, "\n"))
+	m.linearOut = nil
+	return tea.Println(out)
+}
+
+// followRoom says what happened in the room since the last call: on
+// arriving, the room and who's in it; then the shell's finished lines and
+// any new AI messages.
+func (m *Model) followRoom() {
+	if m.linearRoom != m.roomID {
+		m.linearRoom = m.roomID
+		m.linearTerm = nil
+		m.sayRoom()
+		m.linearAISeen = max(len(m.getAIMessages())-1, 0)
+	}
+
+	if m.terminal != m.linearTerm {
+		// a new room or tab: its label and a screenful of what came before
+		m.linearTerm = m.terminal
+		if m.terminal != nil {
+			_, next, _ := m.terminal.LinesSince(math.MaxInt)
+			_, _, _, h := m.termPane()
+			m.linearSeen = max(next-h, 0)
+			m.say(m.terminalLabel() + ":")
+		}
+	}
+	if m.terminal != nil {
+		var lines []string
+		lines, m.linearSeen, _ = m.terminal.LinesSince(m.linearSeen)
+		m.linearOut = append(m.linearOut, lines...)
+	}
+
+	msgs := m.getAIMessages()
+	m.linearAISeen = min(m.linearAISeen, len(msgs))
+	for _, msg := range msgs[m.linearAISeen:] {
+		who := msg.UserID
+		switch {
+		case msg.Role == ai.RoleSummary:
+			who = "Summary"
+		case msg.Role != "user":
+			who = "AI"
+		}
+		m.say(who + " says: " + msg.Text)
+	}
+	m.linearAISeen = len(msgs)
+}
+
+// sayRoom says which room this is and who's in it.
+func (m *Model) sayRoom() {
+	title := "Room " + m.roomID
+	if desc := m.roomDescription(); desc != "" {
+		title += ": " + desc
+	}
+	m.say(title)
+
+	var people []string
+	for _, u := range m.users {
+		p := u.name
+		if u.display != "" {
+			p = u.display + " (" + u.name + ")"
+		}
+		notes := []string{u.role}
+		if u.status != room.StatusActive {
+			notes = append(notes, u.status)
+		}
+		if u.you {
+			notes = append(notes, "you")
+		}
+		p += " [" + strings.Join(notes, ", ") + "]"
+		people = append(people, p)
+	}
+	m.say(fmt.Sprintf("People, %d: %s.", len(m.users), strings.Join(people, ", ")))
+}
+
+// terminalLabel names the tab we're looking at, and how it's doing.
+func (m *Model) terminalLabel() string {
+	label := "Terminal"
+	if m.currentRoom != nil {
+		if tabs := m.currentRoom.Tabs(); len(tabs) > 1 {
+			i := m.currentRoom.TabIndex(m.terminal)
+			label += fmt.Sprintf(", tab %d of %d", i+1, len(tabs))
+			if i >= 0 && i < len(tabs) {
+				label += " " + tabs[i].Title
+			}
+		}
+	}
+	if status := m.shellStatus(); status != "" {
+		label += ", " + status
+	}
+	return label
+}
+
+// viewLinear is what the linear view redraws in a room: the line the shell
+// is writing, usually its prompt, and below it the status or the prompt
+// being typed into. Everything else has been printed above.
+func (m *Model) viewLinear() string {
+	var shell string
+	if m.terminal != nil {
+		_, _, shell = m.terminal.LinesSince(math.MaxInt)
+	}
+
+	if d := m.leaving; d != nil {
+		lines := append([]string{shell, "Leave room?"}, d.note...)
+		for i, item := range d.items {
+			if i == d.cursor {
+				lines = append(lines, "▸ "+item.label)
+			} else {
+				lines = append(lines, "  "+item.label)
+			}
+		}
+		return strings.Join(append(lines, "enter pick, y leave, esc stay."), "\n")
+	}
+
+	status := "Status: " + strings.Trim(m.getModeStatus(), "- ")
+	if m.lost != nil {
+		status += ". " + strings.TrimPrefix(m.lostBanner(), "⟳ ")
+	}
+	if m.isHost && m.currentRoom != nil {
+		if pending := m.currentRoom.PendingUsernames(); len(pending) > 0 {
+			status += ". Knocking: " + strings.Join(pending, ", ") + ", f7 admit, f8 deny"
+		}
+	}
+	if m.inputMode != ModeNormal && m.inputMode != ModeScroll && m.inputMode != ModePoint && m.inputMode != ModeConfirmCode {
+		status = ellipsize(status+". Input: ", m.width/2) + truncate(ansi.Strip(m.cmdInput.View()), m.width-m.width/2)
+	} else {
+		status = ellipsize(status+". f1 help.", m.width)
+	}
+	return truncate(shell, m.width) + "\n" + status
+}
+
+// printOverlay prints what an overlay (help, messages, the pager) would
+// show, as the linear view has no overlays.
+func (m *Model) printOverlay(lines []string) {
+	for _, l := range lines {
+		m.say(strings.TrimRight(ansi.Strip(l), " "))
+	}
+}
+
+// The screens before a room, in the linear view: the same words as the
+// panels, one after another without boxes, centring or colour.
+
+func (m *Model) viewLaunchLinear() string {
+	lines := []string{"duet"}
+	for i, label := range []string{m.tr("launch.create") + " (c)", m.tr("launch.join") + " (J)"} {
+		if m.selected == i {
+			lines = append(lines, "▸ "+label)
+		} else {
+			lines = append(lines, "  "+label)
+		}
+	}
+	if rooms := strings.TrimRight(m.renderRoomList(), "\n"); rooms != "" {
+		lines = append(lines, rooms)
+	}
+	if len(m.toasts) > 0 {
+		lines = append(lines, m.toasts[len(m.toasts)-1].text)
+	}
+	return ansi.Strip(strings.Join(append(lines, m.tr("launch.help")), "\n"))
+}
+
+func (m *Model) viewCreateLinear() string {
+	lines := []string{m.tr("launch.create"), m.tr("create.prompt")}
+	for i, f := range m.createFields() {
+		if i == m.createFocus {
+			lines = append(lines, "▸ "+f.View())
+		} else {
+			lines = append(lines, "  "+f.View())
+		}
+	}
+	if len(m.toasts) > 0 {
+		lines = append(lines, m.toasts[len(m.toasts)-1].text)
+	}
+	return ansi.Strip(strings.Join(append(lines, m.tr("create.help")), "\n"))
+}
+
+func (m *Model) viewJoinLinear() string {
+	lines := []string{m.tr("launch.join"), m.tr("join.prompt"), m.input.View()}
+	if len(m.toasts) > 0 {
+		lines = append(lines, m.toasts[len(m.toasts)-1].text)
+	}
+	return ansi.Strip(strings.Join(append(lines, m.tr("join.help")), "\n"))
+}
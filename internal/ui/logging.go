@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetLogger sets where this session logs. Lines get the room's ID added
+// while the session is in one.
+func (m *Model) SetLogger(l *log.Logger) {
+	m.logger = l
+}
+
+// log returns the session's logger, tagged with its room.
+func (m *Model) log() *log.Logger {
+	l := m.logger
+	if l == nil {
+		l = log.Default()
+	}
+	if m.roomID != "" {
+		return l.With("roomID", m.roomID)
+	}
+	return l
+}
+
+// logContext carries l into provider calls, so their retries are logged
+// against the session that made them.
+func logContext(ctx context.Context, l *log.Logger) context.Context {
+	return log.WithContext(ctx, l)
+}
+
+// SetTraceParent makes the spans this session starts children of sc,
+// its SSH session's span.
+func (m *Model) SetTraceParent(sc trace.SpanContext) {
+	m.traceParent = sc
+}
+
+// span starts a span for something this session does, under its SSH
+// session's span and tagged with its room.
+func (m *Model) span(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if m.traceParent.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, m.traceParent)
+	}
+	if m.roomID != "" {
+		attrs = append(attrs, attribute.String("room.id", m.roomID))
+	}
+	return tracing.Start(ctx, name, attrs...)
+}
+
+// endSpan finishes a span around a command, taking the outcome from the
+// message it returned.
+func endSpan(span trace.Span, msg tea.Msg) {
+	var err error
+	switch msg := msg.(type) {
+	case ErrorMsg:
+		err = msg.Err
+	case RoomCreatedMsg:
+		span.SetAttributes(attribute.String("room.id", msg.RoomID))
+	case RoomJoinedMsg:
+		span.SetAttributes(attribute.String("room.id", msg.RoomID))
+	case KnockSentMsg:
+		span.SetAttributes(attribute.String("room.id", msg.RoomID), attribute.Bool("knocked", true))
+	}
+	tracing.End(span, err)
+}
+
+// SetAudit sets who this session is for the audit log: base is copied into
+// every event it records.
+func (m *Model) SetAudit(base audit.Event) {
+	m.auditBase = base
+}
+
+// audit records a security-relevant event for this session, in its room.
+func (m *Model) audit(e audit.Event) {
+	a := m.auditBase
+	a.Type, a.Target, a.Result, a.Detail = e.Type, e.Target, e.Result, e.Detail
+	a.User = m.username
+	a.RoomID = m.roomID
+	if err := m.roomManager.AuditLog().Record(a); err != nil {
+		m.log().Warn("audit log write failed", "error", err)
+	}
+}
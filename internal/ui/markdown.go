@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	glamouransi "github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+)
+
+// markdown renders AI replies for the sidebar. glamour renderers are slow
+// to build, so one is kept per wrap width.
+type markdown struct {
+	width    int
+	renderer *glamour.TermRenderer
+}
+
+// renderMarkdown formats text as markdown wrapped to width, in the colours
+// the client's terminal supports. ok is false if glamour couldn't render it,
+// and the caller should fall back to plain wrapping.
+func (m *Model) renderMarkdown(text string, width int) (string, bool) {
+	if m.markdown.renderer == nil || m.markdown.width != width {
+		r, err := glamour.NewTermRenderer(
+			glamour.WithStyles(m.markdownStyle()),
+			glamour.WithColorProfile(m.renderer.ColorProfile()),
+			glamour.WithWordWrap(width),
+		)
+		if err != nil {
+			return "", false
+		}
+		m.markdown = markdown{width: width, renderer: r}
+	}
+
+	out, err := m.markdown.renderer.Render(text)
+	if err != nil {
+		return "", false
+	}
+	return strings.Trim(out, "\n"), true
+}
+
+// markdownStyle picks glamour's style for the client's terminal and theme,
+// with the document margin dropped since the sidebar indents replies
+// itself.
+func (m *Model) markdownStyle() glamouransi.StyleConfig {
+	style := styles.LightStyleConfig
+	switch {
+	case m.renderer.ColorProfile() == termenv.Ascii:
+		style = styles.ASCIIStyleConfig
+	case m.styles.theme.Markdown == "dark":
+		style = styles.DarkStyleConfig
+	}
+	var margin uint
+	style.Document.Margin = &margin
+	return style
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mentionFlash is how long the bottom bar flashes when someone @s us.
+const mentionFlash = 2 * time.Second
+
+// skipSeenMentions marks every AI message so far as checked, so joining a
+// room doesn't ring for old mentions or count them as unread.
+func (m *Model) skipSeenMentions() {
+	for _, msg := range m.getAIMessages() {
+		m.mentionTs = max(m.mentionTs, msg.Ts)
+	}
+	m.aiReadTs, m.aiUnread = m.mentionTs, 0
+}
+
+// checkMentions looks through AI messages that arrived since the last
+// check for @ us, in someone else's question or in the reply to it. It
+// flashes the bottom bar and rings the client's terminal the way the bell
+// mode says, except that "toast" rings too; "off" keeps quiet.
+func (m *Model) checkMentions() tea.Cmd {
+	var from, text string
+	author := ""
+	for _, msg := range m.getAIMessages() {
+		if msg.Role == "user" {
+			author = msg.UserID
+		}
+		if msg.Ts <= m.mentionTs {
+			continue
+		}
+		m.mentionTs = msg.Ts
+		if author == m.username || !(mentions(msg.Text, m.username) || mentions(msg.Text, m.name)) {
+			continue
+		}
+		from, text = author, msg.Text
+		if msg.Role != "user" {
+			from = "AI"
+		}
+	}
+	if from == "" {
+		return nil
+	}
+
+	m.flashUntil = time.Now().Add(mentionFlash)
+	m.addToastFor(fmt.Sprintf("%s mentioned you: %s", from, ellipsize(strings.Join(strings.Fields(text), " "), 60)), 5*time.Second)
+	return m.ring(from + " mentioned you")
+}
+
+// mentions reports whether text has @name as a word of its own, ignoring
+// case: "@bob," counts but "@bobby" and "me@bob" don't.
+func mentions(text, name string) bool {
+	if name == "" {
+		return false
+	}
+	lower, target := strings.ToLower(text), "@"+strings.ToLower(name)
+	for i := 0; ; {
+		j := strings.Index(lower[i:], target)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(target)
+		before := start == 0 || !isNameRune(rune(lower[start-1]))
+		after := end == len(lower) || !isNameRune(rune(lower[end]))
+		if before && after {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
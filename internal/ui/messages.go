@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"time"
+)
+
+const (
+	maxMessages       = 200             // toasts kept for the messages overlay
+	errorToastTimeout = 4 * time.Second // errors stay up longer than the usual second
+)
+
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityError
+)
+
+// message is a toast as remembered in the message log.
+type message struct {
+	at       time.Time
+	text     string
+	severity severity
+}
+
+// logMessage remembers a toast for the messages overlay. Once the log is
+// full, the oldest info message makes room; errors only go when there is
+// nothing but errors left.
+func (m *Model) logMessage(text string, sev severity) {
+	m.messages = append(m.messages, message{at: time.Now(), text: text, severity: sev})
+	if len(m.messages) <= maxMessages {
+		return
+	}
+	drop := 0
+	for i, msg := range m.messages {
+		if msg.severity == severityInfo {
+			drop = i
+			break
+		}
+	}
+	m.messages = append(m.messages[:drop], m.messages[drop+1:]...)
+}
+
+// addError shows a toast for something that went wrong: it stays up for
+// longer and outlives the info messages in the log.
+func (m *Model) addError(text string) {
+	m.showToast(text, errorToastTimeout, severityError)
+}
+
+// openMessages shows the message log in the overlay, newest first.
+func (m *Model) openMessages() {
+	if m.linear {
+		m.printOverlay(m.messageLines())
+		return
+	}
+	m.openHelp()
+	m.showMessages = true
+}
+
+// messageLines are the overlay's contents when it shows the message log.
+func (m *Model) messageLines() []string {
+	lines := []string{m.styles.titleStyle.Render("Messages")}
+	if len(m.messages) == 0 {
+		return append(lines, m.styles.dimStyle.Render("  nothing yet"))
+	}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := m.messages[i]
+		style := m.styles.textStyle
+		if msg.severity == severityError {
+			style = m.styles.errorStyle
+		}
+		lines = append(lines, "  "+m.styles.dimStyle.Render(msg.at.Format("15:04:05"))+"  "+style.Render(msg.text))
+	}
+	return lines
+}
@@ -2,7 +2,9 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,15 +13,29 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
 	"github.com/google/uuid"
 	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/container"
+	"github.com/jaypopat/duet/internal/playback"
 	"github.com/jaypopat/duet/internal/room"
 	"github.com/jaypopat/duet/internal/terminal"
+	"github.com/jaypopat/duet/internal/tracing"
+	"github.com/jaypopat/duet/internal/transcript"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
+	// Below this size the room drops its panels for the compact layout.
 	MinWidthForSidebar  = 120
 	MinHeightForSidebar = 24
+
+	// eventBufferSize leaves room for the history replay on join plus
+	// whatever arrives before we start reading.
+	eventBufferSize = 32
+	maxActivity     = 5
 )
 
 type AIMessage = room.AIMessage
@@ -28,36 +44,143 @@ type Model struct {
 	screen   Screen
 	width    int
 	height   int
-	username string
+	username string // as shown in the current room, which may add a -2 suffix
+	name     string // who we connected as, see New
 	clientID string
+	isHost   bool
+
+	fingerprint string // SSH key fingerprint, used for bans
+
+	rejoinToken string // lets this user reclaim their identity after a drop
+	lobbySince  time.Time
+
+	startup tea.Cmd // join or create given as the ssh command, see SetCommand
 
-	selected int
-	input    textinput.Model
+	selected  int
+	input     textinput.Model
+	capInput  textinput.Model // max participants field on ScreenCreate
+	tagsInput textinput.Model // comma separated tags on ScreenCreate
+	nameInput textinput.Model // host display name on ScreenCreate
+
+	profile       room.Profile       // ours, see profile.go
+	profileInput  textinput.Model    // display name on ScreenProfile
+	profileFocus  int                // field with the focus there
+	profilePick   [profileFields]int // option picked for each choice field
+	profileTheme  string             // theme before opening it, for esc
+	chordPrefixed bool               // the key came after ctrl+], see readlineChords
+	shellInput    textinput.Model    // shell override on ScreenCreate
+	dirInput      textinput.Model    // starting directory on ScreenCreate
+	envInput      textinput.Model    // KEY=VALUE pairs on ScreenCreate
+	tmuxInput     textinput.Model    // tmux session to attach, when the server allows it
+	createFocus   int                // index into createFields()
+
+	roomFilter textinput.Model // tag search over the launch screen room list
+	filtering  bool
 
 	roomID       string
 	currentRoom  *room.Room
+	pendingRoom  *room.Room // room we knocked on and await approval for
 	terminal     *terminal.Terminal
 	termUpdateCh chan struct{}
 	termContent  string
-	users        []string
+	users        []participant
 	toasts       []toast
 	inputMode    InputMode
 	cmdInput     textinput.Model
 	typingUser   string
 	typingTime   time.Time
+	activity     []string // recent joins/leaves etc., oldest first
 
 	showAISidebar    bool
+	showUsers        bool    // the users sidebar is shown, see toggleUsersSidebar
+	sidebarFrac      float64 // users sidebar's share of the width, see roomLayout
+	zen              bool    // the terminal has the whole window, see toggleZen
+	aiFrac           float64 // and the AI sidebar's
+	aiBadge          bool    // the AI sidebar is collapsed to a badge, see aiwidth.go
+	aiUnread         int     // AI messages that came in while it was collapsed or hidden
+	aiReadTs         int64   // newest AI message counted for aiUnread
 	aiViewport       viewport.Model
 	aiLoading        bool
+	markdown         markdown // renders AI replies in the sidebar
 	aiSpinner        spinner.Model
 	lastPromptOffset int
+	pendingCode      string // AI code block awaiting confirmation, see ModeConfirmCode
+	pinnedView       string // pinned AI messages above the viewport, see fitAIViewport
+
+	lastSandbox *SandboxResultMsg // our latest sandbox result, for alt+e
+	recall      sandboxRecall     // history navigation in ModeSandbox
+
+	macroRecording bool
+	macroBuf       []byte
+
+	scrollOffset int // lines above the newest output while in ModeScroll
+
+	pointAnchor, pointCursor int      // rows being picked in ModePoint
+	pointer                  *pointer // rows someone last pointed at, see pointer.go
+
+	mentionTs  int64     // newest AI message checked for @ us, see checkMentions
+	flashUntil time.Time // the bottom bar flashes until then
+
+	player       *playback.Player // active recording on ScreenPlayback
+	playbackName string
+
+	mouseOn     bool         // mouse capture is enabled; see syncMouse
+	mousePanels bool         // duet uses the mouse for its panels, see mouse.go
+	focus       panel        // where the mouse and arrow keys go
+	userCursor  int          // user picked in the users list while it has the focus
+	usersTop    int          // sidebar row of the first user, for clicks
+	userMenu    *userMenu    // open over the room after clicking someone
+	leaving     *leaveDialog // the ctrl+l confirmation, see leave.go
+
+	showHelp     bool      // the keybinding overlay is open, see help.go
+	showMessages bool      // it shows the message log instead, see messages.go
+	helpOffset   int       // lines scrolled down in it
+	messages     []message // every toast so far, oldest first
+	pager        *pager    // the last command's output, when it's open
+
+	bellMode bellMode
+	lang     string      // message catalog, see lang.go
+	cmdLine  commandLine // history of the : prompt, see cmdline.go
+	linear   bool        // plain labelled text for screen readers, see linear.go
+	out      *Output     // the program's output too, for bells between frames
+	in       *Input      // the client's keyboard, handed over for raw passthrough
+
+	// what the linear view has printed so far, see linear.go
+	linearReady  bool               // out of the alt screen, so printing works
+	linearOut    []string           // lines to print on the next update
+	linearRoom   string             // the room whose heading was printed
+	linearTerm   *terminal.Terminal // the tab whose lines are being printed
+	linearSeen   int                // its lines printed, see Terminal.LinesSince
+	linearAISeen int                // AI messages printed
 
 	eventChan chan room.RoomEvent
+	lost      *lostStreams // updates or events that stopped, see resync.go
 
 	roomManager *room.Manager
-	aiClient    *ai.Client
+	aiClient    ai.Provider
 	renderer    *lipgloss.Renderer
 	styles      *Styles
+	logger      *log.Logger       // this SSH session's, see SetLogger
+	auditBase   audit.Event       // who this session is, see SetAudit
+	traceParent trace.SpanContext // the SSH session's span, see SetTraceParent
+}
+
+// Shown when the server runs without an AI provider, or without the
+// worker the sandbox needs.
+const (
+	aiDisabledMsg      = "AI is disabled by the server"
+	sandboxDisabledMsg = "Sandbox is disabled by the server (it needs the Duet worker)"
+)
+
+// participant is someone in the users list.
+type participant struct {
+	name    string
+	display string // from their profile, if they set one
+	host    bool
+	you     bool
+	role    string // room.RoleHost, RoleDriver or RoleObserver
+	status  string // room.StatusActive, StatusIdle or StatusReconnecting
+	joined  time.Time
 }
 
 type toast struct {
@@ -65,18 +188,61 @@ type toast struct {
 	expires time.Time
 }
 
-func New(renderer *lipgloss.Renderer, roomManager *room.Manager, username string) *Model {
+func New(renderer *lipgloss.Renderer, roomManager *room.Manager, username, fingerprint string, out *Output, in *Input) *Model {
 	ti := textinput.New()
 	ti.CharLimit = 100
 	ti.Width = 40
 
+	capInput := textinput.New()
+	capInput.CharLimit = 3
+	capInput.Width = 40
+	capInput.Validate = func(s string) error {
+		for _, r := range s {
+			if r < '0' || r > '9' {
+				return fmt.Errorf("digits only")
+			}
+		}
+		return nil
+	}
+
+	tagsInput := textinput.New()
+	tagsInput.CharLimit = 100
+	tagsInput.Width = 40
+
+	nameInput := textinput.New()
+	nameInput.CharLimit = 32
+	nameInput.Width = 40
+
+	shellInput := textinput.New()
+	shellInput.CharLimit = 128
+	shellInput.Width = 40
+
+	dirInput := textinput.New()
+	dirInput.CharLimit = 200
+	dirInput.Width = 40
+
+	envInput := textinput.New()
+	envInput.CharLimit = 300
+	envInput.Width = 40
+
+	tmuxInput := textinput.New()
+	tmuxInput.CharLimit = 64
+	tmuxInput.Width = 40
+
+	roomFilter := textinput.New()
+	roomFilter.CharLimit = 30
+	roomFilter.Width = 30
+	roomFilter.Prompt = "/"
+	roomFilter.Placeholder = "filter by tag"
+
 	cmdInput := textinput.New()
 	cmdInput.CharLimit = 500
 	cmdInput.Width = 60
 
 	aiClient := roomManager.GetAIClient()
 
-	styles := NewStyles(renderer)
+	theme, _ := LookupTheme(DefaultTheme, renderer)
+	styles := NewStyles(renderer, theme)
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
@@ -89,41 +255,50 @@ func New(renderer *lipgloss.Renderer, roomManager *room.Manager, username string
 	aiVP := viewport.New(40, 20)
 	aiVP.Style = lipgloss.NewStyle()
 
-	return &Model{
+	m := &Model{
 		screen:        ScreenLaunch,
 		username:      username,
+		name:          username,
+		fingerprint:   fingerprint,
+		out:           out,
+		in:            in,
 		clientID:      uuid.New().String(),
 		input:         ti,
+		capInput:      capInput,
+		tagsInput:     tagsInput,
+		nameInput:     nameInput,
+		profileInput:  newProfileInput(),
+		shellInput:    shellInput,
+		dirInput:      dirInput,
+		envInput:      envInput,
+		tmuxInput:     tmuxInput,
+		roomFilter:    roomFilter,
 		cmdInput:      cmdInput,
-		users:         []string{},
+		users:         []participant{},
 		toasts:        []toast{},
 		inputMode:     ModeNormal,
 		roomManager:   roomManager,
 		aiClient:      aiClient,
 		showAISidebar: true,
+		showUsers:     true,
+		lang:          DefaultLanguage,
+		sidebarFrac:   defaultSidebarFrac,
+		aiFrac:        defaultAIFrac,
 		aiViewport:    aiVP,
 		aiSpinner:     s,
 		aiLoading:     false,
 		renderer:      renderer,
 		styles:        styles,
 	}
+	if fingerprint != "" && !m.loadProfile() {
+		// first time with this key
+		m.openProfile()
+	}
+	return m
 }
 
 func (m *Model) Init() tea.Cmd {
-	return tickCmd()
-}
-
-func (m *Model) roomLayout() (sidebarW, terminalW, aiSidebarW, mainH int) {
-	sidebarW = m.width / 6
-	if m.showAISidebar {
-		aiSidebarW = m.width / 4
-		terminalW = m.width - sidebarW - aiSidebarW - 2
-	} else {
-		aiSidebarW = 0
-		terminalW = m.width - sidebarW - 1
-	}
-	mainH = m.height - 2
-	return
+	return tea.Batch(tickCmd(), m.startup)
 }
 
 // aiViewportInnerSize returns the usable content area inside the AI sidebar.
@@ -147,23 +322,18 @@ func tickCmd() tea.Cmd {
 }
 
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := m.update(msg)
+	return model, tea.Batch(cmd, m.printLinear())
+}
+
+func (m *Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		m.cmdInput.Width = m.width - 16
 
-		_, terminalW, aiSidebarW, mainH := m.roomLayout()
-
-		if m.terminal != nil {
-			m.terminal.Resize(terminalW, mainH-4)
-		}
-
-		if m.showAISidebar && aiSidebarW > 0 {
-			vpW, vpH := m.aiViewportInnerSize(aiSidebarW, mainH)
-			m.aiViewport.Width = vpW
-			m.aiViewport.Height = vpH
-		}
+		m.relayout()
 		return m, nil
 
 	case tea.KeyMsg:
@@ -181,53 +351,250 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.typingUser != "" && time.Since(m.typingTime) > 2*time.Second {
 			m.typingUser = ""
 		}
-		return m, tickCmd()
+		var resync tea.Cmd
+		if m.screen == ScreenRoom && m.currentRoom != nil {
+			// statuses age without events, so the list is redrawn each tick
+			if !m.currentRoom.Heartbeat(m.clientID) && m.eventChan != nil {
+				resync = m.lostEvents(m.eventChan)
+			}
+			m.users = m.getUserList()
+		}
+		return m, tea.Batch(tickCmd(), resync)
+
+	case tea.MouseMsg:
+		return m, m.handleMouse(msg)
+
+	case linearReadyMsg:
+		m.linearReady = m.linear
+		return m, nil
+
+	case rawModeMsg:
+		if m.screen != ScreenRoom || m.inputMode != ModeNormal || m.terminal == nil {
+			return m, nil
+		}
+		return m, m.enterPassthrough()
+
+	case passthroughDoneMsg:
+		m.reportViewSize()
+		if msg.err != nil {
+			m.addError("Raw mode: " + msg.err.Error())
+		} else if m.terminal != nil {
+			if exited, _ := m.terminal.Exited(); exited {
+				m.addToast("The shell exited")
+			}
+		}
+		return m, nil
+
+	case tabOpenedMsg:
+		return m, m.switchTab(msg.index)
+
+	case playbackTickMsg:
+		if m.screen != ScreenPlayback || m.player == nil {
+			return m, nil
+		}
+		m.player.Advance(playbackFrame)
+		return m, playbackTick()
+
+	case terminalClosedMsg:
+		return m, m.lostTerminal(msg.ch)
+
+	case eventsClosedMsg:
+		return m, m.lostEvents(msg.ch)
+
+	case resyncMsg:
+		return m, m.resync()
 
 	case terminalUpdateMsg:
+		if msg.ch != m.termUpdateCh {
+			return m, nil // left over from a tab we switched away from
+		}
 		if m.terminal != nil {
 			m.termContent = m.terminal.Render()
 		}
-		return m, m.waitForTerminalUpdate()
+		return m, tea.Batch(m.waitForTerminalUpdate(), m.syncMouse())
 
 	case roomEventMsg:
+		m.recordActivity(msg.Event)
+		if msg.Event.Replay {
+			// history from before we joined; the sidebar shows it, no toasts
+			return m, m.listenForRoomEvents()
+		}
 		switch msg.Event.Type {
 		case "join":
-			m.users = append(m.users, msg.Event.Username)
+			m.users = m.getUserList()
 			if msg.Event.Username != m.username {
 				m.addToast(fmt.Sprintf("%s joined", msg.Event.Username))
 			}
 		case "leave":
-			m.users = removeUser(m.users, msg.Event.Username)
-			m.addToast(fmt.Sprintf("%s left", msg.Event.Username))
+			m.users = m.getUserList()
+			if msg.Event.Data == "kicked" {
+				m.addToast(fmt.Sprintf("%s was kicked", msg.Event.Username))
+			} else {
+				m.addToast(fmt.Sprintf("%s left", msg.Event.Username))
+			}
+		case "host_changed":
+			m.isHost = msg.Event.Data == m.clientID
+			m.users = m.getUserList()
+			if m.isHost {
+				m.addToast("You are now the host")
+			} else {
+				m.addToast(fmt.Sprintf("%s is now the host", msg.Event.Username))
+			}
+		case "metadata":
+			if msg.Event.Data == "" {
+				m.addToast(fmt.Sprintf("%s cleared the room description", msg.Event.Username))
+			} else {
+				m.addToast(fmt.Sprintf("%s renamed the room to %q", msg.Event.Username, msg.Event.Data))
+			}
+		case "terminal_started":
+			if m.screen == ScreenLobby && m.currentRoom != nil {
+				m.screen = ScreenRoom
+				return m, tea.Batch(m.startTerminal(), m.listenForRoomEvents())
+			}
+		case "knock":
+			m.addToast(fmt.Sprintf("%s is knocking (f7 admit • f8 deny)", msg.Event.Username))
+		case "approve":
+			r := m.pendingRoom
+			m.pendingRoom = nil
+			if r == nil {
+				return m, nil
+			}
+			m.username = msg.Event.Username // the room may have told us apart from a namesake
+			// RoomJoinedMsg resumes listening on the same channel
+			return m, func() tea.Msg { return RoomJoinedMsg{RoomID: r.ID, Room: r} }
+		case "deny":
+			m.pendingRoom = nil
+			m.eventChan = nil
+			m.roomID = ""
+			m.addToastFor("Not admitted: "+msg.Event.Data, 3*time.Second)
+			return m, gotoScreen(ScreenJoin)
+		case "kicked":
+			// Room already dropped us and closed our channel
+			return m, m.removedFromRoom(msg.Event.Data)
+		case "taken_over":
+			return m, m.takenOver()
 		case "typing":
 			m.typingUser = msg.Event.Username
 			m.typingTime = time.Now()
+		case "env":
+			m.addToast(fmt.Sprintf("%s updated env: %s", msg.Event.Username, msg.Event.Data))
+		case "templates":
+			m.addToast(fmt.Sprintf("%s %s", msg.Event.Username, msg.Event.Data))
+		case "sandbox_reset":
+			m.addToast(fmt.Sprintf("%s reset the sandbox", msg.Event.Username))
+		case "sandbox_cancelled":
+			m.addToast(fmt.Sprintf("%s killed sandbox command: %s", msg.Event.Username, ellipsize(msg.Event.Data, 40)))
+		case "ai_pin":
+			if msg.Event.Username != m.username {
+				m.addToast(fmt.Sprintf("%s %s", msg.Event.Username, msg.Event.Data))
+			}
+			_, _, aiSidebarW, mainH := m.roomLayout()
+			m.fitAIViewport(aiSidebarW, mainH)
+		case "tabs":
+			if msg.Event.Username != m.username {
+				m.addToast(fmt.Sprintf("%s %s", msg.Event.Username, msg.Event.Data))
+			}
+			if m.currentRoom != nil && m.terminal != nil && m.currentRoom.TabIndex(m.terminal) < 0 {
+				// our tab was closed under us
+				return m, tea.Batch(m.switchTab(0), m.listenForRoomEvents())
+			}
+		case "bell":
+			return m, tea.Batch(m.onBell(msg.Event.Data), m.listenForRoomEvents())
+		case "shell_exited":
+			if m.isHost {
+				m.addToast("Shell in tab " + msg.Event.Data + " (f9 restarts)")
+			} else {
+				m.addToast("Shell in tab " + msg.Event.Data)
+			}
+		case "shell_restarted":
+			if msg.Event.Username != m.username {
+				m.addToast(fmt.Sprintf("%s restarted the shell in tab %s", msg.Event.Username, msg.Event.Data))
+			}
+		case "point":
+			m.onPoint(msg.Event)
+		case "timer":
+			m.addToast(fmt.Sprintf("%s %s", msg.Event.Username, msg.Event.Data))
+		case "timer_done":
+			return m, tea.Batch(m.onTimerDone(msg.Event.Data), m.listenForRoomEvents())
+		case "passthrough":
+			if msg.Event.Data == "on" {
+				if msg.Event.Username != m.username {
+					m.addToast(fmt.Sprintf("%s switched the room to raw view (ctrl+] returns)", msg.Event.Username))
+				}
+				return m, tea.Batch(func() tea.Msg { return rawModeMsg{} }, m.listenForRoomEvents())
+			}
+			m.addToast(fmt.Sprintf("%s switched the room back to the normal view", msg.Event.Username))
+		case "input_blocked":
+			if msg.Event.Username == m.username {
+				m.addToastFor("Blocked: "+msg.Event.Data, 3*time.Second)
+			} else {
+				m.addToast(fmt.Sprintf("Blocked %s's command: %s", msg.Event.Username, msg.Event.Data))
+			}
 		case "ai_sync":
 			// Another client updated AI messages - refresh viewport from shared Room
 			m.syncAIViewportContent()
 			m.scrollToLastPrompt()
+			m.countUnreadAI()
+			return m, tea.Batch(m.checkMentions(), m.listenForRoomEvents())
+		case "expiring":
+			m.addToast("Room closing soon: " + msg.Event.Data)
+		case "web_viewer":
+			m.addToast("Someone is " + msg.Event.Data + " the room from a browser")
+		case "announcement":
+			m.addToastFor("Server: "+msg.Event.Data, 8*time.Second)
+		case "expired", "closed":
+			// The manager already tore the room down; just reset local state
+			m.eventChan = nil
+			m.cleanup()
+			m.addToast("Room closed: " + msg.Event.Data)
+			return m, gotoScreen(ScreenLaunch)
 		}
 		return m, m.listenForRoomEvents()
 
 	case GotoScreenMsg:
-		return m.gotoScreen(msg.Screen)
+		model, cmd := m.gotoScreen(msg.Screen)
+		return model, tea.Batch(cmd, m.syncMouse())
 
 	case RoomCreatedMsg:
 		m.roomID = msg.RoomID
 		m.currentRoom = msg.Room
 		m.screen = ScreenRoomCreated
-		m.users = []string{m.username + " (host)"}
+		m.users = []participant{{name: m.username, display: m.profile.DisplayName, host: true, you: true, role: room.RoleHost, status: room.StatusActive, joined: time.Now()}}
+		m.issueRejoinToken()
+		m.log().Info("created room")
+		m.audit(audit.Event{Type: "room_create"})
 		return m, nil
 
+	case KnockSentMsg:
+		m.roomID = msg.RoomID
+		m.pendingRoom = msg.Room
+		m.input.Blur()
+		m.screen = ScreenLobby
+		m.lobbySince = time.Now()
+		return m, m.listenForRoomEvents()
+
 	case RoomJoinedMsg:
 		m.roomID = msg.RoomID
 		m.currentRoom = msg.Room
 		m.screen = ScreenRoom
 		m.users = m.getUserList()
+		m.issueRejoinToken()
+		m.log().Info("joined room", "host", m.isHost)
+		m.audit(audit.Event{Type: "room_join"})
 
 		// Sync AI viewport with existing room messages (history for late joiners)
+		_, _, aiSidebarW, mainH := m.roomLayout()
+		m.fitAIViewport(aiSidebarW, mainH)
 		m.syncAIViewportContent()
 		m.aiViewport.GotoBottom() // For history, show the most recent
+		m.skipSeenMentions()
+
+		// Guests wait in the lobby until the host brings the terminal up
+		if !m.isHost && msg.Room.GetTerminal() == nil {
+			m.screen = ScreenLobby
+			m.lobbySince = time.Now()
+			return m, m.listenForRoomEvents()
+		}
 
 		// start terminal and event listening
 		return m, tea.Batch(
@@ -240,7 +607,22 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case ErrorMsg:
-		m.addToast("Error: " + msg.Err.Error())
+		if errors.Is(msg.Err, room.ErrRoomFull) {
+			m.addToast("That room is full - ask the host to make space")
+			m.aiLoading = false
+			return m, nil
+		}
+		if errors.Is(msg.Err, room.ErrBanned) {
+			m.addToast("You have been banned from that room")
+			m.aiLoading = false
+			return m, nil
+		}
+		var remote *room.RemoteRoomError
+		if errors.As(msg.Err, &remote) {
+			m.addToastFor("That room is on another server - connect with: "+remote.JoinCommand(), 15*time.Second)
+			return m, nil
+		}
+		m.addError("Error: " + msg.Err.Error())
 		m.aiLoading = false
 		return m, nil
 
@@ -256,23 +638,47 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.scrollToLastPrompt()
 
 		m.aiLoading = false
-		return m, nil
+		m.countUnreadAI()
+		return m, m.checkMentions()
 
 	case SandboxResultMsg:
 		output := msg.Output
 		if output == "" {
 			output = "[no output]"
 		}
-		m.addToast(fmt.Sprintf("$ %s → %s", msg.Cmd, truncate(output, 60)))
+		m.lastSandbox = &msg
+		m.addToastFor(fmt.Sprintf("$ %s → %s (alt+e explain)", msg.Cmd, ellipsize(output, 60)), 3*time.Second)
+		if m.currentRoom != nil && m.currentRoom.Transcript != nil {
+			m.currentRoom.Transcript.Add(transcript.KindSandbox, m.username, "$ "+msg.Cmd+"\n"+output)
+		}
 		return m, nil
 	}
 
-	if m.screen == ScreenCreate || m.screen == ScreenJoin {
+	if m.screen == ScreenCreate {
+		field := m.createFields()[m.createFocus]
+		var cmd tea.Cmd
+		*field, cmd = field.Update(msg)
+		return m, cmd
+	}
+
+	if m.screen == ScreenLaunch && m.filtering {
+		var cmd tea.Cmd
+		m.roomFilter, cmd = m.roomFilter.Update(msg)
+		return m, cmd
+	}
+
+	if m.screen == ScreenJoin {
 		var cmd tea.Cmd
 		m.input, cmd = m.input.Update(msg)
 		return m, cmd
 	}
 
+	if m.screen == ScreenProfile {
+		var cmd tea.Cmd
+		m.profileInput, cmd = m.profileInput.Update(msg)
+		return m, cmd
+	}
+
 	if m.screen == ScreenRoom {
 		if m.inputMode != ModeNormal {
 			var cmd tea.Cmd
@@ -287,31 +693,75 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	key := msg.String()
 
+	if m.showHelp {
+		return m.handleHelpKey(key)
+	}
+	if m.pager != nil {
+		return m.handlePagerKey(key, msg)
+	}
+	if m.userMenu != nil {
+		return m.handleUserMenuKey(key)
+	}
+	if m.leaving != nil {
+		return m.handleLeaveKey(key)
+	}
+	if m.helpKeyOpens(key) {
+		m.openHelp()
+		return m, nil
+	}
+
 	if key == "ctrl+c" && m.screen != ScreenRoom {
 		m.cleanup()
 		return m, tea.Quit
 	}
 
 	switch m.screen {
+	case ScreenProfile:
+		return m.handleProfileKey(key, msg)
+
 	case ScreenLaunch:
+		if m.filtering {
+			switch key {
+			case "enter":
+				m.filtering = false
+				m.roomFilter.Blur()
+			case "esc":
+				m.filtering = false
+				m.roomFilter.Reset()
+				m.roomFilter.Blur()
+			default:
+				var cmd tea.Cmd
+				m.roomFilter, cmd = m.roomFilter.Update(msg)
+				return m, cmd
+			}
+			return m, nil
+		}
 		switch key {
+		case "/":
+			m.filtering = true
+			return m, m.roomFilter.Focus()
 		case "up", "k":
-			if m.selected > 0 {
-				m.selected--
-			}
+			m.moveLaunchCursor(-1)
 		case "down", "j":
-			if m.selected < 1 {
-				m.selected++
-			}
+			m.moveLaunchCursor(1)
+		case "pgup":
+			m.moveLaunchCursor(-maxListedRooms)
+		case "pgdown":
+			m.moveLaunchCursor(maxListedRooms)
 		case "c", "C":
 			return m, gotoScreen(ScreenCreate)
+		case "p", "P":
+			return m.openProfile()
 		case "J":
 			return m, gotoScreen(ScreenJoin)
 		case "enter":
-			if m.selected == 0 {
+			switch m.selected {
+			case 0:
 				return m, gotoScreen(ScreenCreate)
+			case 1:
+				return m, gotoScreen(ScreenJoin)
 			}
-			return m, gotoScreen(ScreenJoin)
+			return m, m.joinSelectedRoom()
 		case "q", "esc":
 			return m, tea.Quit
 		}
@@ -322,12 +772,34 @@ func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, m.createRoom
 		case "esc":
 			return m, gotoScreen(ScreenLaunch)
+		case "tab", "down":
+			return m, m.moveCreateFocus(1)
+		case "shift+tab", "up":
+			return m, m.moveCreateFocus(-1)
 		default:
+			field := m.createFields()[m.createFocus]
 			var cmd tea.Cmd
-			m.input, cmd = m.input.Update(msg)
+			*field, cmd = field.Update(msg)
 			return m, cmd
 		}
 
+	case ScreenLobby:
+		switch key {
+		case "esc":
+			if m.pendingRoom != nil {
+				m.cancelKnock()
+				return m, gotoScreen(ScreenJoin)
+			}
+			m.cleanup()
+			return m, gotoScreen(ScreenLaunch)
+		case "s":
+			// Nobody to wait for: let a guest start the shell themselves
+			if m.currentRoom != nil && !m.currentRoom.HostPresent() {
+				m.screen = ScreenRoom
+				return m, m.startTerminal()
+			}
+		}
+
 	case ScreenJoin:
 		switch key {
 		case "enter":
@@ -355,12 +827,48 @@ func (m *Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case ScreenRoom:
 		return m.handleRoomKey(key, msg)
+
+	case ScreenPlayback:
+		return m.handlePlaybackKey(key)
 	}
 
 	return m, nil
 }
 
 func (m *Model) handleRoomKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.currentRoom != nil {
+		m.currentRoom.NoteKey(m.clientID)
+	}
+	if m.colonOpensCommandLine(key) {
+		return m, m.openCommandLine()
+	}
+	if m.inputMode == ModeScroll {
+		m.handleScrollKey(key)
+		return m, nil
+	}
+	if m.inputMode == ModePoint {
+		m.handlePointKey(key)
+		return m, nil
+	}
+	if m.inputMode == ModeConfirmCode {
+		return m, m.handleConfirmCodeKey(key)
+	}
+	if m.inputMode == ModeSandbox && m.handleRecallKey(key) {
+		return m, nil
+	}
+	if m.inputMode == ModeCommand && m.cmdInput.Value() == "" && (readlineChords[key] || prefixedChords[key]) {
+		// ctrl+] then a chord runs it even when the keymap gives it to the shell
+		m.inputMode = ModeNormal
+		m.cmdInput.Reset()
+		m.cmdInput.Prompt = "> "
+		m.cmdInput.ShowSuggestions = false
+		m.chordPrefixed = true
+		defer func() { m.chordPrefixed = false }()
+		return m.handleRoomKey(key, msg)
+	}
+	if m.inputMode == ModeCommand && m.handleCommandHistoryKey(key) {
+		return m, nil
+	}
 	if m.inputMode != ModeNormal {
 		switch key {
 		case "enter":
@@ -368,38 +876,102 @@ func (m *Model) handleRoomKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case "esc":
 			m.inputMode = ModeNormal
 			m.cmdInput.Reset()
+			m.cmdInput.Prompt = "> "
+			m.cmdInput.ShowSuggestions = false
 			return m, nil
 		default:
 			var cmd tea.Cmd
 			m.cmdInput, cmd = m.cmdInput.Update(msg)
+			if m.inputMode == ModeCommand {
+				m.offerCommands()
+			}
 			return m, cmd
 		}
 	}
 
-	switch key {
+	if m.focus != focusTerminal && m.handleFocusKey(key) {
+		return m, nil
+	}
+
+	chord := key
+	if m.shellGetsChord(key) {
+		chord = "" // the shell's
+	}
+	switch chord {
 	case "ctrl+g":
 		if m.aiClient == nil {
-			m.addToast("AI not configured (no worker URL)")
+			m.addToast(aiDisabledMsg)
+			return m, nil
+		}
+		if m.aiPaused() {
 			return m, nil
 		}
 		m.inputMode = ModeAI
 		m.cmdInput.Reset()
-		m.cmdInput.Placeholder = "Ask the AI..."
+		m.cmdInput.Placeholder = "Ask the AI... (/ for templates, tab completes)"
+		m.offerTemplates()
 		m.cmdInput.Focus()
 		return m, textinput.Blink
 	case "ctrl+r":
-		if m.aiClient == nil {
-			m.addToast("Sandbox not configured (no worker URL)")
+		if !ai.HasSandbox(m.aiClient) {
+			m.addToast(sandboxDisabledMsg)
+			return m, nil
+		}
+		if m.aiPaused() {
 			return m, nil
 		}
 		m.inputMode = ModeSandbox
 		m.cmdInput.Reset()
-		m.cmdInput.Placeholder = "Command to run..."
+		m.resetSandboxRecall()
+		m.cmdInput.Placeholder = "Command to run... (up/down history, ctrl+r search)"
 		m.cmdInput.Focus()
 		return m, textinput.Blink
+	case "ctrl+e":
+		return m, m.explainLastOutput()
+	case "alt+e":
+		return m, m.explainSandboxResult()
+	case "alt+p":
+		m.enterPointMode()
+		return m, nil
+	case "alt+z":
+		m.toggleZen()
+		return m, nil
+	case "alt+o":
+		m.openPager()
+		return m, nil
 	case "ctrl+a":
+		if m.compact() {
+			m.openAIPager()
+			return m, nil
+		}
 		m.showAISidebar = !m.showAISidebar
+		if !m.aiCollapsed() {
+			m.aiUnread = 0
+		}
+		m.relayout()
+		return m, nil
+	case "alt+a":
+		m.cycleAIWidth()
 		return m, nil
+	case "alt+u":
+		m.toggleUsersSidebar()
+		return m, nil
+	case "ctrl+left", "ctrl+right", "alt+left", "alt+right":
+		steps := 1
+		if key == "ctrl+left" || key == "alt+left" {
+			steps = -1
+		}
+		m.resizePanel(key == "ctrl+left" || key == "ctrl+right", steps)
+		return m, nil
+	case "ctrl+o":
+		if m.terminal == nil {
+			return m, nil
+		}
+		m.inputMode = ModeCodeBlock
+		m.cmdInput.Reset()
+		m.cmdInput.Placeholder = "AI code block to send (enter for latest, 2 for the one before...)"
+		m.cmdInput.Focus()
+		return m, textinput.Blink
 	case "ctrl+j":
 		if m.showAISidebar {
 			m.aiViewport.ScrollDown(3)
@@ -411,8 +983,65 @@ func (m *Model) handleRoomKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case "ctrl+l":
-		m.cleanup()
-		return m, gotoScreen(ScreenLaunch)
+		m.confirmLeave()
+		return m, nil
+	case "ctrl+]":
+		return m, m.openCommandLine()
+	case "f2":
+		if !m.isHost {
+			m.addToast("Only the host can edit environment variables")
+			return m, nil
+		}
+		m.inputMode = ModeEnv
+		m.cmdInput.Reset()
+		m.cmdInput.Placeholder = "KEY=value to set, -KEY to unset..."
+		m.cmdInput.Focus()
+		return m, textinput.Blink
+	case "f5":
+		m.exportEnvToShell()
+		return m, nil
+	case "f7", "f8":
+		if !m.isHost || m.currentRoom == nil {
+			return m, nil
+		}
+		pending := m.currentRoom.PendingUsernames()
+		if len(pending) == 0 {
+			m.addToast("Nobody is knocking")
+			return m, nil
+		}
+		if key == "f7" {
+			m.admitUser([]string{pending[0]})
+		} else {
+			m.denyUser([]string{pending[0]})
+		}
+		return m, nil
+	case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+		// ctrl+digit doesn't survive most terminals/SSH, so tabs live on alt
+		return m, m.switchTab(int(key[len(key)-1] - '1'))
+	case "pgup", "f6":
+		if key == "pgup" && m.terminal != nil && m.terminal.InAltScreen() {
+			break // less, vim and the like page with it themselves; f6 still works
+		}
+		m.enterScrollMode()
+		if key == "pgup" {
+			m.scrollBy(m.scrollPageHeight())
+		}
+		return m, nil
+	case "f9":
+		m.restartShell()
+		return m, nil
+	case "f10":
+		return m, m.enterPassthrough()
+	case "f3":
+		m.toggleMacroRecording()
+		return m, nil
+	case "f4":
+		m.replayMacro()
+		return m, nil
+	}
+
+	if m.terminal != nil && msg.Paste {
+		return m, m.pasteToTerminal(string(msg.Runes))
 	}
 
 	if m.terminal != nil {
@@ -438,19 +1067,31 @@ func (m *Model) handleRoomKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			data = []byte("\x1b[F")
 		case "delete":
 			data = []byte("\x1b[3~")
+		case "pgup":
+			data = []byte("\x1b[5~")
+		case "pgdown":
+			data = []byte("\x1b[6~")
 		case "esc":
 			data = []byte("\x1b")
 		default:
-			// Regular characters
-			if len(key) == 1 {
-				data = []byte(key)
-			} else if len(msg.Runes) > 0 {
+			// Regular characters. IME commits (CJK) arrive as a single KeyRunes
+			// message carrying every composed rune, so forward them as a whole.
+			if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
 				data = []byte(string(msg.Runes))
+				if msg.Alt {
+					data = append([]byte{0x1b}, data...)
+				}
+			} else if len(key) == 1 {
+				data = []byte(key)
 			}
 		}
 
 		if len(data) > 0 {
-			m.terminal.Write(data)
+			m.terminal.WriteFrom(m.author(), data)
+			m.recordMacro(data)
+			if m.currentRoom != nil {
+				m.currentRoom.NoteInput(m.clientID)
+			}
 
 			// broadcast typing event to other users - debouncing it here as well
 			if m.currentRoom != nil && time.Since(m.typingTime) > 500*time.Millisecond {
@@ -466,44 +1107,183 @@ func (m *Model) handleRoomKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// pasteToTerminal sends a paste to the shell off the update loop, since a
+// large one can block on the PTY for a while.
+func (m *Model) pasteToTerminal(text string) tea.Cmd {
+	if text == "" {
+		return nil
+	}
+	m.recordMacro([]byte(text))
+	if m.currentRoom != nil {
+		m.currentRoom.NoteInput(m.clientID)
+	}
+	t, author := m.terminal, m.author()
+	return func() tea.Msg {
+		if err := t.PasteFrom(author, text); err != nil {
+			return ErrorMsg{err}
+		}
+		return nil
+	}
+}
+
+// author identifies our input to the terminal's input policy; everyone but
+// the host counts as a guest.
+func (m *Model) author() terminal.Author {
+	return terminal.Author{Name: m.username, Guest: !m.isHost}
+}
+
+// syncMouse turns mouse capture on in a room, for the panels (see
+// handleMouse), or with the panels' mouse off only while the shared
+// terminal's program wants mouse reports, so text stays selectable. The
+// linear view never captures it.
+func (m *Model) syncMouse() tea.Cmd {
+	want := m.screen == ScreenRoom && !m.linear && (m.mousePanels || m.terminal != nil && m.terminal.WantsMouse())
+	if want == m.mouseOn {
+		return nil
+	}
+	m.mouseOn = want
+	if want {
+		return tea.EnableMouseCellMotion
+	}
+	return tea.DisableMouse
+}
+
+// forwardMouse passes clicks, drags and wheel events inside the terminal
+// pane to the shell, if the program running there enabled mouse reporting.
+func (m *Model) forwardMouse(msg tea.MouseMsg) {
+	if m.screen != ScreenRoom || m.inputMode != ModeNormal || m.terminal == nil {
+		return
+	}
+	if !m.terminal.WantsMouse() {
+		return
+	}
+
+	paneX, paneY, paneW, paneH := m.termPane()
+	x := msg.X - paneX
+	y := msg.Y - paneY
+	if x < 0 || y < 0 || x >= paneW-2 || y >= paneH {
+		return
+	}
+
+	ev := terminal.MouseEvent{
+		X:       x,
+		Y:       y,
+		Release: msg.Action == tea.MouseActionRelease,
+		Motion:  msg.Action == tea.MouseActionMotion,
+		Shift:   msg.Shift,
+		Alt:     msg.Alt,
+		Ctrl:    msg.Ctrl,
+	}
+	switch msg.Button {
+	case tea.MouseButtonLeft:
+		ev.Button = terminal.MouseLeft
+	case tea.MouseButtonMiddle:
+		ev.Button = terminal.MouseMiddle
+	case tea.MouseButtonRight:
+		ev.Button = terminal.MouseRight
+	case tea.MouseButtonWheelUp:
+		ev.Button = terminal.MouseWheelUp
+	case tea.MouseButtonWheelDown:
+		ev.Button = terminal.MouseWheelDown
+	case tea.MouseButtonNone:
+		ev.Button = terminal.MouseNone
+	default:
+		return
+	}
+	m.terminal.SendMouse(ev)
+}
+
 func (m *Model) submitInput() (tea.Model, tea.Cmd) {
 	text := m.cmdInput.Value()
+	if m.inputMode == ModeCommand {
+		m.cmdInput.Prompt = "> "
+	}
+	if m.inputMode == ModeCodeBlock {
+		m.inputMode = ModeNormal
+		m.cmdInput.Reset()
+		m.pickCodeBlock(text)
+		return m, nil
+	}
 	if text == "" {
 		m.inputMode = ModeNormal
+		m.cmdInput.ShowSuggestions = false
 		return m, nil
 	}
 
 	mode := m.inputMode
 	m.inputMode = ModeNormal
 	m.cmdInput.Reset()
+	m.cmdInput.ShowSuggestions = false
 
 	if mode == ModeAI {
+		var ok bool
+		if text, ok = m.expandTemplate(text); !ok {
+			return m, nil
+		}
 		m.aiLoading = true
 		spinnerCmd := func() tea.Msg { return m.aiSpinner.Tick() }
 		return m, tea.Batch(spinnerCmd, m.sendAIMessage(text))
 	}
 
 	if mode == ModeSandbox {
-		m.addToast(fmt.Sprintf("Running: %s", truncate(text, 30)))
+		m.resetSandboxRecall()
+		if m.currentRoom != nil {
+			m.currentRoom.AddSandboxCommand(text)
+		}
+		m.addToast(fmt.Sprintf("Running: %s", ellipsize(text, 30)))
 		return m, m.execSandboxCmd(text)
 	}
 
+	if mode == ModeEnv {
+		m.applyEnvInput(text)
+		return m, nil
+	}
+
+	if mode == ModeCommand {
+		m.rememberCommand(strings.TrimSpace(text))
+		return m.runCommand(text)
+	}
+
 	return m, nil
 }
 
+// aiPaused tells the user, and reports, when the AI provider's breaker is
+// open so prompts aren't typed only to fail.
+func (m *Model) aiPaused() bool {
+	health, ok := ai.HealthOf(m.aiClient)
+	if !ok || health.State != ai.BreakerOpen {
+		return false
+	}
+	wait := max(time.Until(health.RetryAt).Round(time.Second), time.Second)
+	m.addToast(fmt.Sprintf("AI is paused after repeated failures, trying again in %s", wait))
+	return true
+}
+
 func (m *Model) sendAIMessage(text string) tea.Cmd {
+	if m.currentRoom != nil {
+		m.currentRoom.NoteAIRequest()
+	}
+	m.audit(audit.Event{Type: "ai_prompt", Detail: text})
+	logger := m.log()
 	return func() tea.Msg {
 		if m.aiClient == nil {
-			return ErrorMsg{fmt.Errorf("AI client not configured")}
+			return ErrorMsg{errors.New(aiDisabledMsg)}
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		// local models can take a while; the worker client has its own
+		// shorter timeout
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cancel()
+		ctx, span := m.span(ctx, "ai.request", attribute.Int("prompt.length", len(text)))
 
-		resp, err := m.aiClient.SendMessage(ctx, m.roomID, text, m.username)
+		start := time.Now()
+		resp, err := m.aiClient.SendMessage(logContext(ctx, logger), m.roomID, text, m.username)
+		tracing.End(span, err)
 		if err != nil {
+			logger.Warn("AI request failed", "error", err)
 			return ErrorMsg{err}
 		}
+		logger.Debug("AI request", "duration", time.Since(start).Round(time.Millisecond))
 		var msgs []AIMessage
 		for _, m := range resp.Messages {
 			msgs = append(msgs, AIMessage{
@@ -518,26 +1298,49 @@ func (m *Model) sendAIMessage(text string) tea.Cmd {
 	}
 }
 
+// execSandboxCmd runs cmd in the room's sandbox as a job anyone in the
+// room can kill (ctrl+] kill) instead of waiting out the timeout.
 func (m *Model) execSandboxCmd(cmd string) tea.Cmd {
+	logger := m.log()
+	m.audit(audit.Event{Type: "sandbox_command", Detail: cmd})
 	return func() tea.Msg {
 		if m.aiClient == nil {
-			return ErrorMsg{fmt.Errorf("AI client not configured")}
+			return ErrorMsg{errors.New(aiDisabledMsg)}
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		resp, err := m.aiClient.ExecCommand(ctx, m.roomID, cmd)
+		var env map[string]string
+		jobID := uuid.New().String()[:8]
+		if r := m.currentRoom; r != nil {
+			env = r.GetEnv()
+			job := r.StartSandboxJob(cmd, m.username, cancel)
+			jobID = job.ID
+			defer r.FinishSandboxJob(job.ID)
+		}
+
+		logger.Info("sandbox command", "job", jobID, "cmd", cmd)
+		ctx, span := m.span(ctx, "sandbox.exec", attribute.String("job.id", jobID))
+		resp, err := m.aiClient.ExecCommand(logContext(ctx, logger), m.roomID, jobID, cmd, env)
+		tracing.End(span, err)
+		if errors.Is(err, context.Canceled) {
+			return nil // killed; the room already heard about it
+		}
 		if err != nil {
+			logger.Warn("sandbox command failed", "job", jobID, "error", err)
 			return ErrorMsg{err}
 		}
+		if m.currentRoom != nil {
+			m.currentRoom.NoteSandboxUsed(resp.SandboxName)
+		}
 
 		output := resp.Result.Stdout
 		if output == "" {
 			output = resp.Result.Stderr
 		}
 
-		return SandboxResultMsg{Output: output, Cmd: cmd}
+		return SandboxResultMsg{Output: output, Cmd: cmd, Stdout: resp.Result.Stdout, Stderr: resp.Result.Stderr}
 	}
 }
 
@@ -547,128 +1350,458 @@ func (m *Model) gotoScreen(s Screen) (tea.Model, tea.Cmd) {
 	if s == ScreenCreate {
 		m.input.Reset()
 		m.input.Placeholder = "Room description (optional)..."
+		m.input.CharLimit = 100
 		m.input.Focus()
+		m.capInput.Reset()
+		m.capInput.Placeholder = "Max participants (blank = unlimited)"
+		m.capInput.Blur()
+		m.tagsInput.Reset()
+		m.tagsInput.Placeholder = "Tags, e.g. go, interview (optional)"
+		m.tagsInput.Blur()
+		m.nameInput.Reset()
+		m.nameInput.SetValue(m.profile.DisplayName)
+		m.nameInput.Placeholder = "Your display name (default " + m.name + ")"
+		m.nameInput.Blur()
+		m.shellInput.Reset()
+		m.shellInput.Placeholder = "Shell, e.g. bash, zsh, fish (default server shell)"
+		m.shellInput.Blur()
+		m.dirInput.Reset()
+		m.dirInput.Placeholder = "Start directory, e.g. src or /srv/project (default server setting)"
+		m.dirInput.Blur()
+		m.envInput.Reset()
+		m.envInput.Placeholder = "Env vars, e.g. GOFLAGS=-mod=mod EDITOR=vim"
+		m.envInput.Blur()
+		m.tmuxInput.Reset()
+		m.tmuxInput.Placeholder = "Attach to tmux session (optional)"
+		m.tmuxInput.Blur()
+		m.createFocus = 0
 		return m, textinput.Blink
 	}
 	if s == ScreenJoin {
 		m.input.Reset()
-		m.input.Placeholder = "Room ID..."
+		m.input.Placeholder = "Room ID or rejoin token..."
+		m.input.CharLimit = 200
 		m.input.Focus()
 		return m, textinput.Blink
 	}
 	return m, nil
 }
 
-func (m *Model) createRoom() tea.Msg {
-	desc := strings.TrimSpace(m.input.Value())
-	r, err := m.roomManager.CreateRoom(m.username, desc)
+// createFields lists the ScreenCreate inputs in focus order.
+func (m *Model) createFields() []*textinput.Model {
+	fields := []*textinput.Model{&m.input, &m.capInput, &m.tagsInput, &m.nameInput, &m.shellInput, &m.dirInput, &m.envInput}
+	if m.roomManager.TmuxAllowed() {
+		fields = append(fields, &m.tmuxInput)
+	}
+	return fields
+}
+
+// moveCreateFocus cycles focus through the create form by delta.
+func (m *Model) moveCreateFocus(delta int) tea.Cmd {
+	fields := m.createFields()
+	fields[m.createFocus].Blur()
+	m.createFocus = (m.createFocus + delta + len(fields)) % len(fields)
+	return fields[m.createFocus].Focus()
+}
+
+func (m *Model) createRoom() (msg tea.Msg) {
+	_, span := m.span(context.Background(), "room.create")
+	defer func() { endSpan(span, msg) }()
+
+	maxClients, _ := strconv.Atoi(strings.TrimSpace(m.capInput.Value()))
+	env, err := room.ParseEnvAssignments(m.envInput.Value())
 	if err != nil {
 		return ErrorMsg{err}
 	}
-	m.registerAsClient(r, true)
+	r, err := m.roomManager.CreateRoom(m.name, room.RoomOptions{
+		Description: strings.TrimSpace(m.input.Value()),
+		MaxClients:  maxClients,
+		Tags:        room.ParseTags(m.tagsInput.Value()),
+		HostName:    m.nameInput.Value(),
+
+		HostFingerprint: m.fingerprint,
+		Settings: room.RoomSettings{
+			Shell: strings.TrimSpace(m.shellInput.Value()),
+			Dir:   strings.TrimSpace(m.dirInput.Value()),
+			Env:   env,
+
+			TmuxSession: strings.TrimSpace(m.tmuxInput.Value()),
+		},
+	})
+	if err != nil {
+		return ErrorMsg{err}
+	}
+	if err := m.registerAsClient(r, true); err != nil {
+		return ErrorMsg{err}
+	}
 
 	return RoomCreatedMsg{RoomID: r.ID, Room: r}
 }
 
-func (m *Model) joinRoom() tea.Msg {
+func (m *Model) joinRoom() (msg tea.Msg) {
+	_, span := m.span(context.Background(), "room.join")
+	defer func() { endSpan(span, msg) }()
+
 	id := strings.TrimSpace(m.input.Value())
+	if room.IsRejoinToken(id) {
+		return m.rejoinRoom(id)
+	}
 	r, err := m.roomManager.GetRoom(id)
 	if err != nil {
 		return ErrorMsg{err}
 	}
-	m.registerAsClient(r, false)
+	// the original host reopening a room without one, e.g. restored or
+	// detached, takes it back and needn't knock, if it's their key
+	isHost := r.ReclaimsHost(m.name, m.fingerprint)
+	if r.NeedsApproval() && !isHost {
+		return m.knock(r)
+	}
+	if err := m.registerAsClient(r, isHost); err != nil {
+		return ErrorMsg{err}
+	}
 
 	return RoomJoinedMsg{RoomID: id, Room: r}
 }
 
-func (m *Model) registerAsClient(r *room.Room, isHost bool) {
-	m.eventChan = make(chan room.RoomEvent, 10)
+// rejoinRoom reclaims the client ID (and with it the host flag) recorded in
+// a rejoin token. Approval is skipped since we were already admitted.
+func (m *Model) rejoinRoom(token string) tea.Msg {
+	claim, err := m.roomManager.RedeemRejoinToken(token)
+	if err != nil {
+		return ErrorMsg{err}
+	}
+	if !room.SameUser(claim.Username, m.name) {
+		return ErrorMsg{fmt.Errorf("that token belongs to %s", claim.Username)}
+	}
+	r, err := m.roomManager.GetRoom(claim.RoomID)
+	if err != nil {
+		return ErrorMsg{err}
+	}
+
+	// the token's client ID only becomes ours once the room takes it back
+	if err := m.register(r, &room.Client{ID: claim.ClientID, Rejoin: true}); err != nil {
+		return ErrorMsg{err}
+	}
 
+	return RoomJoinedMsg{RoomID: r.ID, Room: r}
+}
+
+func (m *Model) registerAsClient(r *room.Room, isHost bool) error {
+	return m.register(r, &room.Client{IsHost: isHost})
+}
+
+// register fills in our identity on client and adds it to the room. The
+// room may adjust IsHost (e.g. on rejoin), so we read it back afterwards.
+// A client ID set on client, e.g. from a rejoin token, replaces ours only
+// if the room accepts it.
+func (m *Model) register(r *room.Room, client *room.Client) error {
+	eventChan := make(chan room.RoomEvent, eventBufferSize)
+
+	if client.ID == "" {
+		client.ID = m.clientID
+	}
+	client.Username = m.name
+	client.Fingerprint = m.fingerprint
+	client.DisplayName = m.profile.DisplayName
+	client.Color = m.profile.Color
+	client.Events = eventChan
+	if err := r.AddClient(client); err != nil {
+		return err
+	}
+
+	m.clientID = client.ID
+	m.eventChan = eventChan
+	m.isHost = client.IsHost
+	m.username = client.Username
+	return nil
+}
+
+// issueRejoinToken signs a fresh rejoin token for the current room.
+func (m *Model) issueRejoinToken() {
+	token, err := m.roomManager.IssueRejoinToken(m.roomID, m.clientID, m.username)
+	if err != nil {
+		m.rejoinToken = ""
+		return
+	}
+	m.rejoinToken = token
+}
+
+// knock asks the host of r to let us in; the answer arrives as a room event.
+func (m *Model) knock(r *room.Room) tea.Msg {
+	eventChan := make(chan room.RoomEvent, eventBufferSize)
 	client := &room.Client{
-		ID:       m.clientID,
-		Username: m.username,
-		IsHost:   isHost,
-		Events:   m.eventChan,
+		ID:          m.clientID,
+		Username:    m.name,
+		Fingerprint: m.fingerprint,
+		Events:      eventChan,
 	}
-	r.AddClient(client)
+	if err := r.Knock(client); err != nil {
+		return ErrorMsg{err}
+	}
+	m.eventChan = eventChan
+	return KnockSentMsg{RoomID: r.ID, Room: r}
 }
 
-func (m *Model) getUserList() []string {
-	if m.currentRoom == nil {
-		return []string{m.username}
+func (m *Model) cancelKnock() {
+	if m.pendingRoom == nil {
+		return
 	}
+	m.pendingRoom.CancelKnock(m.clientID)
+	m.pendingRoom = nil
+	m.eventChan = nil
+	m.roomID = ""
+}
 
-	clients := m.currentRoom.GetClients()
-	users := make([]string, 0, len(clients))
-	for _, c := range clients {
-		name := c.Username
-		if c.IsHost {
-			name += " (host)"
-		}
-		if c.Username == m.username {
-			name += " (you)"
+// recordActivity adds a line for ev to the sidebar's recent activity.
+func (m *Model) recordActivity(ev room.RoomEvent) {
+	var text string
+	switch ev.Type {
+	case "join":
+		text = ev.Username + " joined"
+	case "leave":
+		text = ev.Username + " left"
+		if ev.Data == "kicked" {
+			text = ev.Username + " was kicked"
 		}
-		users = append(users, name)
+	case "host_changed":
+		text = ev.Username + " is now host"
+	case "metadata":
+		text = ev.Username + " renamed the room"
+	case "env", "templates", "ai_pin":
+		text = ev.Username + " " + ev.Data
+	case "tabs":
+		text = ev.Username + " " + ev.Data
+	case "shell_exited":
+		text = "tab " + ev.Data
+	case "shell_restarted":
+		text = ev.Username + " restarted tab " + ev.Data
+	case "passthrough":
+		text = ev.Username + " turned raw view " + ev.Data
+	case "input_blocked":
+		text = "blocked " + ev.Username + ": " + ev.Data
+	case "sandbox_cancelled":
+		text = ev.Username + " killed " + ev.Data
+	case "sandbox_reset":
+		text = ev.Username + " reset the sandbox"
+	case "announcement":
+		text = "server: " + ev.Data
+	case "web_viewer":
+		text = "browser " + ev.Data
+	default:
+		return
+	}
+
+	at := ev.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	m.activity = append(m.activity, at.Format("15:04")+" "+text)
+	if len(m.activity) > maxActivity {
+		m.activity = m.activity[len(m.activity)-maxActivity:]
+	}
+}
+
+func (m *Model) roomDescription() string {
+	if m.currentRoom == nil {
+		return ""
+	}
+	return m.currentRoom.GetDescription()
+}
+
+func (m *Model) getUserList() []participant {
+	if m.currentRoom == nil {
+		return []participant{{name: m.username, you: true, role: room.RoleObserver, status: room.StatusActive}}
+	}
+
+	ps := m.currentRoom.Participants()
+	users := make([]participant, 0, len(ps))
+	for _, p := range ps {
+		users = append(users, participant{
+			name:    p.Username,
+			display: p.DisplayName,
+			host:    p.Role == room.RoleHost,
+			you:     p.ClientID == m.clientID,
+			role:    p.Role,
+			status:  p.Status,
+			joined:  p.JoinedAt,
+		})
 	}
 	return users
 }
 
+// Close leaves the room the session is in, if any. Call it once the
+// program has stopped, e.g. because the connection dropped.
+func (m *Model) Close() {
+	m.cleanup()
+}
+
 func (m *Model) cleanup() {
+	m.cancelKnock()
+
 	if m.terminal != nil && m.termUpdateCh != nil {
 		m.terminal.Unsubscribe(m.termUpdateCh)
 		m.termUpdateCh = nil
 	}
 
 	if m.currentRoom != nil && m.roomID != "" {
+		m.log().Info("left room")
+		m.audit(audit.Event{Type: "room_leave"})
+		_, span := m.span(context.Background(), "room.leave")
 		m.roomManager.LeaveRoom(m.roomID, m.clientID)
+		span.End()
 		m.currentRoom = nil
 	}
 
 	m.terminal = nil
 	m.termContent = ""
 	m.roomID = ""
-	m.users = []string{}
+	m.isHost = false
+	m.username = m.name
+	m.rejoinToken = ""
+	m.users = []participant{}
+	m.activity = nil
+	m.pointer = nil
+	m.pager = nil
+	m.lost = nil
+	m.leaving = nil
+	m.userMenu = nil
+	m.focus = focusTerminal
+	m.zen = false
+	m.mentionTs = 0
+	m.player = nil
+	m.pinnedView = ""
+	m.lastSandbox = nil
 }
 
 func (m *Model) startTerminal() tea.Cmd {
-	return func() tea.Msg {
-		if m.currentRoom != nil && m.currentRoom.Terminal != nil {
-			m.terminal = m.currentRoom.Terminal
+	start := func() (msg tea.Msg) {
+		_, span := m.span(context.Background(), "terminal.start")
+		defer func() { endSpan(span, msg) }()
+
+		if t := m.currentRoomTerminal(); t != nil {
+			span.SetAttributes(attribute.Bool("shared", true))
+			m.terminal = t
 			m.termUpdateCh = m.terminal.Subscribe()
+			m.reportViewSize()
 			m.termContent = m.terminal.Render()
-			return terminalUpdateMsg{} // start listening for updates
+			return terminalUpdateMsg{m.termUpdateCh} // start listening for updates
 		}
 
-		_, terminalW, _, mainH := m.roomLayout()
-		termH := mainH - 4 // account for header and padding
-
-		if terminalW < 40 {
-			terminalW = 80
-		}
-		if termH < 10 {
-			termH = 24
-		}
-
-		workDir := "/app"
-		if m.currentRoom != nil && m.currentRoom.WorkspaceDir != "" {
-			workDir = m.currentRoom.WorkspaceDir
-		}
-
-		m.terminal = terminal.New(terminalW, termH, workDir)
-
-		if err := m.terminal.Start(); err != nil {
+		t, err := m.newShell(true)
+		if err != nil {
 			return ErrorMsg{err}
 		}
+		m.terminal = t
 
 		if m.currentRoom != nil {
-			m.currentRoom.Terminal = m.terminal
+			if shared, ok := m.currentRoom.AttachTerminal(m.terminal); !ok {
+				// someone else started it first; use theirs
+				m.terminal.Close()
+				m.terminal = shared
+			}
 		}
 
 		// Subscribe to terminal updates (per-client channel)
 		m.termUpdateCh = m.terminal.Subscribe()
+		m.reportViewSize()
 		m.termContent = m.terminal.Render()
-		return terminalUpdateMsg{} // start listening for updates
+		return terminalUpdateMsg{m.termUpdateCh} // start listening for updates
+	}
+	return tea.Sequence(start, m.autoPassthrough)
+}
+
+// newShell starts a terminal sized to the pane with the room's launch
+// settings and shared env, feeding the room transcript. Only the main
+// terminal attaches to the room's tmux session; extra tabs get shells.
+func (m *Model) newShell(main bool) (*terminal.Terminal, error) {
+	_, _, terminalW, termH := m.termPane()
+
+	if terminalW < 40 {
+		terminalW = 80
+	}
+	if termH < 10 {
+		termH = 24
+	}
+
+	var settings room.RoomSettings
+	var session terminal.SessionInfo
+	var env []string
+	if m.currentRoom != nil {
+		settings = m.currentRoom.LaunchSettings()
+		session = m.currentRoom.SessionInfo()
+		env = m.currentRoom.EnvList()
+	}
+	if settings.Dir == "" {
+		settings.Dir = "/app"
+	}
+
+	t := terminal.New(terminalW, termH, settings.Shell, settings.Dir, env)
+	t.SetSessionInfo(session)
+	if settings.Scrollback != 0 {
+		t.SetScrollback(settings.Scrollback)
+	}
+	if settings.FrameRate != 0 {
+		t.SetFrameRate(settings.FrameRate)
+	}
+	if c := m.roomContainer(); c != nil {
+		argv, err := containerShell(settings.Shell)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Start(); err != nil {
+			return nil, err
+		}
+		// docker exec doesn't pass our env through, so name each variable;
+		// the terminal runs it with their values in its environment
+		t.SetCommand(c.ExecArgs(argv, settings.Dir, append(env, session.Env()...)))
+	} else if main && settings.TmuxSession != "" {
+		t.AttachTmux(settings.TmuxSession)
+	}
+	if m.currentRoom != nil && m.currentRoom.Transcript != nil {
+		t.SetOutputHook(m.currentRoom.Transcript.AddOutput)
+	}
+	if m.currentRoom != nil {
+		t.SetLogger(m.currentRoom.Logger())
+	}
+
+	if err := t.Start(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *Model) roomContainer() *container.Container {
+	if m.currentRoom == nil {
+		return nil
+	}
+	return m.currentRoom.Container()
+}
+
+// containerShell is the argv for a room shell inside its container. The
+// server's $SHELL may not exist in the image, so without a room shell this
+// returns nil and ExecArgs falls back to /bin/sh.
+func containerShell(shell string) ([]string, error) {
+	if shell == "" {
+		return nil, nil
+	}
+	return terminal.ParseCommand(shell)
+}
+
+// reportViewSize tells the shared terminal how big our pane is; it sizes
+// itself to the smallest pane among everyone watching.
+func (m *Model) reportViewSize() {
+	if m.terminal == nil || m.termUpdateCh == nil {
+		return
 	}
+	_, _, w, h := m.termPane()
+	m.terminal.SetViewSize(m.termUpdateCh, m.username, w, h)
+}
+
+func (m *Model) currentRoomTerminal() *terminal.Terminal {
+	if m.currentRoom == nil {
+		return nil
+	}
+	return m.currentRoom.GetTerminal()
 }
 
 // listens for terminal updates via per-client subscription
@@ -676,9 +1809,13 @@ func (m *Model) waitForTerminalUpdate() tea.Cmd {
 	if m.terminal == nil || m.termUpdateCh == nil {
 		return nil
 	}
+	ch := m.termUpdateCh
 	return func() tea.Msg {
-		<-m.termUpdateCh
-		return terminalUpdateMsg{}
+		if _, ok := <-ch; !ok {
+			// unsubscribed: we switched tabs, or the terminal went away
+			return terminalClosedMsg{ch}
+		}
+		return terminalUpdateMsg{ch}
 	}
 }
 
@@ -686,19 +1823,31 @@ func (m *Model) listenForRoomEvents() tea.Cmd {
 	if m.eventChan == nil {
 		return nil
 	}
+	ch := m.eventChan
 	return func() tea.Msg {
-		event, ok := <-m.eventChan
+		event, ok := <-ch
 		if !ok {
-			return nil
+			return eventsClosedMsg{ch}
 		}
 		return roomEventMsg{Event: event}
 	}
 }
 
 func (m *Model) addToast(text string) {
+	m.addToastFor(text, 1*time.Second)
+}
+
+// addToastFor shows a toast for longer, e.g. for text the user must copy.
+func (m *Model) addToastFor(text string, d time.Duration) {
+	m.showToast(text, d, severityInfo)
+}
+
+func (m *Model) showToast(text string, d time.Duration, sev severity) {
+	m.logMessage(text, sev)
+	m.say(text)
 	m.toasts = append(m.toasts, toast{
 		text:    text,
-		expires: time.Now().Add(1 * time.Second),
+		expires: time.Now().Add(d),
 	})
 	if len(m.toasts) > 3 {
 		m.toasts = m.toasts[len(m.toasts)-3:]
@@ -720,19 +1869,50 @@ func (m *Model) View() string {
 	if m.width == 0 {
 		return ""
 	}
+	if m.linear {
+		switch m.screen {
+		case ScreenLaunch:
+			return m.viewLaunchLinear()
+		case ScreenCreate:
+			return m.viewCreateLinear()
+		case ScreenJoin:
+			return m.viewJoinLinear()
+		case ScreenRoom:
+			return m.viewLinear()
+		}
+	}
+	var view string
 	switch m.screen {
 	case ScreenLaunch:
-		return m.viewLaunch()
+		view = m.viewLaunch()
+	case ScreenProfile:
+		view = m.viewProfile()
 	case ScreenCreate:
-		return m.viewCreate()
+		view = m.viewCreate()
 	case ScreenJoin:
-		return m.viewJoin()
+		view = m.viewJoin()
+	case ScreenLobby:
+		view = m.viewLobby()
 	case ScreenRoomCreated:
-		return m.viewRoomCreated()
+		view = m.viewRoomCreated()
 	case ScreenRoom:
-		return m.viewRoom()
+		view = m.viewRoom()
+	case ScreenPlayback:
+		view = m.viewPlayback()
 	}
-	return ""
+	if m.pager != nil && m.screen == ScreenRoom {
+		view = overlay(m.renderPager(), view, m.width, m.height)
+	}
+	if m.userMenu != nil && m.screen == ScreenRoom {
+		view = overlay(m.renderUserMenu(), view, m.width, m.height)
+	}
+	if m.leaving != nil && m.screen == ScreenRoom {
+		view = overlay(m.renderLeaveDialog(), view, m.width, m.height)
+	}
+	if m.showHelp {
+		view = overlay(m.renderHelp(), view, m.width, m.height)
+	}
+	return view
 }
 
 // Helpers
@@ -741,20 +1921,120 @@ func gotoScreen(s Screen) tea.Cmd {
 	return func() tea.Msg { return GotoScreenMsg{s} }
 }
 
-func removeUser(users []string, name string) []string {
-	for i, u := range users {
-		if u == name {
-			return append(users[:i], users[i+1:]...)
+// shared environment: the host edits it, new shells and sandbox execs pick it
+// up automatically, and f5 re-exports it into the running shell.
+
+func (m *Model) applyEnvInput(text string) {
+	if m.currentRoom == nil {
+		return
+	}
+	text = strings.TrimSpace(text)
+
+	var change string
+	if key, ok := strings.CutPrefix(text, "-"); ok {
+		key = strings.TrimSpace(key)
+		if err := m.currentRoom.UnsetEnv(key); err != nil {
+			m.addError("Error: " + err.Error())
+			return
+		}
+		change = "unset " + key
+	} else {
+		key, value, found := strings.Cut(text, "=")
+		if !found {
+			m.addToast("Use KEY=value to set or -KEY to unset")
+			return
 		}
+		if err := m.currentRoom.SetEnv(strings.TrimSpace(key), value); err != nil {
+			m.addError("Error: " + err.Error())
+			return
+		}
+		change = "set " + strings.TrimSpace(key)
 	}
-	return users
+
+	m.addToast("Env " + change + " (f5 to export into shell)")
+	m.currentRoom.BroadcastEvent(room.RoomEvent{
+		Type:     "env",
+		Username: m.username,
+		Data:     change,
+	}, m.clientID)
+}
+
+// exportEnvToShell types an export of the shared env into the shell. It's
+// the host's, as the values (tokens, say) end up on everyone's screen.
+func (m *Model) exportEnvToShell() {
+	if !m.isHost {
+		m.addToast("Only the host can export environment variables")
+		return
+	}
+	if m.currentRoom == nil || m.terminal == nil {
+		return
+	}
+	line := m.currentRoom.ExportCommand()
+	if line == "" {
+		m.addToast("No shared env vars set")
+		return
+	}
+	m.terminal.Write([]byte(line + "\r"))
+}
+
+// keyboard macros: keystrokes sent to the shared terminal are captured while
+// recording and stored per SSH key on the Manager, so they survive leaving
+// rooms.
+
+func (m *Model) toggleMacroRecording() {
+	if !m.macroRecording {
+		if m.fingerprint == "" {
+			m.addToast("Macros need an SSH key")
+			return
+		}
+		m.macroRecording = true
+		m.macroBuf = nil
+		m.addToast("Recording macro (f3 to stop)")
+		return
+	}
+
+	m.macroRecording = false
+	if len(m.macroBuf) == 0 {
+		m.addToast("Macro empty, nothing saved")
+		return
+	}
+	if err := m.roomManager.SetMacro(m.fingerprint, m.macroBuf); err != nil {
+		m.addError("Error: " + err.Error())
+		m.macroBuf = nil
+		return
+	}
+	m.addToast(fmt.Sprintf("Macro saved (%d bytes, f4 to replay)", len(m.macroBuf)))
+	m.macroBuf = nil
+}
+
+// recordMacro adds keystrokes to the macro being recorded, if any, giving
+// up on it once it's too long to save.
+func (m *Model) recordMacro(data []byte) {
+	if !m.macroRecording {
+		return
+	}
+	if len(m.macroBuf)+len(data) > room.MaxMacroLen {
+		m.macroRecording = false
+		m.macroBuf = nil
+		m.addError("Error: " + room.ErrMacroTooLong.Error() + "; recording stopped")
+		return
+	}
+	m.macroBuf = append(m.macroBuf, data...)
 }
 
-func truncate(s string, max int) string {
-	if len(s) <= max {
-		return s
+func (m *Model) replayMacro() {
+	if m.macroRecording {
+		m.addToast("Stop recording before replaying")
+		return
+	}
+	macro := m.roomManager.GetMacro(m.fingerprint)
+	if len(macro) == 0 {
+		m.addToast("No macro recorded (f3 to record)")
+		return
+	}
+	if m.terminal != nil {
+		m.terminal.WriteFrom(m.author(), macro)
 	}
-	return s[:max]
 }
 
 // some helpers for the ai sidebar
@@ -766,6 +2046,18 @@ func (m *Model) syncAIViewportContent() {
 	m.lastPromptOffset = promptOffset
 }
 
+// fitAIViewport sizes the AI viewport to the sidebar, less whatever the
+// pinned messages above it take up.
+func (m *Model) fitAIViewport(aiSidebarW, mainH int) {
+	vpW, vpH := m.aiViewportInnerSize(aiSidebarW, mainH)
+	m.aiViewport.Width = vpW
+	m.pinnedView = m.renderPinned(vpW, vpH/3)
+	if m.pinnedView != "" {
+		vpH -= lipgloss.Height(m.pinnedView) + 1
+	}
+	m.aiViewport.Height = vpH
+}
+
 // scrolls the AI viewport to show the last user prompt
 func (m *Model) scrollToLastPrompt() {
 	m.aiViewport.SetYOffset(m.lastPromptOffset)
@@ -0,0 +1,293 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// panel is the part of the room that has the focus. Keys always go to the
+// shell unless they mean something to duet; the focus decides where the
+// mouse goes and what the arrow keys scroll.
+type panel int
+
+const (
+	focusTerminal panel = iota
+	focusUsers
+	focusAI
+)
+
+// wheelLines is how far one notch of the wheel scrolls a panel.
+const wheelLines = 3
+
+// userMenu is what clicking someone in the users list opens: what we can
+// do to them.
+type userMenu struct {
+	target string
+	items  []menuItem
+	cursor int
+}
+
+type menuItem struct {
+	label string
+	run   func() tea.Cmd
+}
+
+// mouseCommand turns duet's own use of the mouse on or off. Off leaves the
+// mouse to the terminal again, so text can be selected without shift,
+// except while the shared shell's program asks for mouse reports.
+func (m *Model) mouseCommand(args []string) tea.Cmd {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		state := "off"
+		if m.mousePanels {
+			state = "on"
+		}
+		m.addToast("mouse: " + state + " (usage: mouse on|off)")
+		return nil
+	}
+	m.mousePanels = args[0] == "on"
+	if m.mousePanels {
+		m.addToast("Mouse on: shift+drag selects text")
+	} else {
+		m.addToast("Mouse off: the terminal selects text again")
+		m.focus = focusTerminal
+	}
+	return m.syncMouse()
+}
+
+// handleMouse sends a mouse event to the panel under it: the wheel scrolls
+// whatever it's over, a click focuses a panel (and on a name, opens the
+// user menu), and everything over a focused terminal pane goes to the PTY.
+func (m *Model) handleMouse(msg tea.MouseMsg) tea.Cmd {
+	if m.screen != ScreenRoom || m.showHelp || m.pager != nil || m.leaving != nil {
+		return nil
+	}
+	press := msg.Action == tea.MouseActionPress
+	if m.userMenu != nil {
+		if press && msg.Button == tea.MouseButtonLeft {
+			return m.clickUserMenu(msg.X, msg.Y)
+		}
+		return nil
+	}
+
+	sidebarW, terminalW, aiSidebarW, mainH := m.roomLayout()
+	panels := !m.zen && !m.linear && !m.compact()
+	if (panels || m.compact()) && msg.Y >= mainH {
+		return nil // the bottom bar
+	}
+	over := focusTerminal
+	if panels {
+		// each sidebar's border counts as part of it
+		switch {
+		case m.showUsers && msg.X <= sidebarW:
+			over = focusUsers
+		case m.showAISidebar && msg.X >= m.terminalLeft()+terminalW && aiSidebarW > 0:
+			over = focusAI
+		}
+	}
+
+	wheel := msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown
+	up := msg.Button == tea.MouseButtonWheelUp
+	switch over {
+	case focusTerminal:
+		if m.focus == focusTerminal && m.inputMode == ModeNormal && m.terminal != nil && m.terminal.WantsMouse() {
+			m.forwardMouse(msg)
+			return nil
+		}
+		if wheel {
+			m.wheelScrollback(up)
+			return nil
+		}
+		if press && msg.Button == tea.MouseButtonLeft {
+			m.focus = focusTerminal
+		}
+	case focusAI:
+		if wheel {
+			if up {
+				m.aiViewport.ScrollUp(wheelLines)
+			} else {
+				m.aiViewport.ScrollDown(wheelLines)
+			}
+			return nil
+		}
+		if press && msg.Button == tea.MouseButtonLeft {
+			m.focus = focusAI
+			if m.aiBadge {
+				m.aiBadge, m.aiUnread = false, 0
+				m.relayout()
+			}
+		}
+	case focusUsers:
+		if press && msg.Button == tea.MouseButtonLeft {
+			m.focus = focusUsers
+			// one row of padding above the sidebar's content
+			if i := (msg.Y - 1 - m.usersTop) / usersRows; msg.Y-1 >= m.usersTop && i < len(m.users) {
+				m.userCursor = i
+				m.openUserMenu(m.users[i])
+			}
+		}
+	}
+	return nil
+}
+
+// wheelScrollback scrolls the terminal pane's history when the shell's
+// program doesn't want the mouse, leaving scrollback at the bottom.
+func (m *Model) wheelScrollback(up bool) {
+	if m.terminal == nil || (m.inputMode != ModeNormal && m.inputMode != ModeScroll) {
+		return
+	}
+	if up {
+		if m.inputMode == ModeNormal {
+			m.enterScrollMode()
+		}
+		m.scrollBy(wheelLines)
+		return
+	}
+	if m.inputMode == ModeScroll {
+		m.scrollBy(-wheelLines)
+		if m.scrollOffset == 0 {
+			m.inputMode = ModeNormal
+		}
+	}
+}
+
+// handleFocusKey lets a focused sidebar take the keys that can't mean
+// anything to the shell's program: pgup/pgdn and alt+up/down scroll the AI
+// sidebar or move through the users list, alt+enter opens the menu for
+// whoever is picked there. Every other key goes back to the terminal,
+// focus and all, and reaches the shell as usual; it reports false for those.
+func (m *Model) handleFocusKey(key string) bool {
+	switch m.focus {
+	case focusAI:
+		switch key {
+		case "alt+up":
+			m.aiViewport.ScrollUp(1)
+		case "alt+down":
+			m.aiViewport.ScrollDown(1)
+		case "pgup":
+			m.aiViewport.HalfPageUp()
+		case "pgdown":
+			m.aiViewport.HalfPageDown()
+		default:
+			m.focus = focusTerminal
+			return false
+		}
+		return true
+	case focusUsers:
+		switch key {
+		case "alt+up", "pgup":
+			m.userCursor = max(m.userCursor-1, 0)
+		case "alt+down", "pgdown":
+			m.userCursor = min(m.userCursor+1, max(len(m.users)-1, 0))
+		case "alt+enter":
+			if m.userCursor < len(m.users) {
+				m.openUserMenu(m.users[m.userCursor])
+			}
+		default:
+			m.focus = focusTerminal
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// openUserMenu offers what we can do to u: everyone can mention them to
+// the AI, the host can hand over host, kick or ban.
+func (m *Model) openUserMenu(u participant) {
+	if u.you {
+		m.addToast("That's you (p on the launch screen edits your profile)")
+		return
+	}
+	menu := &userMenu{target: u.name}
+	menu.items = append(menu.items, menuItem{"mention in an AI prompt", func() tea.Cmd {
+		return m.mentionInPrompt(u.name)
+	}})
+	if m.isHost {
+		menu.items = append(menu.items,
+			menuItem{"make host", func() tea.Cmd { m.transferHost([]string{u.name}); return nil }},
+			menuItem{"kick", func() tea.Cmd { m.kickUser([]string{u.name}); return nil }},
+			menuItem{"ban", func() tea.Cmd { m.banUser([]string{u.name}); return nil }},
+		)
+	}
+	m.userMenu = menu
+}
+
+// mentionInPrompt opens the AI prompt with @name already typed, like
+// ctrl+g.
+func (m *Model) mentionInPrompt(name string) tea.Cmd {
+	if m.aiClient == nil {
+		m.addToast(aiDisabledMsg)
+		return nil
+	}
+	if m.aiPaused() {
+		return nil
+	}
+	m.inputMode = ModeAI
+	m.cmdInput.Reset()
+	m.cmdInput.Placeholder = "Ask the AI... (/ for templates, tab completes)"
+	m.cmdInput.SetValue("@" + name + " ")
+	m.cmdInput.CursorEnd()
+	m.cmdInput.Focus()
+	return textinput.Blink
+}
+
+func (m *Model) handleUserMenuKey(key string) (tea.Model, tea.Cmd) {
+	menu := m.userMenu
+	switch key {
+	case "up", "k", "shift+tab":
+		menu.cursor = (menu.cursor + len(menu.items) - 1) % len(menu.items)
+	case "down", "j", "tab":
+		menu.cursor = (menu.cursor + 1) % len(menu.items)
+	case "enter", " ":
+		return m, m.runUserMenu(menu.cursor)
+	case "esc", "q", "ctrl+c":
+		m.userMenu = nil
+	}
+	return m, nil
+}
+
+// runUserMenu closes the menu and does what its i'th item says.
+func (m *Model) runUserMenu(i int) tea.Cmd {
+	item := m.userMenu.items[i]
+	m.userMenu = nil
+	m.focus = focusTerminal
+	return item.run()
+}
+
+// clickUserMenu runs the item clicked on, or closes the menu when the
+// click missed it. The menu is drawn centred, like every overlay.
+func (m *Model) clickUserMenu(x, y int) tea.Cmd {
+	box := m.renderUserMenu()
+	w, h := lipgloss.Width(box), lipgloss.Height(box)
+	left, top := max((m.width-w)/2, 0), max((m.height-h)/2, 0)
+	// border, title and a blank line come before the first item
+	if i := y - top - 3; x >= left && x < left+w && i >= 0 && i < len(m.userMenu.items) {
+		return m.runUserMenu(i)
+	}
+	if x < left || x >= left+w || y < top || y >= top+h {
+		m.userMenu = nil
+	}
+	return nil
+}
+
+// renderUserMenu draws the user menu box.
+func (m *Model) renderUserMenu() string {
+	menu := m.userMenu
+	lines := []string{m.userStyle(menu.target).Bold(true).Render(menu.target), ""}
+	for i, item := range menu.items {
+		if i == menu.cursor {
+			lines = append(lines, m.styles.accentStyle.Render("▸ "+item.label))
+		} else {
+			lines = append(lines, m.styles.textStyle.Render("  "+item.label))
+		}
+	}
+	lines = append(lines, "", m.styles.dimStyle.Render("enter or click • esc close"))
+	return m.styles.baseStyle.
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.styles.theme.Accent).
+		Padding(0, 2).
+		Render(strings.Join(lines, "\n"))
+}
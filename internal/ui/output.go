@@ -0,0 +1,25 @@
+package ui
+
+import (
+	"io"
+	"sync"
+)
+
+// Output is the program's output to the SSH session. The renderer writes
+// each frame in a single Write, so serialising writes lets bells and
+// notifications (see ring) go out between frames instead of landing in
+// the middle of an escape sequence.
+type Output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewOutput(w io.Writer) *Output {
+	return &Output{w: w}
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.w.Write(p)
+}
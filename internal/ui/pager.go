@@ -0,0 +1,185 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pagerLines bounds how far back the pager reads the last command's output.
+const pagerLines = 5000
+
+// pager shows the last command's output in a less-like overlay, so a long
+// stack trace can be read without scrolling the live shell.
+type pager struct {
+	title     string
+	lines     []string
+	offset    int // first line shown
+	query     string
+	typing    bool  // the search query is being typed
+	matches   []int // lines containing query
+	match     int   // index into matches of the current one
+	lastShown int   // lines that fit last time it was drawn
+}
+
+// openPager captures the last command and its output from the shared
+// terminal's scrollback.
+func (m *Model) openPager() {
+	if m.terminal == nil {
+		return
+	}
+	out := m.terminal.LastCommand(pagerLines)
+	if out == "" {
+		m.addToast("No command output to page yet")
+		return
+	}
+	lines := strings.Split(out, "\n")
+	if m.linear {
+		m.printOverlay(lines)
+		return
+	}
+	m.pager = &pager{title: ellipsize(lines[0], 60), lines: lines}
+}
+
+func (m *Model) handlePagerKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.pager
+	if p.typing {
+		switch key {
+		case "enter":
+			p.typing = false
+			p.search()
+			m.pagerJump(0)
+		case "esc":
+			p.typing, p.query = false, ""
+			p.matches = nil
+		case "backspace":
+			if r := []rune(p.query); len(r) > 0 {
+				p.query = string(r[:len(r)-1])
+			}
+		default:
+			if msg.Type == tea.KeyRunes || key == " " {
+				p.query += string(msg.Runes)
+			}
+		}
+		return m, nil
+	}
+
+	page := max(p.lastShown, 1)
+	switch key {
+	case "q", "esc", "alt+o":
+		m.pager = nil
+	case "down", "j", "enter":
+		p.scroll(1, page)
+	case "up", "k":
+		p.scroll(-1, page)
+	case "pgdown", " ", "ctrl+f":
+		p.scroll(page, page)
+	case "pgup", "b", "ctrl+b":
+		p.scroll(-page, page)
+	case "home", "g":
+		p.offset = 0
+	case "end", "G":
+		p.scroll(len(p.lines), page)
+	case "/":
+		p.typing, p.query = true, ""
+	case "n":
+		m.pagerJump(1)
+	case "N":
+		m.pagerJump(-1)
+	case "ctrl+c":
+		m.pager = nil
+	}
+	return m, nil
+}
+
+func (p *pager) scroll(delta, page int) {
+	p.offset = min(max(p.offset+delta, 0), max(len(p.lines)-page, 0))
+}
+
+// search finds the lines containing the query, ignoring case.
+func (p *pager) search() {
+	p.matches, p.match = nil, 0
+	if p.query == "" {
+		return
+	}
+	q := strings.ToLower(p.query)
+	for i, l := range p.lines {
+		if strings.Contains(strings.ToLower(l), q) {
+			p.matches = append(p.matches, i)
+		}
+	}
+}
+
+// pagerJump moves to the match delta after the current one, wrapping, and
+// scrolls it into view.
+func (m *Model) pagerJump(delta int) {
+	p := m.pager
+	if len(p.matches) == 0 {
+		if p.query != "" {
+			m.addToast("Not found: " + p.query)
+		}
+		return
+	}
+	n := len(p.matches)
+	p.match = ((p.match+delta)%n + n) % n
+	page := max(p.lastShown, 1)
+	line := p.matches[p.match]
+	if line < p.offset || line >= p.offset+page {
+		p.offset = 0
+		p.scroll(line-page/3, page)
+	}
+}
+
+// renderPager draws the pager box over most of the window.
+func (m *Model) renderPager() string {
+	p := m.pager
+	w := max(m.width*9/10, 20)
+	// border and padding take 4 rows, the title and footer 4 more
+	h := max(m.height*9/10-8, 3)
+	p.lastShown = h
+	p.scroll(0, h)
+
+	current := -1
+	if len(p.matches) > 0 {
+		current = p.matches[p.match]
+	}
+	numW := len(fmt.Sprint(len(p.lines)))
+	var rows []string
+	for i := p.offset; i < min(p.offset+h, len(p.lines)); i++ {
+		num := m.styles.dimStyle.Render(fmt.Sprintf("%*d ", numW, i+1))
+		line := truncate(p.lines[i], w-numW-6)
+		switch {
+		case i == current:
+			line = m.styles.accentStyle.Reverse(true).Render(line)
+		case p.query != "" && !p.typing && strings.Contains(strings.ToLower(line), strings.ToLower(p.query)):
+			line = m.styles.accentStyle.Render(line)
+		default:
+			line = m.styles.textStyle.Render(line)
+		}
+		rows = append(rows, num+line)
+	}
+
+	footer := fmt.Sprintf("lines %d-%d of %d • j/k pgup/pgdn g/G • / search • q close",
+		p.offset+1, min(p.offset+h, len(p.lines)), len(p.lines))
+	if p.typing {
+		footer = "/" + p.query + "█"
+	} else if len(p.matches) > 0 {
+		footer = fmt.Sprintf("match %d/%d for %q • n/N next/prev • ", p.match+1, len(p.matches), p.query) + footer
+	}
+
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		m.styles.titleStyle.Render(ellipsize(p.title, w-6)),
+		"",
+		strings.Join(rows, "\n"),
+		"",
+		m.styles.dimStyle.Render(ellipsize(footer, w-6)),
+	)
+	return m.styles.baseStyle.
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.styles.theme.Accent).
+		Padding(1, 2).
+		Width(w).
+		Render(body)
+}
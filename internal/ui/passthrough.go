@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaypopat/duet/internal/room"
+	"github.com/jaypopat/duet/internal/terminal"
+)
+
+// detachKey leaves raw passthrough, same as the key that opens the command
+// prompt in the normal UI.
+const detachKey = 0x1d // ctrl+]
+
+// On the way in we take over a clean alternate screen; on the way out we
+// undo modes the shell's programs may have left on before bubbletea redraws.
+const (
+	rawEnter = "\x1b[?1049h\x1b[0m\x1b[H\x1b[2J"
+	rawLeave = "\x1b[0m\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1006l\x1b[?2004l\x1b[?1l\x1b>\x1b[?25h\x1b[?1049l"
+)
+
+type rawModeMsg struct{}
+
+type passthroughDoneMsg struct{ err error }
+
+// passthrough is a tea.ExecCommand that hands the client's terminal
+// straight to the shared shell: PTY output is copied as-is instead of going
+// through vt10x and the layout, and keys go straight to the shell.
+type passthrough struct {
+	t      *terminal.Terminal
+	in     *Input
+	out    io.Writer
+	author terminal.Author
+
+	// the room and who we are in it: the UI's tick, which keeps us in the
+	// participant list, doesn't run while we're here, so we keep it
+	// ourselves
+	room     *room.Room
+	clientID string
+}
+
+func (p *passthrough) SetStdin(io.Reader)    {} // we read from p.in, see Input
+func (p *passthrough) SetStdout(w io.Writer) { p.out = w }
+func (p *passthrough) SetStderr(io.Writer)   {}
+
+func (p *passthrough) Run() error {
+	stream := p.t.SubscribeRaw()
+	defer p.t.UnsubscribeRaw(stream)
+
+	stop := make(chan struct{})
+	var once sync.Once
+	finish := func() { once.Do(func() { close(stop) }) }
+
+	if _, err := io.WriteString(p.out, rawEnter); err != nil {
+		return err
+	}
+	if _, err := p.out.Write(p.t.Snapshot()); err != nil {
+		return err
+	}
+
+	// Only this goroutine writes to the client until Run returns, so
+	// output from the shell never interleaves with a resync.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer finish()
+		p.copyOutput(stream, stop)
+	}()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := p.in.ReadRaw(buf, stop)
+		if err != nil {
+			break
+		}
+		data := buf[:n]
+		if i := bytes.IndexByte(data, detachKey); i >= 0 {
+			if i > 0 {
+				p.room.NoteInput(p.clientID)
+			}
+			p.t.WriteFrom(p.author, data[:i])
+			break
+		}
+		p.room.NoteInput(p.clientID)
+		p.t.WriteFrom(p.author, data)
+	}
+	finish()
+	wg.Wait()
+
+	_, err := io.WriteString(p.out, rawLeave)
+	return err
+}
+
+// copyOutput forwards raw chunks until stop closes, the terminal closes,
+// the shell exits or the room lets us go, sending the heartbeat meanwhile.
+// A viewer that fell behind is redrawn from a snapshot rather than
+// replaying everything it missed.
+func (p *passthrough) copyOutput(stream *terminal.RawStream, stop <-chan struct{}) {
+	exitCheck := time.NewTicker(250 * time.Millisecond)
+	defer exitCheck.Stop()
+	heartbeat := time.NewTicker(time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case chunk, ok := <-stream.C:
+			if !ok {
+				return
+			}
+			if stream.Lagged() {
+				stream.Drain()
+				chunk = p.t.Snapshot()
+			}
+			if _, err := p.out.Write(chunk); err != nil {
+				return
+			}
+		case <-exitCheck.C:
+			if exited, _ := p.t.Exited(); exited {
+				return
+			}
+		case <-heartbeat.C:
+			if !p.room.Heartbeat(p.clientID) {
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// enterPassthrough switches this client to raw passthrough on the current
+// tab. The shell is sized to our whole window while we're in it.
+func (m *Model) enterPassthrough() tea.Cmd {
+	if m.terminal == nil || m.in == nil || m.currentRoom == nil {
+		m.addToast("Raw mode isn't available here")
+		return nil
+	}
+	if exited, _ := m.terminal.Exited(); exited {
+		m.addToast("The shell has exited")
+		return nil
+	}
+	if m.termUpdateCh != nil {
+		m.terminal.SetViewSize(m.termUpdateCh, m.username, m.width, m.height)
+	}
+	m.in.Detach()
+	p := &passthrough{t: m.terminal, in: m.in, author: m.author(), room: m.currentRoom, clientID: m.clientID}
+	return tea.Exec(p, func(err error) tea.Msg { return passthroughDoneMsg{err} })
+}
+
+// autoPassthrough enters raw passthrough once the terminal is up, if the
+// room asks for it.
+func (m *Model) autoPassthrough() tea.Msg {
+	if m.currentRoom == nil || !m.currentRoom.RawMode() {
+		return nil
+	}
+	return rawModeMsg{}
+}
+
+// setPassthrough is the host's "raw on|off" command.
+func (m *Model) setPassthrough(args []string) {
+	if !m.isHost {
+		m.addToast("Only the host can change the room's display mode")
+		return
+	}
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		m.addToast("Usage: raw on|off")
+		return
+	}
+	if m.currentRoom == nil {
+		return
+	}
+	m.currentRoom.SetPassthrough(args[0] == "on", m.username)
+}
@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// pinLines is how many wrapped lines of each pinned message the sidebar shows.
+const pinLines = 2
+
+// aiReplies returns the AI's answers, newest first, so reply 1 is always
+// the latest, matching how code blocks are numbered.
+func aiReplies(msgs []AIMessage) []AIMessage {
+	var replies []AIMessage
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "agent" {
+			replies = append(replies, msgs[i])
+		}
+	}
+	return replies
+}
+
+// pinMessage handles "pin [N]": pin the latest AI reply, or the N-th one
+// counting back from it.
+func (m *Model) pinMessage(args []string) {
+	if m.currentRoom == nil {
+		return
+	}
+	replies := aiReplies(m.getAIMessages())
+	if len(replies) == 0 {
+		m.addToast("No AI replies to pin yet")
+		return
+	}
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			m.addToast("Usage: pin [N] (1 is the latest reply)")
+			return
+		}
+	}
+	if n > len(replies) {
+		m.addToast("Only " + strconv.Itoa(len(replies)) + " AI replies so far")
+		return
+	}
+	if err := m.currentRoom.PinAIMessage(replies[n-1], m.username); err != nil {
+		m.addError("Error: " + err.Error())
+	}
+}
+
+// unpinMessage handles "unpin N", N counting pins from the top.
+func (m *Model) unpinMessage(args []string) {
+	if m.currentRoom == nil {
+		return
+	}
+	n := 0
+	if len(args) == 1 {
+		n, _ = strconv.Atoi(args[0])
+	}
+	if n < 1 {
+		m.addToast("Usage: unpin <N> (1 is the top pin)")
+		return
+	}
+	if err := m.currentRoom.UnpinAIMessage(n-1, m.username); err != nil {
+		if errors.Is(err, room.ErrPinNotFound) {
+			m.addToast("No pin number " + args[0])
+			return
+		}
+		m.addError("Error: " + err.Error())
+	}
+}
+
+// renderPinned is the pinned section at the top of the AI sidebar, or ""
+// when nothing is pinned. It never takes more than maxLines lines.
+func (m *Model) renderPinned(w, maxLines int) string {
+	if m.currentRoom == nil {
+		return ""
+	}
+	pins := m.currentRoom.PinnedAIMessages()
+	if len(pins) == 0 || maxLines < 2 {
+		return ""
+	}
+
+	lines := []string{m.styles.accentStyle.Render("pinned:")}
+	for i, p := range pins {
+		text := strings.Join(strings.Fields(p.Text), " ")
+		wrapped := wrapLines(text, w-4)
+		if len(wrapped) > pinLines {
+			wrapped = wrapped[:pinLines]
+			wrapped[pinLines-1] = ellipsize(wrapped[pinLines-1]+" …", w-4)
+		}
+		if len(lines)+len(wrapped) > maxLines {
+			lines = append(lines, m.styles.dimStyle.Render("  +"+strconv.Itoa(len(pins)-i)+" more"))
+			break
+		}
+		for j, line := range wrapped {
+			prefix := "    "
+			if j == 0 {
+				prefix = strconv.Itoa(i+1) + ". "
+			}
+			lines = append(lines, m.styles.textStyle.Render(prefix+line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
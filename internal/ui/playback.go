@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaypopat/duet/internal/playback"
+)
+
+const (
+	playbackFrame = 50 * time.Millisecond
+	playbackSeek  = 5 * time.Second
+)
+
+type playbackTickMsg struct{}
+
+func playbackTick() tea.Cmd {
+	return tea.Tick(playbackFrame, func(time.Time) tea.Msg { return playbackTickMsg{} })
+}
+
+// startPlayback loads an asciicast from inside the room workspace and switches to
+// the playback screen. The shared terminal keeps running underneath.
+func (m *Model) startPlayback(args []string) (tea.Model, tea.Cmd) {
+	if len(args) != 1 {
+		m.addToast("Usage: play <file.cast>")
+		return m, nil
+	}
+	if m.currentRoom == nil {
+		return m, nil
+	}
+
+	path, err := m.currentRoom.WorkspaceFile(args[0])
+	if err != nil {
+		m.addError("Error: " + err.Error())
+		return m, nil
+	}
+	cast, err := playback.Load(path)
+	if err != nil {
+		m.addError("Error: " + err.Error())
+		return m, nil
+	}
+	if len(cast.Events) == 0 {
+		m.addToast("Recording has no output")
+		return m, nil
+	}
+
+	m.player = playback.NewPlayer(cast)
+	m.playbackName = filepath.Base(path)
+	m.screen = ScreenPlayback
+	return m, tea.Batch(playbackTick(), m.syncMouse())
+}
+
+func (m *Model) handlePlaybackKey(key string) (tea.Model, tea.Cmd) {
+	p := m.player
+	switch key {
+	case "esc", "q":
+		m.player = nil
+		m.screen = ScreenRoom
+		return m, m.syncMouse()
+	case " ", "p":
+		p.TogglePause()
+	case "left", "h":
+		p.Seek(p.Position() - playbackSeek)
+	case "right", "l":
+		p.Seek(p.Position() + playbackSeek)
+	case "home", "r":
+		p.Seek(0)
+	case "end":
+		p.Seek(p.Cast().Duration)
+	case "+", "=":
+		p.SetSpeed(p.Speed() * 2)
+	case "-":
+		p.SetSpeed(p.Speed() / 2)
+	}
+	return m, nil
+}
+
+func (m *Model) viewPlayback() string {
+	p := m.player
+	cast := p.Cast()
+
+	name := m.playbackName
+	if cast.Title != "" {
+		name = cast.Title
+	}
+	title := m.styles.titleStyle.Render("playback · " + name)
+
+	state := "▶"
+	if p.Paused() {
+		state = "❚❚"
+	} else if p.Done() {
+		state = "■"
+	}
+	status := m.styles.accentStyle.Render(fmt.Sprintf("%s %s / %s · %gx",
+		state, formatClock(p.Position()), formatClock(cast.Duration), p.Speed()))
+
+	screen := m.styles.terminalStyle.Render(p.Render())
+	help := m.styles.helpStyle.Render("space pause • ←/→ seek 5s • +/- speed • r restart • esc back to room")
+
+	content := lipgloss.JoinVertical(lipgloss.Center, title, "", screen, status, help)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+}
+
+// formatClock renders d as m:ss.
+func formatClock(d time.Duration) string {
+	secs := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// pointDuration is how long rows someone points at stay highlighted.
+const pointDuration = 5 * time.Second
+
+// pointer is rows of a terminal tab someone pointed at with alt+p.
+type pointer struct {
+	user     string
+	tab      int
+	from, to int
+	until    time.Time
+}
+
+// enterPointMode starts picking rows to point at, from the shell's cursor.
+func (m *Model) enterPointMode() {
+	if m.terminal == nil {
+		return
+	}
+	row := m.terminal.CursorRow()
+	m.inputMode = ModePoint
+	m.pointAnchor, m.pointCursor = row, row
+}
+
+// handlePointKey moves or extends the rows being picked, or sends them.
+func (m *Model) handlePointKey(key string) {
+	rows := max(len(strings.Split(m.termContent, "\n")), 1)
+	move := func(delta int, extend bool) {
+		m.pointCursor = min(max(m.pointCursor+delta, 0), rows-1)
+		if !extend {
+			m.pointAnchor = m.pointCursor
+		}
+	}
+	switch key {
+	case "up", "k":
+		move(-1, false)
+	case "down", "j":
+		move(1, false)
+	case "shift+up", "K":
+		move(-1, true)
+	case "shift+down", "J":
+		move(1, true)
+	case "enter", " ":
+		if m.currentRoom != nil {
+			m.currentRoom.Point(m.username, m.currentRoom.TabIndex(m.terminal), m.pointAnchor, m.pointCursor)
+		}
+		m.inputMode = ModeNormal
+	case "esc", "q", "alt+p":
+		m.inputMode = ModeNormal
+	}
+}
+
+// onPoint shows rows someone pointed at, from a "point" event.
+func (m *Model) onPoint(ev room.RoomEvent) {
+	tab, from, to, ok := room.ParsePoint(ev.Data)
+	if !ok {
+		return
+	}
+	m.pointer = &pointer{user: ev.Username, tab: tab, from: from, to: to, until: time.Now().Add(pointDuration)}
+	if ev.Username != m.username && m.currentRoom != nil && m.currentRoom.TabIndex(m.terminal) != tab {
+		m.addToast(fmt.Sprintf("%s is pointing at tab %d (alt+%d)", ev.Username, tab+1, tab+1))
+	}
+}
+
+// pointedRows returns who is pointing at which rows of the tab on screen:
+// our own pick while in ModePoint, else a pointer that hasn't expired.
+func (m *Model) pointedRows() (user string, from, to int, ok bool) {
+	if m.inputMode == ModePoint {
+		return m.username, min(m.pointAnchor, m.pointCursor), max(m.pointAnchor, m.pointCursor), true
+	}
+	p := m.pointer
+	if p == nil || time.Now().After(p.until) || m.currentRoom == nil || m.currentRoom.TabIndex(m.terminal) != p.tab {
+		return "", 0, 0, false
+	}
+	return p.user, p.from, p.to, true
+}
+
+// highlightPointed draws the pointed-at rows of the terminal content in
+// reverse video, in the colour of whoever is pointing.
+func (m *Model) highlightPointed(content string) string {
+	user, from, to, ok := m.pointedRows()
+	if !ok {
+		return content
+	}
+	style := m.userStyle(user).Reverse(true)
+	lines := strings.Split(content, "\n")
+	for i := from; i <= to && i < len(lines); i++ {
+		lines[i] = style.Render(ansi.Strip(lines[i]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pointNote says who is pointing where, for the line above the terminal.
+func (m *Model) pointNote(w int) string {
+	user, from, to, ok := m.pointedRows()
+	if !ok {
+		return ""
+	}
+	rows := fmt.Sprintf("row %d", from+1)
+	if to > from {
+		rows = fmt.Sprintf("rows %d-%d", from+1, to+1)
+	}
+	note := user + " is pointing at " + rows
+	if m.inputMode == ModePoint {
+		note = "pointing at " + rows + " • enter to show everyone"
+	}
+	return m.userStyle(user).Render(truncate(note, w-2))
+}
@@ -0,0 +1,310 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// profileColors are the colours someone can pick for their name instead
+// of the one their username hashes to; "auto" keeps that.
+var profileColors = []struct {
+	name  string
+	color lipgloss.TerminalColor
+}{
+	{"auto", nil},
+	{"red", lipgloss.AdaptiveColor{Light: "160", Dark: "203"}},
+	{"orange", lipgloss.AdaptiveColor{Light: "166", Dark: "214"}},
+	{"yellow", lipgloss.AdaptiveColor{Light: "136", Dark: "221"}},
+	{"green", lipgloss.AdaptiveColor{Light: "28", Dark: "114"}},
+	{"cyan", lipgloss.AdaptiveColor{Light: "31", Dark: "81"}},
+	{"blue", lipgloss.AdaptiveColor{Light: "25", Dark: "75"}},
+	{"purple", lipgloss.AdaptiveColor{Light: "91", Dark: "177"}},
+	{"pink", lipgloss.AdaptiveColor{Light: "127", Dark: "219"}},
+}
+
+// Keymaps decide which ctrl chords duet takes in a room. With readline,
+// the ones bash and friends use for editing (ctrl+a, ctrl+e, ctrl+r...)
+// go to the shell, and ctrl+] followed by the chord runs duet's action.
+const (
+	keymapDefault  = "default"
+	keymapReadline = "readline"
+)
+
+var keymaps = []string{keymapDefault, keymapReadline}
+
+// readlineChords are the chords the readline keymap leaves to the shell.
+var readlineChords = map[string]bool{
+	"ctrl+a": true, "ctrl+e": true, "ctrl+r": true, "ctrl+g": true,
+	"ctrl+k": true, "ctrl+j": true, "ctrl+o": true, "ctrl+l": true,
+}
+
+// prefixedChords go to the shell with every keymap, since shells and the
+// programs in them need them too; ctrl+] then the chord runs duet's action.
+var prefixedChords = map[string]bool{
+	"ctrl+e": true, // end of line in readline, scroll in less and vim
+	// word motion and editing in readline, zsh and emacs
+	"ctrl+left": true, "ctrl+right": true, "alt+left": true, "alt+right": true,
+	"alt+p": true, "alt+z": true, "alt+a": true, "alt+o": true, "alt+u": true,
+}
+
+// shellGetsChord reports whether key goes to the shell rather than to the
+// action duet binds it to, given the keymap and whether ctrl+] came first.
+func (m *Model) shellGetsChord(key string) bool {
+	if m.chordPrefixed {
+		return false
+	}
+	return prefixedChords[key] || m.profile.Keymap == keymapReadline && readlineChords[key]
+}
+
+// the fields of ScreenProfile, top to bottom
+const (
+	profileName = iota
+	profileColor
+	profileTheme
+	profileKeymap
+	profileFields
+)
+
+// loadProfile applies the saved profile for our key, if there is one, and
+// reports whether there was.
+func (m *Model) loadProfile() bool {
+	p, ok := m.roomManager.Profile(m.fingerprint)
+	if !ok {
+		return false
+	}
+	m.profile = p
+	if p.Theme != "" {
+		m.SetTheme(p.Theme)
+	}
+	return true
+}
+
+// SetDefaultTheme switches to the named theme unless the profile picked
+// one; an explicit choice for the session goes through SetTheme instead.
+func (m *Model) SetDefaultTheme(name string) bool {
+	if m.profile.Theme != "" && ValidTheme(m.profile.Theme) {
+		return true
+	}
+	return m.SetTheme(name)
+}
+
+// openProfile shows the profile screen with the saved choices.
+func (m *Model) openProfile() (tea.Model, tea.Cmd) {
+	if m.fingerprint == "" {
+		m.addToast("Profiles are kept by SSH key; connect with one to have one")
+		return m, nil
+	}
+	m.screen = ScreenProfile
+	m.profileFocus = profileName
+	m.profileTheme = m.styles.theme.Name
+	m.profileInput.SetValue(m.profile.DisplayName)
+	m.profilePick = [profileFields]int{
+		profileColor:  max(indexOf(colorNames(), m.profile.Color), 0),
+		profileTheme:  max(indexOf(ThemeNames(), m.profile.Theme), 0),
+		profileKeymap: max(indexOf(keymaps, m.profile.Keymap), 0),
+	}
+	return m, m.profileInput.Focus()
+}
+
+func (m *Model) handleProfileKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key {
+	case "enter":
+		return m.saveProfile()
+	case "esc":
+		if m.profile.UpdatedAt.IsZero() {
+			// first run: keep whatever they did change, if anything
+			return m.saveProfile()
+		}
+		m.SetTheme(m.profileTheme)
+		return m, gotoScreen(ScreenLaunch)
+	case "tab", "down":
+		m.profileFocus = (m.profileFocus + 1) % profileFields
+	case "shift+tab", "up":
+		m.profileFocus = (m.profileFocus + profileFields - 1) % profileFields
+	case "left", "right":
+		if m.profileFocus == profileName {
+			break
+		}
+		step := 1
+		if key == "left" {
+			step = -1
+		}
+		n := len(m.profileOptions(m.profileFocus))
+		m.profilePick[m.profileFocus] = (m.profilePick[m.profileFocus] + step + n) % n
+		if m.profileFocus == profileTheme {
+			// preview it
+			m.SetTheme(ThemeNames()[m.profilePick[profileTheme]])
+		}
+		return m, nil
+	}
+	if m.profileFocus != profileName {
+		m.profileInput.Blur()
+		return m, nil
+	}
+	if !m.profileInput.Focused() {
+		return m, m.profileInput.Focus()
+	}
+	var cmd tea.Cmd
+	m.profileInput, cmd = m.profileInput.Update(msg)
+	return m, cmd
+}
+
+// saveProfile keeps the choices on the profile screen and goes on to the
+// launch screen. A first-run screen left as it was saves nothing, so a key
+// that only ever connects once leaves no profile behind.
+func (m *Model) saveProfile() (tea.Model, tea.Cmd) {
+	p := room.Profile{
+		Fingerprint: m.fingerprint,
+		DisplayName: cleanDisplayName(m.profileInput.Value()),
+		Keymap:      keymaps[m.profilePick[profileKeymap]],
+	}
+	if i := m.profilePick[profileColor]; i > 0 {
+		p.Color = profileColors[i].name
+	}
+	if i := m.profilePick[profileTheme]; i > 0 {
+		p.Theme = ThemeNames()[i]
+	}
+	if m.profile.UpdatedAt.IsZero() && p == (room.Profile{Fingerprint: m.fingerprint, Keymap: keymapDefault}) {
+		m.SetTheme(m.profileTheme)
+		return m, gotoScreen(ScreenLaunch)
+	}
+	if err := m.roomManager.SaveProfile(p); err != nil {
+		m.log().Error("save profile", "err", err)
+		m.addError("Error: " + err.Error())
+		return m, nil
+	}
+	m.profile, _ = m.roomManager.Profile(m.fingerprint)
+	m.SetTheme(p.Theme)
+	m.addToast("Profile saved (p on this screen changes it)")
+	return m, gotoScreen(ScreenLaunch)
+}
+
+// profileOptions lists the choices for a profile field.
+func (m *Model) profileOptions(field int) []string {
+	switch field {
+	case profileColor:
+		return colorNames()
+	case profileTheme:
+		return ThemeNames()
+	case profileKeymap:
+		return keymaps
+	}
+	return nil
+}
+
+func (m *Model) viewProfile() string {
+	title := m.styles.titleStyle.Render("Your Profile")
+	intro := m.styles.dimStyle.Render("Kept with your key, " + m.fingerprint + ", for your next sessions.")
+	if m.profile.UpdatedAt.IsZero() {
+		intro = m.styles.textStyle.Render("Welcome! Set yourself up once; it's kept with your SSH key.")
+	}
+
+	label := func(field int, text string) string {
+		if m.profileFocus == field {
+			return m.styles.accentStyle.Bold(true).Render("▸ " + text)
+		}
+		return m.styles.dimStyle.Render("  " + text)
+	}
+	choice := func(field int) string {
+		opts := m.profileOptions(field)
+		value := opts[m.profilePick[field]]
+		style := m.styles.textStyle
+		if field == profileColor {
+			style = m.profileColorStyle(m.profilePick[field])
+		}
+		return m.styles.dimStyle.Render("‹ ") + style.Render(value) + m.styles.dimStyle.Render(" ›")
+	}
+
+	keymapNote := "ctrl chords run duet's shortcuts in a room"
+	if keymaps[m.profilePick[profileKeymap]] == keymapReadline {
+		keymapNote = "ctrl+a/e/r/k... reach the shell; ctrl+] then the chord for duet"
+	}
+
+	rows := lipgloss.JoinVertical(lipgloss.Left,
+		label(profileName, "Display name"),
+		m.styles.inputBoxStyle.Render(m.profileInput.View()),
+		"",
+		label(profileColor, "Colour     ")+"  "+choice(profileColor),
+		label(profileTheme, "Theme      ")+"  "+choice(profileTheme),
+		label(profileKeymap, "Keymap     ")+"  "+choice(profileKeymap),
+		m.styles.dimStyle.Render("               "+keymapNote),
+	)
+	esc := "esc back"
+	if m.profile.UpdatedAt.IsZero() {
+		esc = "esc skip"
+	}
+	help := m.styles.helpStyle.Render("tab/↑↓ move • ←/→ change • enter save • " + esc)
+
+	content := lipgloss.JoinVertical(lipgloss.Center, title, "", intro, "", rows, help)
+	return lipgloss.Place(m.width, m.height-1, lipgloss.Center, lipgloss.Center, content)
+}
+
+// profileColorStyle is how a pick from profileColors looks; "auto" shows
+// the colour our username gets anyway.
+func (m *Model) profileColorStyle(i int) lipgloss.Style {
+	if c := profileColors[i].color; c != nil {
+		return m.styles.baseStyle.Foreground(c)
+	}
+	return m.styles.user(m.name)
+}
+
+// userStyle is how name looks everywhere: in the colour they picked in
+// their profile, or the one their username hashes to.
+func (m *Model) userStyle(name string) lipgloss.Style {
+	pick := ""
+	if name == m.username {
+		pick = m.profile.Color
+	} else if m.currentRoom != nil {
+		for _, c := range m.currentRoom.GetClients() {
+			if c.Username == name {
+				pick = c.Color
+				break
+			}
+		}
+	}
+	if i := indexOf(colorNames(), pick); i > 0 {
+		return m.profileColorStyle(i)
+	}
+	return m.styles.user(name)
+}
+
+func colorNames() []string {
+	names := make([]string, len(profileColors))
+	for i, c := range profileColors {
+		names[i] = c.name
+	}
+	return names
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// cleanDisplayName keeps control and format characters (bidi overrides,
+// zero-width joiners and the like) out of a name others see.
+func cleanDisplayName(s string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s))
+}
+
+func newProfileInput() textinput.Model {
+	ti := textinput.New()
+	ti.CharLimit = 32
+	ti.Width = 32
+	ti.Placeholder = "e.g. Ada Lovelace (optional)"
+	return ti
+}
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sandboxRecall walks the room's sandbox history from the ctrl+r prompt:
+// up/down step through it like a shell, and ctrl+r searches it for what's
+// been typed so far, fuzzily and newest first.
+type sandboxRecall struct {
+	index int    // 0 is the newest command; -1 means not recalling
+	draft string // what was typed before recalling started
+	query string // ctrl+r search, empty when not searching
+}
+
+// resetSandboxRecall starts over, e.g. on opening the sandbox prompt.
+func (m *Model) resetSandboxRecall() {
+	m.recall = sandboxRecall{index: -1}
+	m.cmdInput.Prompt = "> "
+}
+
+// handleRecallKey handles history keys in ModeSandbox and reports whether
+// key was one of them.
+func (m *Model) handleRecallKey(key string) bool {
+	if m.currentRoom == nil {
+		return false
+	}
+	history := m.currentRoom.SandboxHistory()
+
+	switch key {
+	case "up":
+		if m.recall.index+1 >= len(history) {
+			return true
+		}
+		if m.recall.index < 0 {
+			m.recall.draft = m.cmdInput.Value()
+		}
+		m.recall.index++
+		m.showRecalled(history)
+	case "down":
+		if m.recall.index < 0 {
+			return true
+		}
+		m.recall.index--
+		m.showRecalled(history)
+	case "ctrl+r":
+		if m.recall.query == "" {
+			m.recall.query = m.cmdInput.Value()
+			if m.recall.index < 0 {
+				m.recall.draft = m.recall.query
+			}
+		}
+		for i := m.recall.index + 1; i < len(history); i++ {
+			if fuzzyMatch(history[len(history)-1-i], m.recall.query) {
+				m.recall.index = i
+				m.showRecalled(history)
+				m.cmdInput.Prompt = "(search `" + m.recall.query + "`) "
+				return true
+			}
+		}
+		m.addToast("No older command matches " + m.recall.query)
+	default:
+		if m.recall.query != "" {
+			// editing the match ends the search but keeps the line
+			m.recall.query = ""
+			m.cmdInput.Prompt = "> "
+		}
+		return false
+	}
+	return true
+}
+
+// showRecalled puts the recalled command, or the draft, in the prompt.
+func (m *Model) showRecalled(history []string) {
+	if m.recall.index < 0 {
+		m.cmdInput.SetValue(m.recall.draft)
+	} else {
+		m.cmdInput.SetValue(history[len(history)-1-m.recall.index])
+	}
+	m.cmdInput.CursorEnd()
+}
+
+// fuzzyMatch reports whether query's characters appear in s in order,
+// ignoring case, so "gtv" finds "go test -v ./...".
+func fuzzyMatch(s, query string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(query) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package ui
+
+import (
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// How soon we try to pick a lost stream up again, doubling each time.
+const (
+	resyncDelay    = 500 * time.Millisecond
+	resyncMaxDelay = 8 * time.Second
+)
+
+// lostStreams is what stopped flowing while we're in a room: the shared
+// terminal's updates, the room's events, or both. While it's set the bottom
+// bar says we're reconnecting rather than leaving a frozen screen.
+type lostStreams struct {
+	since    time.Time
+	terminal bool
+	events   bool
+	tries    int
+}
+
+// lostTerminal notes that the terminal subscription ch was closed. Closing
+// our own when we switch tabs or leave is expected and ignored.
+func (m *Model) lostTerminal(ch chan struct{}) tea.Cmd {
+	if ch != m.termUpdateCh || m.screen != ScreenRoom || m.currentRoom == nil {
+		return nil
+	}
+	m.termUpdateCh = nil
+	first := m.lost == nil
+	m.markLost()
+	m.lost.terminal = true
+	m.log().Warn("terminal updates stopped; resubscribing")
+	if first {
+		return m.resync()
+	}
+	return nil
+}
+
+// lostEvents notes that the room stopped sending us events on ch: it closed
+// the channel without a kick or close event first, or dropped us.
+func (m *Model) lostEvents(ch chan room.RoomEvent) tea.Cmd {
+	if ch != m.eventChan || m.screen != ScreenRoom || m.currentRoom == nil {
+		return nil
+	}
+	m.eventChan = nil
+	first := m.lost == nil
+	m.markLost()
+	m.lost.events = true
+	m.log().Warn("room events stopped; rejoining")
+	if first {
+		return m.resync()
+	}
+	return nil
+}
+
+func (m *Model) markLost() {
+	if m.lost == nil {
+		m.lost = &lostStreams{since: time.Now()}
+	}
+}
+
+// resync tries to pick up whatever was lost: rejoining the room for its
+// events, subscribing to our tab again for the terminal, then redrawing
+// the whole screen. What can't be picked up yet is tried again later.
+func (m *Model) resync() tea.Cmd {
+	lost := m.lost
+	if lost == nil || m.screen != ScreenRoom || m.currentRoom == nil {
+		m.lost = nil
+		return nil
+	}
+	var cmds []tea.Cmd
+	if lost.events {
+		switch err := m.rejoinEvents(); {
+		case errors.Is(err, errTakenOver):
+			return m.takenOver()
+		case errors.Is(err, errRemoved), errors.Is(err, room.ErrRejoinExpired), errors.Is(err, room.ErrBanned):
+			m.lost = nil
+			return m.removedFromRoom("")
+		case errors.Is(err, room.ErrRoomNotFound):
+			m.lost = nil
+			m.currentRoom = nil
+			m.cleanup()
+			m.addToast("The room is gone")
+			return gotoScreen(ScreenLaunch)
+		case err != nil:
+			m.log().Warn("rejoin failed", "err", err)
+		default:
+			lost.events = false
+			cmds = append(cmds, m.listenForRoomEvents())
+		}
+	}
+	if lost.terminal {
+		i := 0
+		if m.terminal != nil {
+			i = max(m.currentRoom.TabIndex(m.terminal), 0)
+		}
+		if t := m.currentRoom.TabTerminal(i); t != nil {
+			lost.terminal = false
+			m.terminal = t
+			m.termUpdateCh = t.Subscribe()
+			m.reportViewSize()
+			m.termContent = t.Render()
+			cmds = append(cmds, m.waitForTerminalUpdate(), m.syncMouse())
+		}
+	}
+
+	if lost.events || lost.terminal {
+		delay := min(resyncDelay<<lost.tries, resyncMaxDelay)
+		lost.tries++
+		cmds = append(cmds, tea.Tick(delay, func(time.Time) tea.Msg { return resyncMsg{} }))
+		return tea.Batch(cmds...)
+	}
+	m.lost = nil
+	m.users = m.getUserList()
+	if lost.tries > 0 {
+		m.addToast("Reconnected")
+	}
+	// whatever was drawn while frozen may be stale anywhere on the screen,
+	// except in the linear view, whose printed lines stay as they are
+	if !m.linear {
+		cmds = append(cmds, tea.ClearScreen)
+	}
+	return tea.Batch(cmds...)
+}
+
+// errTakenOver is rejoinEvents finding another session in the room under
+// our client ID, having rejoined with our token.
+var errTakenOver = errors.New("taken over by another session")
+
+// errRemoved is rejoinEvents finding the room has let us go rather than
+// keeping our place, e.g. a kick whose event we missed.
+var errRemoved = errors.New("removed from the room")
+
+// rejoinEvents gets us back into the room's connections with a fresh event
+// channel, reclaiming our identity. That only works while the room keeps
+// our place as someone who left; anything else means we were removed, and
+// joining afresh would undo a kick.
+func (m *Model) rejoinEvents() error {
+	r, err := m.roomManager.GetRoom(m.roomID)
+	if err != nil {
+		return err
+	}
+	for _, c := range r.GetClients() {
+		if c.ID == m.clientID {
+			// rejoining would only close theirs in turn
+			return errTakenOver
+		}
+	}
+	if !r.Departed(m.clientID) {
+		return errRemoved
+	}
+	m.currentRoom = r
+	return m.register(r, &room.Client{Rejoin: true})
+}
+
+// takenOver takes us back to the launch screen once another session has
+// rejoined the room as us.
+func (m *Model) takenOver() tea.Cmd {
+	m.lost = nil
+	m.currentRoom = nil // the other session is in the room as us now
+	m.eventChan = nil
+	m.cleanup()
+	m.addError("This session was taken over by a rejoin elsewhere")
+	return gotoScreen(ScreenLaunch)
+}
+
+// removedFromRoom takes us back to the launch screen after the room let us
+// go, saying why if it did.
+func (m *Model) removedFromRoom(reason string) tea.Cmd {
+	m.eventChan = nil
+	m.cleanup()
+	if reason == "banned" {
+		m.addToast("You were banned from the room")
+	} else {
+		m.addToast("You were removed from the room")
+	}
+	return gotoScreen(ScreenLaunch)
+}
+
+// lostBanner is the bottom bar's text while streams are lost.
+func (m *Model) lostBanner() string {
+	what := "terminal"
+	switch {
+	case m.lost.events && m.lost.terminal:
+		what = "room and terminal"
+	case m.lost.events:
+		what = "room"
+	}
+	return "⟳ reconnecting… " + what + " stopped updating " + shortDuration(time.Since(m.lost.since)) + " ago"
+}
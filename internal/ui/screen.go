@@ -11,6 +11,9 @@ const (
 	ScreenJoin
 	ScreenRoomCreated // Shows room code for copying before entering room
 	ScreenRoom
+	ScreenLobby    // guests waiting for approval or for the host to start the terminal
+	ScreenPlayback // replaying an asciicast recording
+	ScreenProfile  // display name, colour, theme and keymap, kept by key
 )
 
 // represents the input mode in the room screen
@@ -20,6 +23,12 @@ const (
 	ModeNormal InputMode = iota
 	ModeAI
 	ModeSandbox
+	ModeEnv
+	ModeCommand
+	ModeScroll      // reviewing terminal scrollback
+	ModeCodeBlock   // picking an AI code block to send to the shell
+	ModeConfirmCode // confirming the picked code block
+	ModePoint       // picking terminal rows to point everyone at
 )
 
 // Navigation messages
@@ -38,6 +47,12 @@ type RoomJoinedMsg struct {
 	Room   *room.Room
 }
 
+// KnockSentMsg means we are waiting for the host to admit us
+type KnockSentMsg struct {
+	RoomID string
+	Room   *room.Room
+}
+
 // Toast/notification messages
 
 type ToastMsg struct {
@@ -56,8 +71,10 @@ type AIResponseMsg struct {
 }
 
 type SandboxResultMsg struct {
-	Output string
+	Output string // stdout, or stderr when there was none
 	Cmd    string
+	Stdout string
+	Stderr string
 }
 
 // Timer messages
@@ -66,9 +83,33 @@ type tickMsg struct{}
 
 // Terminal messages
 
-type terminalUpdateMsg struct{}
+// terminalUpdateMsg carries the subscription it came from so updates from a
+// tab we've since switched away from can be dropped.
+type terminalUpdateMsg struct {
+	ch chan struct{}
+}
+
+// terminalClosedMsg says the subscription ch was closed under us; see
+// resync.go.
+type terminalClosedMsg struct {
+	ch chan struct{}
+}
+
+// tabOpenedMsg switches to a freshly opened terminal tab
+type tabOpenedMsg struct {
+	index int
+}
 
 // Room event message (from event channel)
 type roomEventMsg struct {
 	Event room.RoomEvent
 }
+
+// eventsClosedMsg says the room closed our event channel ch without
+// telling us why first; see resync.go.
+type eventsClosedMsg struct {
+	ch chan room.RoomEvent
+}
+
+// resyncMsg is the timer for another try at picking up lost streams.
+type resyncMsg struct{}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// scrollPageHeight is how many terminal lines fit in the pane, matching the
+// size startTerminal gives the PTY.
+func (m *Model) scrollPageHeight() int {
+	_, _, _, h := m.termPane()
+	return max(h, 1)
+}
+
+// enterScrollMode freezes the terminal pane on its scrollback so output that
+// has scrolled off can be reviewed (and selected with the mouse, with shift
+// while duet has it, since the history is plain text).
+func (m *Model) enterScrollMode() {
+	if m.terminal == nil {
+		return
+	}
+	if m.linear {
+		m.addToast("The linear view leaves everything in your terminal's own scrollback")
+		return
+	}
+	m.inputMode = ModeScroll
+	m.scrollOffset = 0
+}
+
+func (m *Model) handleScrollKey(key string) {
+	page := m.scrollPageHeight()
+	switch key {
+	case "pgup", "ctrl+b":
+		m.scrollBy(page)
+	case "pgdown", "ctrl+f":
+		m.scrollBy(-page)
+	case "up", "k":
+		m.scrollBy(1)
+	case "down", "j":
+		m.scrollBy(-1)
+	case "home", "g":
+		_, total := m.terminal.Scrollback(0, 0)
+		m.scrollBy(total)
+	case "end", "G":
+		m.scrollOffset = 0
+	case "esc", "q", "f6":
+		m.inputMode = ModeNormal
+		m.scrollOffset = 0
+	}
+}
+
+// scrollBy moves the view delta lines further back in history.
+func (m *Model) scrollBy(delta int) {
+	if m.terminal == nil {
+		return
+	}
+	_, total := m.terminal.Scrollback(0, 0)
+	maxOffset := max(total-m.scrollPageHeight(), 0)
+	m.scrollOffset = min(max(m.scrollOffset+delta, 0), maxOffset)
+}
+
+// renderScrollback draws the page of history the user is looking at.
+func (m *Model) renderScrollback(w int) (header, content string) {
+	page := m.scrollPageHeight()
+	lines, total := m.terminal.Scrollback(page, m.scrollOffset)
+
+	for i, l := range lines {
+		lines[i] = truncate(l, w-4)
+	}
+	if pad := page - len(lines); pad > 0 {
+		lines = append(make([]string, pad), lines...)
+	}
+
+	header = fmt.Sprintf("shared terminal · scrollback %d/%d", total-m.scrollOffset, total)
+	return header, strings.Join(lines, "\n")
+}
@@ -1,18 +1,15 @@
 package ui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"hash/fnv"
 
-const (
-	colorAccent  = lipgloss.Color("6") // Cyan (ANSI 6)
-	colorDim     = lipgloss.Color("8") // Bright black/gray (ANSI 8)
-	colorText    = lipgloss.Color("7") // White (ANSI 7)
-	colorBorder  = lipgloss.Color("8") // Bright black/gray (ANSI 8)
-	colorError   = lipgloss.Color("1") // Red (ANSI 1)
-	colorSuccess = lipgloss.Color("2") // Green (ANSI 2)
+	"github.com/charmbracelet/lipgloss"
 )
 
 // Styles struct holds renderer-aware styles for a session
 type Styles struct {
+	theme Theme
+
 	baseStyle        lipgloss.Style
 	titleStyle       lipgloss.Style
 	textStyle        lipgloss.Style
@@ -32,15 +29,19 @@ type Styles struct {
 	bottomBarStyle   lipgloss.Style
 }
 
-// NewStyles creates renderer-aware styles for the given renderer
-func NewStyles(renderer *lipgloss.Renderer) *Styles {
+// NewStyles creates renderer-aware styles in theme's colours for the given
+// renderer
+func NewStyles(renderer *lipgloss.Renderer, theme Theme) *Styles {
 	if renderer == nil {
 		renderer = lipgloss.DefaultRenderer()
 	}
 
 	baseStyle := renderer.NewStyle()
+	colorAccent, colorDim, colorText := theme.Accent, theme.Dim, theme.Text
+	colorBorder, colorError, colorSuccess := theme.Border, theme.Error, theme.Success
 
 	return &Styles{
+		theme:     theme,
 		baseStyle: baseStyle,
 		titleStyle: baseStyle.
 			Foreground(colorAccent).
@@ -102,6 +103,18 @@ func NewStyles(renderer *lipgloss.Renderer) *Styles {
 	}
 }
 
+// user styles a username in its own colour from the theme's Users. The
+// colour comes from a hash of the name, so everyone sees the same person
+// in the same colour wherever they're named, in every session.
+func (s *Styles) user(name string) lipgloss.Style {
+	if len(s.theme.Users) == 0 {
+		return s.accentStyle
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return s.baseStyle.Foreground(s.theme.Users[h.Sum32()%uint32(len(s.theme.Users))])
+}
+
 // ASCII art for landing
 var asciiLogo = `
     ██████╗ ██╗   ██╗███████╗████████╗
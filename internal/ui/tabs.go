@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaypopat/duet/internal/room"
+	"github.com/jaypopat/duet/internal/terminal"
+)
+
+// switchTab points this client at tab i. Other clients keep whatever tab
+// they're on.
+func (m *Model) switchTab(i int) tea.Cmd {
+	if m.currentRoom == nil {
+		return nil
+	}
+	t := m.currentRoom.TabTerminal(i)
+	if t == nil {
+		m.addToast(fmt.Sprintf("No tab %d", i+1))
+		return nil
+	}
+	if t == m.terminal && m.termUpdateCh != nil {
+		return nil
+	}
+
+	if m.terminal != nil && m.termUpdateCh != nil {
+		// closes the channel, which ends the old waiter
+		m.terminal.Unsubscribe(m.termUpdateCh)
+	}
+	m.terminal = t
+	m.inputMode = ModeNormal
+	m.scrollOffset = 0
+
+	m.termUpdateCh = m.terminal.Subscribe()
+	m.reportViewSize()
+	m.termContent = m.terminal.Render()
+	return tea.Batch(m.waitForTerminalUpdate(), m.syncMouse())
+}
+
+// openTab starts another shell and adds it to the room's tabs.
+func (m *Model) openTab(title string) tea.Cmd {
+	r := m.currentRoom
+	if r == nil || r.GetTerminal() == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		t, err := m.newShell(false)
+		if err != nil {
+			return ErrorMsg{err}
+		}
+		i, err := r.AddTab(title, t, m.username)
+		if err != nil {
+			t.Close()
+			return ErrorMsg{err}
+		}
+		return tabOpenedMsg{index: i}
+	}
+}
+
+// tabCommand handles ":tab new [title]", ":tab close [n]",
+// ":tab rename <title>" and ":tab <n>".
+func (m *Model) tabCommand(args []string) tea.Cmd {
+	if m.currentRoom == nil {
+		return nil
+	}
+	if len(args) == 0 {
+		m.addToast("Usage: tab new [title] | close [n] | rename <title> | <n>")
+		return nil
+	}
+
+	current := m.currentRoom.TabIndex(m.terminal)
+	switch args[0] {
+	case "new":
+		return m.openTab(strings.Join(args[1:], " "))
+	case "close":
+		if !m.isHost {
+			m.addToast("Only the host can close tabs")
+			return nil
+		}
+		i := current
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				m.addToast("Usage: tab close [n]")
+				return nil
+			}
+			i = n - 1
+		}
+		if err := m.currentRoom.CloseTab(i, m.username); err != nil {
+			if errors.Is(err, room.ErrCloseMainTab) {
+				m.addToast("Tab 1 is the main terminal and can't be closed")
+				return nil
+			}
+			m.addError("Error: " + err.Error())
+		}
+		return nil
+	case "rename":
+		if !m.isHost {
+			m.addToast("Only the host can rename tabs")
+			return nil
+		}
+		if err := m.currentRoom.RenameTab(current, strings.Join(args[1:], " "), m.username); err != nil {
+			m.addError("Error: " + err.Error())
+		}
+		return nil
+	default:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			m.addToast("Usage: tab new [title] | close [n] | rename <title> | <n>")
+			return nil
+		}
+		return m.switchTab(n - 1)
+	}
+}
+
+// restartShell brings the shell in our current tab back after it exited.
+func (m *Model) restartShell() {
+	if m.currentRoom == nil || m.terminal == nil {
+		return
+	}
+	if !m.isHost {
+		m.addToast("Only the host can restart the shell")
+		return
+	}
+	if err := m.currentRoom.RestartTab(m.currentRoom.TabIndex(m.terminal), m.username); err != nil {
+		if errors.Is(err, terminal.ErrStillRunning) {
+			m.addToast("The shell is still running")
+			return
+		}
+		m.addError("Error: " + err.Error())
+	}
+}
+
+// renderTabStrip shows the room's tabs with ours highlighted, or the plain
+// pane title when there's only one.
+func (m *Model) renderTabStrip(w int) string {
+	if m.currentRoom == nil {
+		return m.styles.titleStyle.Render("shared terminal")
+	}
+	tabs := m.currentRoom.Tabs()
+	if len(tabs) <= 1 {
+		return m.styles.titleStyle.Render("shared terminal")
+	}
+
+	current := m.currentRoom.TabIndex(m.terminal)
+	var parts []string
+	for _, tab := range tabs {
+		label := fmt.Sprintf(" %d:%s ", tab.Index+1, tab.Title)
+		if tab.Index == current {
+			parts = append(parts, m.styles.titleStyle.Render(label))
+		} else {
+			parts = append(parts, m.styles.dimStyle.Render(label))
+		}
+	}
+	return truncate(strings.Join(parts, " "), w-2)
+}
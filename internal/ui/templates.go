@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaypopat/duet/internal/room"
+)
+
+// offerTemplates lets tab complete "/name" in the AI prompt from the
+// room's prompt templates.
+func (m *Model) offerTemplates() {
+	if m.currentRoom == nil {
+		m.cmdInput.ShowSuggestions = false
+		return
+	}
+	names := m.currentRoom.TemplateNames()
+	suggestions := make([]string, len(names))
+	for i, name := range names {
+		suggestions[i] = "/" + name + " "
+	}
+	m.cmdInput.SetSuggestions(suggestions)
+	m.cmdInput.ShowSuggestions = len(suggestions) > 0
+}
+
+// expandTemplate turns "/name rest" typed into the AI prompt into the
+// template's text, with rest as its {selection}. Without rest, the last
+// command's output is the selection. Anything else is sent as typed; ok is
+// false when the template doesn't exist.
+func (m *Model) expandTemplate(text string) (prompt string, ok bool) {
+	if !strings.HasPrefix(text, "/") || m.currentRoom == nil {
+		return text, true
+	}
+	name, rest, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	tmpl, found := m.currentRoom.Template(name)
+	if !found {
+		m.addToast(fmt.Sprintf("No template named %s (ctrl+] template lists them)", name))
+		return "", false
+	}
+	selection := strings.TrimSpace(rest)
+	if selection == "" {
+		selection = "```\n" + m.lastOutput() + "\n```"
+	}
+	return room.ExpandTemplate(tmpl, selection), true
+}
+
+// templateCommand lists the room's prompt templates, or lets the host
+// define (template set <name> <text>) and remove (template rm <name>) them.
+func (m *Model) templateCommand(args []string) {
+	if m.currentRoom == nil {
+		return
+	}
+	if len(args) == 0 || args[0] == "list" {
+		names := m.currentRoom.TemplateNames()
+		if len(names) == 0 {
+			m.addToast("No prompt templates (template set <name> <text>)")
+			return
+		}
+		m.addToastFor("templates: /"+strings.Join(names, " /"), 5*time.Second)
+		return
+	}
+	if !m.isHost {
+		m.addToast("Only the host can change prompt templates")
+		return
+	}
+
+	var change string
+	switch {
+	case args[0] == "set" && len(args) >= 3:
+		if err := m.currentRoom.SetTemplate(args[1], strings.Join(args[2:], " ")); err != nil {
+			m.addError("Error: " + err.Error())
+			return
+		}
+		change = "set template /" + strings.ToLower(args[1])
+	case args[0] == "rm" && len(args) == 2:
+		if err := m.currentRoom.DeleteTemplate(args[1]); err != nil {
+			if errors.Is(err, room.ErrTemplateNotFound) {
+				m.addToast(fmt.Sprintf("No template named %s", args[1]))
+				return
+			}
+			m.addError("Error: " + err.Error())
+			return
+		}
+		change = "removed template /" + strings.ToLower(args[1])
+	default:
+		m.addToast("Usage: template [list] • template set <name> <text> • template rm <name>")
+		return
+	}
+
+	m.addToast("Prompt " + change)
+	m.currentRoom.BroadcastEvent(room.RoomEvent{
+		Type:     "templates",
+		Username: m.username,
+		Data:     change,
+	}, m.clientID)
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Text in the UI is measured in terminal cells, not bytes or runes: it
+// carries ANSI styling, and CJK characters and emoji take two cells each.
+// Views fit it with these, so a cut never splits an escape sequence or a
+// grapheme cluster.
+
+// textWidth is how many cells s takes on screen.
+func textWidth(s string) int {
+	return ansi.StringWidth(s)
+}
+
+// truncate cuts s to at most max display cells without splitting grapheme
+// clusters, so wide (CJK) characters and emoji sequences never end up broken.
+func truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	return ansi.Truncate(s, max, "")
+}
+
+// ellipsize is truncate ending in "…" when anything was cut, for names and
+// messages where a silent cut would read as the whole thing.
+func ellipsize(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	return ansi.Truncate(s, max, "…")
+}
+
+// wrapLines word-wraps s to width cells, breaking words that don't fit on
+// a line of their own (unspaced CJK, long paths). Styling isn't carried
+// over a break, so wrap plain text and style each line.
+func wrapLines(s string, width int) []string {
+	return strings.Split(ansi.Wrap(s, max(width, 1), ""), "\n")
+}
+
+// padRight fills s out with spaces to width cells.
+func padRight(s string, width int) string {
+	if w := textWidth(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
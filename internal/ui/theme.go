@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is the palette every style is built from. Markdown names the
+// glamour style AI replies are rendered with: "dark" or "light". Users
+// are distinct colours for telling people apart; none should be Accent.
+type Theme struct {
+	Name     string
+	Accent   lipgloss.TerminalColor
+	Dim      lipgloss.TerminalColor
+	Text     lipgloss.TerminalColor
+	Border   lipgloss.TerminalColor
+	Error    lipgloss.TerminalColor
+	Success  lipgloss.TerminalColor
+	Markdown string
+	Users    []lipgloss.TerminalColor // one per participant, see Styles.user
+}
+
+// DefaultTheme picks dark or light from the client's background.
+const DefaultTheme = "auto"
+
+// themes are the palettes people can pick by name. The ANSI ones follow
+// the client's own terminal colours; solarized is fixed.
+var themes = map[string]Theme{
+	"dark": {
+		Accent:   lipgloss.Color("6"), // cyan
+		Dim:      lipgloss.Color("8"), // bright black
+		Text:     lipgloss.Color("7"), // white
+		Border:   lipgloss.Color("8"),
+		Error:    lipgloss.Color("1"),
+		Success:  lipgloss.Color("2"),
+		Markdown: "dark",
+		Users:    colors("203", "214", "114", "81", "177", "221", "75", "209", "156", "219"),
+	},
+	"light": {
+		Accent:   lipgloss.Color("4"), // blue reads better than cyan on white
+		Dim:      lipgloss.Color("8"),
+		Text:     lipgloss.Color("0"), // black
+		Border:   lipgloss.Color("8"),
+		Error:    lipgloss.Color("1"),
+		Success:  lipgloss.Color("2"),
+		Markdown: "light",
+		Users:    colors("160", "166", "28", "25", "91", "130", "31", "127", "64", "55"),
+	},
+	"solarized": {
+		Accent:   lipgloss.Color("#2aa198"), // cyan
+		Dim:      lipgloss.Color("#586e75"), // base01
+		Text:     lipgloss.Color("#93a1a1"), // base1
+		Border:   lipgloss.Color("#586e75"),
+		Error:    lipgloss.Color("#dc322f"),
+		Success:  lipgloss.Color("#859900"),
+		Markdown: "dark",
+		Users:    colors("#b58900", "#cb4b16", "#dc322f", "#d33682", "#6c71c4", "#268bd2", "#859900"),
+	},
+	"high-contrast": {
+		Accent:   lipgloss.Color("11"), // bright yellow
+		Dim:      lipgloss.Color("7"),  // no greys: everything stays legible
+		Text:     lipgloss.Color("15"), // bright white
+		Border:   lipgloss.Color("15"),
+		Error:    lipgloss.Color("9"),
+		Success:  lipgloss.Color("10"),
+		Markdown: "dark",
+		Users:    colors("9", "10", "12", "13", "14", "15"),
+	},
+}
+
+func colors(codes ...string) []lipgloss.TerminalColor {
+	out := make([]lipgloss.TerminalColor, len(codes))
+	for i, c := range codes {
+		out[i] = lipgloss.Color(c)
+	}
+	return out
+}
+
+// ThemeNames lists the names LookupTheme accepts, DefaultTheme first.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes)+1)
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultTheme}, names...)
+}
+
+// LookupTheme returns the named theme for a client, resolving
+// DefaultTheme (and "") from whether its background is dark.
+func LookupTheme(name string, renderer *lipgloss.Renderer) (Theme, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == DefaultTheme {
+		name = "light"
+		if renderer == nil || renderer.HasDarkBackground() {
+			name = "dark"
+		}
+	}
+	t, ok := themes[name]
+	t.Name = name
+	return t, ok
+}
+
+// ValidTheme reports whether name is one LookupTheme knows.
+func ValidTheme(name string) bool {
+	_, ok := LookupTheme(name, nil)
+	return ok
+}
+
+// SetTheme switches the session to the named theme, reporting false (and
+// changing nothing) for a name LookupTheme doesn't know.
+func (m *Model) SetTheme(name string) bool {
+	theme, ok := LookupTheme(name, m.renderer)
+	if !ok {
+		return false
+	}
+	m.styles = NewStyles(m.renderer, theme)
+	m.aiSpinner.Style = m.styles.accentStyle
+	m.markdown = markdown{} // rebuilt in the new style on next render
+	if m.currentRoom != nil {
+		m.syncAIViewportContent()
+	}
+	return true
+}
+
+// SetTheme switches the dashboard to the named theme, like Model.SetTheme.
+func (a *Admin) SetTheme(name string) bool {
+	theme, ok := LookupTheme(name, a.renderer)
+	if !ok {
+		return false
+	}
+	a.styles = NewStyles(a.renderer, theme)
+	return true
+}
+
+// themeCommand shows or switches this session's theme.
+func (m *Model) themeCommand(args []string) {
+	if len(args) == 0 {
+		m.addToastFor("theme: "+m.styles.theme.Name+" (pick from "+strings.Join(ThemeNames(), ", ")+")", 5*time.Second)
+		return
+	}
+	if !m.SetTheme(args[0]) {
+		m.addToast("Unknown theme; pick from " + strings.Join(ThemeNames(), ", "))
+		return
+	}
+	m.addToast("Theme: " + m.styles.theme.Name)
+}
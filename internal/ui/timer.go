@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	defaultTimer = 25 * time.Minute
+	maxTimer     = 8 * time.Hour
+)
+
+// timerCommand handles ":timer [duration|start|stop]": with no argument it
+// says how long is left, "start" is a default pomodoro.
+func (m *Model) timerCommand(args []string) {
+	if m.currentRoom == nil {
+		return
+	}
+	if len(args) == 0 {
+		end, total, by, ok := m.currentRoom.Timer()
+		if !ok {
+			m.addToast("No timer running; timer <duration> starts one, e.g. timer 25m")
+			return
+		}
+		m.addToastFor(fmt.Sprintf("%s left of %s's %s timer", shortDuration(time.Until(end)), by, total), 3*time.Second)
+		return
+	}
+	if len(args) != 1 {
+		m.addToast("Usage: timer [duration|start|stop]")
+		return
+	}
+	switch args[0] {
+	case "stop":
+		if !m.currentRoom.StopTimer(m.username) {
+			m.addToast("No timer running")
+		}
+		return
+	case "start":
+		args[0] = defaultTimer.String()
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		// "timer 25" means minutes
+		mins, convErr := strconv.Atoi(args[0])
+		if convErr != nil {
+			m.addToast("Usage: timer [duration|start|stop], e.g. timer 25m")
+			return
+		}
+		d = time.Duration(mins) * time.Minute
+	}
+	if d < time.Second || d > maxTimer {
+		m.addToast("Timers run from 1s to 8h")
+		return
+	}
+	m.currentRoom.StartTimer(d, m.username)
+}
+
+// timerStatus is the countdown for the status bar, empty when no timer
+// is running.
+func (m *Model) timerStatus() string {
+	if m.currentRoom == nil {
+		return ""
+	}
+	end, _, _, ok := m.currentRoom.Timer()
+	if !ok {
+		return ""
+	}
+	left := max(time.Until(end).Round(time.Second), 0)
+	return fmt.Sprintf("⏱ %02d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+}
+
+// onTimerDone tells everyone the shared timer ran out, rings included:
+// it's a cue to swap seats.
+func (m *Model) onTimerDone(total string) tea.Cmd {
+	m.flashUntil = time.Now().Add(mentionFlash)
+	m.addToastFor(fmt.Sprintf("Time's up: the %s timer ran out", total), 5*time.Second)
+	return m.ring("the " + total + " timer ran out")
+}
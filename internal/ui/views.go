@@ -3,38 +3,63 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/reflow/wordwrap"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/room"
 )
 
 func (m *Model) viewLaunch() string {
 	logo := m.styles.logoStyle.Render(asciiLogo)
 
-	createBtn := m.styles.buttonStyle.Render("Create Room  (c)")
-	joinBtn := m.styles.buttonStyle.Render("Join Room    (J)")
-
-	if m.selected == 0 {
-		createBtn = m.styles.buttonActive.Render("Create Room  (c)")
-	} else {
-		joinBtn = m.styles.buttonActive.Render("Join Room    (J)")
+	// the keys line up however long the translated labels are
+	labelW := max(textWidth(m.tr("launch.create")), textWidth(m.tr("launch.join")))
+	createLabel := padRight(m.tr("launch.create"), labelW) + "  (c)"
+	joinLabel := padRight(m.tr("launch.join"), labelW) + "  (J)"
+	createBtn := m.styles.buttonStyle.Render(createLabel)
+	joinBtn := m.styles.buttonStyle.Render(joinLabel)
+
+	switch m.selected {
+	case 0:
+		createBtn = m.styles.buttonActive.Render(createLabel)
+	case 1:
+		joinBtn = m.styles.buttonActive.Render(joinLabel)
 	}
 
 	buttons := lipgloss.JoinVertical(lipgloss.Center, createBtn, joinBtn)
-	help := m.styles.helpStyle.Render("↑/↓ select • enter confirm • q quit")
-	content := lipgloss.JoinVertical(lipgloss.Center, logo, buttons, help)
+	help := m.styles.helpStyle.Render(m.tr("launch.help"))
+	rooms := m.renderRoomList()
+
+	// e.g. why we were sent back here from a room
+	var toastLine string
+	if len(m.toasts) > 0 {
+		toastLine = m.styles.accentStyle.Render("▸ " + m.toasts[len(m.toasts)-1].text)
+	}
+	content := lipgloss.JoinVertical(lipgloss.Center, logo, buttons, "", rooms, toastLine, help)
 
 	return lipgloss.Place(m.width, m.height-1, lipgloss.Center, lipgloss.Center, content)
 }
 
 func (m *Model) viewCreate() string {
-	title := m.styles.titleStyle.Render("Create Room")
-	prompt := m.styles.textStyle.Render("Enter a description for your room:")
+	title := m.styles.titleStyle.Render(m.tr("launch.create"))
+	prompt := m.styles.textStyle.Render(m.tr("create.prompt"))
 	input := m.styles.inputBoxStyle.Render(m.input.View())
-	help := m.styles.helpStyle.Render("enter create • esc back")
+	capInput := m.styles.inputBoxStyle.Render(m.capInput.View())
+	tagsInput := m.styles.inputBoxStyle.Render(m.tagsInput.View())
+	nameInput := m.styles.inputBoxStyle.Render(m.nameInput.View())
+	shellInput := m.styles.inputBoxStyle.Render(m.shellInput.View())
+	dirInput := m.styles.inputBoxStyle.Render(m.dirInput.View())
+	envInput := m.styles.inputBoxStyle.Render(m.envInput.View())
+	var tmuxInput string
+	if m.roomManager.TmuxAllowed() {
+		tmuxInput = m.styles.inputBoxStyle.Render(m.tmuxInput.View())
+	}
+	help := m.styles.helpStyle.Render(m.tr("create.help"))
 
 	content := lipgloss.JoinVertical(lipgloss.Center,
-		title, "", prompt, "", input, help,
+		title, "", prompt, "", input, capInput, tagsInput, nameInput, shellInput, dirInput, envInput, tmuxInput, help,
 	)
 
 	view := lipgloss.Place(m.width, m.height-1, lipgloss.Center, lipgloss.Center, content)
@@ -43,10 +68,10 @@ func (m *Model) viewCreate() string {
 }
 
 func (m *Model) viewJoin() string {
-	title := m.styles.titleStyle.Render("Join Room")
-	prompt := m.styles.textStyle.Render("Enter the room ID:")
+	title := m.styles.titleStyle.Render(m.tr("launch.join"))
+	prompt := m.styles.textStyle.Render(m.tr("join.prompt"))
 	input := m.styles.inputBoxStyle.Render(m.input.View())
-	help := m.styles.helpStyle.Render("enter join • esc back")
+	help := m.styles.helpStyle.Render(m.tr("join.help"))
 
 	// if room doesnt exist we show the toast
 	var errorLine string
@@ -63,46 +88,104 @@ func (m *Model) viewJoin() string {
 	return view
 }
 
+// viewLobby is shown to guests waiting for approval or for the host to start
+// the shared terminal. The tick message keeps the elapsed time live.
+func (m *Model) viewLobby() string {
+	title := m.styles.titleStyle.Render(m.tr("lobby.title"))
+
+	r := m.currentRoom
+	if r == nil {
+		r = m.pendingRoom
+	}
+
+	var info []string
+	if r != nil {
+		roomInfo := r.Info()
+		if roomInfo.Description != "" {
+			info = append(info, m.styles.textStyle.Render("\""+roomInfo.Description+"\""))
+		}
+		info = append(info, m.styles.dimStyle.Render(m.tr("lobby.host"))+m.styles.accentStyle.Render(roomInfo.HostName))
+		info = append(info, m.styles.dimStyle.Render(m.trn("lobby.count", len(roomInfo.Participants))))
+	}
+
+	var status string
+	help := m.tr("lobby.leave")
+	switch {
+	case m.pendingRoom != nil:
+		status = m.tr("lobby.knocked")
+		help = m.tr("lobby.cancel")
+	case r != nil && !r.HostPresent():
+		status = m.tr("lobby.noHost")
+		help = m.tr("lobby.start")
+	default:
+		status = m.tr("lobby.noShell")
+	}
+	elapsed := time.Since(m.lobbySince).Round(time.Second)
+
+	content := lipgloss.JoinVertical(lipgloss.Center,
+		title, "",
+		lipgloss.JoinVertical(lipgloss.Center, info...), "",
+		m.styles.accentStyle.Render(status),
+		m.styles.dimStyle.Render(m.tr("lobby.waiting", elapsed)),
+		m.styles.helpStyle.Render(help),
+	)
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+}
+
 func (m *Model) viewRoomCreated() string {
-	title := m.styles.titleStyle.Render("Room Created!")
+	title := m.styles.titleStyle.Render(m.tr("created.title"))
 
 	// Room code box - for easy copying
-	codeLabel := m.styles.dimStyle.Render("Share this code with others to join:")
+	codeLabel := m.styles.dimStyle.Render(m.tr("created.share"))
 	codeBox := m.styles.baseStyle.
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(colorAccent).
+		BorderForeground(m.styles.theme.Accent).
 		Padding(1, 3).
 		Bold(true).
-		Foreground(colorSuccess).
+		Foreground(m.styles.theme.Success).
 		Render(m.roomID)
 
-	hint := m.styles.dimStyle.Render("(select and copy the code above)")
-	help := m.styles.helpStyle.Render("enter → enter room • esc back")
+	hint := m.styles.dimStyle.Render(m.tr("created.hint")) + "\n" +
+		m.styles.textStyle.Render(m.roomManager.JoinCommand(m.roomID))
+	help := m.styles.helpStyle.Render(m.tr("created.help"))
+
+	var tokenLine string
+	if m.rejoinToken != "" {
+		tokenLine = m.styles.dimStyle.Render(m.tr("created.rejoin")+"\n") +
+			m.styles.textStyle.Render(m.rejoinToken)
+	}
 
 	content := lipgloss.JoinVertical(lipgloss.Center,
-		title, "", codeLabel, "", codeBox, "", hint, "", help,
+		title, "", codeLabel, "", codeBox, "", hint, "", tokenLine, help,
 	)
 
 	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
 }
 
 func (m *Model) viewRoom() string {
-	if m.width < MinWidthForSidebar || m.height < MinHeightForSidebar {
-		return m.viewResizePrompt()
+	if m.zen {
+		return m.viewZen()
+	}
+	if m.compact() {
+		return m.viewCompact()
 	}
 
 	sidebarW, terminalW, aiSidebarW, mainHeight := m.roomLayout()
 
-	sidebar := m.renderSidebar(sidebarW, mainHeight)
-	terminal := m.renderTerminal(terminalW, mainHeight)
-
-	var main string
+	var panes []string
+	if m.showUsers {
+		panes = append(panes, m.renderSidebar(sidebarW, mainHeight))
+	}
+	panes = append(panes, m.renderTerminal(terminalW, mainHeight))
 	if m.showAISidebar {
-		aiPanel := m.renderAISidebar(aiSidebarW, mainHeight)
-		main = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, terminal, aiPanel)
-	} else {
-		main = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, terminal)
+		if m.aiBadge {
+			panes = append(panes, m.renderAIBadge(aiSidebarW, mainHeight))
+		} else {
+			panes = append(panes, m.renderAISidebar(aiSidebarW, mainHeight))
+		}
 	}
+	main := lipgloss.JoinHorizontal(lipgloss.Top, panes...)
 
 	// bottom bar (vim-like): input bar or toasts
 	bottom := m.renderBottomBar()
@@ -111,134 +194,381 @@ func (m *Model) viewRoom() string {
 	return lipgloss.JoinVertical(lipgloss.Left, main, bottom)
 }
 
+// viewZen is the room with nothing but the terminal. The bottom bar only
+// comes back, over the last row, while there's a prompt or a toast, so
+// the shell's size doesn't change under it.
+func (m *Model) viewZen() string {
+	content := m.highlightPointed(m.termContent)
+	if m.inputMode == ModeScroll && m.terminal != nil {
+		_, content = m.renderScrollback(m.width + 4)
+	}
+	lines := strings.Split(content, "\n")
+	for len(lines) < m.height {
+		lines = append(lines, "")
+	}
+	lines = lines[:m.height]
+	if len(m.toasts) > 0 || m.inputMode != ModeNormal || m.lost != nil {
+		lines[m.height-1] = m.renderBottomBar()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sidebarKeys are the sidebar's key reminders: the key, then the catalog
+// key of what it does. A row without a key continues the one above.
+var sidebarKeys = [][2]string{
+	{"f1", "keys.help"},
+	{"ctrl+g", "keys.ai"},
+	{"^] ^e", "keys.explain"},
+	{"ctrl+a", "keys.toggleAI"},
+	{"^] alt+u", "keys.toggleUsers"},
+	{"ctrl+j/k", "keys.scrollAI"},
+	{"ctrl+r", "keys.run"},
+	{"alt+e", "keys.explainRun"},
+	{"ctrl+o", "keys.sendCode"},
+	{"ctrl+]", "keys.command"},
+	{"", "keys.token"},
+	{"f2/f5", "keys.env"},
+	{"f3/f4", "keys.macro"},
+	{"pgup/f6", "keys.scrollback"},
+	{"alt+1-9", "keys.tab"},
+	{"f9", "keys.restart"},
+	{"f10", "keys.raw"},
+	{"ctrl+l", "keys.leave"},
+}
+
+// usersRows is how many sidebar rows each person in the users list takes.
+const usersRows = 2
+
+// renderUserDetail is the users list's second row for u: their role, how
+// they're connected and how long they've been in the room.
+func (m *Model) renderUserDetail(u participant, w int) string {
+	dim := m.styles.dimStyle
+	status := dim.Render(m.tr("status." + u.status))
+	if u.status == room.StatusReconnecting {
+		status = m.styles.accentStyle.Render(m.tr("status." + u.status))
+	}
+	line := dim.Render("    "+m.tr("role."+u.role)+" · ") + status
+	if !u.joined.IsZero() {
+		line += dim.Render(" · " + shortDuration(time.Since(u.joined)))
+	}
+	return ellipsize(line, w)
+}
+
 func (m *Model) renderSidebar(w, h int) string {
 	var b strings.Builder
 
-	youLabel := m.styles.dimStyle.Render("you: ")
-	youName := m.styles.accentStyle.Bold(true).Render(m.username)
-	b.WriteString(youLabel + youName + "\n\n")
+	// labels are measured, not counted, as translations differ in width
+	youLabel := m.tr("side.you")
+	you := m.username
+	if m.profile.DisplayName != "" {
+		you = m.profile.DisplayName
+	}
+	youName := m.userStyle(m.username).Bold(true).Render(ellipsize(you, w-2-textWidth(youLabel)))
+	b.WriteString(m.styles.dimStyle.Render(youLabel) + youName + "\n\n")
 
-	roomLabel := m.styles.dimStyle.Render("room: ")
-	roomID := m.styles.textStyle.Render(truncate(m.roomID, w-8))
-	b.WriteString(roomLabel + roomID + "\n")
+	roomLabel := m.tr("side.room")
+	roomID := m.styles.textStyle.Render(ellipsize(m.roomID, w-2-textWidth(roomLabel)))
+	b.WriteString(m.styles.dimStyle.Render(roomLabel) + roomID + "\n")
 
-	if m.currentRoom != nil && m.currentRoom.Description != "" {
-		desc := m.currentRoom.Description
-		if len(desc) > w-4 {
-			desc = truncate(desc, w-4)
-		}
+	if desc := m.roomDescription(); desc != "" {
+		desc = ellipsize(desc, w-10)
 		descText := m.styles.dimStyle.Render("      " + "\"" + desc + "\"")
 		b.WriteString(descText + "\n")
 	}
+	if m.currentRoom != nil && len(m.currentRoom.Tags) > 0 {
+		tags := ellipsize("#"+strings.Join(m.currentRoom.Tags, " #"), w-4)
+		b.WriteString(m.styles.accentStyle.Render(tags) + "\n")
+	}
+	if status := m.shellStatus(); status != "" {
+		label := m.tr("side.shell")
+		b.WriteString(m.styles.dimStyle.Render(label) + m.styles.textStyle.Render(ellipsize(status, w-2-textWidth(label))) + "\n")
+	}
 	b.WriteString(m.styles.dimStyle.Render(strings.Repeat("─", w-2)) + "\n\n")
 
 	// Users
-	usersLabel := m.styles.dimStyle.Render(fmt.Sprintf("connected (%d):", len(m.users)))
+	usersLabel := m.styles.dimStyle.Render(ellipsize(m.trn("side.connected", len(m.users)), w-2))
 	b.WriteString(usersLabel + "\n")
-	for _, u := range m.users {
-		b.WriteString(m.styles.textStyle.Render("  • "+u) + "\n")
+	// rows so far, counting any the sidebar's width wraps
+	m.usersTop = lipgloss.Height(m.styles.baseStyle.Width(w-2).Render(b.String())) - 1
+	for i, u := range m.users {
+		bullet := "  • "
+		if m.focus == focusUsers && i == m.userCursor {
+			bullet = m.styles.accentStyle.Render("  ▸ ")
+		}
+		line := bullet + m.userStyle(u.name).Render(u.name)
+		if u.display != "" {
+			line = bullet + m.userStyle(u.name).Render(u.display) + m.styles.dimStyle.Render(" ("+u.name+")")
+		}
+		if u.you {
+			line += m.styles.dimStyle.Render(" " + m.tr("side.self"))
+		}
+		// two rows per user, so a click's row says who it was on
+		b.WriteString(ellipsize(line, w-2) + "\n")
+		b.WriteString(m.renderUserDetail(u, w-2) + "\n")
+	}
+
+	// Knock requests (host only)
+	if m.isHost && m.currentRoom != nil {
+		if pending := m.currentRoom.PendingUsernames(); len(pending) > 0 {
+			b.WriteString("\n" + m.styles.dimStyle.Render(m.trn("side.knocking", len(pending))) + "\n")
+			for _, u := range pending {
+				b.WriteString(m.styles.accentStyle.Render("  ? ") + m.userStyle(u).Render(u) + "\n")
+			}
+			b.WriteString(m.styles.dimStyle.Render("  "+ellipsize(m.tr("side.knockKeys"), w-4)) + "\n")
+		}
+		if banned := m.currentRoom.BannedUsernames(); len(banned) > 0 {
+			b.WriteString("\n" + m.styles.dimStyle.Render(m.trn("side.banned", len(banned))) + "\n")
+			for _, u := range banned {
+				b.WriteString(m.styles.dimStyle.Render("  ✗ "+u) + "\n")
+			}
+		}
+	}
+
+	// Recent activity, including history replayed on join
+	if len(m.activity) > 0 {
+		b.WriteString("\n" + m.styles.dimStyle.Render(m.tr("side.recent")) + "\n")
+		for _, a := range m.activity {
+			b.WriteString(m.styles.dimStyle.Render("  "+ellipsize(a, w-4)) + "\n")
+		}
 	}
 
 	// Typing indicator
 	if m.typingUser != "" {
 		b.WriteString("\n")
-		typingText := fmt.Sprintf("✎ %s is typing...", m.typingUser)
-		b.WriteString(m.styles.accentStyle.Render(typingText) + "\n")
+		typingText := m.userStyle(m.typingUser).Render("✎ "+m.typingUser) + m.styles.dimStyle.Render(" "+m.tr("side.typing"))
+		b.WriteString(typingText + "\n")
 	}
 	b.WriteString(m.styles.dimStyle.Render(strings.Repeat("─", w-2)) + "\n\n")
 
+	// Shared environment; values are often secrets, so only the host sees them
+	if m.currentRoom != nil {
+		if env := m.currentRoom.EnvList(); len(env) > 0 {
+			b.WriteString(m.styles.dimStyle.Render(m.trn("side.env", len(env))) + "\n")
+			for _, kv := range env {
+				if !m.isHost {
+					kv, _, _ = strings.Cut(kv, "=")
+				}
+				b.WriteString(m.styles.textStyle.Render("  "+ellipsize(kv, w-6)) + "\n")
+			}
+			b.WriteString(m.styles.dimStyle.Render(strings.Repeat("─", w-2)) + "\n\n")
+		}
+	}
+
+	// Sandbox state, and commands still running so anyone can kill a runaway one
+	if m.currentRoom != nil && ai.HasSandbox(m.aiClient) {
+		b.WriteString(m.styles.dimStyle.Render(m.tr("side.sandbox")) + "\n")
+		b.WriteString(m.styles.textStyle.Render("  "+ellipsize(m.sandboxStatus(), w-6)) + "\n")
+		if jobs := m.currentRoom.SandboxJobs(); len(jobs) > 0 {
+			b.WriteString(m.styles.dimStyle.Render("  "+m.tr("side.running")) + "\n")
+			for _, j := range jobs {
+				line := fmt.Sprintf("  %s %s %s", j.ID, shortDuration(time.Since(j.Started)), j.Cmd)
+				b.WriteString(m.styles.textStyle.Render(ellipsize(line, w-4)) + "\n")
+			}
+		}
+		b.WriteString(m.styles.dimStyle.Render(strings.Repeat("─", w-2)) + "\n\n")
+	}
+
 	// Keybinds
-	keysLabel := m.styles.dimStyle.Render("keys:")
-	b.WriteString(keysLabel + "\n")
-	b.WriteString(m.styles.textStyle.Render("  ctrl+g  AI prompt") + "\n")
-	b.WriteString(m.styles.textStyle.Render("  ctrl+a  toggle AI") + "\n")
-	b.WriteString(m.styles.textStyle.Render("  ctrl+j/k scroll AI") + "\n")
-	b.WriteString(m.styles.textStyle.Render("  ctrl+r  run command") + "\n")
-	b.WriteString(m.styles.textStyle.Render("  ctrl+l  leave room") + "\n")
-
-	return m.styles.sidebarStyle.Width(w).Height(h).Render(b.String())
+	b.WriteString(m.styles.dimStyle.Render(m.tr("side.keys")) + "\n")
+	for _, k := range sidebarKeys {
+		if k[0] == "" {
+			b.WriteString(m.styles.dimStyle.Render(ellipsize(strings.Repeat(" ", 11)+m.tr(k[1]), w-2)) + "\n")
+			continue
+		}
+		b.WriteString(m.styles.textStyle.Render(ellipsize("  "+padRight(k[0], 8)+" "+m.tr(k[1]), w-2)) + "\n")
+	}
+
+	style := m.styles.sidebarStyle
+	if m.focus == focusUsers {
+		style = style.BorderForeground(m.styles.theme.Accent)
+	}
+	return style.Width(w).Height(h).Render(b.String())
+}
+
+// shellStatus says whether the tab we're looking at is busy, so partners
+// can tell a long build from a forgotten prompt.
+func (m *Model) shellStatus() string {
+	if m.terminal == nil {
+		return ""
+	}
+	if exited, code := m.terminal.Exited(); exited {
+		return fmt.Sprintf("exited (%d)", code)
+	}
+
+	a := m.terminal.Activity()
+	if a.Running {
+		if quiet := time.Since(a.LastOutput); !a.LastOutput.IsZero() && quiet >= 10*time.Second {
+			return "running… quiet " + shortDuration(quiet)
+		}
+		return "running…"
+	}
+	last := a.LastInput
+	if a.LastOutput.After(last) {
+		last = a.LastOutput
+	}
+	if last.IsZero() || time.Since(last) < time.Minute {
+		return "ready"
+	}
+	return "idle " + shortDuration(time.Since(last))
+}
+
+// shortDuration formats d as "45s", "12m" or "3h".
+func shortDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
 }
 
 func (m *Model) renderTerminal(w, h int) string {
-	header := m.styles.titleStyle.Render("shared terminal")
+	header := m.renderTabStrip(w)
 	content := m.termContent
 	if content == "" {
-		content = m.styles.dimStyle.Render("Starting terminal...")
+		content = m.styles.dimStyle.Render(m.tr("term.starting"))
+	}
+	note := m.renderSizeNote(w)
+	if pointed := m.pointNote(w); pointed != "" {
+		content = m.highlightPointed(content)
+		note = pointed
+	}
+	if m.inputMode == ModeScroll && m.terminal != nil {
+		title, history := m.renderScrollback(w)
+		header = m.styles.titleStyle.Render(title)
+		content = m.styles.textStyle.Render(history)
+		note = ""
 	}
 
 	return m.styles.terminalStyle.Width(w).Height(h).Render(
-		lipgloss.JoinVertical(lipgloss.Left, header, "", content),
+		lipgloss.JoinVertical(lipgloss.Left, header, note, content),
 	)
 }
 
+// renderSizeNote says whose window the shared terminal is sized to when
+// it's smaller than our pane, or that the shell has exited.
+func (m *Model) renderSizeNote(w int) string {
+	if m.terminal == nil {
+		return ""
+	}
+	if exited, code := m.terminal.Exited(); exited {
+		note := fmt.Sprintf("shell exited with code %d", code)
+		if m.isHost {
+			note += " • f9 restart"
+		}
+		return m.styles.accentStyle.Render(truncate(note, w-2))
+	}
+	tw, th, limitedBy := m.terminal.SharedSize()
+	_, _, paneW, paneH := m.termPane()
+	if tw >= paneW && th >= paneH {
+		return ""
+	}
+	note := fmt.Sprintf("sized to %s (%dx%d)", strings.Join(limitedBy, ", "), tw, th)
+	return m.styles.dimStyle.Render(truncate(note, w-2))
+}
+
 func (m *Model) renderBottomBar() string {
 	// Right side: Mode status (always visible) similar to vim mode indicator
 	modeText := m.getModeStatus()
 	right := m.styles.accentStyle.Bold(true).Render(modeText)
+	if timer := m.timerStatus(); timer != "" {
+		right = m.styles.textStyle.Render(timer) + "  " + right
+	}
 	rightWidth := lipgloss.Width(right)
 
-	//  Priority: Toasts > Input > Help
+	//  Priority: Reconnecting > Toasts > Input > Help
 	var left string
-	if len(m.toasts) > 0 {
+	if m.lost != nil {
+		left = m.styles.accentStyle.Reverse(true).Render(ellipsize(m.lostBanner(), m.width-rightWidth-2))
+	} else if len(m.toasts) > 0 {
 		var parts []string
 		for _, t := range m.toasts {
 			parts = append(parts, t.text)
 		}
 		toastText := "▸ " + strings.Join(parts, " • ")
-		left = m.styles.accentStyle.Bold(true).Render(truncate(toastText, m.width-rightWidth-2))
+		left = m.styles.accentStyle.Bold(true).Render(ellipsize(toastText, m.width-rightWidth-2))
+	} else if m.inputMode == ModeConfirmCode {
+		prompt := m.tr("bar.send")
+		left = m.styles.accentStyle.Render(prompt) + m.styles.textStyle.Render(ellipsize(m.codePreview(), m.width-rightWidth-textWidth(prompt)-2))
+	} else if m.inputMode == ModeScroll {
+		helpText := m.tr("bar.scroll")
+		left = m.styles.dimStyle.Render(truncate(helpText, m.width-rightWidth-2))
+	} else if m.inputMode == ModePoint {
+		helpText := m.tr("bar.point")
+		left = m.styles.dimStyle.Render(truncate(helpText, m.width-rightWidth-2))
 	} else if m.inputMode != ModeNormal {
-		left = m.cmdInput.View()
+		// a long prompt (history search) mustn't push the mode off
+		left = truncate(m.cmdInput.View(), m.width-rightWidth-1)
 	} else {
-		helpText := "ctrl+g AI • ctrl+a toggle AI • ctrl+r sandbox"
+		helpText := m.tr("bar.help")
+		if m.profile.Keymap == keymapReadline {
+			helpText = m.tr("bar.helpReadline")
+		}
 		left = m.styles.dimStyle.Render(truncate(helpText, m.width-rightWidth-2))
 	}
 
-	leftWidth := lipgloss.Width(left)
-	padding := max(0, m.width-leftWidth-rightWidth)
+	padding := max(0, m.width-textWidth(left)-rightWidth)
 
+	if time.Now().Before(m.flashUntil) {
+		// someone @-mentioned us
+		return m.styles.accentStyle.Reverse(true).Render(ansi.Strip(left + strings.Repeat(" ", padding) + right))
+	}
 	return left + strings.Repeat(" ", padding) + right
 }
 
 func (m *Model) getModeStatus() string {
+	key := "mode.normal"
 	switch m.inputMode {
 	case ModeAI:
-		return "-- AI --"
+		key = "mode.ai"
 	case ModeSandbox:
-		return "-- RUN --"
+		key = "mode.run"
+	case ModeEnv:
+		key = "mode.env"
+	case ModeCommand:
+		key = "mode.cmd"
+	case ModeScroll:
+		key = "mode.scroll"
+	case ModePoint:
+		key = "mode.point"
+	case ModeCodeBlock, ModeConfirmCode:
+		key = "mode.send"
 	default:
-		return "-- NORMAL --"
+		if m.macroRecording {
+			key = "mode.recording"
+		}
 	}
-}
-
-func (m *Model) viewResizePrompt() string {
-	title := m.styles.titleStyle.Render("Terminal Too Small")
-	msg := m.styles.textStyle.Render(fmt.Sprintf(
-		"Please resize your terminal to at least %dx%d",
-		MinWidthForSidebar, MinHeightForSidebar,
-	))
-	current := m.styles.dimStyle.Render(fmt.Sprintf("Current: %dx%d", m.width, m.height))
-	hint := m.styles.dimStyle.Render("(or press ctrl+a to hide AI sidebar)")
-
-	content := lipgloss.JoinVertical(lipgloss.Center,
-		title, "", msg, current, "", hint,
-	)
-
-	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, content)
+	return "-- " + m.tr(key) + " --"
 }
 
 func (m *Model) renderAISidebar(w, h int) string {
 	var b strings.Builder
 
-	header := m.styles.titleStyle.Render("AI Assistant")
+	header := m.styles.titleStyle.Render(ellipsize(m.tr("ai.title"), w-4))
+	if status := m.aiHealthStatus(); status != "" {
+		header += " " + status
+	}
 	b.WriteString(header + "\n")
 	b.WriteString(m.styles.dimStyle.Render(strings.Repeat("─", w-4)) + "\n\n")
 
+	if m.aiClient == nil {
+		b.WriteString(m.styles.dimStyle.Render(m.tr("ai.disabled")))
+		return m.styles.aiSidebarStyle.Width(w).Height(h).Render(b.String())
+	}
+
+	// pins stay put above the scrolling conversation; see fitAIViewport
+	if m.pinnedView != "" {
+		b.WriteString(m.pinnedView + "\n\n")
+	}
+
 	if m.aiLoading {
-		loadingText := fmt.Sprintf("%s Thinking...", m.aiSpinner.View())
+		loadingText := m.aiSpinner.View() + " " + m.tr("ai.thinking")
 		b.WriteString(m.styles.accentStyle.Render(loadingText) + "\n\n")
 		b.WriteString(m.aiViewport.View())
 	} else if len(m.getAIMessages()) == 0 {
-		emptyMsg := m.styles.dimStyle.Render("No messages yet.\nPress ctrl+g to ask AI.")
+		emptyMsg := m.styles.dimStyle.Render(m.tr("ai.empty"))
 		b.WriteString(emptyMsg)
 	} else {
 		b.WriteString(m.aiViewport.View())
@@ -250,7 +580,31 @@ func (m *Model) renderAISidebar(w, h int) string {
 		b.WriteString("\n" + m.styles.dimStyle.Render(scrollInfo))
 	}
 
-	return m.styles.aiSidebarStyle.Width(w).Height(h).Render(b.String())
+	style := m.styles.aiSidebarStyle
+	if m.focus == focusAI {
+		style = style.BorderForeground(m.styles.theme.Accent)
+	}
+	return style.Width(w).Height(h).Render(b.String())
+}
+
+// aiHealthStatus describes the AI provider's circuit breaker for the
+// sidebar header, or is empty while all is well.
+func (m *Model) aiHealthStatus() string {
+	health, ok := ai.HealthOf(m.aiClient)
+	if !ok {
+		return ""
+	}
+	switch health.State {
+	case ai.BreakerOpen:
+		wait := max(time.Until(health.RetryAt).Round(time.Second), time.Second)
+		return m.styles.errorStyle.Render(fmt.Sprintf("paused (%s)", wait))
+	case ai.BreakerHalfOpen:
+		return m.styles.accentStyle.Render("reconnecting")
+	}
+	if health.Failures > 0 {
+		return m.styles.dimStyle.Render(fmt.Sprintf("%d failed", health.Failures))
+	}
+	return ""
 }
 
 // formatting content for viewport with proper line tracking
@@ -270,12 +624,27 @@ func (m *Model) buildAIContent(maxWidth int) (string, int) {
 		var prefix string
 		var isUser bool
 
+		if msg.Role == ai.RoleSummary {
+			// older exchanges the AI folded away to save context
+			b.WriteString(m.styles.dimStyle.Render("earlier, summarized:") + "\n")
+			currentLine++
+			for _, line := range wrapLines(msg.Text, wrapWidth) {
+				b.WriteString("    " + m.styles.dimStyle.Render(line) + "\n")
+				currentLine++
+			}
+			if i < len(msgs)-1 {
+				b.WriteString("\n")
+				currentLine++
+			}
+			continue
+		}
+
 		if msg.Role == "user" {
 			username := msg.UserID
 			if username == "" {
 				username = "you"
 			}
-			prefix = m.styles.accentStyle.Render(username + ": ")
+			prefix = m.userStyle(username).Render(username + ": ")
 			isUser = true
 			// Track the line offset where this user prompt starts
 			lastPromptOffset = currentLine
@@ -284,9 +653,26 @@ func (m *Model) buildAIContent(maxWidth int) (string, int) {
 			isUser = false
 		}
 
-		// Word wrap the message text using reflow
-		wrapped := wordwrap.String(msg.Text, wrapWidth)
-		lines := strings.Split(wrapped, "\n")
+		if !isUser {
+			if rendered, ok := m.renderMarkdown(msg.Text, wrapWidth); ok {
+				// replies start below their label so code blocks line up
+				b.WriteString(prefix + "\n")
+				currentLine++
+				for _, line := range strings.Split(rendered, "\n") {
+					b.WriteString("    " + line + "\n")
+					currentLine++
+				}
+				if i < len(msgs)-1 {
+					b.WriteString("\n")
+					currentLine++
+				}
+				continue
+			}
+		}
+
+		// Word wrap by display width, breaking words (e.g. unspaced CJK)
+		// that don't fit on a line of their own
+		lines := wrapLines(msg.Text, wrapWidth)
 
 		for j, line := range lines {
 			if j == 0 {
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/jaypopat/duet/internal/audit"
+)
+
+// webCommand is "web [control|revoke]": a link for watching the room in a
+// browser, or (host only) one that can type into the shell too, or
+// revoking every link handed out so far.
+func (m *Model) webCommand(args []string) {
+	if m.currentRoom == nil {
+		return
+	}
+	base := m.roomManager.WebURL()
+	if base == "" {
+		m.addToast("The web view isn't enabled on this server (-web-addr)")
+		return
+	}
+	link := base + "/watch/" + m.currentRoom.ID
+
+	if len(args) == 0 {
+		if m.currentRoom.NeedsApproval() && !m.isHost {
+			m.addToast("In rooms that need approval only the host hands out watch links")
+			return
+		}
+		m.addToastFor("watch in a browser (web revoke stops it): "+link+"?watch="+m.currentRoom.WatchToken(), 15*time.Second)
+		return
+	}
+	if len(args) != 1 || (args[0] != "control" && args[0] != "revoke") {
+		m.addToast("Usage: web [control|revoke]")
+		return
+	}
+	if !m.isHost {
+		m.addToast("Only the host can hand out or revoke control links")
+		return
+	}
+	if args[0] == "revoke" {
+		m.currentRoom.RevokeWebToken()
+		m.audit(audit.Event{Type: "web_control_revoke"})
+		m.addToast("Browser links revoked; run web again for new ones")
+		return
+	}
+	token := m.currentRoom.NewWebToken()
+	m.audit(audit.Event{Type: "web_control_link"})
+	m.addToastFor("type from a browser (replaces older links): "+link+"?token="+token, 30*time.Second)
+}
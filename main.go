@@ -1,25 +1,316 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/jaypopat/duet/internal/ai"
+	"github.com/jaypopat/duet/internal/audit"
+	"github.com/jaypopat/duet/internal/container"
+	"github.com/jaypopat/duet/internal/room"
 	"github.com/jaypopat/duet/internal/server"
+	"github.com/jaypopat/duet/internal/terminal"
+	"github.com/jaypopat/duet/internal/tracing"
+	"github.com/jaypopat/duet/internal/ui"
 )
 
 func main() {
-	addr := flag.String("addr", ":2222", "SSH server address")
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and returns once it shuts down. Errors come back
+// here rather than exiting on the spot so deferred cleanup, like closing
+// the database, still runs.
+func run() error {
+	configPath := flag.String("config", os.Getenv("DUET_CONFIG"), "YAML file of settings keyed by flag name, e.g. \"addr: :2222\"; flags on the command line override it (defaults to $DUET_CONFIG)")
+	addr := flag.String("addr", ":2222", "SSH server address: host:port, or unix:///path/to/duet.sock for a Unix socket")
+	publicAddr := flag.String("public-addr", "", "Host (and :port unless 22) people ssh to, used in the invite commands rooms show, e.g. duet.example.com (defaults to localhost on -addr's port; required with a Unix socket)")
+	logFormat := flag.String("log-format", "text", "Log output format: text or json (one object per line, for Loki/ELK)")
+	logLevel := flag.String("log-level", "info", "Lowest log level written: debug, info, warn or error")
+	otlpEndpoint := flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "Send OpenTelemetry traces over OTLP/HTTP to this collector, e.g. http://localhost:4318 for Jaeger or Tempo (off when empty; defaults to $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	traceSample := flag.Float64("trace-sample", 1, "Fraction of SSH sessions to trace with -otlp-endpoint, from 0 to 1")
+	auditPath := flag.String("audit-log", "", "Append security-relevant events (connections, auth, joins, kicks, sandbox commands, AI prompts) to this file as JSON lines (off when empty)")
+	auditRedact := flag.String("audit-redact", "", "Hash these fields in the audit log instead of writing them: prompts, commands, remotes or all, comma separated (keyed with $DUET_AUDIT_KEY, random per run when unset)")
+	authLogPath := flag.String("auth-log", "", "Append a line per refused connection or failed auth (IP, key fingerprint, reason) to this file for fail2ban or CrowdSec (off when empty)")
 	hostKeyPath := flag.String("hostkey", ".ssh/id_ed25519", "Path to SSH host key")
+	var extraHostKeys []string
+	flag.Func("extra-hostkey", "Another SSH host key to offer, e.g. an RSA or ECDSA key for clients without ed25519 (repeatable; skipped if the file is missing)", func(s string) error {
+		extraHostKeys = append(extraHostKeys, s)
+		return nil
+	})
 	workerURL := flag.String("worker", "", "Duet CF Worker base URL (e.g. https://duet-cf-worker.<subdomain>.workers.dev)")
+	workerToken := flag.String("worker-token", "", "Bearer token for -worker, matching the worker's DUET_WORKER_TOKEN secret (defaults to $DUET_WORKER_TOKEN)")
+	ollamaModel := flag.String("ollama-model", "", "Answer the AI sidebar with this local Ollama model, e.g. llama3.2, instead of the worker")
+	ollamaHost := flag.String("ollama-host", ai.DefaultOllamaHost, "Ollama server address for -ollama-model")
+	openAIModel := flag.String("openai-model", "", "Answer the AI sidebar with this model over an OpenAI-compatible API, e.g. gpt-4o-mini, instead of the worker")
+	openAIURL := flag.String("openai-url", ai.DefaultOpenAIURL, "Base URL of the OpenAI-compatible API for -openai-model")
+	openAIKey := flag.String("openai-key", "", "API key for -openai-url (defaults to $OPENAI_API_KEY)")
+	aiRetry := ai.DefaultRetryConfig
+	flag.IntVar(&aiRetry.Retries, "ai-retries", aiRetry.Retries, "Retries for AI requests that fail with a network or server error")
+	flag.DurationVar(&aiRetry.BaseDelay, "ai-retry-delay", aiRetry.BaseDelay, "Wait before the first AI retry, doubling (with jitter) after that")
+	flag.IntVar(&aiRetry.BreakAfter, "ai-break-after", aiRetry.BreakAfter, "Pause AI features after this many failed requests in a row (0 never pauses)")
+	flag.DurationVar(&aiRetry.Cooldown, "ai-break-cooldown", aiRetry.Cooldown, "How long AI features stay paused before trying again")
+	password := flag.String("password", "", "Shared password people without a trusted key must give to connect, e.g. for interviewees without SSH keys; only admin keys skip it (defaults to $DUET_PASSWORD)")
+	passwordHash := flag.String("password-hash", "", "bcrypt hash of -password, e.g. the output of htpasswd -nbB (a leading \"user:\" is ignored), to keep the password itself out of config (defaults to $DUET_PASSWORD_HASH)")
+	pinAuth := flag.Bool("pin-auth", false, "Also accept one-time PINs issued with POST /api/admin/pins, each good for one login")
+	githubKeys := flag.Bool("github-keys", false, "Only admit users whose SSH key is published at github.com/<username>.keys, making room names verified GitHub handles")
+	githubKeysTTL := flag.Duration("github-keys-ttl", 10*time.Minute, "How long to cache a user's GitHub keys for -github-keys")
+	rateLimit := server.DefaultRateLimit
+	connsPerMinute := flag.Float64("rate-limit", rateLimit.Rate*60, "Connections per minute allowed from one IP once its burst is used up (0 disables rate limiting)")
+	flag.IntVar(&rateLimit.Burst, "rate-burst", rateLimit.Burst, "Connections one IP may make back to back")
+	flag.IntVar(&rateLimit.BanAfter, "ban-after", rateLimit.BanAfter, "Ban an IP after this many failed auth attempts in a row, e.g. wrong passwords (0, the default, never bans; leave it off behind a load balancer without -proxy-protocol)")
+	flag.DurationVar(&rateLimit.BanFor, "ban-for", rateLimit.BanFor, "How long an IP stays banned")
+	proxyProtocol := flag.Bool("proxy-protocol", false, "Read a PROXY protocol v1/v2 header (HAProxy, AWS NLB) off each SSH connection and use the client address in it for logs, bans and rate limits")
+	var proxyFrom []string
+	flag.Func("proxy-from", "Expect PROXY headers from this load balancer address or CIDR, e.g. 10.0.0.0/8, and take other connections as they come (repeatable; required with -proxy-protocol except on a Unix socket)", func(s string) error {
+		proxyFrom = append(proxyFrom, s)
+		return nil
+	})
+	maxLifetime := flag.Duration("room-max-lifetime", 0, "Evict rooms older than this (0 disables)")
+	idleTimeout := flag.Duration("room-idle-timeout", 0, "Evict rooms idle for this long (0 disables)")
+	expiryWarning := flag.Duration("room-expiry-warning", time.Minute, "Warn room members this long before eviction")
+	rejoinGrace := flag.Duration("rejoin-grace", 5*time.Minute, "How long a disconnected user can reclaim their identity with a rejoin token")
+	detachGrace := flag.Duration("detach-grace", 0, "Keep a room's shells running this long after everyone disconnects so the host can reattach with the room code (0 closes the room straight away)")
+	nodeID := flag.String("node-id", "", "Name of this server among several sharing -db; joining a room another node hosts tells the client where to connect (needs -node-addr)")
+	nodeAddr := flag.String("node-addr", "", "Host (and :port unless 22) that reaches this particular server, as opposed to -public-addr's load balancer, for -node-id")
+	dbPath := flag.String("db", "", "SQLite database for persisting rooms across restarts (empty keeps rooms in memory)")
+	maxParticipants := flag.Int("max-participants", 0, "Default participant limit per room (0 is unlimited)")
+	apiAddr := flag.String("api-addr", "", "Room management HTTP API address, e.g. :8080 (disabled when empty)")
+	apiToken := flag.String("api-token", "", "Bearer token for the HTTP API (defaults to $DUET_API_TOKEN)")
+	adminToken := flag.String("admin-token", "", "Bearer token for the admin endpoints of the HTTP API, which are off without one (defaults to $DUET_ADMIN_TOKEN)")
+	debugAddr := flag.String("debug-addr", "", "Serve pprof profiles and expvar counters on this address, e.g. :6060 (localhost only unless a host is given; disabled when empty)")
+	webAddr := flag.String("web-addr", "", "Address for the browser spectator view of rooms, e.g. :8081 (disabled when empty)")
+	webURL := flag.String("web-url", "", "Public base URL of -web-addr used in links, e.g. https://duet.example.com (defaults to http://localhost<web-addr>)")
+	theme := flag.String("theme", ui.DefaultTheme, "Default colours for sessions: "+strings.Join(ui.ThemeNames(), ", ")+"; people can pick their own with --theme in the ssh command or $DUET_THEME")
+	lang := flag.String("lang", ui.DefaultLanguage, "Default language of the UI's labels: "+strings.Join(ui.Languages(), ", ")+"; people can pick their own with --lang in the ssh command or $DUET_LANG")
+	linear := flag.Bool("linear", false, "Give every session the linear view for screen readers instead of panels; people can ask for it themselves with --linear in the ssh command or $DUET_LINEAR=1")
+	shell := flag.String("shell", "", "Default shell command for room terminals (defaults to $SHELL)")
+	startDir := flag.String("start-dir", "", "Default starting directory, relative to each room's workspace unless absolute")
+	flag.StringVar(startDir, "workdir", "", "Alias for -start-dir, e.g. -workdir /srv/project to open every room in that repo")
+	allowTmux := flag.Bool("allow-tmux", false, "Let room hosts attach to existing tmux sessions owned by the server user")
+	scrollback := flag.Int("scrollback", 10000, "Lines of terminal history kept per room for scroll mode (negative disables)")
+	maxFPS := flag.Int("max-fps", 30, "Most terminal updates per second sent to each client (negative is uncapped)")
+	var allowedShells []string
+	flag.Func("allow-shell", "Shell command hosts may choose per room, e.g. \"docker compose exec app bash\" (repeatable; defaults to the login shells in /etc/shells)", func(s string) error {
+		allowedShells = append(allowedShells, s)
+		return nil
+	})
+	var adminKeys []string
+	flag.Func("admin-key", "SSH public key (authorized_keys line) or file of them whose holders get the admin dashboard instead of the launch screen (repeatable)", func(s string) error {
+		adminKeys = append(adminKeys, s)
+		return nil
+	})
+	var denyInput, guestAllow []string
+	flag.Func("deny-input", "Regexp for command lines room shells refuse, e.g. \"rm -rf /\" (repeatable)", func(s string) error {
+		denyInput = append(denyInput, s)
+		return nil
+	})
+	flag.Func("guest-allow", "Command guests may run, e.g. ls (repeatable; when set, guests can run nothing else)", func(s string) error {
+		guestAllow = append(guestAllow, s)
+		return nil
+	})
+	var containers container.Config
+	flag.StringVar(&containers.Image, "container-image", "", "Run each room's shells in a container from this image (empty runs them on the host)")
+	flag.StringVar(&containers.Runtime, "container-runtime", "docker", "Container runtime: docker or podman")
+	flag.StringVar(&containers.Memory, "container-memory", "", "Memory limit per room container, e.g. 512m")
+	flag.StringVar(&containers.CPUs, "container-cpus", "", "CPU limit per room container, e.g. 1.5")
+	flag.StringVar(&containers.Network, "container-network", "", "Network for room containers, e.g. none (empty uses the runtime default)")
+	flag.Func("container-mount", "Extra volume for room containers, e.g. /srv/cache:/cache:ro (repeatable)", func(s string) error {
+		containers.Mounts = append(containers.Mounts, s)
+		return nil
+	})
+	defaultEnv := make(map[string]string)
+	flag.Func("env", "Default KEY=VALUE exported into every room (repeatable)", func(s string) error {
+		env, err := room.ParseEnvAssignments(s)
+		if err != nil {
+			return err
+		}
+		for k, v := range env {
+			defaultEnv[k] = v
+		}
+		return nil
+	})
+	templates := make(map[string]string)
+	flag.Func("template", "Default AI prompt template as name=text, e.g. \"review=Review this for concurrency bugs: {selection}\" (repeatable)", func(s string) error {
+		name, text, err := room.ParseTemplate(s)
+		if err != nil {
+			return err
+		}
+		templates[name] = text
+		return nil
+	})
 	flag.Parse()
+	if *configPath != "" {
+		if err := applyConfigFile(flag.CommandLine, *configPath); err != nil {
+			return fmt.Errorf("config error: %w", err)
+		}
+	}
+	// secrets come from the environment only now, rather than as flag
+	// defaults, so -help and usage errors never print them
+	for _, secret := range []struct {
+		value *string
+		env   string
+	}{
+		{workerToken, "DUET_WORKER_TOKEN"},
+		{openAIKey, "OPENAI_API_KEY"},
+		{password, "DUET_PASSWORD"},
+		{passwordHash, "DUET_PASSWORD_HASH"},
+		{apiToken, "DUET_API_TOKEN"},
+		{adminToken, "DUET_ADMIN_TOKEN"},
+	} {
+		if *secret.value == "" {
+			*secret.value = os.Getenv(secret.env)
+		}
+	}
 
 	fmt.Println("Duet - SSH Pair Programming")
 	fmt.Printf("Starting server on %s\n", *addr)
 
-	srv := server.New(*addr, *hostKeyPath, *workerURL)
+	var store room.Store
+	if *dbPath != "" {
+		sqlStore, err := room.OpenSQLiteStore(*dbPath)
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		defer sqlStore.Close()
+		store = sqlStore
+	}
+
+	srv := server.New(*addr, *hostKeyPath, *workerURL, *workerToken, room.Limits{
+		MaxLifetime: *maxLifetime,
+		IdleTimeout: *idleTimeout,
+		WarnBefore:  *expiryWarning,
+
+		MaxParticipants: *maxParticipants,
+		RejoinGrace:     *rejoinGrace,
+		DetachGrace:     *detachGrace,
+		AllowTmux:       *allowTmux,
+		AllowedShells:   allowedShells,
+	}, store)
+	if err := srv.SetLogFormat(*logFormat, *logLevel); err != nil {
+		return fmt.Errorf("log error: %w", err)
+	}
+	if err := srv.SetTheme(*theme); err != nil {
+		return fmt.Errorf("theme error: %w", err)
+	}
+	if err := srv.SetLanguage(*lang); err != nil {
+		return fmt.Errorf("language error: %w", err)
+	}
+	srv.SetLinear(*linear)
+	if *publicAddr != "" {
+		srv.SetPublicAddress(*publicAddr)
+	}
+	if *nodeID != "" {
+		if *dbPath == "" || *nodeAddr == "" {
+			return errors.New("-node-id needs -db (shared with the other nodes) and -node-addr")
+		}
+		srv.JoinCluster(*nodeID, *nodeAddr)
+	}
+	for _, path := range extraHostKeys {
+		srv.AddHostKey(path)
+	}
+	if *otlpEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), *otlpEndpoint, *traceSample)
+		if err != nil {
+			return fmt.Errorf("tracing error: %w", err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			shutdown(ctx)
+		}()
+	}
+	if *auditPath != "" {
+		redact, err := audit.ParseRedact(*auditRedact)
+		if err != nil {
+			return fmt.Errorf("audit error: %w", err)
+		}
+		auditLog, err := audit.Open(*auditPath, redact, []byte(os.Getenv("DUET_AUDIT_KEY")))
+		if err != nil {
+			return fmt.Errorf("audit error: %w", err)
+		}
+		defer auditLog.Close()
+		srv.SetAuditLog(auditLog)
+	}
+	if *authLogPath != "" {
+		f, err := os.OpenFile(*authLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+		if err != nil {
+			return fmt.Errorf("auth log error: %w", err)
+		}
+		defer f.Close()
+		srv.SetAuthLog(f)
+	}
+	if err := containers.Validate(); err != nil {
+		return fmt.Errorf("container error: %w", err)
+	}
+	srv.SetContainerConfig(containers)
+	switch {
+	case *ollamaModel != "" && *openAIModel != "":
+		return errors.New("pick one of -ollama-model and -openai-model")
+	case *ollamaModel != "":
+		srv.UseOllama(*ollamaHost, *ollamaModel)
+	case *openAIModel != "":
+		srv.UseOpenAI(*openAIURL, *openAIKey, *openAIModel)
+	}
+	srv.SetAIRetry(aiRetry)
+	policy, err := terminal.ParseInputPolicy(denyInput, guestAllow)
+	if err != nil {
+		return fmt.Errorf("input policy error: %w", err)
+	}
+	srv.SetInputPolicy(policy)
+	for _, k := range adminKeys {
+		if err := srv.AddAdminKey(k); err != nil {
+			return fmt.Errorf("admin key error: %w", err)
+		}
+	}
+	rateLimit.Rate = *connsPerMinute / 60
+	srv.SetRateLimit(rateLimit)
+	if *proxyProtocol {
+		if err := srv.EnableProxyProtocol(proxyFrom); err != nil {
+			return fmt.Errorf("proxy protocol error: %w", err)
+		}
+	}
+	if *githubKeys {
+		srv.RequireGitHubKeys(*githubKeysTTL)
+	}
+	switch {
+	case *githubKeys && (*password != "" || *passwordHash != "" || *pinAuth):
+		return errors.New("-github-keys can't be combined with -password, -password-hash or -pin-auth")
+	case *password != "" && *passwordHash != "":
+		return errors.New("pick one of -password and -password-hash")
+	case *password != "":
+		if err := srv.SetPassword(*password); err != nil {
+			return fmt.Errorf("password error: %w", err)
+		}
+	case *passwordHash != "":
+		if err := srv.SetPasswordHash(*passwordHash); err != nil {
+			return fmt.Errorf("password error: %w", err)
+		}
+	}
+	if *pinAuth {
+		srv.EnablePINs()
+	}
+	srv.SetRoomDefaults(room.RoomSettings{Shell: *shell, Dir: *startDir, Env: defaultEnv, Templates: templates, Scrollback: *scrollback, FrameRate: *maxFPS})
+	if *debugAddr != "" {
+		srv.EnableDebug(*debugAddr)
+	}
+	if *webAddr != "" {
+		srv.EnableWeb(*webAddr, *webURL)
+	}
+	if *apiAddr != "" {
+		srv.EnableAPI(*apiAddr, *apiToken)
+		srv.EnableAdmin(*adminToken)
+	}
 	if err := srv.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("server error: %w", err)
 	}
+	return nil
 }